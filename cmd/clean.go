@@ -0,0 +1,195 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/projectcontour/integration-tester/pkg/driver"
+	"github.com/projectcontour/integration-tester/pkg/filter"
+	"github.com/projectcontour/integration-tester/pkg/must"
+	"github.com/projectcontour/integration-tester/pkg/utils"
+	"github.com/projectcontour/integration-tester/pkg/version"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// NewCleanCommand returns a command to delete objects that were
+// left behind by test runs.
+func NewCleanCommand() *cobra.Command {
+	clean := &cobra.Command{
+		Use:   "clean [--run-id ID | --older-than DURATION | --all]",
+		Short: "Delete Kubernetes objects left behind by tests",
+		Long: fmt.Sprintf(
+			`Delete Kubernetes objects left behind by tests
+
+The clean command deletes Kubernetes API objects that are labeled as
+managed by integration-tester with the %s%s%s label. Objects
+can be left behind by test runs that were preserved with the
+'--preserve' flag, or that crashed before they could clean up.
+
+Exactly one of the following flags selects the objects to delete:
+
+  --run-id       Delete the objects of the test run with the given
+                 run ID, including its iterations and retries.
+  --older-than   Delete the objects that were created longer ago than
+                 the given duration, e.g. "24h".
+  --all          Delete all the managed objects.
+
+Objects are deleted with the same propagation policy that test runs
+use. The '--dry-run' flag lists the selected objects without deleting
+them.
+`,
+			"`", filter.LabelManagedBy, "`"),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cleanCmd(cmd)
+		},
+	}
+
+	clean.Flags().String("run-id", "", "Delete the objects of the given test run ID")
+	clean.Flags().Duration("older-than", 0, "Delete the objects older than the given duration")
+	clean.Flags().Bool("all", false, "Delete all the objects managed by integration-tester")
+	clean.Flags().Bool("dry-run", false, "List the objects to delete without deleting them")
+
+	return CommandWithDefaults(clean)
+}
+
+// cleanSelector selects which managed objects to delete.
+type cleanSelector struct {
+	runID     string
+	olderThan time.Duration
+	all       bool
+}
+
+// Validate checks that exactly one selection was given.
+func (s cleanSelector) Validate() error {
+	n := 0
+
+	if s.runID != "" {
+		n++
+	}
+
+	if s.olderThan > 0 {
+		n++
+	}
+
+	if s.all {
+		n++
+	}
+
+	switch n {
+	case 0:
+		return ExitErrorf(EX_USAGE, "one of --run-id, --older-than or --all is required")
+	case 1:
+		return nil
+	default:
+		return ExitErrorf(EX_USAGE, "only one of --run-id, --older-than or --all may be given")
+	}
+}
+
+// Match returns whether the object with the given run ID and
+// creation time is selected.
+func (s cleanSelector) Match(runID string, created time.Time, now time.Time) bool {
+	switch {
+	case s.all:
+		return true
+	case s.runID != "":
+		return matchRunID(runID, s.runID)
+	case s.olderThan > 0:
+		return now.Sub(created) > s.olderThan
+	default:
+		return false
+	}
+}
+
+func cleanCmd(cmd *cobra.Command) error {
+	sel := cleanSelector{
+		runID:     must.String(cmd.Flags().GetString("run-id")),
+		olderThan: must.Duration(cmd.Flags().GetDuration("older-than")),
+		all:       must.Bool(cmd.Flags().GetBool("all")),
+	}
+
+	if err := sel.Validate(); err != nil {
+		return err
+	}
+
+	kube, err := driver.NewKubeClient()
+	if err != nil {
+		return fmt.Errorf("failed to initialize Kubernetes context: %s", err)
+	}
+
+	results, err := kube.SelectObjectsByLabel(filter.LabelManagedBy, version.Progname)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	var targets []*unstructured.Unstructured
+	for _, r := range results {
+		if sel.Match(must.String(kube.RunIDFor(r)), r.GetCreationTimestamp().Time, now) {
+			targets = append(targets, r)
+		}
+	}
+
+	dryRun := must.Bool(cmd.Flags().GetBool("dry-run"))
+	objects := driver.NewObjectDriver(kube)
+	defer objects.Done()
+
+	var errs []error
+
+	for _, u := range targets {
+		gk := u.GetObjectKind().GroupVersionKind().GroupKind()
+		name := fmt.Sprintf("%s/%s", strings.ToLower(gk.String()), u.GetName())
+		if ns := u.GetNamespace(); ns != "" {
+			name = ns + "/" + name
+		}
+
+		if dryRun {
+			fmt.Printf("would delete %s\n", name)
+			continue
+		}
+
+		result, err := objects.Delete(u)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete %s: %w", name, err))
+			continue
+		}
+
+		if result.Error != nil {
+			switch result.Error.Reason {
+			case metav1.StatusReasonNotFound, metav1.StatusReasonGone:
+				// Already deleted, possibly by a cascade.
+				continue
+			default:
+				errs = append(errs, fmt.Errorf("failed to delete %s: %s", name, result.Error.Message))
+				continue
+			}
+		}
+
+		fmt.Printf("deleted %s\n", name)
+	}
+
+	if len(errs) > 0 {
+		return ExitError{Code: EX_FAIL, Err: utils.ChainErrors(errs...)}
+	}
+
+	return nil
+}
@@ -0,0 +1,42 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCleanSelectorValidate(t *testing.T) {
+	assert.Error(t, cleanSelector{}.Validate())
+	assert.Error(t, cleanSelector{runID: "github-123", all: true}.Validate())
+	assert.NoError(t, cleanSelector{runID: "github-123"}.Validate())
+	assert.NoError(t, cleanSelector{olderThan: time.Hour}.Validate())
+	assert.NoError(t, cleanSelector{all: true}.Validate())
+}
+
+func TestCleanSelectorMatch(t *testing.T) {
+	now := time.Now()
+
+	assert.True(t, cleanSelector{all: true}.Match("", now, now))
+
+	assert.True(t, cleanSelector{runID: "github-123"}.Match("github-123-2", now, now))
+	assert.False(t, cleanSelector{runID: "github-123"}.Match("github-1234", now, now))
+
+	assert.True(t, cleanSelector{olderThan: time.Hour}.Match("", now.Add(-2*time.Hour), now))
+	assert.False(t, cleanSelector{olderThan: time.Hour}.Match("", now.Add(-time.Minute), now))
+}
@@ -0,0 +1,160 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/projectcontour/integration-tester/pkg/driver"
+	"github.com/projectcontour/integration-tester/pkg/must"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NewCompletionCommand returns a command that generates shell
+// completion scripts.
+func NewCompletionCommand() *cobra.Command {
+	completion := &cobra.Command{
+		Use:   "completion [bash|zsh|fish]",
+		Short: "Generate shell completion scripts",
+		Long: `Generate shell completion scripts
+
+The completion command writes a completion script for the given shell
+to standard output. Besides subcommands and flags, the scripts
+complete the '--watch' flag with the names of the resources that the
+current Kubernetes cluster serves, the '--fixtures' flag with YAML
+files and the '--policies' flag with Rego files.
+
+To load completions in the current bash shell:
+
+    $ source <(integration-tester completion bash)
+
+To load completions for every zsh session, write the script to a
+directory in your $fpath:
+
+    $ integration-tester completion zsh > "${fpath[1]}/_integration-tester"
+
+To load completions for every fish session:
+
+    $ integration-tester completion fish > ~/.config/fish/completions/integration-tester.fish
+`,
+		ValidArgs: []string{"bash", "zsh", "fish"},
+		Args:      cobra.ExactValidArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return completionCmd(cmd.Root(), os.Stdout, args[0])
+		},
+	}
+
+	return CommandWithDefaults(completion)
+}
+
+func completionCmd(root *cobra.Command, out io.Writer, shell string) error {
+	switch shell {
+	case "bash":
+		return root.GenBashCompletion(out)
+	case "zsh":
+		return root.GenZshCompletion(out)
+	case "fish":
+		return root.GenFishCompletion(out, true)
+	default:
+		return ExitErrorf(EX_USAGE, "unsupported shell %q", shell)
+	}
+}
+
+// registerCompletions registers the dynamic flag completions for
+// cmd and all of its subcommands.
+func registerCompletions(cmd *cobra.Command) {
+	fileExtensions := func(ext ...string) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+		return func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+			return ext, cobra.ShellCompDirectiveFilterFileExt
+		}
+	}
+
+	completions := map[string]func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective){
+		"watch":    completeResourceNames,
+		"fixtures": fileExtensions("yaml", "yml"),
+		"policies": fileExtensions("rego"),
+	}
+
+	for name, f := range completions {
+		if cmd.Flags().Lookup(name) != nil {
+			must.Must(cmd.RegisterFlagCompletionFunc(name, f))
+		}
+	}
+
+	for _, c := range cmd.Commands() {
+		registerCompletions(c)
+	}
+}
+
+// completeResourceNames completes the names of the resources that
+// the current cluster serves. Since the flag takes a comma-separated
+// list, only the last element is completed.
+func completeResourceNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	kube, err := driver.NewKubeClient()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	resources, err := kube.ServerResources()
+	if err != nil && driver.DiscoveryFailures(err) == nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	head := ""
+	if i := strings.LastIndex(toComplete, ","); i >= 0 {
+		head, toComplete = toComplete[:i+1], toComplete[i+1:]
+	}
+
+	var names []string
+	for _, n := range watchableResourceNames(resources) {
+		if strings.HasPrefix(n, toComplete) {
+			names = append(names, head+n)
+		}
+	}
+
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// watchableResourceNames returns the sorted, unique names of the
+// resources that can be watched. Subresources are not included.
+func watchableResourceNames(resources []metav1.APIResource) []string {
+	seen := map[string]bool{}
+
+	for _, r := range resources {
+		if strings.Contains(r.Name, "/") {
+			continue
+		}
+
+		for _, v := range r.Verbs {
+			if v == "watch" {
+				seen[r.Name] = true
+				break
+			}
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for n := range seen {
+		names = append(names, n)
+	}
+
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,51 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestWatchableResourceNames(t *testing.T) {
+	watch := metav1.Verbs{"get", "list", "watch"}
+
+	resources := []metav1.APIResource{
+		{Name: "services", Version: "v1", Verbs: watch},
+		{Name: "pods", Version: "v1", Verbs: watch},
+		{Name: "pods/status", Version: "v1", Verbs: watch},
+		{Name: "bindings", Version: "v1", Verbs: metav1.Verbs{"create"}},
+		{Name: "ingresses", Group: "extensions", Version: "v1beta1", Verbs: watch},
+		{Name: "ingresses", Group: "networking.k8s.io", Version: "v1", Verbs: watch},
+	}
+
+	assert.Equal(t, []string{"ingresses", "pods", "services"}, watchableResourceNames(resources))
+}
+
+func TestCompletionScripts(t *testing.T) {
+	root := NewRootCommand()
+
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		var out bytes.Buffer
+		require.NoError(t, completionCmd(root, &out, shell))
+		assert.Contains(t, out.String(), "integration-tester", shell)
+	}
+
+	assert.Error(t, completionCmd(root, &bytes.Buffer{}, "tcsh"))
+}
@@ -0,0 +1,199 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/projectcontour/integration-tester/pkg/must"
+	"github.com/projectcontour/integration-tester/pkg/result"
+	"github.com/projectcontour/integration-tester/pkg/test"
+
+	"github.com/spf13/cobra"
+)
+
+// NewDescribeCommand returns a command that describes the results
+// of a past test run.
+func NewDescribeCommand() *cobra.Command {
+	describe := &cobra.Command{
+		Use:   "describe [FLAGS ...] RUNID|FILE",
+		Short: "Describe the results of a past test run",
+		Long: `Describe the results of a past test run
+
+The describe command reads the JSON results of a test run and prints
+which test documents and steps passed, failed or were skipped, how
+long each of them took, and the Kubernetes objects that each test
+document operated on. The messages of failed steps are printed after
+the steps of each document.
+
+The argument is either the path to a JSON results file, as written
+by 'run --format json=FILE', or the ID of a test run whose results
+were kept in the '--results-dir' directory by 'run --results-dir'.
+`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return describeCmd(cmd, args[0])
+		},
+	}
+
+	describe.Flags().String("results-dir", ".", "Directory of results files kept by 'run --results-dir'")
+
+	return CommandWithDefaults(describe)
+}
+
+func describeCmd(cmd *cobra.Command, arg string) error {
+	path := arg
+
+	// If the argument isn't a file, look it up as a run ID.
+	if info, err := os.Stat(arg); err != nil || info.IsDir() {
+		path = filepath.Join(
+			must.String(cmd.Flags().GetString("results-dir")),
+			resultsFileName(arg, time.Time{}))
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return ExitError{Code: EX_NOINPUT, Err: err}
+	}
+
+	defer f.Close()
+
+	results, err := test.ReadJSONResults(f)
+	if err != nil {
+		return ExitErrorf(EX_DATAERR, "failed to read %s: %s", path, err)
+	}
+
+	return describeResults(os.Stdout, results)
+}
+
+// describeStatus maps the outcome of a test document or step to the
+// status that is printed for it.
+var describeStatus = map[result.Severity]string{
+	result.SeverityNone:  "PASSED",
+	result.SeverityError: "FAILED",
+	result.SeveritySkip:  "SKIPPED",
+}
+
+// describeResults writes a human readable description of the test
+// run results to out.
+func describeResults(out io.Writer, results *test.JSONResults) error {
+	counts := map[result.Severity]int{}
+	attempts := 0
+
+	for _, d := range results.Documents {
+		if d.Retried {
+			attempts++
+			continue
+		}
+
+		counts[d.Outcome()]++
+	}
+
+	status := describeStatus[result.SeverityNone]
+	if results.Failed {
+		status = describeStatus[result.SeverityError]
+	}
+
+	tab := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+
+	if results.RunID != "" {
+		fmt.Fprintf(tab, "Run ID:\t%s\n", results.RunID)
+	}
+
+	fmt.Fprintf(tab, "Status:\t%s\n", status)
+	fmt.Fprintf(tab, "Documents:\t%d passed, %d failed, %d skipped\n",
+		counts[result.SeverityNone], counts[result.SeverityError], counts[result.SeveritySkip])
+
+	if attempts > 0 {
+		fmt.Fprintf(tab, "Retries:\t%d\n", attempts)
+	}
+
+	if err := tab.Flush(); err != nil {
+		return err
+	}
+
+	for _, d := range results.Documents {
+		if err := describeDocument(out, d); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// describeDocument writes the steps, objects and failures of a
+// test document to out.
+func describeDocument(out io.Writer, d *test.JSONDocument) error {
+	status := describeStatus[d.Outcome()]
+	if d.Retried {
+		status = "RETRIED"
+	}
+
+	fmt.Fprintf(out, "\nDocument: %s\n", d.Description)
+	fmt.Fprintf(out, "Status:   %s\n", status)
+	fmt.Fprintf(out, "Duration: %s\n", d.Duration.Round(time.Millisecond))
+
+	tab := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(tab, "\n  STEP\tSTATUS\tDURATION\tDESCRIPTION\n")
+	for _, s := range d.Steps {
+		fmt.Fprintf(tab, "  %s\t%s\t%s\t%s\n",
+			s.ID, describeStatus[s.Outcome()], s.Duration.Round(time.Millisecond), s.Description)
+	}
+
+	if len(d.Objects) > 0 {
+		fmt.Fprintf(tab, "\n  OBJECT\tOPERATION\tSTEP\n")
+		for _, o := range d.Objects {
+			name := o.Name
+			if o.Namespace != "" {
+				name = o.Namespace + "/" + o.Name
+			}
+
+			fmt.Fprintf(tab, "  %s:%s '%s'\t%s\t%s\n",
+				o.APIVersion, o.Kind, name, o.Operation, o.Step)
+		}
+	}
+
+	if err := tab.Flush(); err != nil {
+		return err
+	}
+
+	var failures []string
+	for _, s := range d.Steps {
+		for _, r := range s.Results {
+			if (result.Result{Severity: r.Severity}).IsFailed() {
+				// Indent multi-line messages under their step.
+				text := strings.ReplaceAll(r.Text(), "\n", "\n      ")
+				failures = append(failures, fmt.Sprintf("  %s: %s: %s",
+					s.ID, strings.ToUpper(string(r.Severity)), text))
+			}
+		}
+	}
+
+	if len(failures) > 0 {
+		fmt.Fprintf(out, "\n  Failures:\n")
+		for _, f := range failures {
+			fmt.Fprintf(out, "  %s\n", f)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/projectcontour/integration-tester/pkg/result"
+	"github.com/projectcontour/integration-tester/pkg/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDescribeResults(t *testing.T) {
+	results := &test.JSONResults{
+		RunID:  "github-12345",
+		Failed: true,
+		Documents: []*test.JSONDocument{{
+			Description: "one.yaml",
+			Duration:    1500 * time.Millisecond,
+			Steps: []test.JSONStep{{
+				ID:          "one.yaml#service/echo:update",
+				Description: "updating Kubernetes object",
+				Duration:    200 * time.Millisecond,
+				Results:     []test.JSONResult{{Severity: result.SeverityNone, Message: "ok"}},
+			}, {
+				ID:          "one.yaml#service/echo:check",
+				Description: "running object update check",
+				Results: []test.JSONResult{{
+					Severity: result.SeverityError,
+					Rule:     "error_no_endpoints",
+					Message:  "no endpoints",
+				}},
+			}},
+			Objects: []test.JSONObject{{
+				APIVersion: "v1",
+				Kind:       "Service",
+				Namespace:  "default",
+				Name:       "echo",
+				Operation:  "update",
+				Step:       "one.yaml#service/echo:update",
+			}},
+		}, {
+			Description: "two.yaml",
+			Steps: []test.JSONStep{{
+				ID:      "two.yaml#compile",
+				Results: []test.JSONResult{{Severity: result.SeveritySkip, Message: "skipped"}},
+			}},
+		}},
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, describeResults(&out, results))
+
+	text := out.String()
+	assert.Contains(t, text, "Run ID:     github-12345")
+	assert.Contains(t, text, "Documents:  0 passed, 1 failed, 1 skipped")
+	assert.Contains(t, text, "Document: one.yaml\nStatus:   FAILED\nDuration: 1.5s")
+	assert.Contains(t, text, "v1:Service 'default/echo'")
+	assert.Contains(t, text, "one.yaml#service/echo:check: ERROR: raised predicate \"error_no_endpoints\"\n      no endpoints")
+	assert.Contains(t, text, "Document: two.yaml\nStatus:   SKIPPED")
+}
+
+func TestResultsFileName(t *testing.T) {
+	start := time.Date(2020, 11, 2, 10, 30, 0, 0, time.UTC)
+
+	assert.Equal(t, "20201102T103000Z.json", resultsFileName("", start))
+	assert.Equal(t, "github-12345.json", resultsFileName("github-12345", start))
+	assert.Equal(t, "a_b.json", resultsFileName("a/b", start))
+}
@@ -0,0 +1,253 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/projectcontour/integration-tester/pkg/driver"
+	"github.com/projectcontour/integration-tester/pkg/must"
+	"github.com/projectcontour/integration-tester/pkg/test"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NewEvalCommand returns a command to evaluate Rego against the
+// current cluster.
+func NewEvalCommand() *cobra.Command {
+	eval := &cobra.Command{
+		Use:   "eval [FLAGS ...] [QUERY|FILE]",
+		Short: "Evaluate a Rego query or module against a cluster",
+		Long: `Evaluate a Rego query or module against a cluster
+
+The eval command populates the Rego data document from the current
+Kubernetes cluster in the same way as a test run does, and evaluates
+an ad hoc Rego query or module against it. This is the fastest way
+to develop new checks.
+
+If the argument is the path to a Rego file, the file is evaluated
+as a check module and any check results are printed. Otherwise the
+argument is evaluated as a Rego query, and the result set is printed
+as JSON. Queries can refer to the builtin packages and to any packages
+given with the '--policies' flag.
+
+The '--check' flag names a file that is always evaluated as a check
+module, whatever its file extension. This is useful for iterating on
+a check that fails in a test document, without re-running the whole
+document.
+
+Only the resources given with the '--watch' flag (and the API server
+resource versions) are stored in 'data.resources'. The
+'--watch-namespace' flag further limits the stored namespaced objects
+to the given namespaces. The '--param' and '--namespace' flags have
+the same meaning as they do for the run command.
+
+The '--watch-mode' flag re-evaluates the query or module at each
+'--interval' and prints the result each time that it changes, until
+the command is interrupted.
+`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			check := must.String(cmd.Flags().GetString("check"))
+
+			switch {
+			case check != "" && len(args) > 0:
+				return ExitErrorf(EX_USAGE, "the '--check' flag and a query or file are mutually exclusive")
+			case check != "":
+				return evalCmd(cmd, check, true)
+			case len(args) == 0:
+				return ExitErrorf(EX_USAGE, "missing query, file or '--check' flag")
+			default:
+				return evalCmd(cmd, args[0], strings.HasSuffix(args[0], ".rego"))
+			}
+		},
+	}
+
+	eval.Flags().String("check", "", "Rego check module file to evaluate")
+	eval.Flags().StringSlice("watch", []string{}, "Kubernetes resources to store in the Rego data document")
+	eval.Flags().StringSlice("watch-namespace", []string{}, "Only store namespaced objects from these namespaces")
+	eval.Flags().StringSlice("policies", []string{}, "Additional Rego policy packages")
+	eval.Flags().StringArray("param", []string{}, "Additional Rego parameter(s) in key=value format")
+	eval.Flags().String("namespace", metav1.NamespaceDefault, "Namespace that is stored as the default namespace")
+	eval.Flags().Bool("watch-mode", false, "Continuously re-evaluate and print changed results")
+	eval.Flags().Duration("interval", 2*time.Second, "Evaluation interval for watch mode")
+
+	return CommandWithDefaults(eval)
+}
+
+func evalCmd(cmd *cobra.Command, arg string, isModule bool) error {
+	namespace := must.String(cmd.Flags().GetString("namespace"))
+	if err := validateNamespace(namespace); err != nil {
+		return ExitError{Code: EX_USAGE, Err: err}
+	}
+
+	interval := must.Duration(cmd.Flags().GetDuration("interval"))
+	if interval <= 0 {
+		return ExitErrorf(EX_USAGE, "invalid evaluation interval %s", interval)
+	}
+
+	opts, err := validateParams(
+		must.StringSlice(cmd.Flags().GetStringArray("param")))
+	if err != nil {
+		return err
+	}
+
+	opts = append(opts, test.NamespaceOpt(namespace))
+
+	watchNamespaces := must.StringSlice(cmd.Flags().GetStringSlice("watch-namespace"))
+	for _, ns := range watchNamespaces {
+		if err := validateNamespace(ns); err != nil {
+			return ExitError{Code: EX_USAGE, Err: err}
+		}
+	}
+
+	opts = append(opts, test.WatchNamespacesOpt(watchNamespaces...))
+
+	policies := must.StringSlice(cmd.Flags().GetStringSlice("policies"))
+
+	// If the argument is a Rego file, load it along with the
+	// policies so that it is compiled into the evaluator.
+	var module *ast.Module
+	if isModule {
+		policies = append(policies, arg)
+	}
+
+	if len(policies) > 0 {
+		modules, err := loadPolicies(policies, nil)
+		if err != nil {
+			return ExitError{Code: EX_DATAERR, Err: err}
+		}
+
+		for _, m := range modules {
+			opts = append(opts, test.RegoModuleOpt(m))
+		}
+
+		module = modules[arg]
+	}
+
+	if isModule && module == nil {
+		return ExitErrorf(EX_NOINPUT, "%s is not a Rego module file", arg)
+	}
+
+	kube, err := driver.NewKubeClient()
+	if err != nil {
+		return fmt.Errorf("failed to initialize Kubernetes context: %s", err)
+	}
+
+	opts = append(opts, test.KubeClientOpt(kube))
+
+	for _, n := range must.StringSlice(cmd.Flags().GetStringSlice("watch")) {
+		gvrs, err := kube.ResourcesForName(n)
+		if err != nil {
+			return err
+		}
+
+		for _, gvr := range gvrs {
+			opts = append(opts, test.WatchResourceOpt(gvr))
+		}
+	}
+
+	evaluator, err := test.NewEvaluator(opts...)
+	if err != nil {
+		return ExitError{Code: EX_DATAERR, Err: err}
+	}
+
+	defer evaluator.Close()
+
+	evaluate := func() (string, error) {
+		if module != nil {
+			return evalModule(evaluator, module)
+		}
+
+		return evalQuery(evaluator, arg)
+	}
+
+	out, err := evaluate()
+	if err != nil {
+		return ExitError{Code: EX_DATAERR, Err: err}
+	}
+
+	fmt.Print(out)
+
+	if !must.Bool(cmd.Flags().GetBool("watch-mode")) {
+		return nil
+	}
+
+	for range time.Tick(interval) {
+		next, err := evaluate()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "evaluation failed: %s\n", err)
+			continue
+		}
+
+		if next != out {
+			out = next
+			fmt.Printf("--- %s\n", time.Now().Format(time.RFC3339))
+			fmt.Print(out)
+		}
+	}
+
+	return nil
+}
+
+// evalQuery formats the result set of a query as JSON. Queries
+// that are false or undefined print "undefined", like the OPA REPL.
+func evalQuery(e *test.Evaluator, query string) (string, error) {
+	resultSet, err := e.Query(query)
+	if err != nil {
+		return "", err
+	}
+
+	if len(resultSet) == 0 {
+		return "undefined\n", nil
+	}
+
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetIndent("", "  ")
+
+	if err := enc.Encode(resultSet); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// evalModule formats the check results of a module with one
+// result per line.
+func evalModule(e *test.Evaluator, m *ast.Module) (string, error) {
+	results, err := e.Eval(m)
+	if err != nil {
+		return "", err
+	}
+
+	if len(results) == 0 {
+		return "no results\n", nil
+	}
+
+	buf := &bytes.Buffer{}
+	for _, r := range results {
+		fmt.Fprintf(buf, "%s: %s\n", r.Severity, r.Text())
+	}
+
+	return buf.String(), nil
+}
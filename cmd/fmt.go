@@ -0,0 +1,129 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/projectcontour/integration-tester/pkg/doc"
+	"github.com/projectcontour/integration-tester/pkg/must"
+
+	"github.com/spf13/cobra"
+)
+
+// NewFmtCommand returns a command to format test documents.
+func NewFmtCommand() *cobra.Command {
+	format := &cobra.Command{
+		Use:   "fmt [FLAGS ...] FILE [FILE ...]",
+		Short: "Format test documents",
+		Long: `Format test documents
+
+The fmt command normalizes the formatting of the given test documents.
+Kubernetes objects are re-encoded as YAML with the canonical two space
+indentation, Rego fragments are formatted in the same way as by 'opa
+fmt', and each fragment is preceded by a "---" separator. Comments
+are preserved, and included documents are not expanded.
+
+By default, the formatted documents are printed to standard output.
+The '--write' flag rewrites each document that is not formatted in
+place. The '--check' flag prints the names of the documents that are
+not formatted, and fails if there are any, which is useful in CI.
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				return ExitErrorf(EX_USAGE, "no test file(s)")
+			}
+
+			return fmtCmd(cmd, args)
+		},
+	}
+
+	format.Flags().BoolP("write", "w", false, "Rewrite test documents that are not formatted")
+	format.Flags().Bool("check", false, "List test documents that are not formatted and fail if there are any")
+
+	return CommandWithDefaults(format)
+}
+
+func fmtCmd(cmd *cobra.Command, args []string) error {
+	write := must.Bool(cmd.Flags().GetBool("write"))
+	check := must.Bool(cmd.Flags().GetBool("check"))
+	if write && check {
+		return ExitErrorf(EX_USAGE, "the --write and --check flags are mutually exclusive")
+	}
+
+	unformatted := 0
+
+	for _, path := range args {
+		original, err := ioutil.ReadFile(path) // nolint(gosec)
+		if err != nil {
+			return ExitError{Code: EX_NOINPUT, Err: err}
+		}
+
+		formatted, err := formatDocument(path, original)
+		if err != nil {
+			return ExitError{Code: EX_DATAERR, Err: err}
+		}
+
+		switch {
+		case check:
+			if !bytes.Equal(original, formatted) {
+				fmt.Println(path)
+				unformatted++
+			}
+		case write:
+			if bytes.Equal(original, formatted) {
+				continue
+			}
+
+			info, err := os.Stat(path)
+			if err != nil {
+				return err
+			}
+
+			if err := ioutil.WriteFile(path, formatted, info.Mode()); err != nil {
+				return ExitError{Code: EX_CANTCREAT, Err: err}
+			}
+		default:
+			if _, err := os.Stdout.Write(formatted); err != nil {
+				return err
+			}
+		}
+	}
+
+	if unformatted > 0 {
+		return ExitError{Code: EX_FAIL}
+	}
+
+	return nil
+}
+
+// formatDocument returns the formatted contents of the test
+// document read from path.
+func formatDocument(path string, data []byte) ([]byte, error) {
+	testDoc, err := doc.ReadDocument(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	formatted, err := doc.Format(testDoc)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return formatted, nil
+}
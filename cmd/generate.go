@@ -0,0 +1,181 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/projectcontour/integration-tester/pkg/doc"
+	"github.com/projectcontour/integration-tester/pkg/must"
+
+	"github.com/spf13/cobra"
+)
+
+// NewGenerateCommand returns a command to generate test documents.
+func NewGenerateCommand() *cobra.Command {
+	generate := &cobra.Command{
+		Use:          "generate",
+		Short:        "Generates one of [scale]",
+		Long:         "Generates one of [scale]",
+		SilenceUsage: true,
+	}
+
+	scale := &cobra.Command{
+		Use:   "scale [FLAGS ...] TEMPLATE",
+		Short: "Generate a scale test document from an object template",
+		Long: `Generate a scale test document from an object template
+
+The scale command generates a test document with many copies of the
+Kubernetes object in the TEMPLATE file, so that capacity tests can use
+the same test harness and reporting as other tests. The template is a
+Go text/template, which is executed once for each copy. The '.Index'
+field is the index of the copy, starting at 0, and the '.Count' field
+is the number of copies, which is given by the '--count' flag. Each
+copy must be an object with a distinct name, e.g.:
+
+  apiVersion: projectcontour.io/v1
+  kind: HTTPProxy
+  metadata:
+    name: echo-{{.Index}}
+  spec:
+    virtualhost:
+      fqdn: echo-{{.Index}}.example.com
+
+The copies are followed by an aggregate Rego check that fails until
+all of them have been applied. More checks can be added to the
+generated document, for example to wait for the objects to become
+valid.
+
+The document is printed to standard output, or written to the file
+given by the '--output' flag. An existing file is not overwritten
+unless the '--force' flag is given.
+`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return generateScaleCmd(cmd, args[0])
+		},
+	}
+
+	scale.Flags().Int("count", 100, "Number of copies of the template object")
+	scale.Flags().String("output", "", "Write the test document to the given file")
+	scale.Flags().Bool("force", false, "Overwrite an existing output file")
+
+	generate.AddCommand(CommandWithDefaults(scale))
+	return CommandWithDefaults(generate)
+}
+
+// scaleCheck is the aggregate check that follows the generated
+// objects. Each object is recorded at data.test.applied under its
+// fragment ID, which is its lower-cased kind and its name.
+var scaleCheck = template.Must(template.New("check").Parse(`# Check that all {{.Count}} {{.Kind}} objects were applied.
+
+Want := {{.Count}}
+
+Applied := {name | data.test.applied["{{.Resource}}"][name].kind == "{{.Kind}}"}
+
+error_objects_missing[msg] {
+  count(Applied) != Want
+  msg := sprintf("applied %d of %d {{.Kind}} objects", [count(Applied), Want])
+}
+`))
+
+// generateScale writes a test document with count copies of the
+// object template, followed by the aggregate check.
+func generateScale(out io.Writer, tmpl *template.Template, count int) error {
+	var kind string
+
+	names := map[string]bool{}
+
+	for i := 0; i < count; i++ {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, struct{ Index, Count int }{i, count}); err != nil {
+			return err
+		}
+
+		f := doc.Fragment{Bytes: buf.Bytes()}
+		if fragType, err := f.Decode(); err != nil || fragType != doc.FragmentTypeObject || f.Items() != nil {
+			return fmt.Errorf("copy %d of the template is not a Kubernetes object", i)
+		}
+
+		obj := f.Object()
+
+		switch {
+		case obj.GetName() == "":
+			return fmt.Errorf("copy %d of the template has no name", i)
+		case names[obj.GetName()]:
+			return fmt.Errorf("copy %d of the template has the same name %q as an earlier copy", i, obj.GetName())
+		case kind != "" && obj.GetKind() != kind:
+			return fmt.Errorf("copy %d of the template is a %s, not a %s", i, obj.GetKind(), kind)
+		}
+
+		kind = obj.GetKind()
+		names[obj.GetName()] = true
+
+		fmt.Fprintf(out, "---\n%s\n", strings.TrimSpace(buf.String()))
+	}
+
+	fmt.Fprintf(out, "---\n")
+
+	return scaleCheck.Execute(out, struct {
+		Count    int
+		Kind     string
+		Resource string
+	}{count, kind, strings.ToLower(kind)})
+}
+
+func generateScaleCmd(cmd *cobra.Command, path string) error {
+	count := must.Int(cmd.Flags().GetInt("count"))
+	if count < 1 {
+		return ExitErrorf(EX_USAGE, "invalid count %d", count)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ExitError{Code: EX_NOINPUT, Err: err}
+	}
+
+	tmpl, err := template.New(path).Option("missingkey=error").Parse(string(data))
+	if err != nil {
+		return ExitError{Code: EX_DATAERR, Err: err}
+	}
+
+	var buf bytes.Buffer
+	if err := generateScale(&buf, tmpl, count); err != nil {
+		return ExitErrorf(EX_DATAERR, "%s: %s", path, err)
+	}
+
+	output := must.String(cmd.Flags().GetString("output"))
+	if output == "" {
+		_, err := os.Stdout.Write(buf.Bytes())
+		return err
+	}
+
+	if _, err := os.Stat(output); err == nil && !must.Bool(cmd.Flags().GetBool("force")) {
+		return ExitErrorf(EX_CANTCREAT, "%s already exists", output)
+	}
+
+	if err := ioutil.WriteFile(output, buf.Bytes(), 0644); err != nil { // nolint(gosec)
+		return ExitError{Code: EX_CANTCREAT, Err: err}
+	}
+
+	fmt.Printf("generated %s with %d copies of %s\n", output, count, path)
+	return nil
+}
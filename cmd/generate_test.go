@@ -0,0 +1,66 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"text/template"
+
+	"github.com/projectcontour/integration-tester/pkg/doc"
+	"github.com/projectcontour/integration-tester/pkg/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateScale(t *testing.T) {
+	tmpl := template.Must(template.New("proxy").Parse(`apiVersion: projectcontour.io/v1
+kind: HTTPProxy
+metadata:
+  name: echo-{{.Index}}
+spec:
+  virtualhost:
+    fqdn: echo-{{.Index}}-of-{{.Count}}.example.com
+`))
+
+	var buf bytes.Buffer
+	require.NoError(t, generateScale(&buf, tmpl, 3))
+
+	d, err := doc.ReadDocument(&buf)
+	require.NoError(t, err)
+
+	d.Name = "scale.yaml"
+	assert.Empty(t, test.Lint(d))
+
+	require.Len(t, d.Parts, 4)
+	assert.Equal(t, "echo-2", d.Parts[2].Object().GetName())
+	assert.EqualValues(t, doc.FragmentTypeModule, d.Parts[3].Type)
+	assert.Equal(t, "Check that all 3 HTTPProxy objects were applied.", d.Parts[3].Description())
+}
+
+func TestGenerateScaleInvalid(t *testing.T) {
+	for name, text := range map[string]string{
+		"not an object": "foo: {{.Index}}\n",
+		"no name":       "apiVersion: v1\nkind: ConfigMap\n",
+		"same name":     "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: echo\n",
+		"list":          "apiVersion: v1\nkind: List\nitems:\n- apiVersion: v1\n  kind: ConfigMap\n  metadata:\n    name: echo-{{.Index}}\n",
+	} {
+		tmpl := template.Must(template.New(name).Parse(text))
+
+		var buf bytes.Buffer
+		assert.Error(t, generateScale(&buf, tmpl, 2), name)
+	}
+}
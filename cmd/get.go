@@ -17,16 +17,21 @@ package cmd
 import (
 	"fmt"
 	"log"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/projectcontour/integration-tester/pkg/builtin"
 	"github.com/projectcontour/integration-tester/pkg/driver"
 	"github.com/projectcontour/integration-tester/pkg/filter"
 	"github.com/projectcontour/integration-tester/pkg/must"
+	"github.com/projectcontour/integration-tester/pkg/test"
 	"github.com/projectcontour/integration-tester/pkg/version"
 
 	"github.com/gosuri/uitable"
 	"github.com/spf13/cobra"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/util/duration"
 )
 
@@ -34,8 +39,8 @@ import (
 func NewGetCommand() *cobra.Command {
 	get := &cobra.Command{
 		Use:          "get",
-		Short:        "Gets one of [objects, tests]",
-		Long:         "Gets one of [objects, tests]",
+		Short:        "Gets one of [objects, runs, builtins, checks, input]",
+		Long:         "Gets one of [objects, runs, builtins, checks, input]",
 		SilenceUsage: true,
 	}
 
@@ -48,6 +53,10 @@ func NewGetCommand() *cobra.Command {
 This command lists Kubernetes API objects that are labeled as managed
 by integration-tester. integration-tester labels objects created or
 modified by test documents with the %s%s%s label.
+
+The '--run-id' flag only lists the objects of the test run that was
+given the same '--run-id', including the objects of its iterations
+and retries, whose run IDs are derived from it.
 `,
 			"`", filter.LabelManagedBy, "`"),
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -62,6 +71,18 @@ modified by test documents with the %s%s%s label.
 				return err
 			}
 
+			if runID := must.String(cmd.Flags().GetString("run-id")); runID != "" {
+				var matched []*unstructured.Unstructured
+
+				for _, r := range results {
+					if matchRunID(must.String(kube.RunIDFor(r)), runID) {
+						matched = append(matched, r)
+					}
+				}
+
+				results = matched
+			}
+
 			if len(results) == 0 {
 				return nil
 			}
@@ -88,6 +109,341 @@ modified by test documents with the %s%s%s label.
 		},
 	}
 
+	runs := &cobra.Command{
+		Use:   "runs [FLAGS ...]",
+		Short: "Gets the test runs that have objects in the cluster",
+		Long: fmt.Sprintf(
+			`Gets the test runs that have objects in the cluster
+
+This command groups the Kubernetes API objects that are labeled as
+managed by integration-tester (with the %s%s%s label) by
+their test run ID, and lists each run with the number of objects, the
+age of its oldest object and an inferred status:
+
+  Deleting   Some of the run's objects are being deleted.
+  Active     The run created an object within the '--active-within'
+             duration, so it is probably still running.
+  Stale      The run hasn't created an object recently. Its objects
+             were probably preserved or leaked, and can be deleted
+             with the clean command.
+
+Objects that don't have a run ID are listed under the "-" run.
+`,
+			"`", filter.LabelManagedBy, "`"),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			kube, err := driver.NewKubeClient()
+			if err != nil {
+				return fmt.Errorf("failed to initialize Kubernetes context: %s", err)
+			}
+
+			results, err := kube.SelectObjectsByLabel(filter.LabelManagedBy, version.Progname)
+			if err != nil {
+				return err
+			}
+
+			summaries := summarizeRuns(results,
+				func(u *unstructured.Unstructured) string {
+					return must.String(kube.RunIDFor(u))
+				},
+				time.Now(),
+				must.Duration(cmd.Flags().GetDuration("active-within")))
+
+			if len(summaries) == 0 {
+				return nil
+			}
+
+			table := uitable.New()
+			table.AddRow("RUN ID", "OBJECTS", "AGE", "STATUS")
+
+			for _, r := range summaries {
+				table.AddRow(r.RunID, r.Objects, duration.HumanDuration(r.Age), r.Status)
+			}
+
+			fmt.Println(table)
+			return nil
+		},
+	}
+
+	builtins := &cobra.Command{
+		Use:   "builtins [NAME ...]",
+		Short: "Gets the built-in Rego modules",
+		Long: `Gets the built-in Rego modules
+
+This command lists the Rego modules that are embedded in
+integration-tester, along with their packages and a summary of their
+documentation. Test documents can import these packages to use the
+helpers that they define.
+
+If any module names or packages are given, the documentation, rules
+and source of each of those modules is printed instead.
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			descriptions, err := builtin.DescribeModules()
+			if err != nil {
+				return err
+			}
+
+			if len(args) == 0 {
+				table := uitable.New()
+				table.MaxColWidth = 60
+				table.Wrap = true
+				table.AddRow("NAME", "PACKAGE", "DESCRIPTION")
+
+				for _, d := range descriptions {
+					table.AddRow(d.Name, d.Package, d.Summary())
+				}
+
+				fmt.Println(table)
+				return nil
+			}
+
+			for _, name := range args {
+				var found *builtin.Description
+
+				for i, d := range descriptions {
+					if name == d.Name || name == d.Package ||
+						"data."+name == d.Package {
+						found = &descriptions[i]
+						break
+					}
+				}
+
+				if found == nil {
+					return ExitErrorf(EX_USAGE, "no builtin module %q", name)
+				}
+
+				fmt.Printf("# %s (%s)\n", found.Name, found.Package)
+				fmt.Printf("#\n# Rules: %s\n\n", strings.Join(found.Rules, ", "))
+				fmt.Printf("%s\n", must.Bytes(builtin.Asset(found.Name)))
+			}
+
+			return nil
+		},
+	}
+
+	checks := &cobra.Command{
+		Use:   "checks [FLAGS ...]",
+		Short: "Gets the rules of the built-in and policy Rego modules",
+		Long: `Gets the rules of the built-in and policy Rego modules
+
+This command lists the rules that are defined by the Rego modules
+embedded in integration-tester, and by any policy modules given with
+the '--policies' flag. Each rule is listed with its package and the
+test result severity that it raises. Rules that don't raise test
+results (helper rules and functions) have the severity "-".
+
+The '--rule-severity' flag maps additional rule names to severities,
+in the same way as the run command, so that the listing matches the
+severities of a test run.
+`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			severities, err := parseRuleSeverities(
+				must.StringSlice(cmd.Flags().GetStringArray("rule-severity")))
+			if err != nil {
+				return ExitError{Code: EX_USAGE, Err: err}
+			}
+
+			descriptions, err := builtin.DescribeModules()
+			if err != nil {
+				return err
+			}
+
+			if policies := must.StringSlice(cmd.Flags().GetStringSlice("policies")); len(policies) > 0 {
+				modules, err := loadPolicies(policies, nil)
+				if err != nil {
+					return ExitError{Code: EX_DATAERR, Err: err}
+				}
+
+				for name, m := range modules {
+					descriptions = append(descriptions, builtin.Describe(name, m))
+				}
+			}
+
+			table := uitable.New()
+			table.AddRow("PACKAGE", "RULE", "SEVERITY")
+
+			for _, c := range describeChecks(descriptions, severities) {
+				table.AddRow(c.Package, c.Rule, c.Severity)
+			}
+
+			fmt.Println(table)
+			return nil
+		},
+	}
+
+	input := &cobra.Command{
+		Use:   "input",
+		Short: "Gets the Rego input document for checks",
+		Long: `Gets the Rego input document for checks
+
+This command describes the fields of the Rego input document that
+test checks are evaluated with. The checks on Kubernetes object
+fragments and standalone Rego fragments receive the same input. For
+object checks, the operation fields describe the result of applying
+the object. For Rego fragments, they describe the most recent object
+operation in the test document, and are absent if there hasn't been
+one.
+`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			table := uitable.New()
+			table.AddRow("FIELD", "DESCRIPTION")
+
+			for _, f := range test.DescribeCheckInput() {
+				table.AddRow(f.Name, f.Description)
+			}
+
+			fmt.Println(table)
+			return nil
+		},
+	}
+
+	objects.Flags().String("run-id", "", "Only get the objects of the given test run ID")
+	checks.Flags().StringSlice("policies", []string{}, "Additional Rego policy packages")
+	checks.Flags().StringArray("rule-severity", []string{}, "Additional Rego rule name(s) to treat as test results in name=severity format")
+	runs.Flags().Duration("active-within", 10*time.Minute, "Consider runs that created objects within this duration active")
+
 	get.AddCommand(CommandWithDefaults(objects))
+	get.AddCommand(CommandWithDefaults(runs))
+	get.AddCommand(CommandWithDefaults(builtins))
+	get.AddCommand(CommandWithDefaults(checks))
+	get.AddCommand(CommandWithDefaults(input))
 	return CommandWithDefaults(get)
 }
+
+// matchRunID returns whether the object run ID belongs to the test
+// run with the given run ID. Iterations and retries of a test run
+// have run IDs that are derived from it, by appending a suffix.
+func matchRunID(objectRunID string, runID string) bool {
+	return objectRunID == runID ||
+		strings.HasPrefix(objectRunID, runID+"-")
+}
+
+// checkRule describes a rule of a Rego module.
+type checkRule struct {
+	Package  string
+	Rule     string
+	Severity string
+}
+
+// describeChecks returns the rules of the described modules, sorted
+// by package and rule name.
+func describeChecks(descriptions []builtin.Description, severities *driver.RuleSeverities) []checkRule {
+	var rules []checkRule
+
+	for _, d := range descriptions {
+		for _, r := range d.Rules {
+			severity := "-"
+
+			// Functions are formatted with their arguments,
+			// and can't raise results.
+			if !strings.Contains(r, "(") {
+				if s, ok := severities.Severity(r); ok {
+					severity = string(s)
+				}
+			}
+
+			rules = append(rules, checkRule{
+				Package:  strings.TrimPrefix(d.Package, "data."),
+				Rule:     r,
+				Severity: severity,
+			})
+		}
+	}
+
+	sort.SliceStable(rules, func(i, j int) bool {
+		if rules[i].Package != rules[j].Package {
+			return rules[i].Package < rules[j].Package
+		}
+
+		return rules[i].Rule < rules[j].Rule
+	})
+
+	return rules
+}
+
+// runSummary describes the objects of a test run that are in the cluster.
+type runSummary struct {
+	RunID   string
+	Objects int
+	Age     time.Duration
+	Status  string
+}
+
+// summarizeRuns groups the objects by their run ID and summarizes
+// each run. Runs are sorted from the oldest to the newest.
+func summarizeRuns(
+	objects []*unstructured.Unstructured,
+	runIDFor func(*unstructured.Unstructured) string,
+	now time.Time,
+	activeWithin time.Duration,
+) []runSummary {
+	type run struct {
+		objects  int
+		oldest   time.Time
+		newest   time.Time
+		deleting bool
+	}
+
+	runs := map[string]*run{}
+
+	for _, u := range objects {
+		id := runIDFor(u)
+		if id == "" {
+			id = "-"
+		}
+
+		created := u.GetCreationTimestamp().Time
+
+		r, ok := runs[id]
+		if !ok {
+			r = &run{oldest: created, newest: created}
+			runs[id] = r
+		}
+
+		r.objects++
+
+		if created.Before(r.oldest) {
+			r.oldest = created
+		}
+
+		if created.After(r.newest) {
+			r.newest = created
+		}
+
+		if u.GetDeletionTimestamp() != nil {
+			r.deleting = true
+		}
+	}
+
+	summaries := make([]runSummary, 0, len(runs))
+
+	for id, r := range runs {
+		status := "Stale"
+		switch {
+		case r.deleting:
+			status = "Deleting"
+		case now.Sub(r.newest) <= activeWithin:
+			status = "Active"
+		}
+
+		summaries = append(summaries, runSummary{
+			RunID:   id,
+			Objects: r.objects,
+			Age:     now.Sub(r.oldest),
+			Status:  status,
+		})
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Age != summaries[j].Age {
+			return summaries[i].Age > summaries[j].Age
+		}
+
+		return summaries[i].RunID < summaries[j].RunID
+	})
+
+	return summaries
+}
@@ -0,0 +1,87 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/projectcontour/integration-tester/pkg/builtin"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestMatchRunID(t *testing.T) {
+	assert.True(t, matchRunID("github-123", "github-123"))
+	assert.True(t, matchRunID("github-123-2", "github-123"))
+	assert.True(t, matchRunID("github-123-2-retry-1", "github-123"))
+	assert.False(t, matchRunID("github-1234", "github-123"))
+	assert.False(t, matchRunID("", "github-123"))
+}
+
+func TestSummarizeRuns(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+
+	object := func(runID string, age time.Duration, deleting bool) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{Object: map[string]interface{}{}}
+		u.SetAnnotations(map[string]string{"run-id": runID})
+		u.SetCreationTimestamp(metav1.NewTime(now.Add(-age)))
+		if deleting {
+			ts := metav1.NewTime(now)
+			u.SetDeletionTimestamp(&ts)
+		}
+		return u
+	}
+
+	objects := []*unstructured.Unstructured{
+		object("old", 48*time.Hour, false),
+		object("old", time.Hour, false),
+		object("new", time.Minute, false),
+		object("gone", 2*time.Hour, true),
+		object("", 30*time.Minute, false),
+	}
+
+	summaries := summarizeRuns(objects,
+		func(u *unstructured.Unstructured) string { return u.GetAnnotations()["run-id"] },
+		now, 10*time.Minute)
+
+	assert.Equal(t, []runSummary{
+		{RunID: "old", Objects: 2, Age: 48 * time.Hour, Status: "Stale"},
+		{RunID: "gone", Objects: 1, Age: 2 * time.Hour, Status: "Deleting"},
+		{RunID: "-", Objects: 1, Age: 30 * time.Minute, Status: "Stale"},
+		{RunID: "new", Objects: 1, Age: time.Minute, Status: "Active"},
+	}, summaries)
+}
+
+func TestDescribeChecks(t *testing.T) {
+	severities, err := parseRuleSeverities([]string{"deny=error"})
+	require.NoError(t, err)
+
+	checks := describeChecks([]builtin.Description{
+		{Package: "data.test.b", Rules: []string{"skip_old_cluster", "helper"}},
+		{Package: "data.test.a", Rules: []string{"error_broken", "deny_root", "absent(path, grace)"}},
+	}, severities)
+
+	assert.Equal(t, []checkRule{
+		{Package: "test.a", Rule: "absent(path, grace)", Severity: "-"},
+		{Package: "test.a", Rule: "deny_root", Severity: "Error"},
+		{Package: "test.a", Rule: "error_broken", Severity: "Error"},
+		{Package: "test.b", Rule: "helper", Severity: "-"},
+		{Package: "test.b", Rule: "skip_old_cluster", Severity: "Skip"},
+	}, checks)
+}
@@ -0,0 +1,211 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/projectcontour/integration-tester/pkg/doc"
+	"github.com/projectcontour/integration-tester/pkg/fixture"
+	"github.com/projectcontour/integration-tester/pkg/must"
+	"github.com/projectcontour/integration-tester/pkg/test"
+	"github.com/projectcontour/integration-tester/pkg/utils"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/spf13/cobra"
+)
+
+// NewLintCommand returns a command to check test documents for
+// problems without contacting a cluster.
+func NewLintCommand() *cobra.Command {
+	lint := &cobra.Command{
+		Use:   "lint [FLAGS ...] FILE [FILE ...]",
+		Short: "Check test documents for problems without running them",
+		Long: `Check test documents for problems without running them
+
+The lint command reads each of the given test documents and reports
+any problems that would stop it from running, without contacting a
+Kubernetes cluster. Every fragment of each document is decoded, the
+Kubernetes objects are hydrated (resolving any fixtures), and all
+the Rego in the document is compiled together with the policies
+given by the '--policies' flag. This makes lint suitable for fast
+validation of test suites before they are merged.
+
+Objects that are cloned from the cluster with 'fixture-from-cluster'
+or that rotate cluster Secrets are checked as they are given in the
+document, since lint doesn't fetch anything from the cluster.
+
+Additional fixtures can be given with the '--fixtures' flag, the
+'--env' flag names the environment variables that objects can refer
+to, and the '--rego-capabilities' flag restricts the Rego builtins
+that documents and policies can use, in the same way as for the run
+command.
+
+Documents without problems are also checked for anti-patterns, which
+are reported as warnings. The anti-patterns are checks that don't
+refer to input or to any data other than the test parameters, objects
+that are applied without a check, objects with a hard-coded namespace
+that have the same name as a fixture, objects that are applied by
+more than one fragment, and fragments that are unreachable after a
+skip rule that always triggers. The '--warnings=false' flag disables
+these checks.
+
+Each problem is printed with the "file:line" location that it was
+found at, or as a JSON array if the '--format' flag is "json". The
+command fails if any document has a problem, or if any document has
+a warning and the '--fail-on-warnings' flag is given.
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				return ExitErrorf(EX_USAGE, "no test file(s)")
+			}
+
+			return lintCmd(cmd, args)
+		},
+	}
+
+	lint.Flags().StringSlice("policies", []string{}, "Additional Rego policy packages")
+	lint.Flags().StringSlice("fixtures", []string{}, "Additional Kubernetes resource fixtures")
+	lint.Flags().StringSlice("env", []string{}, "Environment variable(s) that test documents can refer to")
+	lint.Flags().String("rego-capabilities", "", "OPA capabilities file that restricts the Rego builtins checks can use")
+	lint.Flags().String("format", "text", "Lint problems output format [text, json]")
+	lint.Flags().Bool("warnings", true, "Check test documents for anti-patterns")
+	lint.Flags().Bool("fail-on-warnings", false, "Fail if any test document has a warning")
+
+	return CommandWithDefaults(lint)
+}
+
+func lintCmd(cmd *cobra.Command, args []string) error {
+	format := must.String(cmd.Flags().GetString("format"))
+	switch format {
+	case "text", "json":
+	default:
+		return ExitErrorf(EX_USAGE, "invalid lint output format %q", format)
+	}
+
+	if err := fixture.AddEcho(fixture.DefaultEchoImage); err != nil {
+		return err
+	}
+
+	if err := loadFixtures(
+		must.StringSlice(cmd.Flags().GetStringSlice("fixtures"))); err != nil {
+		return ExitError{Code: EX_NOINPUT, Err: err}
+	}
+
+	var opts []test.RunOpt
+	var problems []test.LintProblem
+
+	if names := must.StringSlice(cmd.Flags().GetStringSlice("env")); len(names) > 0 {
+		if err := validateEnvVars(names); err != nil {
+			return err
+		}
+
+		opts = append(opts, test.EnvVarsOpt(names))
+	}
+
+	var capabilities *ast.Capabilities
+	if path := must.String(cmd.Flags().GetString("rego-capabilities")); path != "" {
+		var err error
+		capabilities, err = loadCapabilities(path)
+		if err != nil {
+			return ExitError{Code: EX_NOINPUT, Err: err}
+		}
+
+		opts = append(opts, test.RegoCapabilitiesOpt(capabilities))
+	}
+
+	if policies := must.StringSlice(cmd.Flags().GetStringSlice("policies")); len(policies) > 0 {
+		modules, err := loadPolicies(policies, capabilities)
+		switch {
+		case err == nil:
+			for _, m := range modules {
+				opts = append(opts, test.RegoModuleOpt(m))
+			}
+		case utils.AsRegoCompilationErr(err) != nil:
+			// Report policy problems as well, since a document
+			// can't be linted without its policies.
+			for _, e := range utils.AsRegoCompilationErr(err) {
+				problems = append(problems, policyProblem(e))
+			}
+		default:
+			return ExitError{Code: EX_DATAERR, Err: err}
+		}
+	}
+
+	// Only lint the documents if the policies that they depend
+	// on are valid.
+	if len(problems) == 0 {
+		for _, path := range args {
+			testDoc, err := doc.ReadFile(path)
+			if err != nil {
+				problems = append(problems, test.LintProblem{
+					Location: path,
+					Message:  err.Error(),
+				})
+				continue
+			}
+
+			docProblems := test.Lint(testDoc, opts...)
+			if len(docProblems) == 0 && must.Bool(cmd.Flags().GetBool("warnings")) {
+				docProblems = test.LintAntiPatterns(testDoc)
+			}
+
+			problems = append(problems, docProblems...)
+		}
+	}
+
+	switch format {
+	case "json":
+		// Print an empty array rather than null.
+		if problems == nil {
+			problems = []test.LintProblem{}
+		}
+
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(problems); err != nil {
+			return err
+		}
+	default:
+		for _, p := range problems {
+			fmt.Println(p)
+		}
+	}
+
+	failOnWarnings := must.Bool(cmd.Flags().GetBool("fail-on-warnings"))
+	for _, p := range problems {
+		if !p.Warning || failOnWarnings {
+			return ExitError{Code: EX_FAIL}
+		}
+	}
+
+	return nil
+}
+
+// policyProblem returns the lint problem for a Rego error in a
+// policy module.
+func policyProblem(e *ast.Error) test.LintProblem {
+	p := test.LintProblem{
+		Message: fmt.Sprintf("%s: %s", e.Code, e.Message),
+	}
+
+	if e.Location != nil {
+		p.Location = fmt.Sprintf("%s:%d", e.Location.File, e.Location.Row)
+	}
+
+	return p
+}
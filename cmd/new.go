@@ -0,0 +1,167 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/projectcontour/integration-tester/pkg/must"
+
+	"github.com/spf13/cobra"
+)
+
+// NewNewCommand returns a command to create a skeleton test document.
+func NewNewCommand() *cobra.Command {
+	create := &cobra.Command{
+		Use:   "new [FLAGS ...] NAME",
+		Short: "Create a skeleton test document",
+		Long: `Create a skeleton test document
+
+The new command writes a skeleton test document to the file NAME.yaml
+(or to NAME, if it already has a ".yaml" or ".yml" extension). The
+skeleton contains a Kubernetes object fragment with an example '$check',
+and a standalone Rego fragment with example skip, error and fatal rules,
+so that a new test doesn't have to start from a blank file. The object
+is named after the base name of the file, and is created in the
+namespace given by the '--namespace' flag of the run command.
+
+An existing file is not overwritten unless the '--force' flag is given.
+`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return newCmd(cmd, args[0])
+		},
+	}
+
+	create.Flags().Bool("force", false, "Overwrite an existing test document")
+
+	return CommandWithDefaults(create)
+}
+
+// invalidObjectNameChars matches the characters that can't be used
+// in a Kubernetes object name.
+var invalidObjectNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// scaffoldObjectName returns the name of the skeleton object for the
+// test document at path.
+func scaffoldObjectName(path string) string {
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	name := strings.Trim(invalidObjectNameChars.ReplaceAllString(strings.ToLower(base), "-"), "-")
+	if name == "" {
+		name = "test"
+	}
+
+	return name
+}
+
+var scaffold = template.Must(template.New("scaffold").Parse(`# {{.Name}}: describe what this test checks.
+
+---
+# Objects that don't specify a namespace are created in the namespace
+# given by the --namespace flag (the "default" namespace by default).
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{.Name}}
+data:
+  greeting: hello
+# The $check is evaluated after the object is applied, and is retried
+# until it passes or the check timeout expires. Without a $check, the
+# default check verifies that the object was applied successfully.
+$check: |
+  fatal_apply_failed[msg] {
+    input.error
+    msg := sprintf("failed to apply ConfigMap: %s", [input.error.message])
+  }
+
+  error_wrong_greeting[msg] {
+    input.latest.data.greeting != "hello"
+    msg := sprintf("unexpected greeting %q", [input.latest.data.greeting])
+  }
+
+---
+# Standalone Rego checks are retried until they pass or the check
+# timeout expires. Rules whose names start with "skip", "error" or
+# "fatal" report the corresponding test result.
+
+Name := "{{.Name}}"
+
+Namespace := data.test.params.namespace
+
+# Objects in the default namespace are published at
+# data.resources.$RESOURCE, and other objects at
+# data.resources.$NAMESPACE.$RESOURCE.
+configmap = c {
+  Namespace == "default"
+  c := data.resources.configmaps[Name]
+}
+
+configmap = c {
+  Namespace != "default"
+  c := data.resources[Namespace].configmaps[Name]
+}
+
+# A skip result stops the test without failing it.
+skip_no_configmaps[msg] {
+  not data.resources.configmaps[".versions"]
+  msg := "the cluster does not serve ConfigMaps"
+}
+
+# An error result fails the check, but the test continues.
+error_configmap_missing[msg] {
+  not configmap
+  msg := sprintf("ConfigMap %s/%s is missing", [Namespace, Name])
+}
+
+# A fatal result fails the test and stops it immediately.
+fatal_configmap_not_managed[msg] {
+  configmap.metadata.labels["app.kubernetes.io/managed-by"] != "integration-tester"
+  msg := sprintf("ConfigMap %s/%s is not managed by the test", [Namespace, Name])
+}
+`))
+
+func newCmd(cmd *cobra.Command, name string) error {
+	path := name
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+	default:
+		path += ".yaml"
+	}
+
+	if _, err := os.Stat(path); err == nil && !must.Bool(cmd.Flags().GetBool("force")) {
+		return ExitErrorf(EX_CANTCREAT, "%s already exists", path)
+	}
+
+	var buf bytes.Buffer
+	if err := scaffold.Execute(&buf, struct{ Name string }{
+		Name: scaffoldObjectName(path),
+	}); err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil { // nolint(gosec)
+		return ExitError{Code: EX_CANTCREAT, Err: err}
+	}
+
+	fmt.Printf("created %s\n", path)
+	return nil
+}
@@ -0,0 +1,43 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/projectcontour/integration-tester/pkg/doc"
+	"github.com/projectcontour/integration-tester/pkg/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScaffoldObjectName(t *testing.T) {
+	assert.Equal(t, "httpproxy-tls", scaffoldObjectName("tests/HTTPProxy_TLS.yaml"))
+	assert.Equal(t, "echo", scaffoldObjectName("echo.yml"))
+	assert.Equal(t, "test", scaffoldObjectName("__.yaml"))
+}
+
+func TestScaffoldLints(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, scaffold.Execute(&buf, struct{ Name string }{Name: "echo"}))
+
+	d, err := doc.ReadDocument(&buf)
+	require.NoError(t, err)
+
+	d.Name = "echo.yaml"
+	assert.Empty(t, test.Lint(d))
+}
@@ -0,0 +1,195 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/projectcontour/integration-tester/pkg/doc"
+	"github.com/projectcontour/integration-tester/pkg/driver"
+	"github.com/projectcontour/integration-tester/pkg/fixture"
+	"github.com/projectcontour/integration-tester/pkg/must"
+	"github.com/projectcontour/integration-tester/pkg/test"
+	"github.com/projectcontour/integration-tester/pkg/utils"
+
+	"github.com/gosuri/uitable"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NewPreflightCommand returns a command to check whether test
+// documents can run against the current cluster.
+func NewPreflightCommand() *cobra.Command {
+	preflight := &cobra.Command{
+		Use:   "preflight [FLAGS ...] FILE [FILE ...]",
+		Short: "Check whether test documents are supported by a cluster",
+		Long: `Check whether test documents are supported by a cluster
+
+The preflight command reports whether each of the given test documents
+can run against the current Kubernetes cluster, without applying any
+objects. A document is not supported if the cluster does not serve the
+API group, version and kind of any of the objects in the document, or
+if any of the document's Rego skip rules trigger.
+
+Since no objects are created, skip rules are evaluated against the
+API server resource versions in 'data.resources' and against any
+parameters given with the '--param' flag. Any policies needed by
+the test documents can be given with the '--policies' flag.
+
+If the '--check-image-pull' flag is given, preflight first checks that
+the cluster can pull the image of the builtin echo server fixture (see
+the '--echo-image' flag), by running a pod that uses it. This makes
+test suites fail fast in environments that restrict image registries.
+
+The results are printed as a table, or as a JSON array if the
+'--format' flag is "json". The command fails if any document
+is not supported.
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				return ExitErrorf(EX_USAGE, "no test file(s)")
+			}
+
+			return preflightCmd(cmd, args)
+		},
+	}
+
+	preflight.Flags().StringArray("param", []string{}, "Additional Rego parameter(s) in key=value format")
+	preflight.Flags().StringSlice("policies", []string{}, "Additional Rego policy packages")
+	preflight.Flags().String("format", "table", "Preflight results output format")
+	preflight.Flags().String("echo-image", fixture.DefaultEchoImage, "Container image of the builtin echo server fixture")
+	preflight.Flags().Bool("check-image-pull", false, "Check that the cluster can pull the echo server image")
+
+	return CommandWithDefaults(preflight)
+}
+
+func preflightCmd(cmd *cobra.Command, args []string) error {
+	format := must.String(cmd.Flags().GetString("format"))
+	switch format {
+	case "table", "json":
+	default:
+		return ExitErrorf(EX_USAGE, "invalid preflight output format %q", format)
+	}
+
+	opts, err := validateParams(
+		must.StringSlice(cmd.Flags().GetStringArray("param")))
+	if err != nil {
+		return err
+	}
+
+	if policies := must.StringSlice(cmd.Flags().GetStringSlice("policies")); len(policies) > 0 {
+		modules, err := loadPolicies(policies, nil)
+		if err != nil {
+			return ExitError{Code: EX_DATAERR, Err: err}
+		}
+
+		for _, m := range modules {
+			opts = append(opts, test.RegoModuleOpt(m))
+		}
+	}
+
+	kube, err := driver.NewKubeClient()
+	if err != nil {
+		return fmt.Errorf("failed to initialize Kubernetes context: %s", err)
+	}
+
+	opts = append(opts, test.KubeClientOpt(kube))
+
+	echoImage := must.String(cmd.Flags().GetString("echo-image"))
+	if err := fixture.ValidateImage(echoImage); err != nil {
+		return ExitError{Code: EX_USAGE, Err: err}
+	}
+
+	// Skip rules can test the echo image, but it can be overridden
+	// by an explicit parameter.
+	opts = append([]test.RunOpt{test.RegoParamOpt("echo-image", echoImage)}, opts...)
+
+	if must.Bool(cmd.Flags().GetBool("check-image-pull")) {
+		if err := checkImagePull(kube, metav1.NamespaceDefault, echoImage); err != nil {
+			return ExitError{Code: EX_FAIL, Err: err}
+		}
+	}
+
+	var results []*test.PreflightResult
+
+	for _, path := range args {
+		testDoc, err := decodeDocument(path)
+		if err != nil {
+			return ExitError{Code: EX_DATAERR, Err: err}
+		}
+
+		res, err := test.Preflight(testDoc, opts...)
+		if err != nil {
+			return fmt.Errorf("preflight of %q failed: %w", path, err)
+		}
+
+		results = append(results, res)
+	}
+
+	switch format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			return err
+		}
+	default:
+		table := uitable.New()
+		table.AddRow("DOCUMENT", "SUPPORTED", "REASON")
+
+		for _, r := range results {
+			if len(r.Reasons) == 0 {
+				table.AddRow(r.Document, r.Supported, "")
+			}
+
+			for _, reason := range r.Reasons {
+				table.AddRow(r.Document, r.Supported, reason)
+			}
+		}
+
+		fmt.Println(table)
+	}
+
+	for _, r := range results {
+		if !r.Supported {
+			return ExitError{Code: EX_FAIL}
+		}
+	}
+
+	return nil
+}
+
+// decodeDocument reads the test document at path and decodes all
+// of its fragments.
+func decodeDocument(path string) (*doc.Document, error) {
+	testDoc, err := doc.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range testDoc.Parts {
+		if _, err := testDoc.Parts[i].Decode(); err != nil {
+			if regoErr := utils.AsRegoCompilationErr(err); regoErr != nil {
+				err = regoErr
+			}
+
+			return nil, fmt.Errorf("%s: lines %s: %s", path, testDoc.Parts[i].Location, err)
+		}
+	}
+
+	return testDoc, nil
+}
@@ -0,0 +1,169 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/projectcontour/integration-tester/pkg/doc"
+	"github.com/projectcontour/integration-tester/pkg/driver"
+	"github.com/projectcontour/integration-tester/pkg/fixture"
+	"github.com/projectcontour/integration-tester/pkg/must"
+	"github.com/projectcontour/integration-tester/pkg/utils"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+)
+
+// NewRenderCommand returns a command that prints the hydrated
+// Kubernetes objects of test documents.
+func NewRenderCommand() *cobra.Command {
+	render := &cobra.Command{
+		Use:   "render [FLAGS ...] FILE [FILE ...]",
+		Short: "Print the Kubernetes objects that test documents would apply",
+		Long: `Print the Kubernetes objects that test documents would apply
+
+The render command hydrates each Kubernetes object fragment of the
+given test documents in the same way as the run command, and prints
+the resulting objects as a YAML stream, without contacting a cluster.
+Hydration removes the special '$' fields, substitutes and renames
+fixtures, and injects the test run ID annotation and the managed-by
+label. This shows exactly which object a fragment applies.
+
+Each object is preceded by a comment that gives its location in the
+test document and the operation that would be performed. For patch
+operations, the JSON patch is printed instead of the object.
+
+Objects that are cloned from the cluster with 'fixture-from-cluster'
+or that rotate cluster Secrets are printed as they are given in the
+document. Objects without a namespace are printed without one, since
+the namespace that the run command defaults them to depends on
+whether the cluster serves the object's kind as a namespaced
+resource.
+
+The run ID that is injected can be given with the '--run-id' flag,
+so that the output is stable. Additional fixtures can be given with
+the '--fixtures' flag, and the environment variables that objects can
+refer to with the '--env' flag, in the same way as for the run command.
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				return ExitErrorf(EX_USAGE, "no test file(s)")
+			}
+
+			return renderCmd(cmd, args)
+		},
+	}
+
+	render.Flags().String("run-id", "", "Test run ID to inject into the objects")
+	render.Flags().StringSlice("fixtures", []string{}, "Additional Kubernetes resource fixtures")
+	render.Flags().StringSlice("env", []string{}, "Environment variable(s) that test documents can refer to")
+
+	return CommandWithDefaults(render)
+}
+
+func renderCmd(cmd *cobra.Command, args []string) error {
+	if err := fixture.AddEcho(fixture.DefaultEchoImage); err != nil {
+		return err
+	}
+
+	if err := loadFixtures(
+		must.StringSlice(cmd.Flags().GetStringSlice("fixtures"))); err != nil {
+		return ExitError{Code: EX_NOINPUT, Err: err}
+	}
+
+	opts := []driver.EnvironmentOpt{driver.OfflineOpt()}
+	if runID := must.String(cmd.Flags().GetString("run-id")); runID != "" {
+		opts = append(opts, driver.UniqueIDOpt(runID))
+	}
+
+	envVars := must.StringSlice(cmd.Flags().GetStringSlice("env"))
+	if err := validateEnvVars(envVars); err != nil {
+		return err
+	}
+
+	opts = append(opts, driver.EnvVarsOpt(envVars))
+
+	env := driver.NewEnvironment(opts...)
+
+	for _, path := range args {
+		testDoc, err := doc.ReadFile(path)
+		if err != nil {
+			return ExitError{Code: EX_NOINPUT, Err: err}
+		}
+
+		if err := renderDocument(os.Stdout, env, testDoc); err != nil {
+			return ExitError{Code: EX_DATAERR, Err: err}
+		}
+	}
+
+	return nil
+}
+
+// renderDocument writes the hydrated objects of the test document
+// to out.
+func renderDocument(out io.Writer, env driver.Environment, testDoc *doc.Document) error {
+	for i := range testDoc.Parts {
+		p := &testDoc.Parts[i]
+
+		fragType, err := p.Decode()
+		if err != nil {
+			if regoErr := utils.AsRegoCompilationErr(err); regoErr != nil {
+				return regoErr
+			}
+
+			return fmt.Errorf("%s: lines %s: %w", testDoc.Name, p.Location, err)
+		}
+
+		if fragType != doc.FragmentTypeObject {
+			continue
+		}
+
+		obj, err := env.HydrateObject(p.Bytes)
+		if err != nil {
+			return fmt.Errorf("%s: lines %s: failed to hydrate object: %w",
+				testDoc.Name, p.Location, err)
+		}
+
+		// Included fragments have the location of the
+		// document that they were read from.
+		loc := p.Location.String()
+		if p.Location.Filename == "" {
+			loc = fmt.Sprintf("%s:%s", testDoc.Name, loc)
+		}
+
+		fmt.Fprintf(out, "---\n# %s: %s\n", loc, obj.Operation)
+
+		data := obj.Patch
+		if obj.Operation != driver.ObjectOperationPatch {
+			data, err = yaml.Marshal(obj.Object.Object)
+			if err != nil {
+				return err
+			}
+		}
+
+		if _, err := out.Write(data); err != nil {
+			return err
+		}
+
+		if len(data) > 0 && data[len(data)-1] != '\n' {
+			fmt.Fprintln(out)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/projectcontour/integration-tester/pkg/doc"
+	"github.com/projectcontour/integration-tester/pkg/driver"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/yaml"
+)
+
+func TestRenderDocument(t *testing.T) {
+	d, err := doc.ReadDocument(strings.NewReader(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: greeting
+$check: |
+  error[msg] { false; msg := "never" }
+data:
+  message: hello
+---
+error[msg] { false; msg := "never" }
+`))
+	require.NoError(t, err)
+	d.Name = "test.yaml"
+
+	var out bytes.Buffer
+	env := driver.NewEnvironment(driver.OfflineOpt(), driver.UniqueIDOpt("render-test"))
+	require.NoError(t, renderDocument(&out, env, d))
+
+	parts := strings.SplitN(out.String(), "\n", 3)
+	require.Len(t, parts, 3)
+	assert.Equal(t, "---", parts[0])
+	assert.Equal(t, "# test.yaml:2-9: update", parts[1])
+
+	var obj map[string]interface{}
+	require.NoError(t, yaml.Unmarshal([]byte(parts[2]), &obj))
+
+	assert.NotContains(t, obj, "$check")
+	assert.Equal(t, map[string]interface{}{"message": "hello"}, obj["data"])
+
+	meta := obj["metadata"].(map[string]interface{})
+	assert.Equal(t, "render-test", meta["annotations"].(map[string]interface{})["integration-tester/run-id"])
+}
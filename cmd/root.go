@@ -29,6 +29,10 @@ const (
 	// EX_FAIL is an exit code indicating an unspecified error.
 	EX_FAIL ExitCode = 1 //nolint(golint)
 
+	// EX_CLEANUP means that all the tests passed, but some of
+	// the test objects could not be deleted.
+	EX_CLEANUP ExitCode = 3 //nolint(golint)
+
 	// EX_USAGE is an exit code indicating invalid invocation syntax.
 	EX_USAGE ExitCode = 65 //nolint(golint)
 
@@ -39,6 +43,14 @@ const (
 	// way.  This should only be used for user's data and not
 	// system files.
 	EX_DATAERR ExitCode = 65 //nolint(golint)
+
+	// EX_CANTCREAT means a (user specified) output file cannot
+	// be created.
+	EX_CANTCREAT ExitCode = 73 //nolint(golint)
+
+	// EX_INTERRUPTED means that the process stopped because
+	// it received SIGINT or SIGTERM (128 + SIGINT).
+	EX_INTERRUPTED ExitCode = 130 //nolint(golint)
 )
 
 // ExitError captures an ExitCode and its associated error message.
@@ -91,6 +103,20 @@ each Rego fragment to check test assertions.
 
 	root.AddCommand(NewRunCommand())
 	root.AddCommand(NewGetCommand())
+	root.AddCommand(NewPreflightCommand())
+	root.AddCommand(NewLintCommand())
+	root.AddCommand(NewFmtCommand())
+	root.AddCommand(NewGenerateCommand())
+	root.AddCommand(NewNewCommand())
+	root.AddCommand(NewEvalCommand())
+	root.AddCommand(NewCleanCommand())
+	root.AddCommand(NewRenderCommand())
+	root.AddCommand(NewDescribeCommand())
+	root.AddCommand(NewStatsCommand())
+	root.AddCommand(NewSelfTestCommand())
+	root.AddCommand(NewCompletionCommand())
+
+	registerCompletions(root)
 
 	return CommandWithDefaults(root)
 }
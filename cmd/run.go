@@ -15,23 +15,44 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
+	"github.com/projectcontour/integration-tester/pkg/anonymize"
+	"github.com/projectcontour/integration-tester/pkg/attest"
 	"github.com/projectcontour/integration-tester/pkg/builtin"
 	"github.com/projectcontour/integration-tester/pkg/doc"
 	"github.com/projectcontour/integration-tester/pkg/driver"
+	"github.com/projectcontour/integration-tester/pkg/external"
 	"github.com/projectcontour/integration-tester/pkg/fixture"
+	"github.com/projectcontour/integration-tester/pkg/lock"
 	"github.com/projectcontour/integration-tester/pkg/must"
+	"github.com/projectcontour/integration-tester/pkg/netproxy"
 	"github.com/projectcontour/integration-tester/pkg/result"
 	"github.com/projectcontour/integration-tester/pkg/test"
+	"github.com/projectcontour/integration-tester/pkg/upload"
 	"github.com/projectcontour/integration-tester/pkg/utils"
 	"github.com/projectcontour/integration-tester/pkg/version"
 
 	"github.com/open-policy-agent/opa/ast"
 	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 // NewRunCommand returns a command ro run a test case.
@@ -45,6 +66,15 @@ Test documents are ordered fragments of YAML object and Rego checks,
 separated by the YAML document separator, '---'. The fragments in the
 test document are executed sequentially.
 
+A fragment that contains only an '$include' key with a file path
+is replaced by the fragments of the document at that path. Relative
+paths are resolved from the directory of the including document.
+
+A fragment that contains only a '$kustomize' key with a directory path
+is replaced by the Kubernetes objects that 'kustomize build' would
+render from the kustomization in that directory. The kustomization is
+rendered by the built-in kustomize library.
+
 If a Kubernetes object specifies a target namespace in its metadata,
 integration-tester will implicitly create and manage that namespace.
 This reduces test verbosity be not requiring namespace YAML fragments.
@@ -61,28 +91,394 @@ integration-tester will delete that object. Otherwise, integration-tester
 will attempt to select an object to delete by matching the run ID and
 any specified labels.
 
+If the special '$apply' key contains a 'patch' list of RFC 6902 JSON
+patch operations, integration-tester applies the patch to the existing
+target Kubernetes object, which is named by the kind and name of the
+object fragment.
+
+If the special '$apply' key contains a 'fixture-from-cluster' object
+with a 'from' field, integration-tester clones the existing cluster
+object of the same kind that is named by the "namespace/name" in the
+'from' field, and merges the object fragment over the clone.
+
+integration-tester has a builtin echo server fixture, which is a
+Deployment and a Service named 'integration-tester-echo'. The
+'--echo-image' flag sets the container image of the fixture, e.g.
+to a mirror in an air-gapped registry, and the image is stored at
+'data.test.params["echo-image"]'. The '--check-image-pull' flag checks
+that the cluster can pull the image before any tests run.
+
+If the special '$apply' key is 'rotate', integration-tester
+regenerates the data of the named Secret, and stores a digest of
+the new data at 'data.test.secrets[namespace][name]'.
+
 Unless the '--preserve' flag is specified, integration-tester will
 automatically delete all the Kubernetes objects it created at the
 end of each test.
+If the '--preserve-on-failure' flag is specified, the objects of
+failed tests are not deleted, so that their state can be inspected.
+When a failing test is retried, only the objects of the final attempt
+are preserved.
+
+If the '--artifacts-dir' flag is given, integration-tester writes
+diagnostics for each failed test document to a separate directory
+below it, before the test objects are deleted. The diagnostics are
+the test objects, the recent Kubernetes Events in their namespaces,
+and the container logs of the test pods.
 
 Since both Kubernetes and the services in a cluster are eventually
 consistent, checks are executed repeatedly until they succeed or
-until the timeout given by the '--check-timeout' flag expires.
+until the timeout given by the '--check-timeout' flag expires. A
+failing check is first re-evaluated after the interval given by the
+'--check-interval' flag. The interval then doubles after each failure,
+up to 8 times the initial interval, and is randomly shortened by up
+to half so that checks don't evaluate in lockstep. A Rego fragment
+with a '# $check-interval:' comment sets the initial interval for all
+the checks in its test document.
+
+The '--step-delay' flag paces test documents by waiting for the given
+duration before each fragment of a test document, except the first.
+An object fragment with a special '$delay' key, or a Rego fragment with
+a '# $delay:' comment, waits for an additional duration before it
+runs. Delays are recorded as test steps, so they show in the results.
+
+The '--kind-rate' flag can be provided multiple times to limit the
+rate of operations on objects of a kind, in "KIND=RATE" format, where
+RATE is the number of operations per second. The '--bulk-apply' flag
+applies runs of at least the given number of consecutive plain object
+fragments of the same kind in a single test step, which reports its
+progress as it goes.
 
 The '--param' flag can be provided multiple times to add an element
 to the Rego data store. The argument to this flag is a "key=value"
 pair. The value is stored as 'data.test.params.key'.
 
+A Kubernetes object fragment with a special '$when' key, or a Rego
+fragment with a '# $when:' comment, only runs if the given Rego query
+is true. Otherwise the fragment is skipped. Conditions can refer to
+parameters and to the cluster data in the Rego data store.
+
+The '--rego-strict' flag applies additional checks to the Rego in test
+documents, policies and suite checks. Local variables that are declared
+but never used, local variables that shadow 'input', 'data', imports or
+rules, and calls to deprecated builtins are reported as errors when the
+test document is validated.
+
+The '--rego-capabilities' flag restricts the Rego builtins that checks,
+policies and suite checks can use to those listed in the given OPA
+capabilities file. Checks that use any other builtin (e.g. 'http.send'
+or 'opa.runtime') fail to compile.
+
 integration-tester will automatically watch resource types that are
 created in a test document and publish them into Rego checks in the
-'data.resources' tree. If a test needs to inspect more resources, the
-'--watch' flag can be provided multiple times to specify additional
-resource types to monitor and publish.
+'data.resources' tree. For well-known kinds, the resources that their
+controllers generate are also watched (e.g. pods and replicasets for a
+Deployment, endpoints and endpointslices for a Service). If a test needs
+to inspect more resources, the '--watch' flag can be provided multiple
+times to specify additional resource types to monitor and publish.
+
+The '--patch-history' flag records every observed change to objects
+of the given resource types as a JSON Patch array, and publishes the
+sequence of patches of each object at
+'data.history.patches[resource][namespace][name]'. The resource types
+are also watched.
+
+The '--external' flag can be provided multiple times to poll external
+(i.e. non-Kubernetes) HTTP endpoints that return JSON documents. The
+argument to this flag is a "name=URL" pair. Similarly, the
+'--external-prometheus' flag polls the result of a "name=query"
+Prometheus query against the server given by the '--prometheus-url'
+flag. External data sources are polled at the interval given by the
+'--external-interval' flag for the duration of each test document,
+and the data is stored as 'data.external.name'.
+
+External data sources and the 'tls.probe' Rego builtin honor the
+HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables. The
+'--probe-proxy' flag directs them all through the given proxy instead,
+which can be a SOCKS5 ("socks5://host:port") or an HTTP CONNECT
+("http://host:port") proxy. This is useful when the services under
+test are only reachable through a bastion host. Connections to the
+Kubernetes API server always use the kubeconfig proxy settings.
+
+The '--budget' flag can be provided multiple times to limit the
+resources that the objects created by each test document may request.
+The argument to this flag is a "name=quantity" pair, where the name is
+either 'objects' to limit the number of objects, or the name of a
+compute resource (e.g. 'cpu' or 'memory') to limit the cumulative
+resource requests of the pods that the objects create. Objects that
+would exceed the budget are not created, and the test document fails.
+
+The '--check-io-budget' flag can be provided multiple times to limit
+the external I/O that the builtins of each check, such as 'http.send'
+and 'tls.probe', can perform across all the evaluations of the check.
+The argument to this flag is a "name=value" pair, where the name is
+'requests' to limit the number of builtin calls, 'bytes' to limit the
+size of the responses (e.g. '10Mi'), or 'time' to limit the time spent
+waiting for them (e.g. '30s'). A builtin call that would exceed the
+budget fails the check with an error.
+
+The '--env' flag can be provided multiple times to name the
+environment variables that test documents can refer to. References
+to these variables, written as "${NAME}", are substituted in the
+values of each object after it is parsed, and a literal "${" is
+written as "$${".
+A reference to a variable that is not named by the flag, or that is
+not set, fails the object. If the flag is not given, objects are not
+substituted.
+
+The '--suite-checks' flag can be provided multiple times to specify
+Rego files or directories of suite checks. Suite checks are evaluated
+once, after all the test documents have run. The final resources and
+the results of each test document are published to suite checks in
+the 'data.suite.documents' array.
+
+The '--snapshot' flag writes a JSON snapshot of the final resources
+and the results of each test document to the given file. If the
+'--anonymize' flag is also specified, identifying data is scrubbed
+from the snapshot so that it can be shared in bug reports. The names
+of the current Kubernetes context and cluster, the API server address
+and all IP addresses are replaced with consistent placeholders, and
+the data in Secrets is redacted.
+
+The '--artifacts-upload' flag uploads the directory given by the
+'--artifacts-dir' flag to a cloud object store when the test run
+fails, so that the artifacts are kept when the CI host is not. Point
+other output flags (e.g. '--log-file' or '--snapshot') into the
+artifacts directory to include them. The upload destination is an
+'s3://bucket/prefix', 'gs://bucket/prefix' or
+'azblob://container/prefix' URL. The directory is uploaded as a
+single gzipped tar file that is named after the run ID, and its URL
+is printed with the summary. The object store credentials are taken
+from the environment: AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+AWS_SESSION_TOKEN for S3 (in the AWS_REGION region), an OAuth2
+access token in GOOGLE_OAUTH_ACCESS_TOKEN for GCS, and a shared
+access signature in AZURE_STORAGE_SAS_TOKEN for the Azure storage
+account given by AZURE_STORAGE_ACCOUNT.
+
+If the test run is interrupted by SIGINT or SIGTERM, the current test
+document stops at its current step and deletes its objects (unless the
+'--preserve' flag is given), no further test documents are run, and the
+results so far are written. The exit status is 130. A second signal
+exits immediately, without cleaning up.
+
+If a test document passes, but some of its objects can't be deleted,
+each object is reported as an error of the cleanup step, and the
+summary marks the document as "CLEANUP FAILED". If no test failed
+otherwise, the exit status is 3 rather than 1.
+
+The '--dry-run' flag applies, patches and deletes Kubernetes objects
+with server-side dry-run requests. The API server validates and admits
+the objects, including running any admission webhooks, but doesn't
+persist them. Checks are evaluated against the objects that the API
+server returns, and 'data.test.params["dry-run"]' is true so that
+checks that need persisted objects can be skipped. Since implicit
+namespaces are not created either, the namespaces that objects use
+must already exist.
+
+The '--namespace' flag sets the namespace that namespaced Kubernetes
+objects are created in when the test document doesn't specify one.
+The namespace is implicitly created if it doesn't exist. Objects in
+this namespace are stored in the Rego data document at the paths that
+are normally used for the 'default' namespace, so checks that look up
+objects by name work with any namespace, and objects in the 'default'
+namespace are stored under 'data.resources.default' instead. The
+namespace is also available as 'data.test.params.namespace'.
+
+The '--watch-mode' flag speeds up local test development. After the
+test documents have been run, they are watched for changes (polling
+at the '--watch-interval'), and each document that changes is run
+again, until the command is interrupted. Re-runs reuse the Kubernetes
+client and API discovery cache of the initial run. Watch mode only
+supports the 'tree' output format.
+
+The '--daemon-interval' flag continuously verifies a long-lived
+cluster. After the test documents have been run, they are all run
+again at the given interval, until the command is interrupted. After
+each run, only the changes in the outcomes of the test documents are
+reported: documents that start failing, and documents that recover.
+The '--daemon-state' flag keeps the last known state of each document,
+including its last passing run, in the given file, so that a restarted
+daemon doesn't report the same failures again. The '--notify-webhook'
+flag can be provided multiple times to post the changes as JSON to
+the given URLs. Daemon mode only supports the 'tree' output format.
+
+The '--rule-severity' flag can be provided multiple times to map
+additional Rego rule names to test result severities, so that checks
+can use the rule naming conventions of existing Rego libraries. The
+argument to this flag is a "name=severity" pair, where the severity is
+one of 'error', 'fatal', 'skip' or 'none' (which treats the rule like
+'check'). For example, '--rule-severity deny=error' causes any 'deny'
+or 'deny_*' rules to raise test errors. Names that the builtin rules
+already match (e.g. 'error' or 'skip_old') can't be remapped.
+
+The '--policy-lock' flag makes test runs reproducible with respect to
+shared Rego policy libraries. The SHA-256 digest of each file loaded
+with the '--policies' flag is compared with the digest recorded in the
+lock file, and the run fails if any policy file was changed, added or
+removed. The '--update-policy-lock' flag writes the current digests to
+the lock file instead. The policy digests are also recorded in the
+'json' and 'junit' results formats.
 
 The test results output format can be changed by the '--format' flag.
 The default format is 'tree', which is a custom hierarchical format
 suitable for terminals. The "tap" format emits TAP (Test Anything
-Protocol) results.
+Protocol) results for the whole test run as a single TAP stream. With
+'--tap-bail-out', the TAP stream is stopped at the first fatal error
+and no further tests are run. The "json" format writes the test documents, steps
+and results, with their timestamps and durations, and the objects that
+each document operated on, as a single JSON object at the end of the
+test run. The "junit" format writes a JUnit
+XML report at the end of the test run, with a test suite for each
+test document and a test case for each step. The "progress" format
+shows a single status line with the number of completed documents,
+the current step and the elapsed time, for interactive use. The "dots"
+format writes a single character for each step ('.' for a pass, 'F'
+for an error, 'E' for a fatal error and 'S' for a skip), for compact CI
+logs. Both compact formats list the failures at the end of the run.
+The "conformance" format writes a Gateway API conformance report (see
+below). In all formats, each test step is labeled with a
+stable ID that is derived from the test document path and the position
+or name of the object in the document.
+
+The test results are written to standard output, unless the '--output'
+flag gives a file to write them to.
+
+The "conformance" format writes a Gateway API ConformanceReport YAML
+document. Test documents are tagged with the Gateway API features that
+they test by a '# $conformance-features: A, B' comment in a Rego
+fragment, and each tagged document is reported as a conformance test.
+Documents that only test the core Gateway, HTTPRoute and
+ReferenceGrant features are core tests, and the others are extended
+tests. Untagged documents are not reported. The '--conformance' flag
+sets the report fields in key=value format. The keys are
+'organization', 'project', 'url', 'version', 'contact' (which can be
+given multiple times), 'profile' (default "GATEWAY-HTTP"),
+'gateway-api-version' and 'gateway-api-channel'. The
+'--conformance-import' flag reads an existing report, for example one
+written by the upstream conformance suite, and merges the test
+document results into it.
+
+The '--format' flag can be provided multiple times to write the test
+results in several formats in a single run. A "format=path" argument
+writes the format to the given file, e.g. '--format junit=report.xml
+--format json=results.json'. At most one format can be given without a
+path; it is written to the output, and defaults to 'tree'.
+
+The '--log-file' flag writes a copy of the test results to the given
+file, in addition to the output. The log file uses the same format as
+the output, unless the '--log-format' flag gives a different format.
+
+The '--results-dir' flag keeps a JSON results file for each test run
+in the given directory. The file is named after the run ID, or after
+the start time of the run if no run ID was given, and can be shown
+later with the describe command.
+
+The '--history' flag appends the outcome of each test document to the
+given history file, one JSON object per line. The stats command
+reports the pass rates and recent failures of the test documents in
+the history file.
+
+The '--attestation' flag writes a signed in-toto attestation of the
+test run to the given file, so that release pipelines can verify that
+a build passed its tests. The attestation is a DSSE envelope whose
+subjects are the SHA-256 digests of the test documents, and whose
+predicate records the tester version, the run ID, a fingerprint of
+the cluster and the outcome of each test document. It is signed with
+the Ed25519 private key given by the '--attestation-key' flag, which
+can be generated with "openssl genpkey -algorithm ed25519".
+
+By default, the 'tree' format shows each test step and any failures,
+but not the informational messages that steps record. Use '-v' to
+show all the messages, or '--quiet' to only show the steps that did
+not pass.
+
+The 'tree' format uses terminal colors to highlight errors, skips and
+passes. By default, colors are used when the output is a terminal and
+the NO_COLOR environment variable is not set. Use '--color=always' or
+'--color=never' to override this.
+
+To bound the memory and the size of the test results, long result
+messages are truncated to the size given by '--max-message-size', and
+each test step records at most the number of results given by
+'--max-step-results'. When results are dropped, the number of dropped
+results is recorded at the end of the step with the severity of the
+most severe dropped result.
+
+Consecutive identical informational messages are collapsed into a
+single line followed by a repeat count, so that checks which poll for
+a long time don't flood the output. Use '--coalesce=false' to show
+every message.
+
+Each test document is run with a random run ID, which is annotated
+on every Kubernetes object that the test creates. Use '--run-id' to
+run all the test documents with the given ID, so that the test run
+can be correlated with other systems. The '--run-id-from-ci' flag
+derives the run ID from the build ID environment variables of common
+CI systems (GitHub Actions, GitLab, Buildkite, CircleCI, Travis, Prow
+and Jenkins).
+
+The '--parallel' flag runs up to the given number of test documents
+concurrently. Each document has its own Rego store, object tracking and
+run ID. The results of each document are reported together when the
+document completes, so the documents may be reported in a different
+order than they were given. Since concurrent documents share the
+cluster, they should not create objects with the same names, and they
+should not be given a shared '--run-id'. Concurrent documents also
+share the API discovery cache, which is refreshed whenever a document
+changes a CustomResourceDefinition or an APIService.
+
+The '--count' flag runs all the test documents the given number of
+times, which can help to shake out flaky controller behavior. The
+results of each iteration are reported separately, with the iteration
+number appended to the test document name, and a summary
+shows how many iterations of each test document passed. Every
+iteration uses a fresh run ID. If '--run-id' is given, the iteration
+number is appended to it.
+
+The '--retries' flag re-runs a failing test document up to the given
+number of times. Each retry starts after the failed attempt has
+cleaned up its objects, and uses a fresh run ID. If '--run-id' is
+given, the retry number is appended to it. A document that passes
+after a retry is reported as a flaky pass, and the failures of the
+retried attempts don't fail the test run. The 'json' format marks
+retried attempts, and the 'junit' format omits them. The 'tap' format
+has already reported the failed steps of a retried attempt, but they
+don't affect the exit status.
+
+The '--shuffle' flag runs the test documents in a random order, to
+catch documents that depend on objects or state left behind by other
+documents. The random seed is printed to standard error, and the same
+order can be replayed by passing the seed to the '--seed' flag. When
+'--count' is given, each iteration is shuffled separately.
+
+The '--shard-count' and '--shard-index' flags split the test documents
+into the given number of shards, and only run the documents in the
+shard with the given (zero-based) index. The documents are sorted by
+path and assigned to shards in turn, so every shard gets a similar
+number of documents, and the shards are the same regardless of the
+order that the documents are given in. This lets a large test suite be
+split across CI workers by running the same command on each worker
+with a different shard index.
+
+The '--tag' flag only runs the test documents whose front-matter has
+one of the given tags. The front-matter is an optional leading
+'$meta' fragment that can also give the document a name, which the
+test results are recorded with instead of the document path.
+
+When more than one test document is run, integration-tester prints a
+summary of the results, followed by a breakdown of the time spent
+evaluating the test documents, applying Kubernetes objects and waiting
+for checks to succeed, and the slowest test steps. The '--slowest' flag
+sets the number of slowest steps that are reported, and also enables
+the report for a single test document. Step timings are also included
+in the '--snapshot' output.
+
+If the Kubernetes API server throttles requests with a "429 Too Many
+Requests" response, all further API requests wait for the backoff that
+the server asks for (or an exponential backoff of up to 30s), so that
+the test run as a whole eases the load on the API server. The number
+of throttled requests and the total backoff are reported as a warning
+at the end of the test run.
 `,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if len(args) == 0 {
@@ -95,20 +491,132 @@ Protocol) results.
 
 	run.Flags().String("trace", "", "Set execution tracing flags")
 	run.Flags().Bool("preserve", false, "Don't automatically delete Kubernetes objects")
-	run.Flags().Bool("dry-run", false, "Don't actually create Kubernetes objects")
+	run.Flags().Bool("preserve-on-failure", false, "Don't automatically delete Kubernetes objects of failed test documents")
+	run.Flags().String("artifacts-dir", "", "Write diagnostics for failed test documents to the given directory")
+	run.Flags().String("artifacts-upload", "", "Object store URL (s3://, gs:// or azblob://) to upload the artifacts directory to")
+	run.Flags().Bool("watch-mode", false, "Re-run test documents when they change")
+	run.Flags().Duration("watch-interval", time.Second, "Polling interval for changed test documents in watch mode")
+	run.Flags().Duration("daemon-interval", 0, "Re-run the test documents at this interval, reporting changes in their outcomes (0 disables)")
+	run.Flags().String("daemon-state", "", "Keep the last known state of each test document in the given file in daemon mode")
+	run.Flags().StringArray("notify-webhook", []string{}, "URL(s) to post changes in test document outcomes to in daemon mode")
+	run.Flags().Bool("dry-run", false, "Validate Kubernetes objects with server-side dry-run instead of creating them")
+	run.Flags().String("namespace", metav1.NamespaceDefault, "Namespace for Kubernetes objects that don't specify one")
 	run.Flags().Duration("check-timeout", time.Second*30, "Timeout for evaluating check steps")
+	run.Flags().Duration("check-interval", test.DefaultCheckInterval, "Initial interval between evaluations of a failing check")
+	run.Flags().Duration("step-delay", 0, "Delay before each test document fragment after the first")
+	run.Flags().StringArray("kind-rate", []string{}, "Maximum operations per second on objects of a kind, in KIND=RATE format")
+	run.Flags().Int("bulk-apply", 0, "Apply runs of at least this many consecutive objects of the same kind in one step (0 disables)")
 	run.Flags().StringArray("param", []string{}, "Additional Rego parameter(s) in key=value format")
 	run.Flags().StringSlice("watch", []string{}, "Additional Kubernetes resources to monitor")
+	run.Flags().StringSlice("patch-history", []string{}, "Kubernetes resources to record the changes of as JSON Patch arrays")
 	run.Flags().StringSlice("fixtures", []string{}, "Additional Kubernetes resource fixtures")
+	run.Flags().StringSlice("env", []string{}, "Environment variable(s) that test documents can refer to")
+	run.Flags().String("echo-image", fixture.DefaultEchoImage, "Container image of the builtin echo server fixture")
+	run.Flags().Bool("check-image-pull", false, "Check that the cluster can pull the echo server image before running tests")
 	run.Flags().StringSlice("policies", []string{}, "Additional Rego policy packages")
-	run.Flags().String("format", "tree", "Test results output format")
+	run.Flags().String("policy-lock", "", "Verify Rego policy files against the digests in the given lock file")
+	run.Flags().Bool("update-policy-lock", false, "Write the current Rego policy file digests to the policy lock file")
+	run.Flags().Bool("rego-strict", false, "Apply strict checks when compiling Rego")
+	run.Flags().StringArray("rule-severity", []string{}, "Additional Rego rule name(s) to treat as test results in name=severity format")
+	run.Flags().String("rego-capabilities", "", "OPA capabilities file that restricts the Rego builtins checks can use")
+	run.Flags().StringArray("external", []string{}, "External HTTP JSON endpoint(s) to poll in name=URL format")
+	run.Flags().StringArray("external-prometheus", []string{}, "Prometheus queries to poll in name=query format")
+	run.Flags().String("prometheus-url", "", "Prometheus server URL for external queries")
+	run.Flags().String("probe-proxy", "", "Proxy URL for external sources and TLS probes (socks5, socks5h, http or https)")
+	run.Flags().Duration("external-interval", external.DefaultInterval, "Polling interval for external data sources")
+	run.Flags().StringSlice("budget", []string{}, "Resource budget limit(s) for each test document in name=quantity format")
+	run.Flags().StringSlice("check-io-budget", []string{}, "External I/O budget limit(s) for each check in name=value format")
+	run.Flags().StringSlice("suite-checks", []string{}, "Rego checks to run after all test documents")
+	run.Flags().String("snapshot", "", "Write a JSON snapshot of the test run to the given file")
+	run.Flags().Bool("anonymize", false, "Scrub identifying data from the test run snapshot")
+	run.Flags().StringArray("format", []string{"tree"}, "Test results output format, or format=path to also write a format to a file")
+	run.Flags().StringP("output", "o", "", "Write test results to the given file instead of standard output")
+	run.Flags().String("log-file", "", "Also write test results to the given file")
+	run.Flags().String("results-dir", "", "Keep a JSON results file for each test run in the given directory")
+	run.Flags().String("history", "", "Append the outcome of each test document to the given history file")
+	run.Flags().String("attestation", "", "Write a signed in-toto attestation of the test run to the given file")
+	run.Flags().String("attestation-key", "", "Ed25519 private key (PEM encoded PKCS #8) to sign the attestation with")
+	run.Flags().StringArray("conformance", []string{}, "Conformance report field(s) in key=value format")
+	run.Flags().String("conformance-import", "", "Conformance report to merge into the conformance format results")
+	run.Flags().String("log-format", "", "Test results format for the log file (default is the output format)")
+	run.Flags().String("run-id", "", "Test run ID to label Kubernetes objects and test results with")
+	run.Flags().Bool("run-id-from-ci", false, "Derive the test run ID from CI environment variables")
+	run.Flags().String("color", "auto", "Colorize tree output [auto, always, never]")
+	run.Flags().CountP("verbose", "v", "Show informational messages in tree output")
+	run.Flags().Bool("quiet", false, "Only show failed test steps in tree output")
+	run.Flags().Bool("tap-bail-out", false, "Stop the TAP output at the first fatal error")
+	run.Flags().Bool("coalesce", true, "Collapse consecutive identical output messages")
+	run.Flags().Int("max-message-size", test.DefaultResultLimits.MaxMessageSize,
+		"Maximum size in bytes of a test result message (0 is unlimited)")
+	run.Flags().Int("max-step-results", test.DefaultResultLimits.MaxStepResults,
+		"Maximum number of results recorded for each test step (0 is unlimited)")
+	run.Flags().Int("count", 1, "Number of times to run each test document")
+	run.Flags().Int("retries", 0, "Number of times to retry a failing test document")
+	run.Flags().Int("parallel", 1, "Number of test documents to run concurrently")
+	run.Flags().Bool("shuffle", false, "Run the test documents in a random order")
+	run.Flags().Int64("seed", 0, "Random seed for the order of shuffled test documents (implies --shuffle)")
+	run.Flags().StringSlice("tag", []string{}, "Only run test documents that have one of the given front-matter tags")
+	run.Flags().Int("shard-count", 1, "Number of shards to split the test documents into")
+	run.Flags().Int("shard-index", 0, "Index of the shard of test documents to run")
+	run.Flags().Int("slowest", 5, "Number of slowest test steps to report in the summary")
 
 	return CommandWithDefaults(run)
 }
 
 func runCmd(cmd *cobra.Command, args []string) error {
+	args, err := shardDocuments(args,
+		must.Int(cmd.Flags().GetInt("shard-index")),
+		must.Int(cmd.Flags().GetInt("shard-count")))
+	if err != nil {
+		return ExitError{Code: EX_USAGE, Err: err}
+	}
+
+	metas := readDocumentMetas(args)
+
+	if tags := must.StringSlice(cmd.Flags().GetStringSlice("tag")); len(tags) > 0 {
+		args = filterDocumentTags(args, metas, tags)
+		if len(args) == 0 {
+			return ExitErrorf(EX_NOINPUT, "no test documents are tagged with %s", strings.Join(tags, ", "))
+		}
+	}
+
+	count := must.Int(cmd.Flags().GetInt("count"))
+	if count < 1 {
+		return ExitErrorf(EX_USAGE, "invalid iteration count %d", count)
+	}
+
+	retries := must.Int(cmd.Flags().GetInt("retries"))
+	if retries < 0 {
+		return ExitErrorf(EX_USAGE, "invalid retry count %d", retries)
+	}
+
+	checkInterval := must.Duration(cmd.Flags().GetDuration("check-interval"))
+	if checkInterval <= 0 {
+		return ExitErrorf(EX_USAGE, "invalid check interval %s", checkInterval)
+	}
+
+	stepDelay := must.Duration(cmd.Flags().GetDuration("step-delay"))
+	if stepDelay < 0 {
+		return ExitErrorf(EX_USAGE, "invalid step delay %s", stepDelay)
+	}
+
+	preserveOnFailure := must.Bool(cmd.Flags().GetBool("preserve-on-failure"))
+	if preserveOnFailure && must.Bool(cmd.Flags().GetBool("preserve")) {
+		return ExitErrorf(EX_USAGE, "the --preserve and --preserve-on-failure flags are mutually exclusive")
+	}
+
+	namespace := must.String(cmd.Flags().GetString("namespace"))
+	if err := validateNamespace(namespace); err != nil {
+		return ExitError{Code: EX_USAGE, Err: err}
+	}
+
 	traceFlags := strings.Split(must.String(cmd.Flags().GetString("trace")), ",")
 
+	echoImage := must.String(cmd.Flags().GetString("echo-image"))
+	if err := fixture.AddEcho(echoImage); err != nil {
+		return ExitError{Code: EX_USAGE, Err: err}
+	}
+
 	if err := loadFixtures(
 		must.StringSlice(cmd.Flags().GetStringSlice("fixtures"))); err != nil {
 		return ExitError{Code: EX_NOINPUT, Err: err}
@@ -125,29 +633,190 @@ func runCmd(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to initialize Kubernetes context: %s", err)
 	}
 
-	var recorder test.Recorder
+	runID, err := validateRunID(
+		must.String(cmd.Flags().GetString("run-id")),
+		must.Bool(cmd.Flags().GetBool("run-id-from-ci")))
+	if err != nil {
+		return ExitError{Code: EX_USAGE, Err: err}
+	}
+
+	out := os.Stdout
+	if path := must.String(cmd.Flags().GetString("output")); path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return ExitError{Code: EX_CANTCREAT, Err: err}
+		}
 
-	switch must.String(cmd.Flags().GetString("format")) {
-	case "tree":
-		recorder = test.StackRecorders(&test.TreeWriter{}, test.DefaultRecorder)
-	case "tap":
-		recorder = test.StackRecorders(&test.TapWriter{}, test.DefaultRecorder)
-	default:
-		return ExitErrorf(EX_USAGE, "invalid test output format %q",
-			must.String(cmd.Flags().GetString("format")))
+		defer f.Close()
+		out = f
+	}
+
+	format, formatFiles, err := validateFormats(
+		must.StringSlice(cmd.Flags().GetStringArray("format")))
+	if err != nil {
+		return ExitError{Code: EX_USAGE, Err: err}
+	}
+
+	watchMode := must.Bool(cmd.Flags().GetBool("watch-mode"))
+	if watchMode && format != "tree" {
+		return ExitErrorf(EX_USAGE, "the --watch-mode flag requires the tree output format")
+	}
+
+	if watchMode && must.Duration(cmd.Flags().GetDuration("watch-interval")) <= 0 {
+		return ExitErrorf(EX_USAGE, "invalid watch interval %s",
+			must.Duration(cmd.Flags().GetDuration("watch-interval")))
+	}
+
+	daemonInterval := must.Duration(cmd.Flags().GetDuration("daemon-interval"))
+	switch {
+	case daemonInterval < 0:
+		return ExitErrorf(EX_USAGE, "invalid daemon interval %s", daemonInterval)
+	case daemonInterval > 0 && watchMode:
+		return ExitErrorf(EX_USAGE, "the --daemon-interval and --watch-mode flags are mutually exclusive")
+	case daemonInterval > 0 && format != "tree":
+		return ExitErrorf(EX_USAGE, "the --daemon-interval flag requires the tree output format")
+	case daemonInterval > 0 && count > 1:
+		return ExitErrorf(EX_USAGE, "the --daemon-interval flag can't be used with the --count flag")
+	}
+
+	writer, err := newResultWriter(cmd, format, out, runID, len(args)*count)
+	if err != nil {
+		return err
+	}
+
+	writers := []*resultWriter{writer}
+
+	var drift *driftReporter
+	if daemonInterval > 0 {
+		drift, err = newDriftReporter(
+			must.String(cmd.Flags().GetString("daemon-state")),
+			must.StringSlice(cmd.Flags().GetStringArray("notify-webhook")))
+		if err != nil {
+			return err
+		}
+
+		j := &test.JSONWriter{RunID: runID}
+
+		writers = append(writers, &resultWriter{
+			Recorder: j,
+			json:     j,
+			finish: func() error {
+				return drift.report(test.HistoryEntries(j))
+			},
+		})
+	}
+
+	for _, ff := range formatFiles {
+		f, err := os.Create(ff.path)
+		if err != nil {
+			return ExitError{Code: EX_CANTCREAT, Err: err}
+		}
+
+		defer f.Close()
+
+		fileWriter, err := newResultWriter(cmd, ff.format, f, runID, len(args)*count)
+		if err != nil {
+			return err
+		}
+
+		writers = append(writers, fileWriter)
+	}
+
+	if path := must.String(cmd.Flags().GetString("log-file")); path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return ExitError{Code: EX_CANTCREAT, Err: err}
+		}
+
+		defer f.Close()
+
+		logFormat := must.String(cmd.Flags().GetString("log-format"))
+		if logFormat == "" {
+			logFormat = format
+		}
+
+		logWriter, err := newResultWriter(cmd, logFormat, f, runID, len(args)*count)
+		if err != nil {
+			return err
+		}
+
+		writers = append(writers, logWriter)
+	}
+
+	if dir := must.String(cmd.Flags().GetString("results-dir")); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return ExitError{Code: EX_CANTCREAT, Err: err}
+		}
+
+		f, err := os.Create(filepath.Join(dir, resultsFileName(runID, time.Now())))
+		if err != nil {
+			return ExitError{Code: EX_CANTCREAT, Err: err}
+		}
+
+		defer f.Close()
+
+		resultsWriter, err := newResultWriter(cmd, "json", f, runID, len(args)*count)
+		if err != nil {
+			return err
+		}
+
+		writers = append(writers, resultsWriter)
+	}
+
+	if path := must.String(cmd.Flags().GetString("history")); path != "" {
+		j := &test.JSONWriter{RunID: runID}
+
+		writers = append(writers, &resultWriter{
+			Recorder: j,
+			json:     j,
+			finish: func() error {
+				if err := test.AppendHistory(path, test.HistoryEntries(j)); err != nil {
+					return ExitError{Code: EX_CANTCREAT, Err: err}
+				}
+
+				return nil
+			},
+		})
+	}
+
+	if path := must.String(cmd.Flags().GetString("attestation")); path != "" {
+		attestWriter, err := newAttestationWriter(cmd, path, kube, runID, args)
+		if err != nil {
+			return err
+		}
+
+		writers = append(writers, attestWriter)
+	}
+
+	recorder := test.DefaultRecorder
+	for i := len(writers) - 1; i >= 0; i-- {
+		recorder = test.StackRecorders(writers[i], recorder)
 	}
 
 	summary := &test.SummaryWriter{}
 	recorder = test.StackRecorders(summary, recorder)
 
+	// Stop the test run on SIGINT or SIGTERM, so that the current
+	// test document can clean up its objects.
+	interrupt := handleInterrupts()
+
 	opts := []test.RunOpt{
 		test.KubeClientOpt(kube),
-		test.RecorderOpt(recorder),
 		test.CheckTimeoutOpt(must.Duration(cmd.Flags().GetDuration("check-timeout"))),
+		test.CheckIntervalOpt(checkInterval),
+		test.StepDelayOpt(stepDelay),
+		test.InterruptOpt(interrupt),
 	}
 
+	// Publish the echo image first, so that it can be overridden
+	// by an explicit parameter.
+	opts = append(opts, test.RegoParamOpt("echo-image", echoImage))
 	opts = append(opts, paramOpts...)
 
+	if runID != "" {
+		opts = append(opts, test.RunIDOpt(runID))
+	}
+
 	if must.Bool(cmd.Flags().GetBool("preserve")) {
 		opts = append(opts, test.PreserveObjectsOpt())
 	}
@@ -156,6 +825,73 @@ func runCmd(cmd *cobra.Command, args []string) error {
 		opts = append(opts, test.DryRunOpt())
 	}
 
+	opts = append(opts, test.NamespaceOpt(namespace))
+
+	artifactsDir := must.String(cmd.Flags().GetString("artifacts-dir"))
+	if artifactsDir != "" {
+		opts = append(opts, test.ArtifactsDirOpt(artifactsDir))
+	}
+
+	if err := netproxy.SetProbeProxy(must.String(cmd.Flags().GetString("probe-proxy"))); err != nil {
+		return ExitError{Code: EX_USAGE, Err: err}
+	}
+
+	sources, err := validateExternalSources(
+		must.StringSlice(cmd.Flags().GetStringArray("external")),
+		must.StringSlice(cmd.Flags().GetStringArray("external-prometheus")),
+		must.String(cmd.Flags().GetString("prometheus-url")))
+	if err != nil {
+		return ExitError{Code: EX_USAGE, Err: err}
+	}
+
+	if len(sources) > 0 {
+		opts = append(opts, test.ExternalSourcesOpt(
+			must.Duration(cmd.Flags().GetDuration("external-interval")), sources...))
+	}
+
+	if rates := must.StringSlice(cmd.Flags().GetStringArray("kind-rate")); len(rates) > 0 {
+		parsed, err := test.ParseKindRates(rates)
+		if err != nil {
+			return ExitError{Code: EX_USAGE, Err: err}
+		}
+
+		opts = append(opts, test.KindPacerOpt(test.NewKindPacer(parsed)))
+	}
+
+	if n := must.Int(cmd.Flags().GetInt("bulk-apply")); n != 0 {
+		if n < 2 {
+			return ExitErrorf(EX_USAGE, "invalid bulk apply count %d", n)
+		}
+
+		opts = append(opts, test.BulkApplyOpt(n))
+	}
+
+	if limits := must.StringSlice(cmd.Flags().GetStringSlice("budget")); len(limits) > 0 {
+		budget, err := test.ParseBudget(limits)
+		if err != nil {
+			return ExitError{Code: EX_USAGE, Err: err}
+		}
+
+		opts = append(opts, test.BudgetOpt(budget))
+	}
+
+	if limits := must.StringSlice(cmd.Flags().GetStringSlice("check-io-budget")); len(limits) > 0 {
+		budget, err := driver.ParseIOBudget(limits)
+		if err != nil {
+			return ExitError{Code: EX_USAGE, Err: err}
+		}
+
+		opts = append(opts, test.IOBudgetOpt(budget))
+	}
+
+	if names := must.StringSlice(cmd.Flags().GetStringSlice("env")); len(names) > 0 {
+		if err := validateEnvVars(names); err != nil {
+			return err
+		}
+
+		opts = append(opts, test.EnvVarsOpt(names))
+	}
+
 	if utils.ContainsString(traceFlags, "rego") {
 		opts = append(opts, test.TraceRegoOpt())
 	}
@@ -173,8 +909,43 @@ func runCmd(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	if policies := must.StringSlice(cmd.Flags().GetStringSlice("policies")); len(policies) > 0 {
-		modules, err := loadPolicies(policies)
+	for _, n := range must.StringSlice(cmd.Flags().GetStringSlice("patch-history")) {
+		gvrs, err := kube.ResourcesForName(n)
+		if err != nil {
+			return err
+		}
+
+		for _, gvr := range gvrs {
+			opts = append(opts, test.PatchHistoryOpt(gvr))
+		}
+	}
+
+	var policyModules map[string]*ast.Module
+	var suiteModules map[string]*ast.Module
+
+	regoStrict := must.Bool(cmd.Flags().GetBool("rego-strict"))
+
+	severities, err := parseRuleSeverities(
+		must.StringSlice(cmd.Flags().GetStringArray("rule-severity")))
+	if err != nil {
+		return ExitError{Code: EX_USAGE, Err: err}
+	}
+
+	opts = append(opts, test.RuleSeveritiesOpt(severities))
+
+	var capabilities *ast.Capabilities
+	if path := must.String(cmd.Flags().GetString("rego-capabilities")); path != "" {
+		capabilities, err = loadCapabilities(path)
+		if err != nil {
+			return ExitError{Code: EX_NOINPUT, Err: err}
+		}
+
+		opts = append(opts, test.RegoCapabilitiesOpt(capabilities))
+	}
+
+	policies := must.StringSlice(cmd.Flags().GetStringSlice("policies"))
+	if len(policies) > 0 {
+		policyModules, err = loadPolicies(policies, capabilities)
 		if err != nil {
 			return ExitError{
 				Code: EX_DATAERR,
@@ -182,82 +953,1190 @@ func runCmd(cmd *cobra.Command, args []string) error {
 			}
 		}
 
-		for _, m := range modules {
+		if regoStrict {
+			if err := strictCheckPolicies(policyModules); err != nil {
+				return ExitError{Code: EX_DATAERR, Err: err}
+			}
+		}
+
+		for _, m := range policyModules {
 			opts = append(opts, test.RegoModuleOpt(m))
 		}
 	}
 
-	// TODO(jpeach): set user agent from program version.
-	kube.SetUserAgent(fmt.Sprintf("%s/%s", version.Progname, version.Version))
+	lockPath := must.String(cmd.Flags().GetString("policy-lock"))
+	updateLock := must.Bool(cmd.Flags().GetBool("update-policy-lock"))
+	if updateLock && lockPath == "" {
+		return ExitErrorf(EX_USAGE, "the --update-policy-lock flag requires --policy-lock")
+	}
 
-	for _, path := range args {
-		docCloser := recorder.NewDocument(path)
-		testDoc := validateDocument(path, recorder)
+	digests, err := lockPolicies(policies, lockPath, updateLock)
+	if err != nil {
+		return ExitError{Code: EX_DATAERR, Err: err}
+	}
 
-		if recorder.ShouldContinue() {
-			if err := test.Run(testDoc, opts...); err != nil {
-				return fmt.Errorf("failed to run tests: %s", err)
-			}
+	for _, w := range writers {
+		if w.json != nil {
+			w.json.Policies = digests
 		}
-
-		docCloser.Close()
 	}
 
-	// Only summarize when we run more than one test document.
-	// If we are just running a single test, the summary looks
-	// less like a summary and more like a left-over log line.
-	if len(args) > 1 {
-		summary.Summarize(os.Stdout)
+	if checks := must.StringSlice(cmd.Flags().GetStringSlice("suite-checks")); len(checks) > 0 {
+		// Suite checks can depend on the policy modules.
+		suiteModules, err = loadPolicies(checks, capabilities, policyModules)
+		if err != nil {
+			return ExitError{
+				Code: EX_DATAERR,
+				Err:  err,
+			}
+		}
+
+		if regoStrict {
+			if err := strictCheckPolicies(suiteModules); err != nil {
+				return ExitError{Code: EX_DATAERR, Err: err}
+			}
+		}
 	}
 
-	if recorder.Failed() {
-		return ExitError{Code: EX_FAIL}
+	snapshotPath := must.String(cmd.Flags().GetString("snapshot"))
+	if must.Bool(cmd.Flags().GetBool("anonymize")) && snapshotPath == "" {
+		return ExitErrorf(EX_USAGE, "the --anonymize flag requires --snapshot")
 	}
 
-	return nil
-}
+	var artifactsStore upload.Store
+	if dest := must.String(cmd.Flags().GetString("artifacts-upload")); dest != "" {
+		if artifactsDir == "" {
+			return ExitErrorf(EX_USAGE, "the --artifacts-upload flag requires --artifacts-dir")
+		}
 
-func loadPolicies(paths []string) (map[string]*ast.Module, error) {
-	modules := map[string]*ast.Module{}
-	loadPath := func(filePath string) error {
-		m, err := utils.ParseModuleFile(filePath)
+		artifactsStore, err = upload.NewStore(dest, os.Getenv)
 		if err != nil {
-			return err
+			return ExitError{Code: EX_USAGE, Err: err}
 		}
+	}
 
-		modules[filePath] = m
-		return nil
+	suite := &test.Suite{}
+	if len(suiteModules) > 0 || snapshotPath != "" {
+		recorder = test.StackRecorders(suite, recorder)
+		opts = append(opts, test.SuiteOpt(suite))
 	}
 
-	for _, p := range paths {
-		if err := utils.WalkFiles(p, loadPath); err != nil {
-			return nil, err
+	// Bound the results that any of the recorders retain.
+	recorder = test.LimitRecorders(recorder, test.ResultLimits{
+		MaxMessageSize: must.Int(cmd.Flags().GetInt("max-message-size")),
+		MaxStepResults: must.Int(cmd.Flags().GetInt("max-step-results")),
+	})
+
+	// The test runner records into every recorder in the stack.
+	opts = append(opts, test.RecorderOpt(recorder))
+
+	if must.Bool(cmd.Flags().GetBool("check-image-pull")) && !must.Bool(cmd.Flags().GetBool("dry-run")) {
+		if err := checkImagePull(kube, namespace, echoImage); err != nil {
+			return ExitError{Code: EX_FAIL, Err: err}
 		}
 	}
 
-	// Policy modules can depend on builtins.
-	builtins, err := builtin.CompileModules()
-	if err != nil {
-		return nil, fmt.Errorf("failed to compile builtin modules: %w", err)
-	}
+	parallel := must.Int(cmd.Flags().GetInt("parallel"))
 
-	merged := map[string]*ast.Module{}
-	for k, m := range builtins {
-		merged[k] = m
-	}
-	for k, m := range modules {
-		merged[k] = m
-	}
+	var shuffle *rand.Rand
+	if must.Bool(cmd.Flags().GetBool("shuffle")) || cmd.Flags().Changed("seed") {
+		seed := must.Int64(cmd.Flags().GetInt64("seed"))
+		if !cmd.Flags().Changed("seed") {
+			seed = time.Now().UnixNano()
+		}
 
-	// Verify that the policies compile. We compile them all at
-	// the end so that the compiler can resolve any dependencies.
-	compiler := ast.NewCompiler()
-	if compiler.Compile(merged); compiler.Failed() {
-		return nil, compiler.Errors
+		// Print the seed separately from the test results, so
+		// that it doesn't corrupt the structured formats.
+		fmt.Fprintf(os.Stderr, "shuffling test documents with --seed=%d\n", seed)
+		shuffle = rand.New(rand.NewSource(seed)) //nolint(gosec)
 	}
 
-	return modules, nil
-}
+	for i := 1; i <= count && !isInterrupted(interrupt); i++ {
+		docs := make([]documentRun, 0, len(args))
+		iterOpts := opts
+
+		paths := args
+		if shuffle != nil {
+			paths = shuffleDocuments(args, shuffle)
+		}
+
+		// Give each iteration a distinct run ID. If no run ID
+		// was specified, each document generates a fresh one.
+		iterRunID := runID
+		if count > 1 && runID != "" {
+			iterRunID = fmt.Sprintf("%s-%d", runID, i)
+			iterOpts = append(opts[:len(opts):len(opts)],
+				test.RunIDOpt(iterRunID))
+		}
+
+		for _, path := range paths {
+			d := documentRun{
+				path:              path,
+				desc:              documentDesc(path, metas),
+				runID:             iterRunID,
+				retries:           retries,
+				interrupt:         interrupt,
+				preserveOnFailure: preserveOnFailure,
+			}
+
+			if count > 1 {
+				d.desc = test.IterationDesc(documentDesc(path, metas), i, count)
+			}
+
+			docs = append(docs, d)
+		}
+
+		if parallel > 1 {
+			if err := runParallel(docs, parallel, recorder, regoStrict, iterOpts...); err != nil {
+				return err
+			}
+		} else {
+			for _, d := range docs {
+				if isInterrupted(interrupt) {
+					break
+				}
+
+				if err := runDocument(d, recorder, regoStrict, iterOpts...); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if len(suiteModules) > 0 {
+		if err := test.RunSuiteChecks(suite, recorder,
+			moduleSlice(suiteModules), moduleSlice(policyModules), capabilities, severities); err != nil {
+			return fmt.Errorf("failed to run suite checks: %s", err)
+		}
+	}
+
+	// Complete the output of the formats that are written at
+	// the end of the run.
+	for _, w := range writers {
+		if err := w.Finish(); err != nil {
+			return err
+		}
+	}
+
+	if snapshotPath != "" {
+		var scrub func(interface{}) interface{}
+		if must.Bool(cmd.Flags().GetBool("anonymize")) {
+			scrub = anonymize.NewAnonymizer(kube.Identifiers...).Scrub
+		}
+
+		if err := writeSnapshot(snapshotPath, suite, scrub); err != nil {
+			return ExitError{Code: EX_CANTCREAT, Err: err}
+		}
+	}
+
+	// The structured results are a single document, so there
+	// can't be any other output.
+	if format == "json" || format == "junit" || format == "conformance" {
+		kube.Throttle.WriteReport(os.Stderr)
+
+		if isInterrupted(interrupt) {
+			return ExitErrorf(EX_INTERRUPTED, "test run was interrupted")
+		}
+
+		if recorder.Failed() {
+			uploadArtifacts(os.Stderr, artifactsStore, artifactsDir, runID)
+			return failedExitError(summary)
+		}
+
+		return nil
+	}
+
+	// Only summarize when we run more than one test document.
+	// If we are just running a single test, the summary looks
+	// less like a summary and more like a left-over log line.
+	if len(args)*count > 1 {
+		summary.Summarize(out)
+	}
+
+	if count > 1 {
+		descs := make([]string, 0, len(args))
+		for _, path := range args {
+			descs = append(descs, documentDesc(path, metas))
+		}
+
+		summary.SummarizeIterations(out, descs, count)
+	}
+
+	// Report timings with the summary, or on request.
+	if n := must.Int(cmd.Flags().GetInt("slowest")); n > 0 &&
+		(len(args)*count > 1 || cmd.Flags().Changed("slowest")) {
+		summary.SummarizeTimings(out, n)
+	}
+
+	kube.Throttle.WriteReport(out)
+
+	if isInterrupted(interrupt) {
+		return ExitErrorf(EX_INTERRUPTED, "test run was interrupted")
+	}
+
+	if recorder.Failed() {
+		uploadArtifacts(out, artifactsStore, artifactsDir, runID)
+	}
+
+	if watchMode {
+		watchDocuments(args, writer, regoStrict,
+			must.Duration(cmd.Flags().GetDuration("watch-interval")), interrupt, preserveOnFailure, opts...)
+		return ExitErrorf(EX_INTERRUPTED, "test run was interrupted")
+	}
+
+	if drift != nil {
+		daemonRuns(args, metas, writer, regoStrict, daemonInterval, interrupt, runID, retries, drift, opts...)
+		return ExitErrorf(EX_INTERRUPTED, "test run was interrupted")
+	}
+
+	if recorder.Failed() {
+		return failedExitError(summary)
+	}
+
+	return nil
+}
+
+// artifactsUploadTimeout is how long uploading the artifacts
+// directory can take.
+const artifactsUploadTimeout = 10 * time.Minute
+
+// uploadArtifacts uploads the artifacts directory to the object
+// store, if there is one, and reports the URL of the upload to w.
+// The upload is named after the run ID, or the current time if the
+// documents have their own run IDs. Failing to upload doesn't change
+// the exit status, since the test run has already failed.
+func uploadArtifacts(w io.Writer, store upload.Store, dir string, runID string) {
+	if store == nil {
+		return
+	}
+
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		fmt.Fprintf(w, "no artifacts to upload in %s\n", dir)
+		return
+	}
+
+	name := runID
+	if name == "" {
+		name = time.Now().UTC().Format("20060102T150405Z")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), artifactsUploadTimeout)
+	defer cancel()
+
+	location, err := upload.Directory(ctx, store, dir, name)
+	if err != nil {
+		fmt.Fprintf(w, "failed to upload artifacts: %s\n", err)
+		return
+	}
+
+	fmt.Fprintf(w, "uploaded artifacts to %s\n", location)
+}
+
+// failedExitError returns the error for a failed test run. A run
+// whose only failures were in deleting the test objects exits with
+// a distinct code, so that CI can tell a broken test from a dirty
+// cluster.
+func failedExitError(summary *test.SummaryWriter) error {
+	if summary.OnlyCleanupFailed() {
+		return ExitErrorf(EX_CLEANUP, "tests passed, but test objects could not be deleted")
+	}
+
+	return ExitError{Code: EX_FAIL}
+}
+
+// shardDocuments returns the test documents in the shard with the
+// given index, preserving their original order. Documents are
+// assigned to shards round-robin in path order, so that the shards
+// don't depend on the order of the arguments.
+func shardDocuments(paths []string, index int, count int) ([]string, error) {
+	if count < 1 {
+		return nil, fmt.Errorf("invalid shard count %d", count)
+	}
+
+	if index < 0 || index >= count {
+		return nil, fmt.Errorf("shard index %d is out of range for %d shards", index, count)
+	}
+
+	if count == 1 {
+		return paths, nil
+	}
+
+	sorted := make([]string, len(paths))
+	copy(sorted, paths)
+	sort.Strings(sorted)
+
+	selected := map[string]bool{}
+	for i, p := range sorted {
+		if i%count == index {
+			selected[p] = true
+		}
+	}
+
+	var shard []string
+	for _, p := range paths {
+		if selected[p] {
+			shard = append(shard, p)
+		}
+	}
+
+	return shard, nil
+}
+
+// readDocumentMetas returns the front-matter of each of the test
+// documents. Documents that can't be read are omitted, since they
+// will fail validation when they run.
+func readDocumentMetas(paths []string) map[string]doc.Meta {
+	metas := map[string]doc.Meta{}
+
+	for _, path := range paths {
+		if testDoc, err := doc.ReadFile(path); err == nil {
+			metas[path] = testDoc.Meta
+		}
+	}
+
+	return metas
+}
+
+// filterDocumentTags returns the test documents that have any of
+// the given front-matter tags.
+func filterDocumentTags(paths []string, metas map[string]doc.Meta, tags []string) []string {
+	var tagged []string
+
+	for _, path := range paths {
+		if metas[path].HasTag(tags...) {
+			tagged = append(tagged, path)
+		}
+	}
+
+	return tagged
+}
+
+// documentDesc returns the description that the results of a test
+// document are recorded with. This is the front-matter name of the
+// document, if it has one, and otherwise its path.
+func documentDesc(path string, metas map[string]doc.Meta) string {
+	if name := metas[path].Name; name != "" {
+		return name
+	}
+
+	return path
+}
+
+// documentRun is a test document to run, and the description that
+// its results are recorded with. A failing document is retried up
+// to retries times. Retries derive their run ID from runID, if it
+// is set. Documents are not retried once interrupt is closed. If
+// preserveOnFailure is set, the objects of the final attempt are not
+// deleted if it fails.
+type documentRun struct {
+	path              string
+	desc              string
+	runID             string
+	retries           int
+	interrupt         <-chan struct{}
+	preserveOnFailure bool
+}
+
+// shuffleDocuments returns a copy of the test document paths in an
+// order that is determined by the random source.
+func shuffleDocuments(paths []string, r *rand.Rand) []string {
+	shuffled := make([]string, len(paths))
+	copy(shuffled, paths)
+
+	r.Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+
+	return shuffled
+}
+
+// runDocument validates and runs the test document at d.path. If the
+// document fails, it is retried up to d.retries times. Each retry is
+// a fresh run of the document, after the failed attempt has cleaned
+// up its objects.
+func runDocument(d documentRun, r test.Recorder, strict bool, opts ...test.RunOpt) error {
+	for attempt := 0; ; attempt++ {
+		attemptOpts := opts[:len(opts):len(opts)]
+
+		// Each retry needs a fresh run ID so that it doesn't
+		// match objects from the failed attempt.
+		if attempt > 0 && d.runID != "" {
+			attemptOpts = append(attemptOpts,
+				test.RunIDOpt(fmt.Sprintf("%s-retry-%d", d.runID, attempt)))
+		}
+
+		// Only the final attempt may preserve its objects,
+		// since they would otherwise clash with the retry.
+		if attempt >= d.retries && d.preserveOnFailure {
+			attemptOpts = append(attemptOpts, test.PreserveOnFailureOpt())
+		}
+
+		// Track the failures of this attempt separately from
+		// the failures of the whole run.
+		attemptRecorder := test.NewRecorder()
+		ar := test.StackRecorders(r, attemptRecorder)
+		attemptOpts = append(attemptOpts, test.RecorderOpt(ar))
+
+		if err := runAttempt(d, ar, strict, attemptOpts...); err != nil {
+			return err
+		}
+
+		if attempt >= d.retries || !attemptRecorder.Failed() || isInterrupted(d.interrupt) {
+			return nil
+		}
+
+		r.RetryDocument()
+	}
+}
+
+// runAttempt validates and runs a single attempt of the test document.
+func runAttempt(d documentRun, r test.Recorder, strict bool, opts ...test.RunOpt) error {
+	docCloser := r.NewDocument(d.desc)
+	defer docCloser.Close()
+
+	testDoc := validateDocument(d.path, r, strict)
+
+	if d.desc != d.path {
+		opts = append(opts[:len(opts):len(opts)], test.DocumentDescOpt(d.desc))
+	}
+
+	if r.ShouldContinue() {
+		if err := test.Run(testDoc, opts...); err != nil {
+			return fmt.Errorf("failed to run tests: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// handleInterrupts returns a channel that is closed when the process
+// receives SIGINT or SIGTERM. This gives the current test document a
+// chance to stop and delete its objects. If a second signal arrives
+// before the test run stops, the process exits immediately.
+func handleInterrupts() <-chan struct{} {
+	interrupt := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		sig := <-signals
+		fmt.Fprintf(os.Stderr, "received %s, cleaning up test objects (repeat to exit immediately)\n", sig)
+		close(interrupt)
+
+		<-signals
+		os.Exit(int(EX_INTERRUPTED))
+	}()
+
+	return interrupt
+}
+
+// isInterrupted returns whether the interrupt channel is closed.
+func isInterrupted(interrupt <-chan struct{}) bool {
+	select {
+	case <-interrupt:
+		return true
+	default:
+		return false
+	}
+}
+
+// watchDocuments polls the test documents for modifications, and
+// re-runs each document that changes, until the command is interrupted.
+// Re-runs reuse the Kubernetes client (and its discovery cache) from
+// the initial run, and only record into the primary output.
+func watchDocuments(paths []string, out test.Recorder, strict bool, interval time.Duration, interrupt <-chan struct{}, preserveOnFailure bool, opts ...test.RunOpt) {
+	modTime := func(path string) time.Time {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}
+		}
+
+		return info.ModTime()
+	}
+
+	modified := map[string]time.Time{}
+	for _, p := range paths {
+		modified[p] = modTime(p)
+	}
+
+	fmt.Fprintf(os.Stderr, "watching %d test document(s) for changes\n", len(paths))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-interrupt:
+			return
+		case <-ticker.C:
+		}
+
+		for _, p := range paths {
+			m := modTime(p)
+			if m.Equal(modified[p]) {
+				continue
+			}
+
+			modified[p] = m
+
+			// Editors may remove the file before they
+			// write it again. We will see it next time.
+			if m.IsZero() {
+				continue
+			}
+
+			// Each re-run starts with a fresh recorder so
+			// that earlier failures don't stop it.
+			r := test.StackRecorders(out, test.NewRecorder())
+			runOpts := append(opts[:len(opts):len(opts)], test.RecorderOpt(r))
+
+			if err := runDocument(documentRun{path: p, desc: p, interrupt: interrupt, preserveOnFailure: preserveOnFailure}, r, strict, runOpts...); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", p, err)
+			}
+		}
+	}
+}
+
+// driftReporter reports the changes in the outcomes of the test
+// documents between the runs of daemon mode.
+type driftReporter struct {
+	tracker   *test.DriftTracker
+	statePath string
+	webhooks  []string
+	client    *http.Client
+}
+
+// newDriftReporter returns a driftReporter that starts from the state
+// in statePath, if there is one.
+func newDriftReporter(statePath string, webhooks []string) (*driftReporter, error) {
+	tracker := test.NewDriftTracker()
+
+	if statePath != "" {
+		var err error
+		if tracker, err = test.ReadDriftTracker(statePath); err != nil {
+			return nil, ExitError{Code: EX_DATAERR, Err: err}
+		}
+	}
+
+	for _, w := range webhooks {
+		if u, err := url.Parse(w); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, ExitErrorf(EX_USAGE, "invalid webhook URL %q", w)
+		}
+	}
+
+	return &driftReporter{
+		tracker:   tracker,
+		statePath: statePath,
+		webhooks:  webhooks,
+		client:    &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// report updates the document states from the history entries of a
+// run, and reports the transitions on standard error and to each of
+// the webhooks. A webhook that fails doesn't stop the others.
+func (d *driftReporter) report(entries []test.HistoryEntry) error {
+	transitions := d.tracker.Observe(entries)
+
+	for _, t := range transitions {
+		switch {
+		case t.Transition == test.TransitionFailed && t.LastGood != nil:
+			fmt.Fprintf(os.Stderr, "%s: %s (last passed at %s)\n",
+				t.Test, t.Transition, t.LastGood.Start.Format(time.RFC3339))
+		default:
+			fmt.Fprintf(os.Stderr, "%s: %s\n", t.Test, t.Transition)
+		}
+	}
+
+	if d.statePath != "" {
+		if err := d.tracker.WriteFile(d.statePath); err != nil {
+			return ExitError{Code: EX_CANTCREAT, Err: err}
+		}
+	}
+
+	if len(transitions) == 0 {
+		return nil
+	}
+
+	for _, w := range d.webhooks {
+		if err := test.NotifyTransitions(context.Background(), d.client, w, transitions); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to notify webhook: %s\n", err)
+		}
+	}
+
+	return nil
+}
+
+// daemonRuns re-runs all the test documents at each interval, until
+// the command is interrupted, and reports the changes in their
+// outcomes. Like watch mode, re-runs reuse the Kubernetes client from
+// the initial run, and only record into the primary output. A run
+// that is interrupted is not reported, since its documents didn't all
+// finish.
+func daemonRuns(paths []string, metas map[string]doc.Meta, out test.Recorder, strict bool, interval time.Duration, interrupt <-chan struct{}, runID string, retries int, drift *driftReporter, opts ...test.RunOpt) {
+	fmt.Fprintf(os.Stderr, "re-running %d test document(s) every %s\n", len(paths), interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for n := 2; ; n++ {
+		select {
+		case <-interrupt:
+			return
+		case <-ticker.C:
+		}
+
+		// Each run starts with a fresh recorder so that
+		// earlier failures don't stop it, and gets a distinct
+		// run ID if one was given.
+		j := &test.JSONWriter{RunID: runID}
+		r := test.StackRecorders(out, test.StackRecorders(j, test.NewRecorder()))
+		runOpts := append(opts[:len(opts):len(opts)], test.RecorderOpt(r))
+
+		if runID != "" {
+			j.RunID = fmt.Sprintf("%s-%d", runID, n)
+			runOpts = append(runOpts, test.RunIDOpt(j.RunID))
+		}
+
+		for _, p := range paths {
+			if isInterrupted(interrupt) {
+				return
+			}
+
+			d := documentRun{
+				path:      p,
+				desc:      documentDesc(p, metas),
+				runID:     j.RunID,
+				retries:   retries,
+				interrupt: interrupt,
+			}
+
+			if err := runDocument(d, r, strict, runOpts...); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", p, err)
+			}
+		}
+
+		if isInterrupted(interrupt) {
+			return
+		}
+
+		if err := drift.report(test.HistoryEntries(j)); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+		}
+	}
+}
+
+// runParallel runs up to parallel test documents concurrently. Each
+// document records into its own buffer, which is replayed into the
+// recorder when the document completes, so the output of each
+// document is reported as a unit, in the order that the documents
+// complete.
+func runParallel(docs []documentRun, parallel int, r test.Recorder, strict bool, opts ...test.RunOpt) error {
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+
+	errs := make([]error, len(docs))
+	sem := make(chan struct{}, parallel)
+
+	for i, d := range docs {
+		sem <- struct{}{}
+
+		// Don't start any more documents once interrupted.
+		if isInterrupted(d.interrupt) {
+			<-sem
+			break
+		}
+
+		wg.Add(1)
+
+		go func(i int, d documentRun) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			buf := &test.DocumentBuffer{}
+
+			// The last RecorderOpt wins, so the document
+			// records into the buffer.
+			docOpts := append(opts[:len(opts):len(opts)], test.RecorderOpt(buf))
+			errs[i] = runDocument(d, buf, strict, docOpts...)
+
+			lock.Lock()
+			buf.Flush(r)
+			lock.Unlock()
+		}(i, d)
+	}
+
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// newAttestationWriter returns a resultWriter that writes a signed
+// attestation of the test run to path when it finishes.
+func newAttestationWriter(cmd *cobra.Command, path string, kube *driver.KubeClient, runID string, documents []string) (*resultWriter, error) {
+	keyPath := must.String(cmd.Flags().GetString("attestation-key"))
+	if keyPath == "" {
+		return nil, ExitErrorf(EX_USAGE, "the --attestation flag requires --attestation-key")
+	}
+
+	key, err := attest.ReadPrivateKey(keyPath)
+	if err != nil {
+		return nil, ExitError{Code: EX_NOINPUT, Err: err}
+	}
+
+	// Hash the test documents before the run starts, so that the
+	// attestation is about the documents that actually ran.
+	subjects := make([]attest.Subject, 0, len(documents))
+	for _, d := range documents {
+		s, err := attest.FileSubject(d)
+		if err != nil {
+			return nil, ExitError{Code: EX_NOINPUT, Err: err}
+		}
+
+		subjects = append(subjects, s)
+	}
+
+	// Prefer the kube-system namespace UID, which is unique to
+	// the cluster, but fall back to the names of the context.
+	fingerprint := attest.Fingerprint(kube.Identifiers...)
+	if uid, err := kube.ClusterUID(); err == nil {
+		fingerprint = attest.Fingerprint(uid)
+	}
+
+	started := time.Now()
+	j := &test.JSONWriter{RunID: runID}
+
+	return &resultWriter{
+		Recorder: j,
+		json:     j,
+		finish: func() error {
+			summary, docs := attest.Results(j)
+			statement := attest.NewStatement(subjects, attest.Predicate{
+				Tester: attest.Tester{
+					Name:    version.Progname,
+					Version: version.Version,
+					Commit:  version.Sha,
+				},
+				RunID:              runID,
+				ClusterFingerprint: fingerprint,
+				StartedOn:          started.UTC(),
+				FinishedOn:         time.Now().UTC(),
+				Summary:            summary,
+				Documents:          docs,
+			})
+
+			envelope, err := attest.Sign(statement, key)
+			if err != nil {
+				return err
+			}
+
+			data, err := json.MarshalIndent(envelope, "", "  ")
+			if err != nil {
+				return err
+			}
+
+			if err := ioutil.WriteFile(path, append(data, '\n'), 0644); err != nil { //nolint:gosec
+				return ExitError{Code: EX_CANTCREAT, Err: err}
+			}
+
+			return nil
+		},
+	}, nil
+}
+
+// resultWriter is a Recorder that writes test results in one of
+// the output formats.
+type resultWriter struct {
+	test.Recorder
+
+	// finish completes the output at the end of the test run.
+	finish func() error
+
+	// json is the underlying JSONWriter for structured formats.
+	json *test.JSONWriter
+}
+
+// Finish completes the output at the end of the test run. The TAP
+// plan and the JSON results are written when the run is finished.
+func (w *resultWriter) Finish() error {
+	if w.finish == nil {
+		return nil
+	}
+
+	return w.finish()
+}
+
+// newResultWriter returns a resultWriter that writes the test results
+// to out in the given format. The number of test documents that will
+// be run is used to report progress.
+func newResultWriter(cmd *cobra.Command, format string, out *os.File, runID string, documents int) (*resultWriter, error) {
+	var w resultWriter
+
+	switch format {
+	case "tree":
+		color, err := validateColor(must.String(cmd.Flags().GetString("color")), out)
+		if err != nil {
+			return nil, ExitError{Code: EX_USAGE, Err: err}
+		}
+
+		verbosity := must.Int(cmd.Flags().GetCount("verbose"))
+		if must.Bool(cmd.Flags().GetBool("quiet")) {
+			if verbosity > 0 {
+				return nil, ExitErrorf(EX_USAGE, "the --quiet and --verbose flags are mutually exclusive")
+			}
+
+			verbosity = -1
+		}
+
+		w.Recorder = &test.TreeWriter{Out: out, Color: color, Verbosity: verbosity}
+	case "tap":
+		tap := &test.TapWriter{
+			Out:     out,
+			BailOut: must.Bool(cmd.Flags().GetBool("tap-bail-out")),
+		}
+
+		// The TAP plan covers every step in the run.
+		w.Recorder = tap
+		w.finish = func() error {
+			tap.Close()
+			return nil
+		}
+	case "progress", "dots":
+		p := &test.ProgressWriter{Out: out, Total: documents}
+		if format == "dots" {
+			p.Mode = test.ProgressDots
+		}
+
+		w.Recorder = p
+		w.finish = func() error {
+			p.Close()
+			return nil
+		}
+	case "json":
+		j := &test.JSONWriter{RunID: runID}
+
+		w.Recorder = j
+		w.json = j
+		w.finish = func() error {
+			return j.Write(out)
+		}
+
+		// Structured output should record every result.
+		return &w, nil
+	case "junit":
+		j := &test.JUnitWriter{JSONWriter: test.JSONWriter{RunID: runID}}
+
+		w.Recorder = j
+		w.json = &j.JSONWriter
+		w.finish = func() error {
+			return j.Write(out)
+		}
+
+		return &w, nil
+	case "conformance":
+		c, err := newConformanceWriter(cmd, runID)
+		if err != nil {
+			return nil, err
+		}
+
+		w.Recorder = c
+		w.json = &c.JSONWriter
+		w.finish = func() error {
+			return c.Write(out)
+		}
+
+		return &w, nil
+	default:
+		return nil, ExitErrorf(EX_USAGE, "invalid test output format %q", format)
+	}
+
+	if must.Bool(cmd.Flags().GetBool("coalesce")) {
+		w.Recorder = test.CoalesceRecorders(w.Recorder)
+	}
+
+	return &w, nil
+}
+
+// newConformanceWriter returns a writer for the Gateway API conformance
+// report format. The report metadata is given by the '--conformance'
+// flag, and the results of an existing report can be imported with
+// the '--conformance-import' flag.
+func newConformanceWriter(cmd *cobra.Command, runID string) (*test.ConformanceWriter, error) {
+	c := &test.ConformanceWriter{
+		JSONWriter: test.JSONWriter{RunID: runID},
+		Profile:    "GATEWAY-HTTP",
+		Features:   map[string][]string{},
+	}
+
+	if path := must.String(cmd.Flags().GetString("conformance-import")); path != "" {
+		f, err := os.Open(path) // nolint(gosec)
+		if err != nil {
+			return nil, ExitError{Code: EX_NOINPUT, Err: err}
+		}
+
+		defer f.Close()
+
+		report, err := test.ReadConformanceReport(f)
+		if err != nil {
+			return nil, ExitError{Code: EX_DATAERR,
+				Err: fmt.Errorf("failed to import conformance report %q: %w", path, err)}
+		}
+
+		c.Report = *report
+	}
+
+	for _, kv := range must.StringSlice(cmd.Flags().GetStringArray("conformance")) {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			return nil, ExitErrorf(EX_USAGE, "missing value for conformance field %q", parts[0])
+		}
+
+		switch parts[0] {
+		case "organization":
+			c.Report.Implementation.Organization = parts[1]
+		case "project":
+			c.Report.Implementation.Project = parts[1]
+		case "url":
+			c.Report.Implementation.URL = parts[1]
+		case "version":
+			c.Report.Implementation.Version = parts[1]
+		case "contact":
+			c.Report.Implementation.Contact = append(c.Report.Implementation.Contact, parts[1])
+		case "profile":
+			c.Profile = parts[1]
+		case "gateway-api-version":
+			c.Report.GatewayAPIVersion = parts[1]
+		case "gateway-api-channel":
+			c.Report.GatewayAPIChannel = parts[1]
+		default:
+			return nil, ExitErrorf(EX_USAGE, "invalid conformance field %q", parts[0])
+		}
+	}
+
+	// Tag the results of every iteration of each document.
+	count := must.Int(cmd.Flags().GetInt("count"))
+
+	for _, path := range cmd.Flags().Args() {
+		testDoc, err := doc.ReadFile(path)
+		if err != nil {
+			// The document will fail validation when it runs.
+			continue
+		}
+
+		for i := range testDoc.Parts {
+			_, _ = testDoc.Parts[i].Decode()
+		}
+
+		features, err := test.DocumentConformanceFeatures(testDoc)
+		if err != nil {
+			return nil, ExitError{Code: EX_DATAERR, Err: fmt.Errorf("%s: %w", path, err)}
+		}
+
+		// Results are recorded with the front-matter name of
+		// the document, if it has one.
+		desc := path
+		if testDoc.Meta.Name != "" {
+			desc = testDoc.Meta.Name
+		}
+
+		c.Features[desc] = features
+		for i := 1; i <= count && count > 1; i++ {
+			c.Features[test.IterationDesc(desc, i, count)] = features
+		}
+	}
+
+	return c, nil
+}
+
+// formatFile is a test results format that is written to a file.
+type formatFile struct {
+	format string
+	path   string
+}
+
+// validateFormats parses the '--format' flag values. A value is
+// either a format name, which is written to the output, or a
+// "format=path" pair, which is written to the file at path. At most
+// one format can be written to the output. If none is given, the
+// output format is "tree".
+func validateFormats(specs []string) (string, []formatFile, error) {
+	output := ""
+	var files []formatFile
+
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, "=", 2)
+
+		if len(parts) == 1 {
+			if output != "" {
+				return "", nil, fmt.Errorf(
+					"only one output format can be written to the output, got %q and %q", output, spec)
+			}
+
+			output = spec
+			continue
+		}
+
+		if parts[1] == "" {
+			return "", nil, fmt.Errorf("missing path for %q format", parts[0])
+		}
+
+		files = append(files, formatFile{format: parts[0], path: parts[1]})
+	}
+
+	if output == "" {
+		output = "tree"
+	}
+
+	return output, files, nil
+}
+
+func writeSnapshot(path string, suite *test.Suite, scrub func(interface{}) interface{}) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := suite.WriteSnapshot(f, scrub); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	return f.Close()
+}
+
+// loadPolicies loads the Rego modules from the given paths and verifies
+// that they compile. The modules may depend on the builtin modules and
+// on any of the given dependencies. If capabilities is not nil, the
+// policies can only use the builtins that it allows.
+func loadPolicies(paths []string, capabilities *ast.Capabilities, deps ...map[string]*ast.Module) (map[string]*ast.Module, error) {
+	modules := map[string]*ast.Module{}
+	loadPath := func(filePath string) error {
+		m, err := utils.ParseModuleFile(filePath)
+		if err != nil {
+			return err
+		}
+
+		modules[filePath] = m
+		return nil
+	}
+
+	for _, p := range paths {
+		if err := utils.WalkFiles(p, loadPath); err != nil {
+			return nil, err
+		}
+	}
+
+	// Policy modules can depend on builtins.
+	builtins, err := builtin.CompileModules()
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile builtin modules: %w", err)
+	}
+
+	merged := map[string]*ast.Module{}
+	for k, m := range builtins {
+		merged[k] = m
+	}
+	for _, d := range deps {
+		for k, m := range d {
+			merged[k] = m
+		}
+	}
+	for k, m := range modules {
+		merged[k] = m
+	}
+
+	// Verify that the policies compile. We compile them all at
+	// the end so that the compiler can resolve any dependencies.
+	compiler := ast.NewCompiler()
+	if capabilities != nil {
+		compiler = compiler.WithCapabilities(capabilities)
+	}
+
+	if compiler.Compile(merged); compiler.Failed() {
+		return nil, compiler.Errors
+	}
+
+	return modules, nil
+}
+
+// loadCapabilities loads an OPA capabilities JSON file.
+func loadCapabilities(path string) (*ast.Capabilities, error) {
+	f, err := os.Open(path) // nolint(gosec)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	capabilities, err := ast.LoadCapabilitiesJSON(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load capabilities from %q: %w", path, err)
+	}
+
+	return capabilities, nil
+}
+
+// strictCheckPolicies applies the Rego strict checks to each of the
+// policy modules.
+func strictCheckPolicies(modules map[string]*ast.Module) error {
+	var errs ast.Errors
+
+	for _, name := range sortedModuleNames(modules) {
+		errs = append(errs, utils.StrictCheckModule(modules[name])...)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func sortedModuleNames(modules map[string]*ast.Module) []string {
+	names := make([]string, 0, len(modules))
+	for name := range modules {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+func moduleSlice(modules map[string]*ast.Module) []*ast.Module {
+	var s []*ast.Module
+	for _, m := range modules {
+		s = append(s, m)
+	}
+
+	return s
+}
+
+// imagePullTimeout is how long to wait for the cluster to pull an
+// image when checking that it can be pulled.
+const imagePullTimeout = 2 * time.Minute
+
+// checkImagePull verifies that the cluster can pull the image. The
+// check runs in the given namespace if it exists, and otherwise in
+// the default namespace.
+func checkImagePull(kube *driver.KubeClient, namespace string, image string) error {
+	exists, err := kube.NamespaceExists(namespace)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		namespace = metav1.NamespaceDefault
+	}
+
+	return driver.CheckImagePull(kube, namespace, image, imagePullTimeout)
+}
 
 func loadFixtures(paths []string) error {
 	loadPath := func(filePath string) error {
@@ -292,8 +2171,231 @@ func validateParams(params []string) ([]test.RunOpt, error) {
 	return opts, nil
 }
 
-func validateDocument(path string, r test.Recorder) *doc.Document {
-	stepCloser := r.NewStep(fmt.Sprintf("validating document %q", path))
+// validateEnvVars checks the environment variable names from the
+// '--env' flag.
+func validateEnvVars(names []string) error {
+	for _, n := range names {
+		if err := driver.ValidateEnvVarName(n); err != nil {
+			return ExitError{Code: EX_USAGE, Err: err}
+		}
+	}
+
+	return nil
+}
+
+// parseRuleSeverities parses the "name=severity" rule mappings
+// from the '--rule-severity' flag.
+func parseRuleSeverities(mappings []string) (*driver.RuleSeverities, error) {
+	severities := &driver.RuleSeverities{}
+
+	for _, m := range mappings {
+		parts := strings.SplitN(m, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("missing severity for rule %q", parts[0])
+		}
+
+		severity, err := result.ParseSeverity(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid severity for rule %q: %w", parts[0], err)
+		}
+
+		if err := severities.Add(parts[0], severity); err != nil {
+			return nil, err
+		}
+	}
+
+	return severities, nil
+}
+
+var externalNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ciRunIDVariables lists the environment variables that CI systems
+// use to identify a build, in order of precedence. Prow sets
+// BUILD_ID like Jenkins does, so it must be checked first.
+var ciRunIDVariables = []struct {
+	System string
+	Env    string
+}{
+	{"github", "GITHUB_RUN_ID"},
+	{"gitlab", "CI_JOB_ID"},
+	{"buildkite", "BUILDKITE_BUILD_ID"},
+	{"circleci", "CIRCLE_WORKFLOW_JOB_ID"},
+	{"travis", "TRAVIS_JOB_ID"},
+	{"prow", "PROW_JOB_ID"},
+	{"jenkins", "BUILD_ID"},
+}
+
+// resultsFileName returns the name of the JSON results file for a
+// test run. Characters of the run ID that are not safe in file names
+// are replaced with underscores. Runs without an ID are named by the
+// time they started.
+func resultsFileName(runID string, start time.Time) string {
+	if runID == "" {
+		return start.UTC().Format("20060102T150405Z") + ".json"
+	}
+
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r == '-', r == '_', r == '.':
+			return r
+		default:
+			return '_'
+		}
+	}, runID) + ".json"
+}
+
+// validateRunID returns the test run ID given by the flags. An empty
+// ID means that each test document generates a random ID.
+func validateRunID(runID string, fromCI bool) (string, error) {
+	if fromCI && runID != "" {
+		return "", fmt.Errorf("the --run-id and --run-id-from-ci flags are mutually exclusive")
+	}
+
+	if !fromCI {
+		return runID, nil
+	}
+
+	for _, v := range ciRunIDVariables {
+		if id := os.Getenv(v.Env); id != "" {
+			return fmt.Sprintf("%s-%s", v.System, id), nil
+		}
+	}
+
+	return "", fmt.Errorf("failed to derive a run ID from the CI environment")
+}
+
+// lockPolicies returns the content digests of the policy files in
+// the given paths. If a lock file path is given, the digests are
+// verified against the lock file, or written to it if update is set.
+func lockPolicies(paths []string, lockPath string, update bool) (map[string]string, error) {
+	digests, err := lock.DigestFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case lockPath == "":
+		return digests, nil
+	case update:
+		l := &lock.Lock{Policies: digests}
+		if err := l.Write(lockPath); err != nil {
+			return nil, fmt.Errorf("failed to write policy lock: %w", err)
+		}
+	default:
+		l, err := lock.Read(lockPath)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := l.Verify(digests); err != nil {
+			return nil, err
+		}
+	}
+
+	return digests, nil
+}
+
+// validateNamespace checks that the namespace name is a valid DNS
+// label, since that is what the API server requires.
+func validateNamespace(ns string) error {
+	if errs := validation.IsDNS1123Label(ns); len(errs) > 0 {
+		return fmt.Errorf("invalid namespace %q: %s", ns, strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
+// validateColor returns whether the "auto", "always" or "never"
+// color mode enables colors. In "auto" mode, colors are enabled if
+// the output is a terminal, unless the NO_COLOR environment
+// variable is set or the terminal is dumb.
+func validateColor(mode string, out *os.File) (bool, error) {
+	switch mode {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "auto":
+		if _, ok := os.LookupEnv("NO_COLOR"); ok {
+			return false, nil
+		}
+
+		if os.Getenv("TERM") == "dumb" {
+			return false, nil
+		}
+
+		info, err := out.Stat()
+		if err != nil {
+			return false, nil
+		}
+
+		return info.Mode()&os.ModeCharDevice != 0, nil
+	default:
+		return false, fmt.Errorf("invalid color mode %q", mode)
+	}
+}
+
+func validateExternalSources(endpoints []string, queries []string, prometheus string) ([]external.Source, error) {
+	var sources []external.Source
+
+	probeClient := &http.Client{Transport: netproxy.Transport()}
+
+	split := func(arg string) (string, string, error) {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 {
+			return "", "", fmt.Errorf("missing value for external source %q", parts[0])
+		}
+
+		if !externalNamePattern.MatchString(parts[0]) {
+			return "", "", fmt.Errorf("invalid external source name %q", parts[0])
+		}
+
+		return parts[0], parts[1], nil
+	}
+
+	for _, e := range endpoints {
+		name, u, err := split(e)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := url.ParseRequestURI(u); err != nil {
+			return nil, fmt.Errorf("invalid URL for external source %q: %w", name, err)
+		}
+
+		sources = append(sources, &external.HTTPSource{
+			SourceName: name,
+			URL:        u,
+			Client:     probeClient,
+		})
+	}
+
+	if len(queries) > 0 && prometheus == "" {
+		return nil, fmt.Errorf("the --external-prometheus flag requires --prometheus-url")
+	}
+
+	for _, q := range queries {
+		name, query, err := split(q)
+		if err != nil {
+			return nil, err
+		}
+
+		sources = append(sources, &external.PrometheusSource{
+			SourceName: name,
+			Server:     strings.TrimSuffix(prometheus, "/"),
+			Query:      query,
+			Client:     probeClient,
+		})
+	}
+
+	return sources, nil
+}
+
+func validateDocument(path string, r test.Recorder, strict bool) *doc.Document {
+	stepCloser := r.NewStep(test.StepID(path, "", "validate"),
+		fmt.Sprintf("validating document %q", path))
 	defer stepCloser.Close()
 
 	r.Update(result.Infof("reading document from %s", path))
@@ -315,6 +2417,12 @@ func validateDocument(path string, r test.Recorder) *doc.Document {
 		switch err {
 		case nil:
 			r.Update(result.Infof("decoded part %d as %s (lines %s)", i, fragType, part.Location))
+
+			if strict && fragType == doc.FragmentTypeModule {
+				if errs := utils.StrictCheckModule(part.Rego()); errs != nil {
+					r.Update(result.Fatalf("%s", errs.Error()))
+				}
+			}
 		default:
 			if regoErr := utils.AsRegoCompilationErr(err); regoErr != nil {
 				r.Update(result.Fatalf("%s", regoErr.Error()))
@@ -15,10 +15,15 @@
 package cmd
 
 import (
+	"math/rand"
+	"os"
 	"testing"
 
+	"github.com/projectcontour/integration-tester/pkg/doc"
+	"github.com/projectcontour/integration-tester/pkg/result"
 	"github.com/projectcontour/integration-tester/pkg/test"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestParamValidation(t *testing.T) {
@@ -38,3 +43,163 @@ func TestParamValidation(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, 2, len(opts))
 }
+
+func TestColorValidation(t *testing.T) {
+	color, err := validateColor("always", os.Stdout)
+	assert.NoError(t, err)
+	assert.True(t, color)
+
+	color, err = validateColor("never", os.Stdout)
+	assert.NoError(t, err)
+	assert.False(t, color)
+
+	_, err = validateColor("sometimes", os.Stdout)
+	assert.Error(t, err)
+}
+
+func TestRunIDValidation(t *testing.T) {
+	// Clear the CI environment, restoring it when we are done.
+	for _, v := range ciRunIDVariables {
+		if val, ok := os.LookupEnv(v.Env); ok {
+			defer os.Setenv(v.Env, val) //nolint(errcheck)
+			os.Unsetenv(v.Env)
+		}
+	}
+
+	id, err := validateRunID("", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "", id)
+
+	id, err = validateRunID("build-42", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "build-42", id)
+
+	_, err = validateRunID("build-42", true)
+	assert.Error(t, err)
+
+	_, err = validateRunID("", true)
+	assert.Error(t, err)
+
+	os.Setenv("BUILD_ID", "7")       //nolint(errcheck)
+	os.Setenv("PROW_JOB_ID", "9f4c") //nolint(errcheck)
+	defer os.Unsetenv("BUILD_ID")    //nolint(errcheck)
+	defer os.Unsetenv("PROW_JOB_ID") //nolint(errcheck)
+
+	id, err = validateRunID("", true)
+	assert.NoError(t, err)
+	assert.Equal(t, "prow-9f4c", id)
+}
+
+func TestRuleSeverityValidation(t *testing.T) {
+	for _, bad := range [][]string{
+		{"deny"},
+		{"deny=bad"},
+		{"deny-all=error"},
+		{"error=fatal"},
+		{"check_deny=error"},
+	} {
+		_, err := parseRuleSeverities(bad)
+		assert.Error(t, err, bad)
+	}
+
+	severities, err := parseRuleSeverities([]string{"deny=error", "warn=none"})
+	require.NoError(t, err)
+
+	severity, ok := severities.Severity("warn_latest_tag")
+	assert.True(t, ok)
+	assert.Equal(t, result.SeverityNone, severity)
+}
+
+func TestNamespaceValidation(t *testing.T) {
+	assert.NoError(t, validateNamespace("default"))
+	assert.NoError(t, validateNamespace("test-1"))
+	assert.Error(t, validateNamespace(""))
+	assert.Error(t, validateNamespace("Test"))
+	assert.Error(t, validateNamespace("test.one"))
+}
+
+func TestShardDocuments(t *testing.T) {
+	paths := []string{"d.yaml", "b.yaml", "a.yaml", "c.yaml", "e.yaml"}
+
+	_, err := shardDocuments(paths, 0, 0)
+	assert.Error(t, err)
+
+	_, err = shardDocuments(paths, 2, 2)
+	assert.Error(t, err)
+
+	_, err = shardDocuments(paths, -1, 2)
+	assert.Error(t, err)
+
+	shard, err := shardDocuments(paths, 0, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, paths, shard)
+
+	shard, err = shardDocuments(paths, 0, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a.yaml", "c.yaml", "e.yaml"}, shard)
+
+	shard, err = shardDocuments(paths, 1, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"d.yaml", "b.yaml"}, shard)
+
+	// Shards don't depend on the argument order.
+	shard, err = shardDocuments([]string{"e.yaml", "d.yaml", "c.yaml", "b.yaml", "a.yaml"}, 1, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"d.yaml", "b.yaml"}, shard)
+}
+
+func TestShuffleDocuments(t *testing.T) {
+	paths := []string{"a.yaml", "b.yaml", "c.yaml", "d.yaml", "e.yaml", "f.yaml"}
+
+	first := shuffleDocuments(paths, rand.New(rand.NewSource(42)))
+	second := shuffleDocuments(paths, rand.New(rand.NewSource(42)))
+
+	// The same seed gives the same order, and the
+	// paths are not modified.
+	assert.Equal(t, first, second)
+	assert.ElementsMatch(t, paths, first)
+	assert.Equal(t, []string{"a.yaml", "b.yaml", "c.yaml", "d.yaml", "e.yaml", "f.yaml"}, paths)
+}
+
+func TestDocumentTags(t *testing.T) {
+	metas := map[string]doc.Meta{
+		"a.yaml": {Name: "Alpha", Tags: []string{"smoke", "tls"}},
+		"b.yaml": {Tags: []string{"slow"}},
+	}
+
+	paths := []string{"a.yaml", "b.yaml", "c.yaml"}
+
+	assert.Equal(t, []string{"a.yaml"}, filterDocumentTags(paths, metas, []string{"tls"}))
+	assert.Equal(t, []string{"a.yaml", "b.yaml"}, filterDocumentTags(paths, metas, []string{"smoke", "slow"}))
+	assert.Empty(t, filterDocumentTags(paths, metas, []string{"none"}))
+
+	assert.Equal(t, "Alpha", documentDesc("a.yaml", metas))
+	assert.Equal(t, "b.yaml", documentDesc("b.yaml", metas))
+	assert.Equal(t, "c.yaml", documentDesc("c.yaml", metas))
+}
+
+func TestFormatValidation(t *testing.T) {
+	format, files, err := validateFormats([]string{"tree"})
+	assert.NoError(t, err)
+	assert.Equal(t, "tree", format)
+	assert.Empty(t, files)
+
+	format, files, err = validateFormats([]string{"junit=report.xml", "json=results.json"})
+	assert.NoError(t, err)
+	assert.Equal(t, "tree", format)
+	assert.Equal(t, []formatFile{
+		{format: "junit", path: "report.xml"},
+		{format: "json", path: "results.json"},
+	}, files)
+
+	format, files, err = validateFormats([]string{"tap", "json=results.json"})
+	assert.NoError(t, err)
+	assert.Equal(t, "tap", format)
+	assert.Len(t, files, 1)
+
+	_, _, err = validateFormats([]string{"tree", "tap"})
+	assert.Error(t, err)
+
+	_, _, err = validateFormats([]string{"json="})
+	assert.Error(t, err)
+}
@@ -0,0 +1,111 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/projectcontour/integration-tester/pkg/must"
+	"github.com/projectcontour/integration-tester/pkg/test"
+	"github.com/projectcontour/integration-tester/pkg/version"
+
+	"github.com/gosuri/uitable"
+	"github.com/spf13/cobra"
+)
+
+// NewSelfTestCommand returns a command to check the installation
+// of integration-tester on the current host.
+func NewSelfTestCommand() *cobra.Command {
+	selftest := &cobra.Command{
+		Use:   "selftest [FLAGS ...]",
+		Short: "Check that integration-tester works on this host",
+		Long: `Check that integration-tester works on this host
+
+The selftest command runs a set of checks that verify the
+integration-tester binary on the current host, without contacting a
+Kubernetes cluster. This separates installation problems from cluster
+and test problems.
+
+The checks compile the builtin Rego modules, load the builtin echo
+server fixtures, and run an embedded test document whose objects are
+stored directly in the Rego data document instead of being applied to
+a cluster. The document also probes a local TLS server with the
+'tls.probe' builtin. Finally, the checks resolve "localhost", load the
+system root certificates and load the Kubernetes client configuration.
+
+Checks can be skipped with the '--skip' flag, e.g. to skip the
+"kubeconfig" check on hosts that only lint test documents.
+
+The results are printed as a table. The command fails if any check
+fails.
+`,
+		Args: cobra.NoArgs,
+		RunE: selfTestCmd,
+	}
+
+	selftest.Flags().StringSlice("skip", []string{}, "Names of the checks to skip")
+
+	return CommandWithDefaults(selftest)
+}
+
+func selfTestCmd(cmd *cobra.Command, args []string) error {
+	checks := test.SelfChecks()
+	skip := map[string]bool{}
+
+	for _, name := range must.StringSlice(cmd.Flags().GetStringSlice("skip")) {
+		found := false
+		for _, c := range checks {
+			found = found || c.Name == name
+		}
+
+		if !found {
+			return ExitErrorf(EX_USAGE, "unknown self check %q", name)
+		}
+
+		skip[name] = true
+	}
+
+	table := uitable.New()
+	table.AddRow("CHECK", "RESULT", "DETAIL")
+	table.AddRow("binary", "pass",
+		fmt.Sprintf("%s/%s %s/%s", version.Progname, version.Version, runtime.GOOS, runtime.GOARCH))
+
+	failed := 0
+
+	for _, c := range checks {
+		if skip[c.Name] {
+			table.AddRow(c.Name, "skip", "")
+			continue
+		}
+
+		detail, err := c.Run()
+		if err != nil {
+			failed++
+			table.AddRow(c.Name, "fail", err.Error())
+			continue
+		}
+
+		table.AddRow(c.Name, "pass", detail)
+	}
+
+	fmt.Println(table)
+
+	if failed > 0 {
+		return ExitErrorf(EX_FAIL, "%d self check(s) failed", failed)
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/projectcontour/integration-tester/pkg/must"
+	"github.com/projectcontour/integration-tester/pkg/test"
+
+	"github.com/spf13/cobra"
+)
+
+// NewStatsCommand returns a command that reports statistics from a
+// test history file.
+func NewStatsCommand() *cobra.Command {
+	stats := &cobra.Command{
+		Use:   "stats [FLAGS ...] FILE",
+		Short: "Report pass rates and recent failures from a test history file",
+		Long: `Report pass rates and recent failures from a test history file
+
+The stats command reads a history file that was appended to by
+'run --history FILE', and reports the number of runs, the pass rate,
+the average duration and the most recent failures of each test
+document. A document that has both passed and failed is marked as
+flaky. Skipped runs are counted, but don't affect the pass rate or
+the average duration.
+
+Use '--flaky' to only report the flaky test documents, and '--recent'
+to change the number of recent failures that are reported.
+`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return statsCmd(cmd, args[0])
+		},
+	}
+
+	stats.Flags().Bool("flaky", false, "Only report test documents that have both passed and failed")
+	stats.Flags().Int("recent", 3, "Number of recent failures to report for each test document")
+
+	return CommandWithDefaults(stats)
+}
+
+func statsCmd(cmd *cobra.Command, path string) error {
+	recent := must.Int(cmd.Flags().GetInt("recent"))
+	if recent < 0 {
+		return ExitErrorf(EX_USAGE, "invalid number of recent failures %d", recent)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return ExitError{Code: EX_NOINPUT, Err: err}
+	}
+
+	defer f.Close()
+
+	entries, err := test.ReadHistory(f)
+	if err != nil {
+		return ExitErrorf(EX_DATAERR, "failed to read %s: %s", path, err)
+	}
+
+	stats := test.SummarizeHistory(entries, recent)
+
+	if must.Bool(cmd.Flags().GetBool("flaky")) {
+		var flaky []*test.HistoryStats
+		for _, s := range stats {
+			if s.Flaky() {
+				flaky = append(flaky, s)
+			}
+		}
+
+		stats = flaky
+	}
+
+	return writeStats(os.Stdout, stats)
+}
+
+// writeStats writes a table of the test history statistics to out,
+// followed by the recent failures of each test.
+func writeStats(out io.Writer, stats []*test.HistoryStats) error {
+	tab := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(tab, "TEST\tRUNS\tPASSED\tFAILED\tSKIPPED\tPASS RATE\tAVG DURATION\tFLAKY\n")
+	for _, s := range stats {
+		flaky := ""
+		if s.Flaky() {
+			flaky = "yes"
+		}
+
+		fmt.Fprintf(tab, "%s\t%d\t%d\t%d\t%d\t%.1f%%\t%s\t%s\n",
+			s.Test, s.Runs, s.Passed, s.Failed, s.Skipped,
+			s.PassRate()*100, s.AverageDuration.Round(time.Millisecond), flaky)
+	}
+
+	if err := tab.Flush(); err != nil {
+		return err
+	}
+
+	for _, s := range stats {
+		if len(s.RecentFailures) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(out, "\nRecent failures of %s:\n", s.Test)
+		for _, e := range s.RecentFailures {
+			run := e.RunID
+			if run == "" {
+				run = "-"
+			}
+
+			fmt.Fprintf(out, "  %s  run %s  %s\n",
+				e.Start.UTC().Format(time.RFC3339), run, strings.Join(e.FailedSteps, ", "))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/projectcontour/integration-tester/pkg/result"
+	"github.com/projectcontour/integration-tester/pkg/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteStats(t *testing.T) {
+	start := time.Date(2020, 11, 2, 10, 0, 0, 0, time.UTC)
+	stats := test.SummarizeHistory([]test.HistoryEntry{
+		{Test: "one.yaml", Start: start, Duration: time.Second, Outcome: result.SeverityNone},
+		{
+			Test:        "one.yaml",
+			RunID:       "nightly-2",
+			Start:       start.Add(time.Hour),
+			Duration:    3 * time.Second,
+			Outcome:     result.SeverityError,
+			FailedSteps: []string{"one.yaml#0:check"},
+		},
+	}, 3)
+
+	var out bytes.Buffer
+	require.NoError(t, writeStats(&out, stats))
+
+	text := out.String()
+	assert.Contains(t, text, "one.yaml  2     1       1       0        50.0%      2s            yes")
+	assert.Contains(t, text, "Recent failures of one.yaml:\n  2020-11-02T11:00:00Z  run nightly-2  one.yaml#0:check")
+}
@@ -0,0 +1,178 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package anonymize
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Redacted is the value that replaces secret data.
+const Redacted = "REDACTED"
+
+var (
+	ipv4Pattern = regexp.MustCompile(`\b(?:[0-9]{1,3}\.){3}[0-9]{1,3}\b`)
+	ipv6Pattern = regexp.MustCompile(`[0-9a-fA-F]{0,4}(?::[0-9a-fA-F]{0,4}){2,7}`)
+)
+
+// Anonymizer scrubs identifying data from generic (i.e. decoded
+// from JSON) data. Each distinct IP address and identifying term is
+// consistently replaced by the same placeholder, so that relationships
+// between objects are preserved in the scrubbed data.
+type Anonymizer struct {
+	terms        map[string]string
+	replacements map[string]string
+}
+
+// NewAnonymizer returns a new Anonymizer that scrubs the given
+// identifying terms (e.g. cluster names, API server hosts), in
+// addition to IP addresses and Secret data.
+func NewAnonymizer(terms ...string) *Anonymizer {
+	a := &Anonymizer{
+		terms:        map[string]string{},
+		replacements: map[string]string{},
+	}
+
+	for _, t := range terms {
+		if t == "" {
+			continue
+		}
+
+		if _, ok := a.terms[t]; !ok {
+			a.terms[t] = fmt.Sprintf("identifier-%d", len(a.terms)+1)
+		}
+	}
+
+	return a
+}
+
+// Scrub returns a scrubbed copy of the given data.
+func (a *Anonymizer) Scrub(val interface{}) interface{} {
+	switch val := val.(type) {
+	case map[string]interface{}:
+		scrubbed := make(map[string]interface{}, len(val))
+
+		for k, v := range val {
+			scrubbed[a.ScrubString(k)] = a.Scrub(v)
+		}
+
+		if isSecret(val) {
+			redactSecret(scrubbed)
+		}
+
+		return scrubbed
+
+	case []interface{}:
+		scrubbed := make([]interface{}, len(val))
+		for i, v := range val {
+			scrubbed[i] = a.Scrub(v)
+		}
+
+		return scrubbed
+
+	case string:
+		return a.ScrubString(val)
+
+	default:
+		return val
+	}
+}
+
+// ScrubString replaces any identifying terms and IP addresses in s.
+func (a *Anonymizer) ScrubString(s string) string {
+	// Replace the longest terms first so that terms that
+	// contain other terms are replaced in full.
+	terms := make([]string, 0, len(a.terms))
+	for t := range a.terms {
+		terms = append(terms, t)
+	}
+
+	sort.Slice(terms, func(i, j int) bool {
+		return len(terms[i]) > len(terms[j])
+	})
+
+	for _, t := range terms {
+		s = strings.ReplaceAll(s, t, a.terms[t])
+	}
+
+	s = ipv4Pattern.ReplaceAllStringFunc(s, func(addr string) string {
+		if ip := net.ParseIP(addr); ip == nil {
+			return addr
+		}
+
+		return a.replace(addr, func(n int) string {
+			return fmt.Sprintf("10.%d.%d.%d", (n>>16)&0xff, (n>>8)&0xff, n&0xff)
+		})
+	})
+
+	s = ipv6Pattern.ReplaceAllStringFunc(s, func(addr string) string {
+		// Avoid matching short tokens like timestamps.
+		if !strings.Contains(addr, "::") && strings.Count(addr, ":") != 7 {
+			return addr
+		}
+
+		if ip := net.ParseIP(addr); ip == nil {
+			return addr
+		}
+
+		return a.replace(addr, func(n int) string {
+			return fmt.Sprintf("fd00::%x", n)
+		})
+	})
+
+	return s
+}
+
+func (a *Anonymizer) replace(s string, format func(int) string) string {
+	if r, ok := a.replacements[s]; ok {
+		return r
+	}
+
+	r := format(len(a.replacements) + 1)
+	a.replacements[s] = r
+	return r
+}
+
+func isSecret(obj map[string]interface{}) bool {
+	kind, ok := obj["kind"].(string)
+	if !ok || kind != "Secret" {
+		return false
+	}
+
+	apiVersion, ok := obj["apiVersion"].(string)
+	return ok && apiVersion == "v1"
+}
+
+func redactSecret(obj map[string]interface{}) {
+	for _, field := range []string{"data", "stringData"} {
+		if data, ok := obj[field].(map[string]interface{}); ok {
+			for k := range data {
+				data[k] = Redacted
+			}
+		}
+	}
+
+	// The last applied configuration contains a copy of the Secret data.
+	if meta, ok := obj["metadata"].(map[string]interface{}); ok {
+		if annotations, ok := meta["annotations"].(map[string]interface{}); ok {
+			if _, ok := annotations["kubectl.kubernetes.io/last-applied-configuration"]; ok {
+				annotations["kubectl.kubernetes.io/last-applied-configuration"] = Redacted
+			}
+		}
+	}
+}
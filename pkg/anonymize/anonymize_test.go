@@ -0,0 +1,86 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package anonymize
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScrubString(t *testing.T) {
+	a := NewAnonymizer("prod-cluster", "prod-cluster.example.com")
+
+	assert.Equal(t, "identifier-2 at 10.0.0.1", a.ScrubString("prod-cluster.example.com at 172.16.4.2"))
+	assert.Equal(t, "identifier-1 at 10.0.0.1", a.ScrubString("prod-cluster at 172.16.4.2"))
+	assert.Equal(t, "10.0.0.2 and fd00::3", a.ScrubString("192.168.1.1 and 2001:db8::1"))
+	assert.Equal(t, "started at 12:30:05", a.ScrubString("started at 12:30:05"))
+	assert.Equal(t, "version 1.2.3", a.ScrubString("version 1.2.3"))
+}
+
+func TestScrubSecret(t *testing.T) {
+	a := NewAnonymizer()
+
+	secret := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata": map[string]interface{}{
+			"name": "tls",
+			"annotations": map[string]interface{}{
+				"kubectl.kubernetes.io/last-applied-configuration": `{"data":{"key":"c2VjcmV0"}}`,
+			},
+		},
+		"data": map[string]interface{}{
+			"key": "c2VjcmV0",
+		},
+	}
+
+	assert.Equal(t, map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata": map[string]interface{}{
+			"name": "tls",
+			"annotations": map[string]interface{}{
+				"kubectl.kubernetes.io/last-applied-configuration": Redacted,
+			},
+		},
+		"data": map[string]interface{}{
+			"key": Redacted,
+		},
+	}, a.Scrub(secret))
+
+	// The original data must not be modified.
+	assert.Equal(t, "c2VjcmV0", secret["data"].(map[string]interface{})["key"])
+}
+
+func TestScrubNested(t *testing.T) {
+	a := NewAnonymizer("kind-kind")
+
+	scrubbed := a.Scrub([]interface{}{
+		map[string]interface{}{
+			"kind-kind": "10.96.0.1",
+			"ips":       []interface{}{"10.96.0.1", "10.96.0.2"},
+			"count":     2.0,
+		},
+	})
+
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{
+			"identifier-1": "10.0.0.1",
+			"ips":          []interface{}{"10.0.0.1", "10.0.0.2"},
+			"count":        2.0,
+		},
+	}, scrubbed)
+}
@@ -0,0 +1,289 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package attest generates signed in-toto attestations of test runs.
+// The attestation is an in-toto Statement, signed with an Ed25519 key
+// and wrapped in a DSSE envelope.
+package attest
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/projectcontour/integration-tester/pkg/result"
+	"github.com/projectcontour/integration-tester/pkg/test"
+)
+
+const (
+	// StatementType is the in-toto Statement type.
+	StatementType = "https://in-toto.io/Statement/v0.1"
+
+	// PredicateType is the type of the test run predicate.
+	PredicateType = "https://projectcontour.io/integration-tester/run/v1"
+
+	// PayloadType is the DSSE payload type of in-toto statements.
+	PayloadType = "application/vnd.in-toto+json"
+)
+
+// Subject is an artifact that the attestation is about. The subjects
+// of a test run are its test documents.
+type Subject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// Tester identifies the program that ran the tests.
+type Tester struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Commit  string `json:"commit,omitempty"`
+}
+
+// Document is the outcome of a test document.
+type Document struct {
+	Name    string `json:"name"`
+	Outcome string `json:"outcome"`
+}
+
+// Summary counts the outcomes of the test documents.
+type Summary struct {
+	Passed  int  `json:"passed"`
+	Failed  int  `json:"failed"`
+	Skipped int  `json:"skipped"`
+	Success bool `json:"success"`
+}
+
+// Predicate describes a test run.
+type Predicate struct {
+	Tester             Tester     `json:"tester"`
+	RunID              string     `json:"runID,omitempty"`
+	ClusterFingerprint string     `json:"clusterFingerprint,omitempty"`
+	StartedOn          time.Time  `json:"startedOn"`
+	FinishedOn         time.Time  `json:"finishedOn"`
+	Summary            Summary    `json:"summary"`
+	Documents          []Document `json:"documents"`
+}
+
+// Statement is an in-toto Statement about a test run.
+type Statement struct {
+	Type          string    `json:"_type"`
+	Subject       []Subject `json:"subject"`
+	PredicateType string    `json:"predicateType"`
+	Predicate     Predicate `json:"predicate"`
+}
+
+// NewStatement returns a Statement with the given subjects and
+// predicate.
+func NewStatement(subjects []Subject, predicate Predicate) *Statement {
+	return &Statement{
+		Type:          StatementType,
+		Subject:       subjects,
+		PredicateType: PredicateType,
+		Predicate:     predicate,
+	}
+}
+
+// FileSubject returns a Subject for the file at path, with the
+// SHA-256 digest of its contents.
+func FileSubject(path string) (Subject, error) {
+	data, err := ioutil.ReadFile(path) //nolint:gosec
+	if err != nil {
+		return Subject{}, err
+	}
+
+	sum := sha256.Sum256(data)
+
+	return Subject{
+		Name:   path,
+		Digest: map[string]string{"sha256": hex.EncodeToString(sum[:])},
+	}, nil
+}
+
+// Fingerprint returns a stable identifier for a cluster that doesn't
+// reveal the identifying values it is derived from.
+func Fingerprint(values ...string) string {
+	h := sha256.New()
+	for _, v := range values {
+		h.Write([]byte(v))
+		h.Write([]byte{0})
+	}
+
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// Results returns the summary and the outcome of each test document
+// that the JSONWriter recorded. Failed attempts that were retried
+// don't count towards the summary.
+func Results(j *test.JSONWriter) (Summary, []Document) {
+	var summary Summary
+	var docs []Document
+
+	for _, d := range j.Documents {
+		if d.Retried {
+			continue
+		}
+
+		var outcome string
+
+		switch d.Outcome() {
+		case result.SeverityError:
+			outcome = "failed"
+			summary.Failed++
+		case result.SeveritySkip:
+			outcome = "skipped"
+			summary.Skipped++
+		default:
+			outcome = "passed"
+			summary.Passed++
+		}
+
+		docs = append(docs, Document{Name: d.Description, Outcome: outcome})
+	}
+
+	summary.Success = summary.Failed == 0
+	return summary, docs
+}
+
+// Signature is a DSSE signature.
+type Signature struct {
+	KeyID string `json:"keyid"`
+	Sig   string `json:"sig"`
+}
+
+// Envelope is a DSSE envelope.
+type Envelope struct {
+	PayloadType string      `json:"payloadType"`
+	Payload     string      `json:"payload"`
+	Signatures  []Signature `json:"signatures"`
+}
+
+// pae returns the DSSE pre-authentication encoding of the payload,
+// which is the message that is signed.
+func pae(payloadType string, payload []byte) []byte {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "DSSEv1 %d %s %d ", len(payloadType), payloadType, len(payload))
+	buf.Write(payload)
+
+	return buf.Bytes()
+}
+
+// KeyID returns the ID of the public key, which is the hex SHA-256
+// digest of its PKIX encoding.
+func KeyID(pub ed25519.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Sign signs the Statement with the key and returns the DSSE envelope.
+func Sign(s *Statement, key ed25519.PrivateKey) (*Envelope, error) {
+	payload, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+
+	keyID, err := KeyID(key.Public().(ed25519.PublicKey))
+	if err != nil {
+		return nil, err
+	}
+
+	sig := ed25519.Sign(key, pae(PayloadType, payload))
+
+	return &Envelope{
+		PayloadType: PayloadType,
+		Payload:     base64.StdEncoding.EncodeToString(payload),
+		Signatures: []Signature{{
+			KeyID: keyID,
+			Sig:   base64.StdEncoding.EncodeToString(sig),
+		}},
+	}, nil
+}
+
+// Verify checks that the envelope is signed by the key, and returns
+// the Statement that it contains.
+func Verify(e *Envelope, pub ed25519.PublicKey) (*Statement, error) {
+	if e.PayloadType != PayloadType {
+		return nil, fmt.Errorf("unexpected payload type %q", e.PayloadType)
+	}
+
+	payload, err := base64.StdEncoding.DecodeString(e.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payload: %w", err)
+	}
+
+	verified := false
+	for _, s := range e.Signatures {
+		sig, err := base64.StdEncoding.DecodeString(s.Sig)
+		if err != nil {
+			continue
+		}
+
+		if ed25519.Verify(pub, pae(e.PayloadType, payload), sig) {
+			verified = true
+			break
+		}
+	}
+
+	if !verified {
+		return nil, errors.New("no valid signature")
+	}
+
+	var s Statement
+	if err := json.Unmarshal(payload, &s); err != nil {
+		return nil, fmt.Errorf("invalid statement: %w", err)
+	}
+
+	return &s, nil
+}
+
+// ReadPrivateKey reads a PEM encoded PKCS #8 Ed25519 private key
+// (as generated by "openssl genpkey -algorithm ed25519") from path.
+func ReadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	data, err := ioutil.ReadFile(path) //nolint:gosec
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data", path)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: %T is not an Ed25519 private key", path, key)
+	}
+
+	return edKey, nil
+}
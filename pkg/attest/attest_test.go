@@ -0,0 +1,142 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package attest
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/projectcontour/integration-tester/pkg/result"
+	"github.com/projectcontour/integration-tester/pkg/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignVerify(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	s := NewStatement(
+		[]Subject{{Name: "one.yaml", Digest: map[string]string{"sha256": "abc"}}},
+		Predicate{
+			Tester:  Tester{Name: "integration-tester", Version: "v1.0.0"},
+			RunID:   "release-1",
+			Summary: Summary{Passed: 1, Success: true},
+		},
+	)
+
+	e, err := Sign(s, key)
+	require.NoError(t, err)
+	assert.Equal(t, PayloadType, e.PayloadType)
+	require.Len(t, e.Signatures, 1)
+
+	keyID, err := KeyID(pub)
+	require.NoError(t, err)
+	assert.Equal(t, keyID, e.Signatures[0].KeyID)
+
+	verified, err := Verify(e, pub)
+	require.NoError(t, err)
+	assert.Equal(t, StatementType, verified.Type)
+	assert.Equal(t, PredicateType, verified.PredicateType)
+	assert.Equal(t, "release-1", verified.Predicate.RunID)
+	assert.Equal(t, s.Subject, verified.Subject)
+
+	// A different key must not verify.
+	other, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, err = Verify(e, other)
+	assert.Error(t, err)
+
+	// A tampered payload must not verify.
+	e.Payload = e.Payload[1:]
+	_, err = Verify(e, pub)
+	assert.Error(t, err)
+}
+
+func TestReadPrivateKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "attest")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, "key.pem")
+	require.NoError(t, ioutil.WriteFile(path,
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+
+	read, err := ReadPrivateKey(path)
+	require.NoError(t, err)
+	assert.Equal(t, key, read)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("not a key"), 0600))
+	_, err = ReadPrivateKey(path)
+	assert.Error(t, err)
+}
+
+func TestFileSubject(t *testing.T) {
+	dir, err := ioutil.TempDir("", "attest")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte("hello\n"), 0600))
+
+	s, err := FileSubject(path)
+	require.NoError(t, err)
+	assert.Equal(t, path, s.Name)
+	assert.Equal(t,
+		"5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03",
+		s.Digest["sha256"])
+}
+
+func TestResults(t *testing.T) {
+	errorStep := test.JSONStep{Results: []test.JSONResult{{Severity: result.SeverityError}}}
+	skipStep := test.JSONStep{Results: []test.JSONResult{{Severity: result.SeveritySkip}}}
+
+	j := &test.JSONWriter{
+		Documents: []*test.JSONDocument{
+			{Description: "one.yaml"},
+			{Description: "two.yaml", Retried: true, Steps: []test.JSONStep{errorStep}},
+			{Description: "two.yaml", Retries: 1},
+			{Description: "three.yaml", Steps: []test.JSONStep{errorStep}},
+			{Description: "four.yaml", Steps: []test.JSONStep{skipStep}},
+		},
+	}
+
+	summary, docs := Results(j)
+	assert.Equal(t, Summary{Passed: 2, Failed: 1, Skipped: 1}, summary)
+	assert.Equal(t, []Document{
+		{Name: "one.yaml", Outcome: "passed"},
+		{Name: "two.yaml", Outcome: "passed"},
+		{Name: "three.yaml", Outcome: "failed"},
+		{Name: "four.yaml", Outcome: "skipped"},
+	}, docs)
+}
+
+func TestFingerprint(t *testing.T) {
+	assert.Equal(t, Fingerprint("a", "b"), Fingerprint("a", "b"))
+	assert.NotEqual(t, Fingerprint("ab"), Fingerprint("a", "b"))
+}
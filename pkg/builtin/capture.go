@@ -90,6 +90,10 @@ func CaptureAsset(filePath string, xfrm ...NameTransformFunc) error {
 		return nil
 	}
 
+	// Asset names always use slash separators, whatever the
+	// platform's file path separator is.
+	filePath = filepath.ToSlash(filePath)
+
 	for _, x := range xfrm {
 		filePath = x(filePath)
 	}
@@ -0,0 +1,141 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package builtin
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/open-policy-agent/opa/ast"
+)
+
+// Description describes a built-in Rego module.
+type Description struct {
+	// Name is the asset name of the module.
+	Name string `json:"name"`
+	// Package is the full Rego package path of the module,
+	// e.g. "data.builtin.version".
+	Package string `json:"package"`
+	// Doc is the comment block that follows the package
+	// declaration.
+	Doc string `json:"doc"`
+	// Rules is the sorted list of the rules and functions
+	// that the module defines.
+	Rules []string `json:"rules"`
+}
+
+// Summary returns the first sentence of the module documentation.
+func (d Description) Summary() string {
+	doc := strings.Join(strings.Fields(d.Doc), " ")
+	if i := strings.Index(doc, ". "); i >= 0 {
+		return doc[:i+1]
+	}
+
+	return doc
+}
+
+// DescribeModules returns a description of each built-in Rego
+// module, sorted by name.
+func DescribeModules() ([]Description, error) {
+	modules, err := CompileModules()
+	if err != nil {
+		return nil, err
+	}
+
+	var descriptions []Description
+
+	for name, m := range modules {
+		descriptions = append(descriptions, Describe(name, m))
+	}
+
+	sort.Slice(descriptions, func(i, j int) bool {
+		return descriptions[i].Name < descriptions[j].Name
+	})
+
+	return descriptions, nil
+}
+
+// Describe returns a description of the given Rego module.
+func Describe(name string, m *ast.Module) Description {
+	return Description{
+		Name:    name,
+		Package: m.Package.Path.String(),
+		Doc:     moduleDoc(m),
+		Rules:   moduleRules(m),
+	}
+}
+
+// moduleDoc returns the first comment block after the package
+// declaration, as long as it is not attached to a rule.
+func moduleDoc(m *ast.Module) string {
+	pkgRow := m.Package.Location.Row
+
+	var lines []string
+	nextRow := 0
+
+	for _, c := range m.Comments {
+		if c.Location.Row <= pkgRow {
+			continue
+		}
+
+		// Stop at the end of the first comment block.
+		if nextRow != 0 && c.Location.Row != nextRow {
+			break
+		}
+
+		lines = append(lines, strings.TrimSpace(string(c.Text)))
+		nextRow = c.Location.Row + 1
+	}
+
+	// If the next row is a rule, the comment documents the
+	// rule, not the module.
+	for _, r := range m.Rules {
+		if r.Location.Row == nextRow {
+			return ""
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// moduleRules returns the unique rule names in the module. Functions
+// are formatted with their arguments.
+func moduleRules(m *ast.Module) []string {
+	unique := map[string]struct{}{}
+
+	for _, r := range m.Rules {
+		name := r.Head.Name.String()
+
+		if len(r.Head.Args) > 0 {
+			var args []string
+			for _, a := range r.Head.Args {
+				args = append(args, a.String())
+			}
+
+			name = fmt.Sprintf("%s(%s)", name, strings.Join(args, ", "))
+		}
+
+		unique[name] = struct{}{}
+	}
+
+	rules := make([]string, 0, len(unique))
+	for name := range unique {
+		rules = append(rules, name)
+	}
+
+	sort.Strings(rules)
+	return rules
+}
@@ -0,0 +1,62 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package builtin
+
+import (
+	"testing"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDescribeModules(t *testing.T) {
+	descriptions, err := DescribeModules()
+	require.NoError(t, err)
+
+	byName := map[string]Description{}
+	for _, d := range descriptions {
+		byName[d.Name] = d
+	}
+
+	v, ok := byName["pkg/builtin/version.rego"]
+	require.True(t, ok)
+
+	assert.Equal(t, "data.builtin.version", v.Package)
+	assert.Contains(t, v.Rules, "at_least(v, minimum)")
+	assert.Equal(t, `Helpers for comparing semantic versions.`, v.Summary())
+}
+
+func TestModuleDoc(t *testing.T) {
+	m := ast.MustParseModule(`package test
+
+# First line of the module doc.
+# Second line.
+
+# Rule comment.
+p = 1
+`)
+
+	assert.Equal(t, "First line of the module doc.\nSecond line.", moduleDoc(m))
+
+	m = ast.MustParseModule(`package test
+
+# Rule comment.
+p(x) = x
+`)
+
+	assert.Equal(t, "", moduleDoc(m))
+	assert.Equal(t, []string{"p(x)"}, moduleRules(m))
+}
@@ -0,0 +1,101 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package builtin
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/topdown"
+	"github.com/open-policy-agent/opa/topdown/builtins"
+	"github.com/open-policy-agent/opa/types"
+)
+
+// NanosFromSeconds is a Rego builtin that converts a number of
+// seconds to an integer number of nanoseconds:
+//
+//	nanos.from_seconds(1.5) = 1500000000
+//
+// It is an error if the result doesn't fit in a 64-bit integer.
+var NanosFromSeconds = &ast.Builtin{
+	Name: "nanos.from_seconds",
+	Decl: types.NewFunction([]types.Type{types.N}, types.N),
+}
+
+// NanosParseRFC3339 is a Rego builtin that returns the
+// nanoseconds since the Unix epoch of an RFC 3339 timestamp. Unlike
+// "time.parse_rfc3339_ns", it is an error if the timestamp is out of
+// the range of a 64-bit nanosecond time.
+var NanosParseRFC3339 = &ast.Builtin{
+	Name: "nanos.parse_rfc3339",
+	Decl: types.NewFunction([]types.Type{types.S}, types.N),
+}
+
+var (
+	minTime = time.Unix(0, math.MinInt64)
+	maxTime = time.Unix(0, math.MaxInt64)
+)
+
+func init() {
+	ast.RegisterBuiltin(NanosFromSeconds)
+	topdown.RegisterFunctionalBuiltin1(NanosFromSeconds.Name, secondsNanos)
+
+	ast.RegisterBuiltin(NanosParseRFC3339)
+	topdown.RegisterFunctionalBuiltin1(NanosParseRFC3339.Name, parseRFC3339Nanos)
+}
+
+func secondsNanos(a ast.Value) (ast.Value, error) {
+	n, err := builtins.NumberOperand(a, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	// Use exact arithmetic, so that fractional seconds like 0.1
+	// don't pick up floating point rounding errors.
+	secs, ok := new(big.Rat).SetString(string(n))
+	if !ok {
+		return nil, fmt.Errorf("invalid number %q", n)
+	}
+
+	secs.Mul(secs, big.NewRat(int64(time.Second), 1))
+
+	ns := new(big.Int).Quo(secs.Num(), secs.Denom())
+	if !ns.IsInt64() {
+		return nil, fmt.Errorf("%s seconds overflows a nanosecond duration", n)
+	}
+
+	return builtins.IntToNumber(ns), nil
+}
+
+func parseRFC3339Nanos(a ast.Value) (ast.Value, error) {
+	s, err := builtins.StringOperand(a, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := time.Parse(time.RFC3339, string(s))
+	if err != nil {
+		return nil, err
+	}
+
+	if t.Before(minTime) || t.After(maxTime) {
+		return nil, fmt.Errorf("timestamp %q overflows a nanosecond time", s)
+	}
+
+	return builtins.IntToNumber(big.NewInt(t.UnixNano())), nil
+}
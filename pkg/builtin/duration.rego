@@ -0,0 +1,48 @@
+package builtin.duration
+
+# Helpers for durations and for time arithmetic against the timestamps
+# in Kubernetes objects. All durations are returned in nanoseconds,
+# which is the unit used by the Rego time builtins.
+
+# ns returns the given duration in nanoseconds. The duration can be
+# a Go duration string (e.g. "1m30s") or a number of seconds.
+ns(d) = n {
+  is_string(d)
+  n := time.parse_duration_ns(d)
+}
+
+ns(d) = n {
+  is_number(d)
+  n := nanos.from_seconds(d)
+}
+
+# since returns the time elapsed since the given RFC 3339 timestamp.
+since(timestamp) = n {
+  n := time.now_ns() - nanos.parse_rfc3339(timestamp)
+}
+
+older_than(timestamp, d) {
+  since(timestamp) > ns(d)
+}
+
+# condition returns the status condition of the given type.
+condition(obj, type) = c {
+  c := obj.status.conditions[_]
+  c.type == type
+}
+
+# condition_age returns the time elapsed since the status condition
+# of the given type last transitioned.
+condition_age(obj, type) = n {
+  n := since(condition(obj, type).lastTransitionTime)
+}
+
+# condition_stable is true if the status condition of the given type
+# has had the given status for longer than the duration d.
+condition_stable(obj, type, status, d) {
+  c := condition(obj, type)
+  c.status == status
+  older_than(c.lastTransitionTime, d)
+}
+
+# vim: ts=2 sts=2 sw=2 et:
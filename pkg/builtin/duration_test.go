@@ -0,0 +1,64 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package builtin
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDurationNanoseconds(t *testing.T) {
+	assert.Equal(t, json.Number("90000000000"),
+		evalWithResources(t, `data.builtin.duration.ns("1m30s")`, `{}`))
+	assert.Equal(t, json.Number("2000000000"),
+		evalWithResources(t, `data.builtin.duration.ns(2)`, `{}`))
+	assert.Equal(t, json.Number("100000000"),
+		evalWithResources(t, `data.builtin.duration.ns(0.1)`, `{}`))
+	assert.Equal(t, json.Number("1000000000000000000"),
+		evalWithResources(t, `data.builtin.duration.ns(1e9)`, `{}`))
+
+	// 1e10 seconds doesn't fit in a 64-bit nanosecond duration.
+	_, err := evalResources(t, `data.builtin.duration.ns(1e10)`, `{}`)
+	assert.Error(t, err)
+}
+
+func TestDurationConditionStable(t *testing.T) {
+	resources := `{
+  "deployments": {
+    "echo": {
+      "status": {
+        "conditions": [
+          {"type": "Available", "status": "True", "lastTransitionTime": "2020-01-01T00:00:00Z"},
+          {"type": "Progressing", "status": "True", "lastTransitionTime": "2999-01-01T00:00:00Z"}
+        ]
+      }
+    }
+  }
+}`
+
+	query := func(cond string, status string) string {
+		return `data.builtin.duration.condition_stable(data.resources.deployments.echo, "` +
+			cond + `", "` + status + `", "10m")`
+	}
+
+	assert.Equal(t, true, evalWithResources(t, query("Available", "True"), resources))
+	assert.Equal(t, true, evalWithResources(t, "not "+query("Available", "False"), resources))
+
+	// The year 2999 is out of the range of a nanosecond timestamp.
+	_, err := evalResources(t, query("Progressing", "True"), resources)
+	assert.Error(t, err)
+}
@@ -0,0 +1,75 @@
+package builtin.endpoints
+
+# Helpers for inspecting the endpoints of a Service. On clusters that
+# serve EndpointSlices, the slices for a Service are used. Otherwise,
+# we fall back to the Endpoints object for the Service.
+
+ServiceNameLabel := "kubernetes.io/service-name"
+
+# resources returns the collection of resources of the given type
+# in the given namespace.
+resources(namespace, resource) = r {
+  namespace == "default"
+  r := data.resources[resource]
+}
+
+resources(namespace, resource) = r {
+  namespace != "default"
+  r := data.resources[namespace][resource]
+}
+
+# slices returns the set of EndpointSlices that belong to the named Service.
+slices(namespace, name) = s {
+  all := resources(namespace, "endpointslices")
+  s := { slice |
+    slice := all[_]
+    slice.metadata.labels[ServiceNameLabel] == name
+  }
+}
+
+has_slices(namespace, name) {
+  s := slices(namespace, name)
+  count(s) > 0
+}
+
+has_endpoints(namespace, name) {
+  all := resources(namespace, "endpoints")
+  all[name]
+}
+
+# ready_addresses returns the set of ready addresses for the named Service.
+ready_addresses(namespace, name) = addrs {
+  has_slices(namespace, name)
+
+  s := slices(namespace, name)
+  addrs := { addr |
+    ep := s[_].endpoints[_]
+
+    # An unset ready condition should be interpreted as ready.
+    not ep.conditions.ready == false
+
+    addr := ep.addresses[_]
+  }
+}
+
+ready_addresses(namespace, name) = addrs {
+  not has_slices(namespace, name)
+  has_endpoints(namespace, name)
+
+  all := resources(namespace, "endpoints")
+  addrs := { addr | addr := all[name].subsets[_].addresses[_].ip }
+}
+
+ready_addresses(namespace, name) = addrs {
+  not has_slices(namespace, name)
+  not has_endpoints(namespace, name)
+
+  addrs := set()
+}
+
+# ready_count returns the number of ready addresses for the named Service.
+ready_count(namespace, name) = n {
+  n := count(ready_addresses(namespace, name))
+}
+
+# vim: ts=2 sts=2 sw=2 et:
@@ -0,0 +1,109 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/open-policy-agent/opa/storage/inmem"
+	"github.com/stretchr/testify/require"
+)
+
+func evalWithResources(t *testing.T, query string, resources string) interface{} {
+	t.Helper()
+
+	rs, err := evalResources(t, query, resources)
+	require.NoError(t, err)
+	require.Len(t, rs, 1)
+
+	return rs[0].Expressions[0].Value
+}
+
+func evalResources(t *testing.T, query string, resources string) (rego.ResultSet, error) {
+	t.Helper()
+
+	modules, err := CompileModules()
+	require.NoError(t, err)
+
+	compiler := ast.NewCompiler()
+	if compiler.Compile(modules); compiler.Failed() {
+		t.Fatalf("failed to compile builtin modules: %s", compiler.Errors)
+	}
+
+	var data map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(resources), &data))
+
+	return rego.New(
+		rego.Query(query),
+		rego.Compiler(compiler),
+		rego.Store(inmem.NewFromObject(map[string]interface{}{"resources": data})),
+	).Eval(context.Background())
+}
+
+func TestEndpointsFromSlices(t *testing.T) {
+	resources := `{
+  "endpointslices": {
+    "echo-abcde": {
+      "metadata": {"labels": {"kubernetes.io/service-name": "echo"}},
+      "endpoints": [
+        {"addresses": ["10.0.0.1"], "conditions": {"ready": true}},
+        {"addresses": ["10.0.0.2"], "conditions": {"ready": false}},
+        {"addresses": ["10.0.0.3"]}
+      ]
+    },
+    "other-abcde": {
+      "metadata": {"labels": {"kubernetes.io/service-name": "other"}},
+      "endpoints": [
+        {"addresses": ["10.0.0.4"], "conditions": {"ready": true}}
+      ]
+    }
+  },
+  "endpoints": {
+    "echo": {
+      "subsets": [{"addresses": [{"ip": "10.0.0.1"}]}]
+    }
+  }
+}`
+
+	require.Equal(t, json.Number("2"),
+		evalWithResources(t, `data.builtin.endpoints.ready_count("default", "echo")`, resources))
+}
+
+func TestEndpointsFromEndpoints(t *testing.T) {
+	resources := `{
+  "projectcontour": {
+    "endpoints": {
+      "echo": {
+        "subsets": [
+          {"addresses": [{"ip": "10.0.0.1"}, {"ip": "10.0.0.2"}]},
+          {"notReadyAddresses": [{"ip": "10.0.0.3"}]}
+        ]
+      }
+    }
+  }
+}`
+
+	require.Equal(t, json.Number("2"),
+		evalWithResources(t, `data.builtin.endpoints.ready_count("projectcontour", "echo")`, resources))
+}
+
+func TestEndpointsMissing(t *testing.T) {
+	require.Equal(t, json.Number("0"),
+		evalWithResources(t, `data.builtin.endpoints.ready_count("default", "echo")`, `{}`))
+}
@@ -0,0 +1,58 @@
+package builtin.mutations
+
+# Helpers for asserting the mutations that admission webhooks and API
+# defaulting make to applied objects. The harness records a timeline
+# of the differences between each submitted and persisted object at
+# data.test.mutations, in the order that the objects were applied.
+# Each entry has a "sequence" number, the "step" ID, the object
+# "kind", "namespace", "name" and "uid", and a list of "changes". Each
+# change has the field "path", the "op" (add, remove or replace) and
+# the "submitted" and "persisted" field values.
+
+# entries returns the timeline entries for the named object.
+entries(kind, namespace, name) = e {
+  e := [entry |
+    entry := data.test.mutations[_]
+    entry.kind == kind
+    entry.namespace == namespace
+    entry.name == name
+  ]
+}
+
+# changes returns every change to the named object, in timeline order.
+changes(kind, namespace, name) = c {
+  c := [change |
+    entry := entries(kind, namespace, name)[_]
+    change := entry.changes[_]
+  ]
+}
+
+# mutated is true if the field at path of the named object was
+# changed by the API server on any apply.
+mutated(kind, namespace, name, path) {
+  changes(kind, namespace, name)[_].path == path
+}
+
+# persisted returns the value that the API server most recently
+# persisted for the mutated field at path of the named object.
+persisted(kind, namespace, name, path) = v {
+  c := [change | change := changes(kind, namespace, name)[_]; change.path == path]
+  v := c[count(c) - 1].persisted
+}
+
+# first_mutation returns the sequence number of the first apply that
+# changed the field at path of the named object.
+first_mutation(kind, namespace, name, path) = s {
+  s := min({e.sequence |
+    e := entries(kind, namespace, name)[_]
+    e.changes[_].path == path
+  })
+}
+
+# mutated_before is true if the field at path a of the named object
+# was first changed on an earlier apply than the field at path b.
+mutated_before(kind, namespace, name, a, b) {
+  first_mutation(kind, namespace, name, a) < first_mutation(kind, namespace, name, b)
+}
+
+# vim: ts=2 sts=2 sw=2 et:
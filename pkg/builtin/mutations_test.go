@@ -0,0 +1,77 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/open-policy-agent/opa/storage/inmem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMutationsHelpers(t *testing.T) {
+	var mutations []interface{}
+	require.NoError(t, json.Unmarshal([]byte(`[
+  {"sequence": 0, "kind": "Pod", "namespace": "default", "name": "echo", "changes": [
+    {"path": "metadata.labels.injected", "op": "add", "persisted": "first"}
+  ]},
+  {"sequence": 1, "kind": "Service", "namespace": "default", "name": "echo", "changes": []},
+  {"sequence": 2, "kind": "Pod", "namespace": "default", "name": "echo", "changes": [
+    {"path": "metadata.labels.injected", "op": "replace", "submitted": "first", "persisted": "second"},
+    {"path": "spec.priority", "op": "add", "persisted": 100}
+  ]}
+]`), &mutations))
+
+	modules, err := CompileModules()
+	require.NoError(t, err)
+
+	compiler := ast.NewCompiler()
+	if compiler.Compile(modules); compiler.Failed() {
+		t.Fatalf("failed to compile builtin modules: %s", compiler.Errors)
+	}
+
+	eval := func(query string) interface{} {
+		rs, err := rego.New(
+			rego.Query(query),
+			rego.Compiler(compiler),
+			rego.Store(inmem.NewFromObject(map[string]interface{}{
+				"test": map[string]interface{}{"mutations": mutations},
+			})),
+		).Eval(context.Background())
+		require.NoError(t, err)
+
+		if len(rs) != 1 {
+			return nil
+		}
+
+		return rs[0].Expressions[0].Value
+	}
+
+	assert.Equal(t, true,
+		eval(`data.builtin.mutations.mutated("Pod", "default", "echo", "spec.priority")`))
+	assert.Nil(t,
+		eval(`data.builtin.mutations.mutated("Service", "default", "echo", "spec.priority")`))
+	assert.Equal(t, "second",
+		eval(`data.builtin.mutations.persisted("Pod", "default", "echo", "metadata.labels.injected")`))
+	assert.Equal(t, true,
+		eval(`data.builtin.mutations.mutated_before("Pod", "default", "echo", "metadata.labels.injected", "spec.priority")`))
+	assert.Nil(t,
+		eval(`data.builtin.mutations.mutated_before("Pod", "default", "echo", "spec.priority", "metadata.labels.injected")`))
+}
@@ -0,0 +1,17 @@
+package builtin.check.create
+
+# Default check for creating Kubernetes objects.
+
+fatal_create_error[msg] {
+  # If the Error field is present, the create failed.
+  input.error.message
+
+  msg := sprintf("failed to create %s '%s/%s': %s", [
+    input.target.meta.kind,
+    input.target.namespace,
+    input.target.name,
+    input.error.message,
+  ])
+}
+
+# vim: ts=2 sts=2 sw=2 et:
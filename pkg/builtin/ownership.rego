@@ -0,0 +1,44 @@
+package builtin.ownership
+
+# Helpers for detecting controllers that fight the tester over object
+# fields. The harness counts the changes to the fields of the objects
+# that it watches, and stores them at
+# data.test.ownership[resource][namespace][name].fields. Each field
+# has the number of "changes" and the number of "reverts", which are
+# the changes back to a value that the field had before. A field that
+# keeps reverting is flapping between the values of two owners.
+
+# max_reverts is the number of reverts that a field can have before
+# it is considered to be flapping.
+default max_reverts = 2
+
+# history returns the field change history of the named object.
+history(resource, namespace, name) = h {
+  h := data.test.ownership[resource][namespace][name].fields
+}
+
+# flapping_fields returns the set of flapping fields of the named object.
+flapping_fields(resource, namespace, name) = fields {
+  fields := { f | history(resource, namespace, name)[f].reverts > max_reverts }
+}
+
+# is_flapping is true if any field of the named object is flapping.
+is_flapping(resource, namespace, name) {
+  count(flapping_fields(resource, namespace, name)) > 0
+}
+
+# flapping is the set of messages that describe every flapping field
+# of every watched object. Test documents can raise these as errors:
+#
+#   error[msg] { data.builtin.ownership.flapping[msg] }
+flapping[msg] {
+  obj := data.test.ownership[resource][namespace][name]
+  h := obj.fields[field]
+  h.reverts > max_reverts
+
+  msg := sprintf("%s '%s/%s' field %s changed %d times and reverted %d times", [
+    obj.kind, namespace, name, field, h.changes, h.reverts,
+  ])
+}
+
+# vim: ts=2 sts=2 sw=2 et:
@@ -0,0 +1,85 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/open-policy-agent/opa/storage/inmem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOwnershipHelpers(t *testing.T) {
+	var ownership map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+  "deployments": {
+    "projectcontour": {
+      "contour": {
+        "kind": "Deployment",
+        "fields": {
+          "spec.replicas": {"changes": 8, "reverts": 7},
+          "metadata.labels.app": {"changes": 1, "reverts": 0}
+        }
+      },
+      "envoy": {
+        "kind": "Deployment",
+        "fields": {
+          "spec.replicas": {"changes": 2, "reverts": 1}
+        }
+      }
+    }
+  }
+}`), &ownership))
+
+	modules, err := CompileModules()
+	require.NoError(t, err)
+
+	compiler := ast.NewCompiler()
+	if compiler.Compile(modules); compiler.Failed() {
+		t.Fatalf("failed to compile builtin modules: %s", compiler.Errors)
+	}
+
+	eval := func(query string) interface{} {
+		rs, err := rego.New(
+			rego.Query(query),
+			rego.Compiler(compiler),
+			rego.Store(inmem.NewFromObject(map[string]interface{}{
+				"test": map[string]interface{}{"ownership": ownership},
+			})),
+		).Eval(context.Background())
+		require.NoError(t, err)
+
+		if len(rs) != 1 {
+			return nil
+		}
+
+		return rs[0].Expressions[0].Value
+	}
+
+	assert.Equal(t, true,
+		eval(`data.builtin.ownership.is_flapping("deployments", "projectcontour", "contour")`))
+	assert.Nil(t,
+		eval(`data.builtin.ownership.is_flapping("deployments", "projectcontour", "envoy")`))
+	assert.Equal(t, []interface{}{"spec.replicas"},
+		eval(`data.builtin.ownership.flapping_fields("deployments", "projectcontour", "contour")`))
+	assert.Equal(t, []interface{}{
+		"Deployment 'projectcontour/contour' field spec.replicas changed 8 times and reverted 7 times",
+	}, eval(`data.builtin.ownership.flapping`))
+}
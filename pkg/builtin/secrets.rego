@@ -0,0 +1,43 @@
+package builtin.secrets
+
+# Helpers for checking Secrets that were rotated with "$apply: rotate".
+# The harness stores a digest of each rotated Secret at
+# data.test.secrets[namespace][name]. The digest has the "sha256"
+# hash of the Secret data, the hash of each of the data "keys", the
+# "rotated" time and, for TLS Secrets, the certificate "serial".
+
+# digest returns the digest of the named rotated Secret.
+digest(namespace, name) = d {
+  d := data.test.secrets[namespace][name]
+}
+
+# is_rotated is true if the named Secret was rotated.
+is_rotated(namespace, name) {
+  digest(namespace, name)
+}
+
+# hash returns the SHA256 hash of the data of the named rotated Secret.
+hash(namespace, name) = h {
+  h := digest(namespace, name).sha256
+}
+
+# serial returns the certificate serial number of the named rotated
+# TLS Secret, as a lower case hexadecimal string.
+serial(namespace, name) = s {
+  s := digest(namespace, name).serial
+}
+
+# serial_matches is true if the given certificate serial number is
+# the serial number of the named rotated TLS Secret.
+serial_matches(namespace, name, other) {
+  lower(serial(namespace, name)) == lower(trim_left(other, "0"))
+}
+
+# serving_rotated is true if the TLS server at the given address
+# presents the certificate from the named rotated TLS Secret.
+serving_rotated(namespace, name, address, server_name) {
+  probe := tls.probe(address, server_name)
+  serial_matches(namespace, name, probe.serial)
+}
+
+# vim: ts=2 sts=2 sw=2 et:
@@ -0,0 +1,95 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/open-policy-agent/opa/storage/inmem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func evalWithSecrets(t *testing.T, query string, secrets map[string]interface{}) bool {
+	t.Helper()
+
+	modules, err := CompileModules()
+	require.NoError(t, err)
+
+	compiler := ast.NewCompiler()
+	if compiler.Compile(modules); compiler.Failed() {
+		t.Fatalf("failed to compile builtin modules: %s", compiler.Errors)
+	}
+
+	rs, err := rego.New(
+		rego.Query(query),
+		rego.Compiler(compiler),
+		rego.Store(inmem.NewFromObject(map[string]interface{}{
+			"test": map[string]interface{}{"secrets": secrets},
+		})),
+	).Eval(context.Background())
+	require.NoError(t, err)
+
+	return len(rs) == 1 && rs[0].Expressions[0].Value == true
+}
+
+func TestSecretsHelpers(t *testing.T) {
+	var secrets map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+  "projectcontour": {
+    "fallback": {"sha256": "abcd", "serial": "1f2e3d", "keys": {}}
+  }
+}`), &secrets))
+
+	assert.True(t, evalWithSecrets(t,
+		`data.builtin.secrets.is_rotated("projectcontour", "fallback")`, secrets))
+	assert.False(t, evalWithSecrets(t,
+		`data.builtin.secrets.is_rotated("projectcontour", "other")`, secrets))
+	assert.True(t, evalWithSecrets(t,
+		`data.builtin.secrets.hash("projectcontour", "fallback") == "abcd"`, secrets))
+	assert.True(t, evalWithSecrets(t,
+		`data.builtin.secrets.serial_matches("projectcontour", "fallback", "001F2E3D")`, secrets))
+	assert.False(t, evalWithSecrets(t,
+		`data.builtin.secrets.serial_matches("projectcontour", "fallback", "1f2e3e")`, secrets))
+}
+
+func TestSecretsServingRotated(t *testing.T) {
+	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer s.Close()
+
+	addr := strings.TrimPrefix(s.URL, "https://")
+	secrets := map[string]interface{}{
+		"default": map[string]interface{}{
+			"echo": map[string]interface{}{
+				"serial": s.Certificate().SerialNumber.Text(16),
+			},
+			"stale": map[string]interface{}{
+				"serial": "1",
+			},
+		},
+	}
+
+	assert.True(t, evalWithSecrets(t,
+		`data.builtin.secrets.serving_rotated("default", "echo", "`+addr+`", "example.com")`, secrets))
+	assert.False(t, evalWithSecrets(t,
+		`data.builtin.secrets.serving_rotated("default", "stale", "`+addr+`", "example.com")`, secrets))
+}
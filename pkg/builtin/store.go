@@ -0,0 +1,159 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package builtin
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/topdown"
+	"github.com/open-policy-agent/opa/types"
+)
+
+// StoreDiff is a Rego builtin that compares two snapshots of the
+// 'data.resources' tree and returns the paths of the Kubernetes
+// objects that were added, removed or changed between them:
+//
+//	store.diff(a, b) = {
+//	    "added": ["<path>", ...],
+//	    "removed": ["<path>", ...],
+//	    "changed": ["<path>", ...],
+//	}
+//
+// Paths are relative to the snapshot root and separated by '/', e.g.
+// "pods/echo" or "projectcontour/services/envoy". Any object with a
+// "kind" and "metadata" is compared as a whole, and keys that begin
+// with a '.' (e.g. ".versions") are ignored.
+var StoreDiff = &ast.Builtin{
+	Name: "store.diff",
+	Decl: types.NewFunction(
+		[]types.Type{types.A, types.A},
+		types.NewObject(nil, types.NewDynamicProperty(types.S, types.NewArray(nil, types.S))),
+	),
+}
+
+func init() {
+	ast.RegisterBuiltin(StoreDiff)
+	topdown.RegisterFunctionalBuiltin2(StoreDiff.Name, storeDiff)
+}
+
+func storeDiff(a ast.Value, b ast.Value) (ast.Value, error) {
+	before, err := ast.JSON(a)
+	if err != nil {
+		return nil, fmt.Errorf("store.diff: %w", err)
+	}
+
+	after, err := ast.JSON(b)
+	if err != nil {
+		return nil, fmt.Errorf("store.diff: %w", err)
+	}
+
+	d := &treeDiff{}
+	d.compare(nil, before, after)
+
+	return ast.InterfaceToValue(map[string]interface{}{
+		"added":   d.sorted(d.added),
+		"removed": d.sorted(d.removed),
+		"changed": d.sorted(d.changed),
+	})
+}
+
+type treeDiff struct {
+	added   []string
+	removed []string
+	changed []string
+}
+
+func (d *treeDiff) sorted(paths []string) []interface{} {
+	sort.Strings(paths)
+
+	s := make([]interface{}, 0, len(paths))
+	for _, p := range paths {
+		s = append(s, p)
+	}
+
+	return s
+}
+
+// isObject returns whether the value is a Kubernetes object.
+func isObject(v interface{}) bool {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return false
+	}
+
+	_, hasKind := m["kind"]
+	_, hasMeta := m["metadata"]
+	return hasKind && hasMeta
+}
+
+// compare records the differences between the trees a and b, which
+// are both at the given path.
+func (d *treeDiff) compare(path []string, a interface{}, b interface{}) {
+	aMap, aIsMap := a.(map[string]interface{})
+	bMap, bIsMap := b.(map[string]interface{})
+
+	// Compare leaves and objects as a whole.
+	if !aIsMap || !bIsMap || isObject(a) || isObject(b) {
+		if !reflect.DeepEqual(a, b) {
+			d.changed = append(d.changed, strings.Join(path, "/"))
+		}
+
+		return
+	}
+
+	for k, av := range aMap {
+		if strings.HasPrefix(k, ".") {
+			continue
+		}
+
+		p := append(append([]string{}, path...), k)
+		if bv, ok := bMap[k]; ok {
+			d.compare(p, av, bv)
+		} else {
+			d.collect(&d.removed, p, av)
+		}
+	}
+
+	for k, bv := range bMap {
+		if strings.HasPrefix(k, ".") {
+			continue
+		}
+
+		if _, ok := aMap[k]; !ok {
+			d.collect(&d.added, append(append([]string{}, path...), k), bv)
+		}
+	}
+}
+
+// collect appends the paths of all the objects in the tree v to paths.
+func (d *treeDiff) collect(paths *[]string, path []string, v interface{}) {
+	m, ok := v.(map[string]interface{})
+	if !ok || isObject(v) {
+		*paths = append(*paths, strings.Join(path, "/"))
+		return
+	}
+
+	for k, child := range m {
+		if strings.HasPrefix(k, ".") {
+			continue
+		}
+
+		d.collect(paths, append(append([]string{}, path...), k), child)
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package builtin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreDiff(t *testing.T) {
+	before := map[string]interface{}{
+		"pods": map[string]interface{}{
+			"echo": map[string]interface{}{
+				"kind":     "Pod",
+				"metadata": map[string]interface{}{"name": "echo", "resourceVersion": "1"},
+			},
+			"gone": map[string]interface{}{
+				"kind":     "Pod",
+				"metadata": map[string]interface{}{"name": "gone"},
+			},
+		},
+		".versions": map[string]interface{}{"pods": "v1"},
+	}
+
+	after := map[string]interface{}{
+		"pods": map[string]interface{}{
+			"echo": map[string]interface{}{
+				"kind":     "Pod",
+				"metadata": map[string]interface{}{"name": "echo", "resourceVersion": "2"},
+			},
+		},
+		"projectcontour": map[string]interface{}{
+			"services": map[string]interface{}{
+				"envoy": map[string]interface{}{
+					"kind":     "Service",
+					"metadata": map[string]interface{}{"name": "envoy"},
+				},
+			},
+		},
+	}
+
+	rs, err := rego.New(
+		rego.Query(`x := store.diff(input.before, input.after)`),
+		rego.Input(map[string]interface{}{"before": before, "after": after}),
+	).Eval(context.Background())
+	require.NoError(t, err)
+	require.Len(t, rs, 1)
+
+	diff := rs[0].Bindings["x"].(map[string]interface{})
+	assert.Equal(t, []interface{}{"projectcontour/services/envoy"}, diff["added"])
+	assert.Equal(t, []interface{}{"pods/gone"}, diff["removed"])
+	assert.Equal(t, []interface{}{"pods/echo"}, diff["changed"])
+}
+
+func TestStoreDiffUnchanged(t *testing.T) {
+	rs, err := rego.New(
+		rego.Query(`x := store.diff({"pods": {}}, {"pods": {}})`),
+	).Eval(context.Background())
+	require.NoError(t, err)
+	require.Len(t, rs, 1)
+
+	diff := rs[0].Bindings["x"].(map[string]interface{})
+	assert.Empty(t, diff["added"])
+	assert.Empty(t, diff["removed"])
+	assert.Empty(t, diff["changed"])
+}
@@ -0,0 +1,136 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package builtin
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	"github.com/projectcontour/integration-tester/pkg/netproxy"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/topdown"
+	"github.com/open-policy-agent/opa/topdown/builtins"
+	"github.com/open-policy-agent/opa/types"
+)
+
+// TLSProbeTimeout is the time limit for the TLS handshake of the
+// "tls.probe" builtin.
+var TLSProbeTimeout = 5 * time.Second
+
+// TLSProbe is a Rego builtin that connects to a TLS server and
+// returns a summary of the certificate that it presents:
+//
+//	tls.probe(address, server_name) = {
+//	    "serial": "<hex>",
+//	    "subject": "<distinguished name>",
+//	    "dns_names": [...],
+//	    "not_after": "<RFC3339 time>",
+//	}
+//
+// The server certificate is not verified, since tests commonly use
+// self-signed certificates. The connection goes through the probe
+// proxy, if one is configured.
+var TLSProbe = &ast.Builtin{
+	Name: "tls.probe",
+	Decl: types.NewFunction(
+		[]types.Type{types.S, types.S},
+		types.NewObject(nil, types.NewDynamicProperty(types.S, types.A)),
+	),
+}
+
+func init() {
+	ast.RegisterBuiltin(TLSProbe)
+	topdown.RegisterBuiltinFunc(TLSProbe.Name,
+		func(bctx topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
+			// Probe within the evaluation context, so that
+			// the probe is bounded by the I/O budget of the
+			// check.
+			v, err := tlsProbe(bctx.Context, operands[0].Value, operands[1].Value)
+			if err != nil {
+				if _, ok := err.(builtins.ErrOperand); ok {
+					return &topdown.Error{
+						Code:     topdown.TypeErr,
+						Message:  fmt.Sprintf("%s: %s", TLSProbe.Name, err),
+						Location: bctx.Location,
+					}
+				}
+
+				return &topdown.Error{
+					Code:     topdown.BuiltinErr,
+					Message:  fmt.Sprintf("%s: %s", TLSProbe.Name, err),
+					Location: bctx.Location,
+				}
+			}
+
+			return iter(ast.NewTerm(v))
+		})
+}
+
+func tlsProbe(ctx context.Context, a ast.Value, b ast.Value) (ast.Value, error) {
+	address, err := builtins.StringOperand(a, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	serverName, err := builtins.StringOperand(b, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, TLSProbeTimeout)
+	defer cancel()
+
+	raw, err := netproxy.DialContext(ctx, string(address), "https")
+	if err != nil {
+		return nil, err
+	}
+
+	conn := tls.Client(raw, &tls.Config{
+		ServerName:         string(serverName),
+		InsecureSkipVerify: true, //nolint(gosec)
+	})
+
+	defer conn.Close()
+
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	if err := conn.Handshake(); err != nil {
+		return nil, err
+	}
+
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate from %s", address)
+	}
+
+	cert := certs[0]
+	names := make([]interface{}, 0, len(cert.DNSNames))
+	for _, n := range cert.DNSNames {
+		names = append(names, n)
+	}
+
+	return ast.InterfaceToValue(map[string]interface{}{
+		"serial":    cert.SerialNumber.Text(16),
+		"subject":   cert.Subject.String(),
+		"dns_names": names,
+		"not_after": cert.NotAfter.UTC().Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,56 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package builtin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTLSProbe(t *testing.T) {
+	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer s.Close()
+
+	addr := strings.TrimPrefix(s.URL, "https://")
+
+	rs, err := rego.New(
+		rego.Query(`x := tls.probe("` + addr + `", "example.com")`),
+	).Eval(context.Background())
+	require.NoError(t, err)
+	require.Len(t, rs, 1)
+
+	probe := rs[0].Bindings["x"].(map[string]interface{})
+	assert.Equal(t, s.Certificate().SerialNumber.Text(16), probe["serial"])
+	assert.Contains(t, probe["dns_names"], "example.com")
+}
+
+func TestTLSProbeFailure(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer s.Close()
+
+	addr := strings.TrimPrefix(s.URL, "http://")
+
+	_, err := rego.New(
+		rego.Query(`x := tls.probe("` + addr + `", "example.com")`),
+	).Eval(context.Background())
+	assert.Error(t, err)
+}
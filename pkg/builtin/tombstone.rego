@@ -0,0 +1,52 @@
+package builtin.tombstone
+
+# Helpers for asserting that objects stay deleted. When the harness
+# sees an object being deleted, it stores a tombstone at the same path
+# under data.test.tombstones that the object had under data.resources.
+# The "deleted" field of the tombstone is the RFC 3339 timestamp of
+# the deletion.
+#
+# Checking that an object is absent can pass spuriously in the window
+# between the object being deleted and a controller recreating it.
+# Requiring the object to have been absent for a grace period avoids
+# this.
+
+# keys returns the path of an object under data.resources as an array
+# of keys. The path can already be an array, e.g. ["pods", "echo"], or
+# it can be a string of keys separated by '/', e.g. "pods/echo".
+keys(path) = k {
+  is_array(path)
+  k := path
+}
+
+keys(path) = k {
+  is_string(path)
+  k := split(trim(path, "/"), "/")
+}
+
+# exists is true if there is an object at the given path.
+exists(path) {
+  walk(data.resources, [keys(path), _])
+}
+
+# tombstone returns the tombstone of the object at the given path.
+tombstone(path) = t {
+  walk(data.test.tombstones, [keys(path), t])
+}
+
+# absent is true if there is no object at the given path, and it was
+# deleted longer ago than the grace duration. The grace duration can
+# be a Go duration string or a number of seconds. An object that was
+# never seen has no tombstone, and is considered to have always been
+# absent.
+absent(path, grace) {
+  not exists(path)
+  not tombstone(path)
+}
+
+absent(path, grace) {
+  not exists(path)
+  data.builtin.duration.older_than(tombstone(path).deleted, grace)
+}
+
+# vim: ts=2 sts=2 sw=2 et:
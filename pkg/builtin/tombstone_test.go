@@ -0,0 +1,82 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/open-policy-agent/opa/storage/inmem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTombstoneAbsent(t *testing.T) {
+	recent := time.Now().UTC().Format(time.RFC3339Nano)
+
+	var data map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+  "resources": {
+    "pods": {
+      "recreated": {"metadata": {"name": "recreated"}}
+    }
+  },
+  "test": {
+    "tombstones": {
+      "pods": {
+        "recreated": {"kind": "Pod", "deleted": "2020-01-01T00:00:00Z"},
+        "old": {"kind": "Pod", "deleted": "2020-01-01T00:00:00Z"},
+        "recent": {"kind": "Pod", "deleted": "`+recent+`"}
+      }
+    }
+  }
+}`), &data))
+
+	modules, err := CompileModules()
+	require.NoError(t, err)
+
+	compiler := ast.NewCompiler()
+	if compiler.Compile(modules); compiler.Failed() {
+		t.Fatalf("failed to compile builtin modules: %s", compiler.Errors)
+	}
+
+	absent := func(path string) bool {
+		rs, err := rego.New(
+			rego.Query(`data.builtin.tombstone.absent(`+path+`, "1m")`),
+			rego.Compiler(compiler),
+			rego.Store(inmem.NewFromObject(data)),
+		).Eval(context.Background())
+		require.NoError(t, err)
+
+		return len(rs) == 1
+	}
+
+	// Deleted longer ago than the grace period.
+	assert.True(t, absent(`"pods/old"`))
+	assert.True(t, absent(`["pods", "old"]`))
+
+	// Deleted within the grace period.
+	assert.False(t, absent(`"pods/recent"`))
+
+	// Deleted, then recreated.
+	assert.False(t, absent(`"pods/recreated"`))
+
+	// Never seen.
+	assert.True(t, absent(`"pods/missing"`))
+}
@@ -0,0 +1,44 @@
+package builtin.version
+
+# Helpers for comparing semantic versions. Versions may have a leading
+# "v" and may omit the minor or patch number, so Kubernetes versions
+# (e.g. "v1.19.2") and API server versions (e.g. "1.19") are accepted.
+
+# normalize returns the given version as a full semantic version.
+normalize(v) = n {
+  s := trim_prefix(v, "v")
+  count(split(s, ".")) >= 3
+  n := s
+}
+
+normalize(v) = n {
+  s := trim_prefix(v, "v")
+  count(split(s, ".")) == 2
+  n := concat("", [s, ".0"])
+}
+
+normalize(v) = n {
+  s := trim_prefix(v, "v")
+  count(split(s, ".")) == 1
+  n := concat("", [s, ".0.0"])
+}
+
+is_valid(v) {
+  semver.is_valid(normalize(v))
+}
+
+# compare returns -1 if a is older than b, 0 if they are the same
+# version, and 1 if a is newer than b.
+compare(a, b) = c {
+  c := semver.compare(normalize(a), normalize(b))
+}
+
+at_least(v, minimum) {
+  compare(v, minimum) >= 0
+}
+
+older_than(v, other) {
+  compare(v, other) < 0
+}
+
+# vim: ts=2 sts=2 sw=2 et:
@@ -0,0 +1,45 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package builtin
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersionCompare(t *testing.T) {
+	cases := map[string]json.Number{
+		`data.builtin.version.compare("v1.19.2", "1.19.2")`:   "0",
+		`data.builtin.version.compare("v1.18", "v1.19.0")`:    "-1",
+		`data.builtin.version.compare("1.20", "1.9")`:         "1",
+		`data.builtin.version.compare("v2", "v1.99.99")`:      "1",
+		`data.builtin.version.compare("1.19.0-rc.1", "1.19")`: "-1",
+	}
+
+	for query, want := range cases {
+		assert.Equal(t, want, evalWithResources(t, query, `{}`), query)
+	}
+}
+
+func TestVersionAtLeast(t *testing.T) {
+	assert.Equal(t, true, evalWithResources(t,
+		`data.builtin.version.at_least("v1.19.2", "1.18")`, `{}`))
+	assert.Equal(t, true, evalWithResources(t,
+		`not data.builtin.version.at_least("v1.17.2", "1.18")`, `{}`))
+	assert.Equal(t, true, evalWithResources(t,
+		`data.builtin.version.older_than("v1.17.2", "1.18")`, `{}`))
+}
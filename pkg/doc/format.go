@@ -0,0 +1,137 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package doc
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/projectcontour/integration-tester/pkg/utils"
+
+	"github.com/open-policy-agent/opa/format"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// formatPackage is the placeholder package that Rego fragments are
+// formatted in, since the Rego formatter needs a complete module.
+const formatPackage = "package fmt\n"
+
+// Format returns the canonical formatting of the test document.
+// Kubernetes objects are re-encoded as YAML with the canonical
+// indentation, Rego fragments are formatted with the OPA formatter,
+// and every fragment is preceded by a "---" separator. Any other
+// fragments are only stripped of leading blank lines and trailing
+// whitespace.
+//
+// The document should be read with ReadDocument, not ReadFile, so
+// that formatting doesn't expand any includes.
+func Format(d *Document) ([]byte, error) {
+	var out bytes.Buffer
+
+	// The front-matter isn't one of the document parts, but it
+	// needs to be kept in its place at the start of the document.
+	writeMeta := func() error {
+		data, err := formatObject(d.metaPart.Bytes)
+		if err != nil {
+			return fmt.Errorf("lines %s: %w", d.metaPart.Location, err)
+		}
+
+		out.WriteString("---\n")
+		out.WriteString(strings.TrimRight(strings.TrimLeft(string(data), "\r\n"), " \t\r\n"))
+		out.WriteString("\n")
+		return nil
+	}
+
+	for i := range d.Parts {
+		p := &d.Parts[i]
+
+		if d.metaPart != nil && d.metaIndex == i {
+			if err := writeMeta(); err != nil {
+				return nil, err
+			}
+		}
+
+		fragType, err := p.Decode()
+		if err != nil {
+			if regoErr := utils.AsRegoCompilationErr(err); regoErr != nil {
+				err = regoErr
+			}
+
+			return nil, fmt.Errorf("lines %s: %w", p.Location, err)
+		}
+
+		var data []byte
+
+		switch fragType {
+		case FragmentTypeObject:
+			data, err = formatObject(p.Bytes)
+		case FragmentTypeModule:
+			if isSkipFragment(p) {
+				data, err = formatObject(p.Bytes)
+			} else {
+				data, err = formatRego(p.Location.String(), p.Bytes)
+			}
+		default:
+			data = p.Bytes
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("lines %s: %w", p.Location, err)
+		}
+
+		out.WriteString("---\n")
+		out.WriteString(strings.TrimRight(strings.TrimLeft(string(data), "\r\n"), " \t\r\n"))
+		out.WriteString("\n")
+	}
+
+	if d.metaPart != nil && d.metaIndex >= len(d.Parts) {
+		if err := writeMeta(); err != nil {
+			return nil, err
+		}
+	}
+
+	return out.Bytes(), nil
+}
+
+// formatObject re-encodes a YAML object, preserving its comments.
+func formatObject(data []byte) ([]byte, error) {
+	node, err := yaml.Parse(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := node.String()
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(s), nil
+}
+
+// formatRego formats a Rego fragment, which has no package
+// declaration.
+func formatRego(filename string, data []byte) ([]byte, error) {
+	out, err := format.Source(filename, append([]byte(formatPackage), data...))
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.HasPrefix(out, []byte(formatPackage)) {
+		return nil, fmt.Errorf("unexpected Rego formatter output")
+	}
+
+	return bytes.TrimLeft(bytes.TrimPrefix(out, []byte(formatPackage)), "\n"), nil
+}
@@ -0,0 +1,126 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package doc
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func formatString(t *testing.T, data string) string {
+	t.Helper()
+
+	d, err := ReadDocument(strings.NewReader(data))
+	require.NoError(t, err)
+
+	out, err := Format(d)
+	require.NoError(t, err)
+
+	return string(out)
+}
+
+func TestFormat(t *testing.T) {
+	out := formatString(t, `apiVersion: v1
+kind: Service
+metadata:
+    # The echo service.
+    name: echo
+---
+error_fail[msg]{msg:="fail"}
+---
+
+# Just a comment.
+`)
+
+	assert.Equal(t, `---
+apiVersion: v1
+kind: Service
+metadata:
+  # The echo service.
+  name: echo
+---
+error_fail[msg] {
+	msg := "fail"
+}
+---
+# Just a comment.
+`, out)
+}
+
+func TestFormatIdempotent(t *testing.T) {
+	once := formatString(t, `---
+apiVersion: v1
+kind: Service
+metadata:
+  name: echo
+$check: |
+  error[msg] {
+    msg := "fail"
+  }
+---
+# $when: data.test.params.tls == "true"
+error_no_tls[msg] {
+  not data.resources.secrets["tls-cert"]
+  msg := "no TLS secret"
+}
+`)
+
+	twice := formatString(t, once)
+	assert.Equal(t, once, twice)
+}
+
+func TestFormatInvalid(t *testing.T) {
+	d, err := ReadDocument(bytes.NewBufferString(`---
+error[msg] {
+`))
+	require.NoError(t, err)
+
+	_, err = Format(d)
+	assert.Error(t, err)
+}
+
+func TestFormatMeta(t *testing.T) {
+	out := formatString(t, `# Leading comment.
+---
+$meta:
+    name: TLS termination
+---
+error_fail[msg]{msg:="fail"}
+`)
+
+	assert.Equal(t, `---
+# Leading comment.
+---
+$meta:
+  name: TLS termination
+---
+error_fail[msg] {
+	msg := "fail"
+}
+`, out)
+
+	out = formatString(t, `$meta:
+  name: Only front-matter
+`)
+
+	assert.Equal(t, `---
+$meta:
+  name: Only front-matter
+`, out)
+}
@@ -18,6 +18,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/projectcontour/integration-tester/pkg/utils"
 
@@ -103,8 +104,33 @@ type Fragment struct {
 	Type     FragmentType
 	Location Location
 
+	// Group identifies the includes that the fragment was read
+	// through. Each include adds the position of the included
+	// file in the including document, so the fragments that were
+	// read from the same included file, or from the files that it
+	// includes, share a Group prefix. The fragments of the top-level
+	// document have no Group.
+	Group []int
+
 	object *unstructured.Unstructured
 	module *ast.Module
+	items  []Fragment
+}
+
+// InGroup returns whether the fragment was read from the file that
+// the given group identifies, or from a file that it includes.
+func (f *Fragment) InGroup(group []int) bool {
+	if len(f.Group) < len(group) {
+		return false
+	}
+
+	for i := range group {
+		if f.Group[i] != group[i] {
+			return false
+		}
+	}
+
+	return true
 }
 
 // Object returns the Kubernetes object if there is one.
@@ -117,6 +143,17 @@ func (f *Fragment) Object() *unstructured.Unstructured {
 	}
 }
 
+// Items returns a fragment for each of the objects in a Kubernetes
+// List object, in order. It returns nil if the fragment is not a List.
+func (f *Fragment) Items() []Fragment {
+	switch f.Type {
+	case FragmentTypeObject:
+		return f.items
+	default:
+		return nil
+	}
+}
+
 // Rego returns the Rego module if there is one.
 func (f *Fragment) Rego() *ast.Module {
 	switch f.Type {
@@ -127,6 +164,40 @@ func (f *Fragment) Rego() *ast.Module {
 	}
 }
 
+// Description returns the first paragraph of the comment block that
+// leads the fragment, with the comment markers removed and the lines
+// joined by spaces. YAML and Rego both use "#" comments. The paragraph
+// ends at an empty comment line, or at the first line that is not a
+// comment. Editor modelines are ignored.
+func (f *Fragment) Description() string {
+	var words []string
+
+	for _, line := range strings.Split(string(f.Bytes), "\n") {
+		line = strings.TrimSpace(line)
+
+		// Skip blank lines before the comment block.
+		if line == "" && len(words) == 0 {
+			continue
+		}
+
+		if !strings.HasPrefix(line, "#") {
+			break
+		}
+
+		text := strings.TrimSpace(strings.TrimLeft(line, "#"))
+		switch {
+		case strings.HasPrefix(text, "vim:"):
+			continue
+		case text == "" && len(words) > 0:
+			return strings.Join(words, " ")
+		case text != "":
+			words = append(words, text)
+		}
+	}
+
+	return strings.Join(words, " ")
+}
+
 func hasKindVersion(u *unstructured.Unstructured) bool {
 	k := u.GetObjectKind().GroupVersionKind()
 	return len(k.Version) > 0 && len(k.Kind) > 0
@@ -175,8 +246,34 @@ func (f *Fragment) Decode() (FragmentType, error) {
 	if u, err := decodeYAMLOrJSON(f.Bytes); err == nil {
 		// It's only a valid object if it has a version & kind.
 		if hasKindVersion(u) {
+			items, err := decodeListItems(f.Location, u)
+			if err != nil {
+				return FragmentTypeInvalid,
+					utils.ChainErrors(&InvalidFragmentErr{Type: FragmentTypeObject}, err)
+			}
+
 			f.Type = FragmentTypeObject
 			f.object = u
+			f.items = items
+			return f.Type, nil
+		}
+
+		// A skip fragment is a Rego skip rule in YAML form.
+		src, err := skipModule(u)
+		if err != nil {
+			return FragmentTypeInvalid,
+				utils.ChainErrors(&InvalidFragmentErr{Type: FragmentTypeModule}, err)
+		}
+
+		if src != nil {
+			m, err := utils.ParseCheckFragment(f.Location.String(), string(src))
+			if err != nil {
+				return FragmentTypeInvalid,
+					utils.ChainErrors(&InvalidFragmentErr{Type: FragmentTypeModule}, err)
+			}
+
+			f.Type = FragmentTypeModule
+			f.module = m
 			return f.Type, nil
 		}
 
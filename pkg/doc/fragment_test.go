@@ -146,3 +146,35 @@ metadata:
 		Want: FragmentTypeEmpty,
 	})
 }
+
+func TestFragmentDescription(t *testing.T) {
+	desc := func(data string) string {
+		f := Fragment{Bytes: []byte(data)}
+		return f.Description()
+	}
+
+	assert.Equal(t, "", desc(`
+apiVersion: v1
+kind: Service
+`))
+
+	assert.Equal(t, "Create the echo service that the ingress routes to.", desc(`
+# Create the echo service that the
+# ingress routes to.
+apiVersion: v1
+kind: Service
+`))
+
+	assert.Equal(t, "Check that the ingress is valid.", desc(`
+# Check that the ingress is valid.
+#
+# The status is updated asynchronously.
+package test
+`))
+
+	assert.Equal(t, "Check the status.", desc(`
+# vim: ts=2 sts=2 sw=2 et:
+## Check the status.
+package test
+`))
+}
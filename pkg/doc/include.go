@@ -0,0 +1,198 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package doc
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// IncludeKey is the key of a fragment that includes the fragments
+// of another document, e.g.:
+//
+//	$include: common/echo.yaml
+//
+// The value can also be a list of paths, whose fragments are
+// included in order. Relative paths are resolved from the directory
+// of the including document. The Kubernetes objects of the included
+// document are reordered so that their dependencies are applied first
+// (see DependsOnKey).
+//
+// The kinds that the front-matter of an included document requires
+// are added to those of the including document. The rest of the
+// included front-matter (e.g. its name and tags) is ignored.
+const IncludeKey = "$include"
+
+// includePaths returns the paths that the fragment includes, or
+// nil if this is not an include fragment.
+func includePaths(f *Fragment) ([]string, error) {
+	u, err := decodeYAMLOrJSON(f.Bytes)
+	if err != nil {
+		return nil, nil
+	}
+
+	val, ok := u.Object[IncludeKey]
+	if !ok {
+		return nil, nil
+	}
+
+	if len(u.Object) != 1 {
+		return nil, fmt.Errorf("%q fragment must not have other fields", IncludeKey)
+	}
+
+	var paths []string
+
+	switch v := val.(type) {
+	case string:
+		paths = append(paths, v)
+	case []interface{}:
+		for _, p := range v {
+			path, ok := p.(string)
+			if !ok {
+				return nil, fmt.Errorf("%q value must be a file path or a list of file paths", IncludeKey)
+			}
+
+			paths = append(paths, path)
+		}
+	}
+
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("%q value must be a file path or a list of file paths", IncludeKey)
+	}
+
+	for _, path := range paths {
+		if path == "" {
+			return nil, fmt.Errorf("%q value must be a file path or a list of file paths", IncludeKey)
+		}
+	}
+
+	return paths, nil
+}
+
+// expandIncludes replaces each include fragment in the document with
+// the fragments of the included document, and each kustomize fragment
+// with the objects that the kustomization renders. The included fragments
+// keep the file name and line numbers of the document they were
+// read from. The stack holds the paths of the documents that are
+// currently being expanded, and is used to detect include cycles.
+func expandIncludes(d *Document, stack []string) error {
+	var parts []Fragment
+
+	// Each included file is a new group of fragments.
+	group := 0
+
+	for i := range d.Parts {
+		part := d.Parts[i]
+
+		paths, err := includePaths(&part)
+		if err != nil {
+			return fmt.Errorf("%s: lines %s: %w", d.Name, part.Location, err)
+		}
+
+		if paths == nil {
+			dir, err := kustomizePath(&part)
+			if err != nil {
+				return fmt.Errorf("%s: lines %s: %w", d.Name, part.Location, err)
+			}
+
+			if dir == "" {
+				parts = append(parts, part)
+				continue
+			}
+
+			rendered, err := kustomizeFragments(d, part, dir)
+			if err != nil {
+				return err
+			}
+
+			parts = append(parts, rendered...)
+			continue
+		}
+
+		for _, path := range paths {
+			grouped, err := includeFile(d, part, path, stack)
+			if err != nil {
+				return err
+			}
+
+			group++
+			for j := range grouped {
+				grouped[j].Group = append([]int{group}, grouped[j].Group...)
+			}
+
+			parts = append(parts, grouped...)
+		}
+	}
+
+	d.Parts = parts
+	return nil
+}
+
+// includeFile reads the fragments of the document at path, which is
+// included by the given fragment of d.
+func includeFile(d *Document, part Fragment, path string, stack []string) ([]Fragment, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(d.Name), path)
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range stack {
+		if s == abs {
+			return nil, fmt.Errorf("%s: lines %s: include cycle: %s",
+				d.Name, part.Location, strings.Join(append(stack, abs), " -> "))
+		}
+	}
+
+	included, err := readFile(path, append(stack[:len(stack):len(stack)], abs))
+	if err != nil {
+		return nil, fmt.Errorf("%s: lines %s: failed to include %q: %w",
+			d.Name, part.Location, path, err)
+	}
+
+	// The included fragments are a group, whose objects
+	// are applied in dependency order.
+	grouped, err := orderObjects(included.Name, included.Parts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: lines %s: failed to include %q: %w",
+			d.Name, part.Location, path, err)
+	}
+
+	mergeRequires(d, included)
+
+	return grouped, nil
+}
+
+// mergeRequires adds the kinds that the included document requires
+// to the kinds that d requires.
+func mergeRequires(d *Document, included *Document) {
+	for _, gvk := range included.Meta.Requires {
+		found := false
+		for _, r := range d.Meta.Requires {
+			if r == gvk {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			d.Meta.Requires = append(d.Meta.Requires, gvk)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package doc
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeDocs(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "include")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func TestReadFileInclude(t *testing.T) {
+	dir := writeDocs(t, map[string]string{
+		"test.yaml": `first
+---
+$include: common/echo.yaml
+---
+last
+`,
+		"common/echo.yaml": `echo
+---
+$include: service.yaml
+`,
+		"common/service.yaml": `service
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	d, err := ReadFile(filepath.Join(dir, "test.yaml"))
+	if err != nil {
+		t.Fatalf("read error: %s", err)
+	}
+
+	var got []string
+	for _, p := range d.Parts {
+		got = append(got, string(p.Bytes)+"@"+filepath.Base(p.Location.Filename))
+	}
+
+	// Included fragments keep their bytes exactly as they were
+	// read, so a fragment that ends before a separator keeps its
+	// trailing newline, just like it does in the including document.
+	want := "first\n@test.yaml echo\n@echo.yaml service@service.yaml last@test.yaml"
+	if strings.Join(got, " ") != want {
+		t.Fatalf("got %q, want %q", strings.Join(got, " "), want)
+	}
+
+	if d.Parts[3].Location.Start != 5 {
+		t.Fatalf("got start line %d, want 5", d.Parts[3].Location.Start)
+	}
+}
+
+func TestReadFileIncludeCycle(t *testing.T) {
+	dir := writeDocs(t, map[string]string{
+		"a.yaml": `$include: b.yaml`,
+		"b.yaml": `$include: a.yaml`,
+	})
+	defer os.RemoveAll(dir)
+
+	_, err := ReadFile(filepath.Join(dir, "a.yaml"))
+	if err == nil || !strings.Contains(err.Error(), "include cycle") {
+		t.Fatalf("expected include cycle error, got %v", err)
+	}
+}
+
+func TestReadFileIncludeInvalid(t *testing.T) {
+	dir := writeDocs(t, map[string]string{
+		"a.yaml": `$include: b.yaml
+extra: field
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	_, err := ReadFile(filepath.Join(dir, "a.yaml"))
+	if err == nil || !strings.Contains(err.Error(), "must not have other fields") {
+		t.Fatalf("expected invalid include error, got %v", err)
+	}
+}
+
+func TestReadFileIncludeList(t *testing.T) {
+	dir := writeDocs(t, map[string]string{
+		"test.yaml": `$include:
+- common/echo.yaml
+- common/service.yaml
+---
+last
+`,
+		"common/echo.yaml":    `echo`,
+		"common/service.yaml": `service`,
+	})
+	defer os.RemoveAll(dir)
+
+	d, err := ReadFile(filepath.Join(dir, "test.yaml"))
+	if err != nil {
+		t.Fatalf("read error: %s", err)
+	}
+
+	var got []string
+	for _, p := range d.Parts {
+		got = append(got, string(p.Bytes))
+	}
+
+	if want := "echo service last"; strings.Join(got, " ") != want {
+		t.Fatalf("got %q, want %q", strings.Join(got, " "), want)
+	}
+
+	dir = writeDocs(t, map[string]string{
+		"test.yaml": `$include: [common/echo.yaml, 42]`,
+	})
+	defer os.RemoveAll(dir)
+
+	_, err = ReadFile(filepath.Join(dir, "test.yaml"))
+	if err == nil || !strings.Contains(err.Error(), "list of file paths") {
+		t.Fatalf("expected invalid include error, got %v", err)
+	}
+}
+
+func TestReadFileIncludeMeta(t *testing.T) {
+	dir := writeDocs(t, map[string]string{
+		"test.yaml": `$meta:
+  name: test
+  requires:
+  - v1/Service
+---
+$include: common/proxy.yaml
+`,
+		"common/proxy.yaml": `$meta:
+  name: proxy
+  tags: [common]
+  requires:
+  - v1/Service
+  - projectcontour.io/v1/HTTPProxy
+---
+proxy
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	d, err := ReadFile(filepath.Join(dir, "test.yaml"))
+	if err != nil {
+		t.Fatalf("read error: %s", err)
+	}
+
+	// Only the requirements of the included front-matter are kept.
+	if d.Meta.Name != "test" || len(d.Meta.Tags) != 0 {
+		t.Fatalf("got name %q and tags %q, want the front-matter of test.yaml",
+			d.Meta.Name, d.Meta.Tags)
+	}
+
+	var requires []string
+	for _, gvk := range d.Meta.Requires {
+		requires = append(requires, gvk.String())
+	}
+
+	want := "/v1, Kind=Service projectcontour.io/v1, Kind=HTTPProxy"
+	if strings.Join(requires, " ") != want {
+		t.Fatalf("got requires %q, want %q", strings.Join(requires, " "), want)
+	}
+}
+
+func TestReadFileIncludeGroups(t *testing.T) {
+	dir := writeDocs(t, map[string]string{
+		"test.yaml": `first
+---
+$include: [common/outer.yaml, common/outer.yaml]
+---
+last
+`,
+		"common/outer.yaml": `outer
+---
+$include: inner.yaml
+`,
+		"common/inner.yaml": `inner`,
+	})
+	defer os.RemoveAll(dir)
+
+	d, err := ReadFile(filepath.Join(dir, "test.yaml"))
+	if err != nil {
+		t.Fatalf("read error: %s", err)
+	}
+
+	var got []string
+	for _, p := range d.Parts {
+		got = append(got, fmt.Sprintf("%s%v", strings.TrimSpace(string(p.Bytes)), p.Group))
+	}
+
+	want := "first[] outer[1] inner[1 1] outer[2] inner[2 1] last[]"
+	if strings.Join(got, " ") != want {
+		t.Fatalf("got %q, want %q", strings.Join(got, " "), want)
+	}
+
+	// The first include group holds the fragments of the
+	// first included file and of the file that it includes.
+	var grouped []string
+	for _, p := range d.Parts {
+		if p.InGroup([]int{1}) {
+			grouped = append(grouped, fmt.Sprintf("%v", p.Group))
+		}
+	}
+
+	if want := "[1] [1 1]"; strings.Join(grouped, " ") != want {
+		t.Fatalf("got group %q, want %q", strings.Join(grouped, " "), want)
+	}
+}
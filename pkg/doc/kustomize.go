@@ -0,0 +1,110 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package doc
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/krusty"
+)
+
+// KustomizeKey is the key of a fragment that is replaced by the
+// Kubernetes objects that kustomize renders from a kustomization
+// directory, e.g.:
+//
+//	$kustomize: overlays/test
+//
+// Relative paths are resolved from the directory of the document.
+const KustomizeKey = "$kustomize"
+
+// kustomizePath returns the kustomization directory of the fragment,
+// or an empty string if this is not a kustomize fragment.
+func kustomizePath(f *Fragment) (string, error) {
+	u, err := decodeYAMLOrJSON(f.Bytes)
+	if err != nil {
+		return "", nil
+	}
+
+	val, ok := u.Object[KustomizeKey]
+	if !ok {
+		return "", nil
+	}
+
+	if len(u.Object) != 1 {
+		return "", fmt.Errorf("%q fragment must not have other fields", KustomizeKey)
+	}
+
+	path, ok := val.(string)
+	if !ok || path == "" {
+		return "", fmt.Errorf("%q value must be a directory path", KustomizeKey)
+	}
+
+	return path, nil
+}
+
+// kustomizeFragments renders the kustomization at path, which is
+// named by the given fragment of d, and returns a fragment for each
+// of the rendered objects. The fragments are located at their lines
+// in the rendered output of the kustomization directory.
+func kustomizeFragments(d *Document, part Fragment, path string) ([]Fragment, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(d.Name), path)
+	}
+
+	fail := func(err error) error {
+		return fmt.Errorf("%s: lines %s: failed to kustomize %q: %w",
+			d.Name, part.Location, path, err)
+	}
+
+	// Render with the same options as "kustomize build".
+	k := krusty.MakeKustomizer(filesys.MakeFsOnDisk(), krusty.MakeDefaultOptions())
+
+	resources, err := k.Run(path)
+	if err != nil {
+		return nil, fail(err)
+	}
+
+	out, err := resources.AsYaml()
+	if err != nil {
+		return nil, fail(err)
+	}
+
+	rendered, err := ReadDocument(bytes.NewReader(out))
+	if err != nil {
+		return nil, fail(err)
+	}
+
+	var parts []Fragment
+
+	for _, p := range rendered.Parts {
+		fragType, err := p.Decode()
+		switch {
+		case err != nil:
+			return nil, fail(err)
+		case fragType == FragmentTypeEmpty:
+			continue
+		case fragType != FragmentTypeObject:
+			return nil, fail(fmt.Errorf("rendered lines %s are not a Kubernetes object", p.Location))
+		}
+
+		p.Location.Filename = path
+		parts = append(parts, p)
+	}
+
+	return parts, nil
+}
@@ -0,0 +1,118 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package doc
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// kustomization is an overlay that renames and labels the objects
+// of its base, so the tests can tell that it was rendered.
+var kustomization = map[string]string{
+	"base/kustomization.yaml": `resources:
+- service.yaml
+- serviceaccount.yaml
+`,
+	"base/service.yaml": `apiVersion: v1
+kind: Service
+metadata:
+  name: echo
+spec:
+  ports:
+  - port: 80
+`,
+	"base/serviceaccount.yaml": `apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: echo
+`,
+	"overlays/test/kustomization.yaml": `namePrefix: test-
+commonLabels:
+  app: echo
+resources:
+- ../../base
+`,
+}
+
+func TestReadFileKustomize(t *testing.T) {
+	files := map[string]string{
+		"test.yaml": `first
+---
+$kustomize: overlays/test
+---
+last
+`,
+	}
+
+	for name, content := range kustomization {
+		files[name] = content
+	}
+
+	dir := writeDocs(t, files)
+	defer os.RemoveAll(dir)
+
+	d, err := ReadFile(filepath.Join(dir, "test.yaml"))
+	require.NoError(t, err)
+	require.Len(t, d.Parts, 4)
+
+	assert.Equal(t, "first", strings.TrimSpace(string(d.Parts[0].Bytes)))
+	assert.Equal(t, "last", strings.TrimSpace(string(d.Parts[3].Bytes)))
+
+	// Kustomize renders the objects in its legacy order, which
+	// puts the ServiceAccount first.
+	account := d.Parts[1].Object()
+	assert.Equal(t, "ServiceAccount", account.GetKind())
+	assert.Equal(t, "test-echo", account.GetName())
+	assert.Equal(t, map[string]string{"app": "echo"}, account.GetLabels())
+
+	service := d.Parts[2].Object()
+	assert.Equal(t, "Service", service.GetKind())
+	assert.Equal(t, "test-echo", service.GetName())
+	assert.Equal(t, map[string]string{"app": "echo"}, service.GetLabels())
+
+	assert.Equal(t, filepath.Join(dir, "overlays/test"), d.Parts[2].Location.Filename)
+	assert.Greater(t, d.Parts[2].Location.Start, d.Parts[1].Location.End)
+}
+
+func TestReadFileKustomizeInvalid(t *testing.T) {
+	for name, files := range map[string]map[string]string{
+		"other fields": {
+			"test.yaml": "$kustomize: overlays/test\nfoo: bar\n",
+		},
+		"empty path": {
+			"test.yaml": "$kustomize: ''\n",
+		},
+		"missing kustomization": {
+			"test.yaml": "$kustomize: missing\n",
+		},
+		"missing resource": {
+			"test.yaml":                        "$kustomize: overlays/test\n",
+			"overlays/test/kustomization.yaml": "resources:\n- missing.yaml\n",
+		},
+	} {
+		dir := writeDocs(t, files)
+
+		_, err := ReadFile(filepath.Join(dir, "test.yaml"))
+		assert.Error(t, err, name)
+
+		os.RemoveAll(dir)
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package doc
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// decodeListItems returns a fragment for each of the items of a
+// Kubernetes List object, such as a "v1.List" or a typed list like a
+// "ConfigMapList". It returns nil if the object is not a list. Special
+// pseudo-fields can't be used on a list or on its items, since the
+// items are applied together.
+func decodeListItems(loc Location, u *unstructured.Unstructured) ([]Fragment, error) {
+	if !u.IsList() {
+		return nil, nil
+	}
+
+	if key := specialField(u); key != "" {
+		return nil, fmt.Errorf("the %q field can't be used on a %s", key, u.GetKind())
+	}
+
+	list, err := u.ToList()
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", u.GetKind(), err)
+	}
+
+	if len(list.Items) == 0 {
+		return nil, fmt.Errorf("%s has no items", u.GetKind())
+	}
+
+	items := make([]Fragment, 0, len(list.Items))
+
+	for i := range list.Items {
+		item := &list.Items[i]
+
+		switch {
+		case !hasKindVersion(item):
+			return nil, fmt.Errorf("%s item %d is not a Kubernetes object", u.GetKind(), i)
+		case item.IsList():
+			return nil, fmt.Errorf("%s item %d is a nested list", u.GetKind(), i)
+		}
+
+		if key := specialField(item); key != "" {
+			return nil, fmt.Errorf("the %q field can't be used on %s item %d", key, u.GetKind(), i)
+		}
+
+		// JSON is valid YAML, so the item can be hydrated
+		// like any other object fragment.
+		data, err := json.Marshal(item.Object)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s item %d: %w", u.GetKind(), i, err)
+		}
+
+		items = append(items, Fragment{
+			Bytes:    data,
+			Type:     FragmentTypeObject,
+			Location: loc,
+			object:   item,
+		})
+	}
+
+	return items, nil
+}
+
+// specialField returns the name of the first top-level pseudo-field of
+// the object, in sorted order, or an empty string if it has none.
+func specialField(u *unstructured.Unstructured) string {
+	first := ""
+
+	for key := range u.Object {
+		if strings.HasPrefix(key, "$") && (first == "" || key < first) {
+			first = key
+		}
+	}
+
+	return first
+}
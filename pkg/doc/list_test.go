@@ -0,0 +1,114 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package doc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeList(t *testing.T) {
+	f := Fragment{Bytes: []byte(`
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: one
+- apiVersion: v1
+  kind: Service
+  metadata:
+    name: two
+`)}
+
+	fragType, err := f.Decode()
+	require.NoError(t, err)
+	assert.EqualValues(t, FragmentTypeObject, fragType)
+
+	items := f.Items()
+	require.Len(t, items, 2)
+
+	for i, name := range []string{"one", "two"} {
+		assert.EqualValues(t, FragmentTypeObject, items[i].Type)
+		assert.Equal(t, name, items[i].Object().GetName())
+
+		// The item bytes decode to the same object.
+		_, err := items[i].Decode()
+		require.NoError(t, err)
+		assert.Equal(t, name, items[i].Object().GetName())
+	}
+
+	f = Fragment{Bytes: []byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: one
+`)}
+
+	_, err = f.Decode()
+	require.NoError(t, err)
+	assert.Nil(t, f.Items())
+}
+
+func TestDecodeInvalidList(t *testing.T) {
+	for name, data := range map[string]string{
+		"empty": `
+apiVersion: v1
+kind: List
+items: []
+`,
+		"not an object": `
+apiVersion: v1
+kind: List
+items:
+- name: one
+`,
+		"nested list": `
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: List
+  items:
+  - apiVersion: v1
+    kind: ConfigMap
+`,
+		"special list field": `
+apiVersion: v1
+kind: List
+$check: false
+items:
+- apiVersion: v1
+  kind: ConfigMap
+`,
+		"special item field": `
+apiVersion: v1
+kind: ConfigMapList
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  $check: false
+`,
+	} {
+		f := Fragment{Bytes: []byte(data)}
+
+		fragType, err := f.Decode()
+		assert.Error(t, err, name)
+		assert.EqualValues(t, FragmentTypeInvalid, fragType, name)
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package doc
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	sigyaml "sigs.k8s.io/yaml"
+)
+
+// MetaKey is the key of the optional front-matter fragment that
+// describes a test document, e.g.:
+//
+//	$meta:
+//	  name: HTTPProxy TLS termination
+//	  description: Checks that TLS is terminated at Envoy.
+//	  tags: [tls, httpproxy]
+//	  check-timeout: 2m
+//	  requires:
+//	  - projectcontour.io/v1/HTTPProxy
+//
+// The front-matter must be the first fragment of the document.
+// Each entry in "requires" is a Requirement that the cluster must
+// meet for the document to run, in the same form as the entries of
+// a "$requires" declaration.
+const MetaKey = "$meta"
+
+// Meta is the front-matter metadata of a test document.
+type Meta struct {
+	Name         string
+	Description  string
+	Tags         []string
+	CheckTimeout time.Duration
+	Requires     []Requirement
+}
+
+// HasTag returns whether the document is tagged with any of the given tags.
+func (m Meta) HasTag(tags ...string) bool {
+	for _, want := range tags {
+		for _, t := range m.Tags {
+			if t == want {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+type metaFragment struct {
+	Meta *struct {
+		Name         string   `json:"name"`
+		Description  string   `json:"description"`
+		Tags         []string `json:"tags"`
+		CheckTimeout string   `json:"check-timeout"`
+		Requires     []string `json:"requires"`
+	} `json:"$meta"`
+	Requires []string `json:"$requires"`
+}
+
+// isMetaFragment returns whether the fragment is a front-matter
+// fragment, i.e. it has a "$meta" or a "$requires" key.
+func isMetaFragment(f *Fragment) bool {
+	u, err := decodeYAMLOrJSON(f.Bytes)
+	if err != nil {
+		return false
+	}
+
+	_, meta := u.Object[MetaKey]
+	_, requires := u.Object[RequiresKey]
+	return meta || requires
+}
+
+// parseMeta parses the front-matter fragment.
+func parseMeta(f *Fragment) (Meta, error) {
+	var frag metaFragment
+
+	if err := sigyaml.UnmarshalStrict(f.Bytes, &frag); err != nil {
+		return Meta{}, fmt.Errorf("invalid %q fragment: %w", MetaKey, err)
+	}
+
+	meta := Meta{}
+
+	for _, r := range frag.Requires {
+		req, err := ParseRequirement(r)
+		if err != nil {
+			return Meta{}, fmt.Errorf("invalid %q declaration: %w", RequiresKey, err)
+		}
+
+		meta.Requires = append(meta.Requires, req)
+	}
+
+	if frag.Meta == nil {
+		if meta.Requires == nil {
+			return Meta{}, fmt.Errorf("%q value must be a map", MetaKey)
+		}
+
+		return meta, nil
+	}
+
+	meta = Meta{
+		Name:        strings.TrimSpace(frag.Meta.Name),
+		Description: strings.TrimSpace(frag.Meta.Description),
+		Tags:        frag.Meta.Tags,
+		Requires:    meta.Requires,
+	}
+
+	if frag.Meta.CheckTimeout != "" {
+		timeout, err := time.ParseDuration(frag.Meta.CheckTimeout)
+		if err != nil {
+			return Meta{}, fmt.Errorf("invalid %q check-timeout: %w", MetaKey, err)
+		}
+
+		if timeout <= 0 {
+			return Meta{}, fmt.Errorf("invalid %q check-timeout %q", MetaKey, frag.Meta.CheckTimeout)
+		}
+
+		meta.CheckTimeout = timeout
+	}
+
+	for _, r := range frag.Meta.Requires {
+		req, err := ParseRequirement(r)
+		if err != nil {
+			return Meta{}, fmt.Errorf("invalid %q requires: %w", MetaKey, err)
+		}
+
+		meta.Requires = append(meta.Requires, req)
+	}
+
+	return meta, nil
+}
+
+// extractMeta removes the front-matter fragment from the document
+// and stores its metadata. Empty fragments may precede the
+// front-matter, but it is an error for it to follow any other
+// fragment.
+func extractMeta(d *Document) error {
+	var parts []Fragment
+
+	leading := true
+
+	for i := range d.Parts {
+		part := d.Parts[i]
+
+		if !isMetaFragment(&part) {
+			if decodeEmpty(part.Bytes) != nil {
+				leading = false
+			}
+
+			parts = append(parts, part)
+			continue
+		}
+
+		if !leading {
+			return fmt.Errorf("lines %s: %q and %q must be in the first fragment of the document",
+				part.Location, MetaKey, RequiresKey)
+		}
+
+		meta, err := parseMeta(&part)
+		if err != nil {
+			return fmt.Errorf("lines %s: %w", part.Location, err)
+		}
+
+		d.Meta = meta
+		d.metaPart = &d.Parts[i]
+		d.metaIndex = len(parts)
+		leading = false
+	}
+
+	d.Parts = parts
+	return nil
+}
@@ -0,0 +1,106 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package doc
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestReadDocumentMeta(t *testing.T) {
+	d, err := ReadDocument(bytes.NewBufferString(`# Leading comment.
+---
+$meta:
+  name: TLS termination
+  description: Checks TLS.
+  tags: [tls, httpproxy]
+  check-timeout: 2m
+  requires:
+  - projectcontour.io/v1/HTTPProxy
+  - v1/Service
+---
+one
+`))
+	if err != nil {
+		t.Fatalf("read error: %s", err)
+	}
+
+	want := Meta{
+		Name:         "TLS termination",
+		Description:  "Checks TLS.",
+		Tags:         []string{"tls", "httpproxy"},
+		CheckTimeout: 2 * time.Minute,
+		Requires: []Requirement{
+			{Kind: schema.GroupVersionKind{Group: "projectcontour.io", Version: "v1", Kind: "HTTPProxy"}},
+			{Kind: schema.GroupVersionKind{Version: "v1", Kind: "Service"}},
+		},
+	}
+
+	if diff := cmp.Diff(want, d.Meta); diff != "" {
+		t.Fatalf(diff)
+	}
+
+	if len(d.Parts) != 2 || string(d.Parts[1].Bytes) != "one" {
+		t.Fatalf("unexpected parts %v", d.Parts)
+	}
+
+	if !d.Meta.HasTag("smoke", "tls") || d.Meta.HasTag("smoke") {
+		t.Fatalf("unexpected tag matches for %v", d.Meta.Tags)
+	}
+}
+
+func TestReadDocumentMetaErrors(t *testing.T) {
+	for name, tc := range map[string]struct {
+		data string
+		want string
+	}{
+		"not first": {
+			data: "one\n---\n$meta:\n  name: late\n",
+			want: "must be in the first fragment",
+		},
+		"unknown field": {
+			data: "$meta:\n  title: wrong\n",
+			want: "invalid \"$meta\" fragment",
+		},
+		"bad timeout": {
+			data: "$meta:\n  check-timeout: soon\n",
+			want: "check-timeout",
+		},
+		"bad requirement": {
+			data: "$meta:\n  requires: [Service]\n",
+			want: "must be an API group, group version, kind or Kubernetes version",
+		},
+		"bad version requirement": {
+			data: "$requires: [kubernetes >= latest]\n",
+			want: "invalid \"$requires\" declaration",
+		},
+		"late requires": {
+			data: "one\n---\n$requires: [apps/v1]\n",
+			want: "must be in the first fragment",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ReadDocument(bytes.NewBufferString(tc.data))
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("got error %v, want %q", err, tc.want)
+			}
+		})
+	}
+}
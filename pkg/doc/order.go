@@ -0,0 +1,247 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package doc
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// DependsOnKey is the key of an object field that names the objects
+// that must be applied before it, e.g.:
+//
+//	$depends-on: customresourcedefinition/httpproxies.projectcontour.io
+//
+// or
+//
+//	$depends-on:
+//	- namespace/projectcontour
+//	- secret/envoy-cert
+//
+// Objects are named by their lower-cased kind and name.
+const DependsOnKey = "$depends-on"
+
+// kindOrder is the order in which objects of the listed kinds are
+// applied within an include group. This follows the kubectl and Helm
+// install ordering, so that namespaces and custom resource
+// definitions are created before the objects that need them. Objects
+// of other kinds (e.g. custom resources) are applied last.
+var kindOrder = []string{
+	"Namespace",
+	"NetworkPolicy",
+	"ResourceQuota",
+	"LimitRange",
+	"PodSecurityPolicy",
+	"PodDisruptionBudget",
+	"ServiceAccount",
+	"Secret",
+	"ConfigMap",
+	"StorageClass",
+	"PersistentVolume",
+	"PersistentVolumeClaim",
+	"CustomResourceDefinition",
+	"ClusterRole",
+	"ClusterRoleBinding",
+	"Role",
+	"RoleBinding",
+	"Service",
+	"DaemonSet",
+	"Pod",
+	"ReplicationController",
+	"ReplicaSet",
+	"Deployment",
+	"HorizontalPodAutoscaler",
+	"StatefulSet",
+	"Job",
+	"CronJob",
+	"Ingress",
+	"APIService",
+	"ValidatingWebhookConfiguration",
+	"MutatingWebhookConfiguration",
+}
+
+// kindRank returns the position of the kind in the apply order.
+func kindRank(kind string) int {
+	for i, k := range kindOrder {
+		if k == kind {
+			return i
+		}
+	}
+
+	return len(kindOrder)
+}
+
+// objectID returns the name that an object is referred to by in a
+// DependsOnKey field.
+func objectID(u *unstructured.Unstructured) string {
+	return fmt.Sprintf("%s/%s", strings.ToLower(u.GetKind()), u.GetName())
+}
+
+// objectDepends returns the names of the objects that the object
+// depends on.
+func objectDepends(u *unstructured.Unstructured) ([]string, error) {
+	val, ok := u.Object[DependsOnKey]
+	if !ok {
+		return nil, nil
+	}
+
+	switch val := val.(type) {
+	case string:
+		return []string{val}, nil
+	case []interface{}:
+		deps := make([]string, 0, len(val))
+
+		for _, v := range val {
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("%q value must be a list of object names", DependsOnKey)
+			}
+
+			deps = append(deps, s)
+		}
+
+		return deps, nil
+	default:
+		return nil, fmt.Errorf("%q value must be a list of object names", DependsOnKey)
+	}
+}
+
+// orderObjects sorts each run of consecutive Kubernetes object
+// fragments, so that objects are applied in kind order (see
+// kindOrder), and after any objects named in their DependsOnKey
+// field. Objects of the same rank keep their document order. Other
+// fragments (e.g. Rego checks) stay where they are, and separate the
+// runs of objects.
+func orderObjects(name string, parts []Fragment) ([]Fragment, error) {
+	ordered := make([]Fragment, 0, len(parts))
+	seen := map[string]bool{}
+
+	var run []orderNode
+
+	flush := func() error {
+		sorted, err := sortRun(name, run, seen)
+		if err != nil {
+			return err
+		}
+
+		ordered = append(ordered, sorted...)
+		run = nil
+		return nil
+	}
+
+	for _, part := range parts {
+		u, err := decodeYAMLOrJSON(part.Bytes)
+		if err != nil || !hasKindVersion(u) {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+
+			ordered = append(ordered, part)
+			continue
+		}
+
+		deps, err := objectDepends(u)
+		if err != nil {
+			return nil, fmt.Errorf("%s: lines %s: %w", name, part.Location, err)
+		}
+
+		run = append(run, orderNode{
+			part: part,
+			id:   objectID(u),
+			rank: kindRank(u.GetKind()),
+			deps: deps,
+		})
+	}
+
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	return ordered, nil
+}
+
+type orderNode struct {
+	part Fragment
+	id   string
+	rank int
+	deps []string
+}
+
+// sortRun topologically sorts a run of object fragments. At each
+// step, the first object of the lowest rank whose dependencies are
+// satisfied is chosen. The seen map holds the names of the objects
+// that were applied by earlier runs.
+func sortRun(name string, run []orderNode, seen map[string]bool) ([]Fragment, error) {
+	inRun := map[string]bool{}
+	for _, n := range run {
+		inRun[n.id] = true
+	}
+
+	for _, n := range run {
+		for _, d := range n.deps {
+			if !inRun[d] && !seen[d] {
+				return nil, fmt.Errorf("%s: lines %s: %q depends on %q, which is not applied before it",
+					name, n.part.Location, n.id, d)
+			}
+		}
+	}
+
+	sorted := make([]Fragment, 0, len(run))
+	done := make([]bool, len(run))
+
+	for len(sorted) < len(run) {
+		next := -1
+
+		for i, n := range run {
+			if done[i] || !dependsSatisfied(n, seen) {
+				continue
+			}
+
+			if next == -1 || n.rank < run[next].rank {
+				next = i
+			}
+		}
+
+		if next == -1 {
+			var cycle []string
+			for i, n := range run {
+				if !done[i] {
+					cycle = append(cycle, n.id)
+				}
+			}
+
+			return nil, fmt.Errorf("%s: %q dependency cycle: %s",
+				name, DependsOnKey, strings.Join(cycle, ", "))
+		}
+
+		done[next] = true
+		seen[run[next].id] = true
+		sorted = append(sorted, run[next].part)
+	}
+
+	return sorted, nil
+}
+
+func dependsSatisfied(n orderNode, seen map[string]bool) bool {
+	for _, d := range n.deps {
+		if !seen[d] {
+			return false
+		}
+	}
+
+	return true
+}
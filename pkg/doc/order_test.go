@@ -0,0 +1,107 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package doc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fragments(data ...string) []Fragment {
+	parts := make([]Fragment, 0, len(data))
+	for i, d := range data {
+		parts = append(parts, Fragment{
+			Bytes:    []byte(d),
+			Location: Location{Start: i + 1, End: i + 1},
+		})
+	}
+
+	return parts
+}
+
+func fragmentText(parts []Fragment) []string {
+	var text []string
+	for _, p := range parts {
+		text = append(text, string(p.Bytes))
+	}
+
+	return text
+}
+
+const (
+	orderNamespace = "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: projectcontour\n"
+	orderCRD       = "apiVersion: apiextensions.k8s.io/v1\nkind: CustomResourceDefinition\nmetadata:\n  name: httpproxies.projectcontour.io\n"
+	orderProxy     = "apiVersion: projectcontour.io/v1\nkind: HTTPProxy\nmetadata:\n  name: echo\n"
+	orderService   = "apiVersion: v1\nkind: Service\nmetadata:\n  name: echo\n"
+	orderCheck     = "error[msg] { msg := \"fail\" }"
+)
+
+func TestOrderObjectsByKind(t *testing.T) {
+	ordered, err := orderObjects("test.yaml",
+		fragments(orderProxy, orderService, orderCRD, orderNamespace))
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		[]string{orderNamespace, orderCRD, orderService, orderProxy},
+		fragmentText(ordered))
+}
+
+func TestOrderObjectsKeepsChecks(t *testing.T) {
+	ordered, err := orderObjects("test.yaml",
+		fragments(orderService, orderNamespace, orderCheck, orderProxy, orderCRD))
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		[]string{orderNamespace, orderService, orderCheck, orderCRD, orderProxy},
+		fragmentText(ordered))
+}
+
+func TestOrderObjectsDependsOn(t *testing.T) {
+	service := orderService + "$depends-on: httpproxy/echo\n"
+
+	ordered, err := orderObjects("test.yaml", fragments(service, orderProxy))
+	require.NoError(t, err)
+	assert.Equal(t, []string{orderProxy, service}, fragmentText(ordered))
+
+	_, err = orderObjects("test.yaml", fragments(orderService+"$depends-on: secret/missing\n"))
+	assert.Error(t, err)
+
+	_, err = orderObjects("test.yaml", fragments(
+		service, orderProxy+"$depends-on:\n- service/echo\n"))
+	assert.Error(t, err)
+}
+
+func TestReadFileIncludeOrder(t *testing.T) {
+	dir := writeDocs(t, map[string]string{
+		"test.yaml":   "$include: common.yaml\n",
+		"common.yaml": orderService + "---\n" + orderNamespace,
+	})
+	defer os.RemoveAll(dir)
+
+	d, err := ReadFile(filepath.Join(dir, "test.yaml"))
+	require.NoError(t, err)
+
+	// Reordering moves the fragments as they were read, so the
+	// Service keeps the newline that preceded its separator and the
+	// Namespace, which ended the file, has none.
+	assert.Equal(t, []string{
+		"apiVersion: v1\nkind: Namespace\nmetadata:\n  name: projectcontour",
+		"apiVersion: v1\nkind: Service\nmetadata:\n  name: echo\n",
+	}, fragmentText(d.Parts))
+}
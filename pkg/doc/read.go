@@ -19,6 +19,7 @@ import (
 	"bytes"
 	"io"
 	"os"
+	"path/filepath"
 	"regexp"
 
 	"github.com/projectcontour/integration-tester/pkg/must"
@@ -28,12 +29,21 @@ import (
 // Document is a collection of related Fragments.
 type Document struct {
 	Name  string
+	Meta  Meta
 	Parts []Fragment
+
+	// metaPart is the front-matter fragment that Meta was
+	// parsed from, which is kept so that it can be formatted.
+	// It preceded the part at metaIndex.
+	metaPart  *Fragment
+	metaIndex int
 }
 
 // ReadDocument reads a stream of Fragments that are separated by a
 // YAML document separator (see https://yaml.org/spec/1.0/#id2561718).
 // The contents of each Fragment is opaque and need not be YAML.
+// A leading front-matter fragment (see MetaKey) is removed from the
+// Fragments and stored in the Document Meta.
 func ReadDocument(in io.Reader) (*Document, error) {
 	filename := ""
 	startLine := 0
@@ -102,11 +112,26 @@ func ReadDocument(in io.Reader) (*Document, error) {
 		return nil, err
 	}
 
+	if err := extractMeta(&doc); err != nil {
+		return nil, err
+	}
+
 	return &doc, nil
 }
 
-// ReadFile reads a Document from the given file path.
+// ReadFile reads a Document from the given file path. Any include
+// fragments (see IncludeKey) are replaced with the fragments of the
+// included documents.
 func ReadFile(filePath string) (*Document, error) {
+	abs, err := filepath.Abs(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return readFile(filePath, []string{abs})
+}
+
+func readFile(filePath string, stack []string) (*Document, error) {
 	fh, err := os.OpenFile(filePath, os.O_RDONLY, 0) //nolint:gosec
 	if err != nil {
 		return nil, err
@@ -120,5 +145,10 @@ func ReadFile(filePath string) (*Document, error) {
 	}
 
 	doc.Name = filePath
+
+	if err := expandIncludes(doc, stack); err != nil {
+		return nil, err
+	}
+
 	return doc, nil
 }
@@ -38,7 +38,7 @@ func TestReadDocument(t *testing.T) {
 				t.Fatalf("read error: %s", err)
 			}
 
-			if diff := cmp.Diff(&tc.Want, got, cmpopts.IgnoreUnexported(Fragment{})); diff != "" {
+			if diff := cmp.Diff(&tc.Want, got, cmpopts.IgnoreUnexported(Document{}, Fragment{})); diff != "" {
 				t.Fatalf(diff)
 			}
 		})
@@ -0,0 +1,152 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package doc
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilversion "k8s.io/apimachinery/pkg/util/version"
+)
+
+// RequiresKey is the key of the optional declaration of the cluster
+// capabilities that a test document requires, e.g.:
+//
+//	$requires:
+//	- projectcontour.io/v1/HTTPProxy
+//	- networking.k8s.io/v1
+//	- gateway.networking.k8s.io
+//	- kubernetes >= 1.19
+//
+// The declaration is part of the front-matter, so it must be in the
+// first fragment of the document, either on its own or alongside
+// "$meta".
+const RequiresKey = "$requires"
+
+// Requirement is a cluster capability that a test document requires.
+// It is either an API group, an API group version, an API kind, or a
+// minimum Kubernetes version.
+type Requirement struct {
+	// Kind is the required API group, version and kind. The
+	// version and kind are empty if only the group, or the group
+	// version is required.
+	Kind schema.GroupVersionKind
+
+	// MinVersion is the minimum Kubernetes version required,
+	// if this is a version requirement.
+	MinVersion *utilversion.Version
+}
+
+// String returns the requirement in the same form that it is declared in.
+func (r Requirement) String() string {
+	switch {
+	case r.MinVersion != nil:
+		return "kubernetes >= " + r.MinVersion.String()
+	case r.Kind.Kind != "":
+		return strings.TrimPrefix(r.Kind.GroupVersion().String()+"/"+r.Kind.Kind, "/")
+	case r.Kind.Version != "":
+		return r.Kind.GroupVersion().String()
+	default:
+		return r.Kind.Group
+	}
+}
+
+// Satisfied returns whether the requirement is met by a cluster at
+// the given version that serves the given kinds.
+func (r Requirement) Satisfied(serverVersion *utilversion.Version, kinds []schema.GroupVersionKind) bool {
+	if r.MinVersion != nil {
+		return serverVersion != nil && serverVersion.AtLeast(r.MinVersion)
+	}
+
+	for _, k := range kinds {
+		if k.Group != r.Kind.Group {
+			continue
+		}
+
+		if r.Kind.Version != "" && k.Version != r.Kind.Version {
+			continue
+		}
+
+		if r.Kind.Kind != "" && k.Kind != r.Kind.Kind {
+			continue
+		}
+
+		return true
+	}
+
+	return false
+}
+
+var (
+	versionRequirement = regexp.MustCompile(`^kubernetes\s*>=\s*(\S+)$`)
+	groupRequirement   = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`)
+)
+
+// ParseRequirement parses a requirement, which is one of "kubernetes
+// >= VERSION", "group/version/Kind", "version/Kind" (for the core API
+// group), "group/version", or "group". A path whose last element
+// starts with an upper case letter names a kind.
+func ParseRequirement(r string) (Requirement, error) {
+	r = strings.TrimSpace(r)
+
+	if m := versionRequirement.FindStringSubmatch(r); m != nil {
+		v, err := utilversion.ParseGeneric(m[1])
+		if err != nil {
+			return Requirement{}, fmt.Errorf("invalid requirement %q: %w", r, err)
+		}
+
+		return Requirement{MinVersion: v}, nil
+	}
+
+	parts := strings.Split(r, "/")
+	last := parts[len(parts)-1]
+
+	switch {
+	case len(parts) > 1 && last != "" && strings.ToUpper(last[:1]) == last[:1]:
+		gvk, err := parseRequiredKind(r)
+		if err != nil {
+			return Requirement{}, err
+		}
+
+		return Requirement{Kind: gvk}, nil
+	case len(parts) == 2 && groupRequirement.MatchString(parts[0]) && groupRequirement.MatchString(parts[1]):
+		return Requirement{Kind: schema.GroupVersionKind{Group: parts[0], Version: parts[1]}}, nil
+	case len(parts) == 1 && groupRequirement.MatchString(r):
+		return Requirement{Kind: schema.GroupVersionKind{Group: r}}, nil
+	default:
+		return Requirement{}, fmt.Errorf(
+			"invalid requirement %q: must be an API group, group version, kind or Kubernetes version", r)
+	}
+}
+
+// parseRequiredKind parses a "group/version/Kind" or "version/Kind"
+// requirement.
+func parseRequiredKind(r string) (schema.GroupVersionKind, error) {
+	i := strings.LastIndex(r, "/")
+	if i < 1 || i == len(r)-1 {
+		return schema.GroupVersionKind{},
+			fmt.Errorf("invalid requirement %q: must be an API version and kind", r)
+	}
+
+	gv, err := schema.ParseGroupVersion(r[:i])
+	if err != nil {
+		return schema.GroupVersionKind{},
+			fmt.Errorf("invalid requirement %q: %w", r, err)
+	}
+
+	return gv.WithKind(r[i+1:]), nil
+}
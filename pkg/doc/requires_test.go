@@ -0,0 +1,128 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package doc
+
+import (
+	"bytes"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilversion "k8s.io/apimachinery/pkg/util/version"
+)
+
+func TestParseRequirement(t *testing.T) {
+	for r, want := range map[string]string{
+		"projectcontour.io/v1/HTTPProxy": "projectcontour.io/v1/HTTPProxy",
+		"v1/Service":                     "v1/Service",
+		"networking.k8s.io/v1":           "networking.k8s.io/v1",
+		"apps":                           "apps",
+		"kubernetes >= 1.19":             "kubernetes >= 1.19",
+		"kubernetes>=v1.18.3":            "kubernetes >= 1.18.3",
+	} {
+		req, err := ParseRequirement(r)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", r, err)
+			continue
+		}
+
+		if req.String() != want {
+			t.Errorf("%q: got %q, want %q", r, req.String(), want)
+		}
+	}
+
+	for _, r := range []string{"", "Service", "apps/", "a/b/c/Kind", "kubernetes >= next"} {
+		if _, err := ParseRequirement(r); err == nil {
+			t.Errorf("%q: expected error", r)
+		}
+	}
+}
+
+func TestRequirementSatisfied(t *testing.T) {
+	kinds := []schema.GroupVersionKind{
+		{Version: "v1", Kind: "Service"},
+		{Group: "networking.k8s.io", Version: "v1", Kind: "Ingress"},
+		{Group: "networking.k8s.io", Version: "v1beta1", Kind: "Ingress"},
+	}
+
+	server := utilversion.MustParseGeneric("1.19.2")
+
+	for r, want := range map[string]bool{
+		"v1/Service":                      true,
+		"v1/Pod":                          false,
+		"networking.k8s.io":               true,
+		"networking.k8s.io/v1beta1":       true,
+		"networking.k8s.io/v2":            false,
+		"networking.k8s.io/v1/Ingress":    true,
+		"networking.k8s.io/v1/IngressFoo": false,
+		"projectcontour.io":               false,
+		"kubernetes >= 1.19":              true,
+		"kubernetes >= 1.19.3":            false,
+		"kubernetes >= 1.18":              true,
+	} {
+		req, err := ParseRequirement(r)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %s", r, err)
+		}
+
+		if got := req.Satisfied(server, kinds); got != want {
+			t.Errorf("%q: got %t, want %t", r, got, want)
+		}
+	}
+
+	req, err := ParseRequirement("kubernetes >= 1.0")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if req.Satisfied(nil, kinds) {
+		t.Errorf("version requirement satisfied without a server version")
+	}
+}
+
+func TestReadDocumentRequires(t *testing.T) {
+	for name, data := range map[string]string{
+		"standalone": `$requires:
+- apps/v1
+- kubernetes >= 1.19
+---
+one
+`,
+		"with meta": `$meta:
+  name: requires
+$requires:
+- apps/v1
+- kubernetes >= 1.19
+---
+one
+`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			d, err := ReadDocument(bytes.NewBufferString(data))
+			if err != nil {
+				t.Fatalf("read error: %s", err)
+			}
+
+			if len(d.Meta.Requires) != 2 ||
+				d.Meta.Requires[0].String() != "apps/v1" ||
+				d.Meta.Requires[1].String() != "kubernetes >= 1.19" {
+				t.Fatalf("unexpected requirements %v", d.Meta.Requires)
+			}
+
+			if len(d.Parts) != 1 || string(d.Parts[0].Bytes) != "one" {
+				t.Fatalf("unexpected parts %v", d.Parts)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package doc
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/open-policy-agent/opa/ast"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// SkipKey is the key of a fragment that skips the rest of the test
+// document if its Rego condition is true, e.g.:
+//
+//	$skip: not data.resources[".versions"].httpproxies
+//
+// A skip fragment decodes to a Rego module whose skip rule is the
+// condition. The same key can be used as a pseudo-field on an
+// object fragment.
+const SkipKey = "$skip"
+
+// skipModule returns the Rego source of the skip fragment, or
+// nil if this is not a skip fragment.
+func skipModule(u *unstructured.Unstructured) ([]byte, error) {
+	val, ok := u.Object[SkipKey]
+	if !ok {
+		return nil, nil
+	}
+
+	if len(u.Object) != 1 {
+		return nil, fmt.Errorf("%q fragment must not have other fields", SkipKey)
+	}
+
+	condition, ok := val.(string)
+	if !ok || condition == "" {
+		return nil, fmt.Errorf("%q value must be a Rego condition", SkipKey)
+	}
+
+	body, err := ast.ParseBody(condition)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %q condition: %w", SkipKey, err)
+	}
+
+	msg, err := json.Marshal(fmt.Sprintf("skipped because %q condition %q is true", SkipKey, body))
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(fmt.Sprintf("skip[msg] {\n  %s\n  msg := %s\n}\n", body, msg)), nil
+}
+
+// isSkipFragment returns whether the fragment is a skip fragment.
+func isSkipFragment(f *Fragment) bool {
+	u, err := decodeYAMLOrJSON(f.Bytes)
+	if err != nil {
+		return false
+	}
+
+	_, ok := u.Object[SkipKey]
+	return ok && !hasKindVersion(u)
+}
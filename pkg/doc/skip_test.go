@@ -0,0 +1,51 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package doc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSkipFragment(t *testing.T) {
+	f := Fragment{Bytes: []byte(`$skip: not data.resources[".versions"].httpproxies`)}
+
+	fragType, err := f.Decode()
+	require.NoError(t, err)
+	assert.Equal(t, FragmentType(FragmentTypeModule), fragType)
+	require.Len(t, f.Rego().Rules, 1)
+	assert.Equal(t, "skip", f.Rego().Rules[0].Head.Name.String())
+
+	for _, bad := range []string{
+		`$skip: 42`,
+		`$skip: "not ["`,
+		"$skip: 'true'\nextra: field",
+	} {
+		f := Fragment{Bytes: []byte(bad)}
+		_, err := f.Decode()
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestFormatSkipFragment(t *testing.T) {
+	out := formatString(t, `$skip:   "true"
+`)
+
+	assert.Equal(t, "---\n$skip: \"true\"\n", out)
+	assert.False(t, strings.Contains(out, "skip[msg]"))
+}
@@ -0,0 +1,46 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package driver
+
+import (
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var (
+	resourcePods           = schema.GroupResource{Group: "", Resource: "pods"}
+	resourceReplicaSets    = schema.GroupResource{Group: "apps", Resource: "replicasets"}
+	resourceEndpoints      = schema.GroupResource{Group: "", Resource: "endpoints"}
+	resourceEndpointSlices = schema.GroupResource{Group: "discovery.k8s.io", Resource: "endpointslices"}
+)
+
+// kindDependencies maps an object kind to the resources that its
+// controller creates on its behalf. When a test applies an object of
+// one of these kinds, we also inform on the dependent resources so
+// that checks can inspect the objects that the controller generates.
+var kindDependencies = map[schema.GroupKind][]schema.GroupResource{
+	{Group: "apps", Kind: "Deployment"}:  {resourceReplicaSets, resourcePods},
+	{Group: "apps", Kind: "ReplicaSet"}:  {resourcePods},
+	{Group: "apps", Kind: "StatefulSet"}: {resourcePods},
+	{Group: "apps", Kind: "DaemonSet"}:   {resourcePods},
+	{Group: "batch", Kind: "Job"}:        {resourcePods},
+	{Group: "", Kind: "Service"}:         {resourceEndpoints, resourceEndpointSlices},
+}
+
+// DependentResourcesForKind returns the resources that are generated
+// by the controller for the given kind. The returned resources are
+// unversioned, since the served version depends on the cluster.
+func DependentResourcesForKind(gk schema.GroupKind) []schema.GroupResource {
+	return kindDependencies[gk]
+}
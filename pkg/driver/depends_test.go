@@ -0,0 +1,40 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package driver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestDependentResourcesForKind(t *testing.T) {
+	assert.ElementsMatch(t,
+		[]schema.GroupResource{resourceReplicaSets, resourcePods},
+		DependentResourcesForKind(schema.GroupKind{Group: "apps", Kind: "Deployment"}))
+
+	assert.ElementsMatch(t,
+		[]schema.GroupResource{resourceEndpoints, resourceEndpointSlices},
+		DependentResourcesForKind(schema.GroupKind{Group: "", Kind: "Service"}))
+
+	// Kinds that aren't in the map have no dependencies.
+	assert.Empty(t,
+		DependentResourcesForKind(schema.GroupKind{Group: "", Kind: "ConfigMap"}))
+
+	// Dependencies are matched on the API group too.
+	assert.Empty(t,
+		DependentResourcesForKind(schema.GroupKind{Group: "extensions", Kind: "Deployment"}))
+}
@@ -15,17 +15,23 @@
 package driver
 
 import (
+	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/projectcontour/integration-tester/pkg/doc"
 	"github.com/projectcontour/integration-tester/pkg/filter"
 	"github.com/projectcontour/integration-tester/pkg/fixture"
 	"github.com/projectcontour/integration-tester/pkg/must"
+	"github.com/projectcontour/integration-tester/pkg/result"
+	"github.com/projectcontour/integration-tester/pkg/utils"
 	"github.com/projectcontour/integration-tester/pkg/version"
 
 	"github.com/google/uuid"
 	"github.com/open-policy-agent/opa/ast"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/kustomize/kyaml/yaml"
 	sigyaml "sigs.k8s.io/yaml"
 )
@@ -39,17 +45,65 @@ type Environment interface {
 	HydrateObject(objData []byte) (*Object, error)
 }
 
+// ObjectGetter fetches the named object of the given kind from the
+// cluster.
+type ObjectGetter func(kind schema.GroupVersionKind, namespace string, name string) (*unstructured.Unstructured, error)
+
+// EnvironmentOpt sets options for an Environment.
+type EnvironmentOpt func(*environ)
+
+// UniqueIDOpt sets the unique identifier of the Environment. If
+// this option is not given, a random UUID is used.
+func UniqueIDOpt(id string) EnvironmentOpt {
+	return EnvironmentOpt(func(e *environ) {
+		e.uid = id
+	})
+}
+
+// OfflineOpt hydrates objects without contacting the cluster. Objects
+// that would be cloned from a cluster fixture or rotated from a cluster
+// Secret are hydrated as they are given in the test document.
+func OfflineOpt() EnvironmentOpt {
+	return EnvironmentOpt(func(e *environ) {
+		e.offline = true
+	})
+}
+
 // NewEnvironment returns a new Environment.
-func NewEnvironment() Environment {
-	return &environ{
+func NewEnvironment(opts ...EnvironmentOpt) Environment {
+	e := &environ{
 		uid: uuid.New().String(),
 	}
+
+	for _, o := range opts {
+		o(e)
+	}
+
+	return e
+}
+
+// NewClusterEnvironment returns a new Environment that can hydrate
+// objects from cluster fixtures by fetching them with get.
+func NewClusterEnvironment(get ObjectGetter, opts ...EnvironmentOpt) Environment {
+	e := &environ{
+		uid: uuid.New().String(),
+		get: get,
+	}
+
+	for _, o := range opts {
+		o(e)
+	}
+
+	return e
 }
 
 var _ Environment = &environ{}
 
 type environ struct {
-	uid string
+	uid     string
+	get     ObjectGetter
+	offline bool
+	envVars map[string]bool
 }
 
 // UniqueID returns a unique identifier for this Environment instance.
@@ -67,6 +121,12 @@ const (
 	// ObjectOperationUpdate indicates this object should be
 	// updated (i.e created or patched).
 	ObjectOperationUpdate = "update"
+	// ObjectOperationCreate indicates this object should be
+	// created, failing if it already exists.
+	ObjectOperationCreate = "create"
+	// ObjectOperationPatch indicates that a JSON patch should
+	// be applied to an existing object.
+	ObjectOperationPatch = "patch"
 )
 
 // Fixture is a marker to tell the Environment that a Kubernetes
@@ -75,6 +135,19 @@ type Fixture struct {
 	As string
 }
 
+// ClusterFixture is a marker to tell the Environment that a
+// Kubernetes object should be cloned from the existing cluster
+// object named by From. The fields in the test object are merged
+// over the clone.
+type ClusterFixture struct {
+	From string
+}
+
+// Patch is a marker to tell the Environment that a Kubernetes
+// object is the target of an RFC 6902 JSON patch. The value is
+// the list of patch operations.
+type Patch []interface{}
+
 // Object captures an Unstructured Kubernetes API object and its
 // associated metadata.
 //
@@ -86,11 +159,73 @@ type Object struct {
 	// Check is a Rego check to run on the apply.
 	Check *ast.Module
 
+	// When is a Rego query that must be true for the object
+	// to be applied.
+	When ast.Body
+
+	// Skip is a Rego query that skips the rest of the test
+	// document, including this object, if it is true. This is
+	// derived from the "$skip" pseudo-field.
+	Skip ast.Body
+
 	// Operation specifies whether we are updating or deleting the object.
 	Operation ObjectOperationType
 
 	// Fixture specifies that we should replace this object with the corresponding fixture.
 	Fixture *Fixture
+
+	// Patch is the JSON patch to apply for a patch operation.
+	Patch []byte
+
+	// Rotated specifies that the data of this Secret object was
+	// regenerated from the cluster object.
+	Rotated bool
+
+	// Alias is the name that the identity of the applied object
+	// is recorded under. This is derived from the "$alias"
+	// pseudo-field.
+	Alias string
+
+	// Snapshot is the name that the Rego store resources are saved
+	// under before the object is applied. This is derived from the
+	// "$store-snapshot" pseudo-field.
+	Snapshot string
+
+	// DependsOn names the objects that must be applied before
+	// this one. This is derived from the "$depends-on"
+	// pseudo-field, and is used to order the objects of
+	// included documents.
+	DependsOn []string
+
+	// SkipScope is the default scope of Skip results that are
+	// raised by the object check. This is derived from the
+	// "$skip-scope" pseudo-field.
+	SkipScope result.Scope
+
+	// ExpectError specifies that the operation is expected to be
+	// rejected by the API server. This is derived from the
+	// "$expect-error" pseudo-field, and generates the object Check.
+	ExpectError *ExpectedError
+
+	// Delay is the time to wait before the object is applied.
+	// This is derived from the "$delay" pseudo-field.
+	Delay time.Duration
+
+	// Timeout overrides the check timeout for the object check.
+	// This is derived from the "$timeout" pseudo-field.
+	Timeout time.Duration
+
+	// Wait is the state that the object must reach after the
+	// operation, before the test document continues. This is
+	// derived from the "$wait" pseudo-field.
+	Wait *Wait
+
+	// Versions are the API versions (within the object's API
+	// group) that the object is applied at, in order. The view
+	// of the object at each of these versions is fetched after
+	// the operation. This is derived from the "$versions"
+	// pseudo-field.
+	Versions []string
 }
 
 func yamlToUnstructured(node *yaml.RNode) (*unstructured.Unstructured, error) {
@@ -120,13 +255,15 @@ func matchFixture(resource *yaml.RNode) fixture.Fixture {
 // HydrateObject unmarshals YAML data into a unstructured.Unstructured
 // object, applying any defaults and expanding templates.
 func (e *environ) HydrateObject(objData []byte) (*Object, error) {
-	// TODO(jpeach): before parsing YAML, apply Go template context.
-
 	resource, err := yaml.Parse(string(objData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse YAML object:%w", err)
 	}
 
+	if err := e.expandEnv(resource); err != nil {
+		return nil, err
+	}
+
 	// Filter out any special operations.
 	ops := newSpecialOpsFilter()
 
@@ -153,6 +290,20 @@ func (e *environ) HydrateObject(objData []byte) (*Object, error) {
 
 			resource = match.AsNode()
 		}
+
+		if fix, ok := val.(ClusterFixture); ok {
+			resource, err = e.cloneFromCluster(resource, fix)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if val == "rotate" {
+			resource, err = e.rotateFromCluster(resource)
+			if err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	// Inject test metadata.
@@ -178,6 +329,25 @@ func (e *environ) HydrateObject(objData []byte) (*Object, error) {
 		}
 	}
 
+	if len(o.Versions) > 0 && o.Operation == ObjectOperationDelete {
+		return nil, fmt.Errorf("the %q field can't be used to delete objects", "$versions")
+	}
+
+	if len(o.Versions) > 0 && o.Operation == ObjectOperationCreate {
+		return nil, fmt.Errorf("the %q field can't be used to create objects", "$versions")
+	}
+
+	if o.ExpectError != nil {
+		if o.Check != nil {
+			return nil, fmt.Errorf("the %q and %q fields are mutually exclusive", "$check", "$expect-error")
+		}
+
+		o.Check, err = o.ExpectError.Check()
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	o.Object, err = yamlToUnstructured(resource)
 	if err != nil {
 		return nil, err
@@ -186,6 +356,148 @@ func (e *environ) HydrateObject(objData []byte) (*Object, error) {
 	return &o, nil
 }
 
+// cloneFromCluster fetches the object named by the cluster fixture,
+// strips the fields that the API server populates, and merges the
+// fields of resource over it.
+func (e *environ) cloneFromCluster(resource *yaml.RNode, fix ClusterFixture) (*yaml.RNode, error) {
+	if fix.From == "" {
+		return nil, fmt.Errorf("missing %q object name for cluster fixture", "from")
+	}
+
+	if e.offline {
+		return resource, nil
+	}
+
+	if e.get == nil {
+		return nil, fmt.Errorf("cluster fixtures are not supported in this environment")
+	}
+
+	u, err := yamlToUnstructured(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	ns, name := utils.SplitObjectName(fix.From)
+
+	live, err := e.get(u.GroupVersionKind(), ns, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s %q: %w", u.GetKind(), fix.From, err)
+	}
+
+	clone := live.DeepCopy()
+	cleanClusterObject(clone)
+	mergeFields(clone.Object, u.Object)
+
+	jsonBytes, err := json.Marshal(clone.Object)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode cluster fixture: %w", err)
+	}
+
+	// JSON is valid YAML.
+	return yaml.Parse(string(jsonBytes))
+}
+
+// rotateFromCluster fetches the Secret named by resource, merges
+// the fields of resource over it and regenerates its data.
+func (e *environ) rotateFromCluster(resource *yaml.RNode) (*yaml.RNode, error) {
+	u, err := yamlToUnstructured(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.GetName() == "" {
+		return nil, fmt.Errorf("cannot rotate anonymous %s object", u.GetKind())
+	}
+
+	if e.offline {
+		return resource, nil
+	}
+
+	if e.get == nil {
+		return nil, fmt.Errorf("secret rotation is not supported in this environment")
+	}
+
+	ns := utils.NamespaceOrDefault(u)
+
+	live, err := e.get(u.GroupVersionKind(), ns, u.GetName())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s \"%s/%s\": %w", u.GetKind(), ns, u.GetName(), err)
+	}
+
+	secret := live.DeepCopy()
+	cleanClusterObject(secret)
+	mergeFields(secret.Object, u.Object)
+
+	if err := RotateSecret(secret); err != nil {
+		return nil, err
+	}
+
+	jsonBytes, err := json.Marshal(secret.Object)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode rotated secret: %w", err)
+	}
+
+	return yaml.Parse(string(jsonBytes))
+}
+
+// cleanClusterObject removes the status and the fields that are
+// populated by the API server or by other controllers, so that the
+// object can be applied as a new object.
+func cleanClusterObject(u *unstructured.Unstructured) {
+	unstructured.RemoveNestedField(u.Object, "status")
+
+	for _, field := range []string{
+		"uid",
+		"resourceVersion",
+		"generation",
+		"creationTimestamp",
+		"deletionTimestamp",
+		"deletionGracePeriodSeconds",
+		"selfLink",
+		"managedFields",
+		"ownerReferences",
+		"finalizers",
+	} {
+		unstructured.RemoveNestedField(u.Object, "metadata", field)
+	}
+
+	unstructured.RemoveNestedField(u.Object,
+		"metadata", "annotations", "kubectl.kubernetes.io/last-applied-configuration")
+
+	// Allocated Service addresses and ports can't be reused.
+	if u.GetAPIVersion() == "v1" && u.GetKind() == "Service" {
+		unstructured.RemoveNestedField(u.Object, "spec", "clusterIP")
+		unstructured.RemoveNestedField(u.Object, "spec", "clusterIPs")
+
+		if ports, ok, _ := unstructured.NestedSlice(u.Object, "spec", "ports"); ok {
+			for _, p := range ports {
+				if port, ok := p.(map[string]interface{}); ok {
+					delete(port, "nodePort")
+				}
+			}
+
+			must.Must(unstructured.SetNestedSlice(u.Object, ports, "spec", "ports"))
+		}
+	}
+}
+
+// mergeFields recursively merges the fields in src into dst. Maps
+// are merged, and all other values (including lists) in src replace
+// the values in dst.
+func mergeFields(dst map[string]interface{}, src map[string]interface{}) {
+	for k, v := range src {
+		srcMap, srcOK := v.(map[string]interface{})
+		dstMap, dstOK := dst[k].(map[string]interface{})
+
+		if srcOK && dstOK {
+			mergeFields(dstMap, srcMap)
+			continue
+		}
+
+		dst[k] = v
+	}
+}
+
 func newSpecialOpsFilter() *filter.SpecialOpsFilter {
 	// Filter out any special operations.
 	ops := filter.SpecialOpsFilter{
@@ -193,7 +505,11 @@ func newSpecialOpsFilter() *filter.SpecialOpsFilter {
 	}
 
 	ops.Decoders["$apply"] = filter.UnmarshalFunc(func(n *yaml.Node) error {
-		var as struct{ Fixture Fixture }
+		var as struct {
+			Fixture     Fixture
+			FromCluster *ClusterFixture `yaml:"fixture-from-cluster"`
+			Patch       Patch
+		}
 		var str string
 
 		// We support two syntaxes for fixtures:
@@ -202,9 +518,29 @@ func newSpecialOpsFilter() *filter.SpecialOpsFilter {
 		//	$apply:
 		//	  fixture:
 		//	    as: some-other-name
+		//
+		// JSON patches are given as a list of operations:
+		//	$apply:
+		//	  patch:
+		//	  - op: replace
+		//	    path: /spec/replicas
+		//	    value: 2
+		//
+		// Cluster fixtures name the object to clone:
+		//	$apply:
+		//	  fixture-from-cluster:
+		//	    from: projectcontour/contour
 
 		if err := n.Decode(&as); err == nil {
-			ops.Ops["$apply"] = as.Fixture
+			switch {
+			case as.Patch != nil:
+				ops.Ops["$apply"] = as.Patch
+			case as.FromCluster != nil:
+				ops.Ops["$apply"] = *as.FromCluster
+			default:
+				ops.Ops["$apply"] = as.Fixture
+			}
+
 			return nil
 		}
 
@@ -222,6 +558,82 @@ func newSpecialOpsFilter() *filter.SpecialOpsFilter {
 		return fmt.Errorf("unable to decode YAML field %q", "$apply")
 	})
 
+	// Dependencies are given as a single object name, or as
+	// a list of names.
+	ops.Decoders[doc.DependsOnKey] = filter.UnmarshalFunc(func(n *yaml.Node) error {
+		var names []string
+		var str string
+
+		if err := n.Decode(&names); err == nil {
+			ops.Ops[doc.DependsOnKey] = names
+			return nil
+		}
+
+		if err := n.Decode(&str); err == nil {
+			ops.Ops[doc.DependsOnKey] = []string{str}
+			return nil
+		}
+
+		return fmt.Errorf("unable to decode YAML field %q", doc.DependsOnKey)
+	})
+
+	// Waits are given as a map, or as the condition to wait for:
+	//	$wait:
+	//	  for: condition=Available
+	//	  timeout: 3m
+	// or
+	//	$wait: condition=Available
+	ops.Decoders["$wait"] = filter.UnmarshalFunc(func(n *yaml.Node) error {
+		var spec waitSpec
+		var str string
+
+		if err := n.Decode(&spec); err == nil {
+			ops.Ops["$wait"] = spec
+			return nil
+		}
+
+		if err := n.Decode(&str); err == nil {
+			ops.Ops["$wait"] = waitSpec{For: str}
+			return nil
+		}
+
+		return fmt.Errorf("unable to decode YAML field %q", "$wait")
+	})
+
+	ops.Decoders["$versions"] = filter.UnmarshalFunc(func(n *yaml.Node) error {
+		var versions []string
+
+		if err := n.Decode(&versions); err == nil {
+			ops.Ops["$versions"] = versions
+			return nil
+		}
+
+		return fmt.Errorf("unable to decode YAML field %q", "$versions")
+	})
+
+	// Expected errors are given as a map, or as a status reason:
+	//	$expect-error:
+	//	  reason: Invalid
+	//	  message-contains: "spec.replicas"
+	// or
+	//	$expect-error: Forbidden
+	ops.Decoders["$expect-error"] = filter.UnmarshalFunc(func(n *yaml.Node) error {
+		var expect ExpectedError
+		var str string
+
+		if err := n.Decode(&expect); err == nil {
+			ops.Ops["$expect-error"] = expect
+			return nil
+		}
+
+		if err := n.Decode(&str); err == nil {
+			ops.Ops["$expect-error"] = ExpectedError{Reason: str}
+			return nil
+		}
+
+		return fmt.Errorf("unable to decode YAML field %q", "$expect-error")
+	})
+
 	return &ops
 }
 
@@ -243,22 +655,231 @@ var specialOpHandlers = map[string]func(val interface{}, o *Object) error{
 		return nil
 	},
 
+	"$alias": func(val interface{}, o *Object) error {
+		strval, ok := val.(string)
+		if !ok {
+			return fmt.Errorf(
+				"failed to decode %q field: unexpected type %T",
+				"$alias", val)
+		}
+
+		if !ruleNameRegex.MatchString(strval) {
+			return fmt.Errorf("invalid %q field %q", "$alias", strval)
+		}
+
+		o.Alias = strval
+		return nil
+	},
+
+	doc.DependsOnKey: func(val interface{}, o *Object) error {
+		names, ok := val.([]string)
+		if !ok {
+			return fmt.Errorf(
+				"failed to decode %q field: unexpected type %T",
+				doc.DependsOnKey, val)
+		}
+
+		o.DependsOn = names
+		return nil
+	},
+
+	"$delay": func(val interface{}, o *Object) error {
+		strval, ok := val.(string)
+		if !ok {
+			return fmt.Errorf(
+				"failed to decode %q field: unexpected type %T",
+				"$delay", val)
+		}
+
+		delay, err := time.ParseDuration(strval)
+		if err != nil {
+			return fmt.Errorf("invalid %q field: %w", "$delay", err)
+		}
+
+		if delay < 0 {
+			return fmt.Errorf("invalid %q duration %q", "$delay", strval)
+		}
+
+		o.Delay = delay
+		return nil
+	},
+
+	"$expect-error": func(val interface{}, o *Object) error {
+		expect, ok := val.(ExpectedError)
+		if !ok {
+			return fmt.Errorf(
+				"failed to decode %q field: unexpected type %T",
+				"$expect-error", val)
+		}
+
+		o.ExpectError = &expect
+		return nil
+	},
+
+	"$skip-scope": func(val interface{}, o *Object) error {
+		strval, ok := val.(string)
+		if !ok {
+			return fmt.Errorf(
+				"failed to decode %q field: unexpected type %T",
+				"$skip-scope", val)
+		}
+
+		scope, err := result.ParseScope(strval)
+		if err != nil {
+			return fmt.Errorf("invalid %q field: %w", "$skip-scope", err)
+		}
+
+		o.SkipScope = scope
+		return nil
+	},
+
+	"$store-snapshot": func(val interface{}, o *Object) error {
+		strval, ok := val.(string)
+		if !ok {
+			return fmt.Errorf(
+				"failed to decode %q field: unexpected type %T",
+				"$store-snapshot", val)
+		}
+
+		if !ruleNameRegex.MatchString(strval) {
+			return fmt.Errorf("invalid %q field %q", "$store-snapshot", strval)
+		}
+
+		o.Snapshot = strval
+		return nil
+	},
+
+	doc.SkipKey: func(val interface{}, o *Object) error {
+		strval, ok := val.(string)
+		if !ok {
+			return fmt.Errorf(
+				"failed to decode %q field: unexpected type %T",
+				doc.SkipKey, val)
+		}
+
+		query, err := ast.ParseBody(strval)
+		if err != nil {
+			return fmt.Errorf("failed to parse %q field: %w", doc.SkipKey, err)
+		}
+
+		o.Skip = query
+		return nil
+	},
+
+	"$timeout": func(val interface{}, o *Object) error {
+		strval, ok := val.(string)
+		if !ok {
+			return fmt.Errorf(
+				"failed to decode %q field: unexpected type %T",
+				"$timeout", val)
+		}
+
+		timeout, err := time.ParseDuration(strval)
+		if err != nil {
+			return fmt.Errorf("invalid %q field: %w", "$timeout", err)
+		}
+
+		if timeout <= 0 {
+			return fmt.Errorf("invalid %q duration %q", "$timeout", strval)
+		}
+
+		o.Timeout = timeout
+		return nil
+	},
+
+	"$versions": func(val interface{}, o *Object) error {
+		versions, ok := val.([]string)
+		if !ok {
+			return fmt.Errorf(
+				"failed to decode %q field: unexpected type %T",
+				"$versions", val)
+		}
+
+		if len(versions) == 0 {
+			return fmt.Errorf("empty %q field", "$versions")
+		}
+
+		for _, v := range versions {
+			if v == "" || strings.Contains(v, "/") {
+				return fmt.Errorf("invalid %q version %q", "$versions", v)
+			}
+		}
+
+		o.Versions = versions
+		return nil
+	},
+
+	"$wait": func(val interface{}, o *Object) error {
+		spec, ok := val.(waitSpec)
+		if !ok {
+			return fmt.Errorf(
+				"failed to decode %q field: unexpected type %T",
+				"$wait", val)
+		}
+
+		wait, err := ParseWait(spec.For, spec.Timeout)
+		if err != nil {
+			return err
+		}
+
+		o.Wait = wait
+		return nil
+	},
+
+	"$when": func(val interface{}, o *Object) error {
+		strval, ok := val.(string)
+		if !ok {
+			return fmt.Errorf(
+				"failed to decode %q field: unexpected type %T",
+				"$when", val)
+		}
+
+		query, err := ast.ParseBody(strval)
+		if err != nil {
+			return fmt.Errorf("failed to parse %q field: %w", "$when", err)
+		}
+
+		o.When = query
+		return nil
+	},
+
 	"$apply": func(val interface{}, o *Object) error {
 		switch what := val.(type) {
 		case string:
 			switch what {
 			case "update":
 				o.Operation = ObjectOperationUpdate
+			case "create":
+				o.Operation = ObjectOperationCreate
 			case "delete":
 				o.Operation = ObjectOperationDelete
 			case "fixture":
 				o.Operation = ObjectOperationUpdate
+				o.Fixture = &Fixture{}
+			case "rotate":
+				o.Operation = ObjectOperationUpdate
+				o.Rotated = true
 			default:
 				return fmt.Errorf(
 					"unsupported operation %q for %q field", what, "$apply")
 			}
 		case Fixture:
 			o.Operation = ObjectOperationUpdate
+			o.Fixture = &what
+		case ClusterFixture:
+			o.Operation = ObjectOperationUpdate
+		case Patch:
+			if err := validatePatch(what); err != nil {
+				return err
+			}
+
+			data, err := json.Marshal(what)
+			if err != nil {
+				return fmt.Errorf("failed to encode JSON patch: %w", err)
+			}
+
+			o.Operation = ObjectOperationPatch
+			o.Patch = data
 		default:
 			return fmt.Errorf(
 				"failed to decode %q field: unexpected type %T",
@@ -268,3 +889,27 @@ var specialOpHandlers = map[string]func(val interface{}, o *Object) error{
 		return nil
 	},
 }
+
+// validatePatch verifies that each patch operation is a map with
+// the mandatory "op" and "path" fields. The API server validates
+// the patch semantics.
+func validatePatch(p Patch) error {
+	if len(p) == 0 {
+		return fmt.Errorf("empty JSON patch")
+	}
+
+	for i, op := range p {
+		fields, ok := op.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("JSON patch operation %d is a %T, not an object", i, op)
+		}
+
+		for _, name := range []string{"op", "path"} {
+			if _, ok := fields[name].(string); !ok {
+				return fmt.Errorf("JSON patch operation %d has no %q field", i, name)
+			}
+		}
+	}
+
+	return nil
+}
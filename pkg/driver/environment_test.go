@@ -0,0 +1,430 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package driver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestHydratePatch(t *testing.T) {
+	env := NewEnvironment()
+
+	obj, err := env.HydrateObject([]byte(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: echo
+$apply:
+  patch:
+  - op: replace
+    path: /spec/replicas
+    value: 2
+`))
+	require.NoError(t, err)
+
+	assert.Equal(t, ObjectOperationType(ObjectOperationPatch), obj.Operation)
+	assert.JSONEq(t, `[{"op": "replace", "path": "/spec/replicas", "value": 2}]`, string(obj.Patch))
+	assert.Equal(t, "echo", obj.Object.GetName())
+}
+
+func TestHydrateInvalidPatch(t *testing.T) {
+	env := NewEnvironment()
+
+	_, err := env.HydrateObject([]byte(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: echo
+$apply:
+  patch:
+  - path: /spec/replicas
+`))
+	assert.EqualError(t, err, `JSON patch operation 0 has no "op" field`)
+}
+
+func TestHydrateDeleteOperation(t *testing.T) {
+	env := NewEnvironment()
+
+	obj, err := env.HydrateObject([]byte(`
+apiVersion: v1
+kind: Secret
+metadata:
+  name: stub
+$apply: delete
+`))
+	require.NoError(t, err)
+	assert.Equal(t, ObjectOperationType(ObjectOperationDelete), obj.Operation)
+	assert.Nil(t, obj.Patch)
+}
+
+func TestHydrateCreateOperation(t *testing.T) {
+	env := NewEnvironment()
+
+	obj, err := env.HydrateObject([]byte(`
+apiVersion: v1
+kind: Secret
+metadata:
+  name: stub
+$apply: create
+`))
+	require.NoError(t, err)
+	assert.Equal(t, ObjectOperationType(ObjectOperationCreate), obj.Operation)
+
+	_, err = env.HydrateObject([]byte(`
+apiVersion: example.com/v1alpha1
+kind: Widget
+metadata:
+  name: round-trip
+$apply: create
+$versions: [v1]
+`))
+	assert.Error(t, err)
+}
+
+func TestHydrateClusterFixture(t *testing.T) {
+	env := NewClusterEnvironment(func(kind schema.GroupVersionKind, namespace string, name string) (*unstructured.Unstructured, error) {
+		assert.Equal(t, "Service", kind.Kind)
+		assert.Equal(t, "projectcontour", namespace)
+		assert.Equal(t, "envoy", name)
+
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Service",
+			"metadata": map[string]interface{}{
+				"name":            "envoy",
+				"namespace":       "projectcontour",
+				"uid":             "2f0a0b3c",
+				"resourceVersion": "1234",
+				"labels":          map[string]interface{}{"app": "envoy"},
+			},
+			"spec": map[string]interface{}{
+				"type":      "NodePort",
+				"clusterIP": "10.96.0.10",
+				"ports": []interface{}{
+					map[string]interface{}{"port": int64(80), "nodePort": int64(30080)},
+				},
+			},
+			"status": map[string]interface{}{
+				"loadBalancer": map[string]interface{}{},
+			},
+		}}, nil
+	})
+
+	obj, err := env.HydrateObject([]byte(`
+apiVersion: v1
+kind: Service
+metadata:
+  name: envoy-clone
+  namespace: test
+  labels:
+    clone: "true"
+$apply:
+  fixture-from-cluster:
+    from: projectcontour/envoy
+spec:
+  type: ClusterIP
+`))
+	require.NoError(t, err)
+
+	assert.Equal(t, ObjectOperationType(ObjectOperationUpdate), obj.Operation)
+	assert.Equal(t, "envoy-clone", obj.Object.GetName())
+	assert.Equal(t, "test", obj.Object.GetNamespace())
+	assert.Equal(t, "", string(obj.Object.GetUID()))
+	assert.Equal(t, "", obj.Object.GetResourceVersion())
+	assert.Equal(t, "envoy", obj.Object.GetLabels()["app"])
+	assert.Equal(t, "true", obj.Object.GetLabels()["clone"])
+
+	spec := obj.Object.Object["spec"].(map[string]interface{})
+	assert.Equal(t, "ClusterIP", spec["type"])
+	assert.NotContains(t, spec, "clusterIP")
+	assert.Equal(t, []interface{}{map[string]interface{}{"port": int64(80)}}, spec["ports"])
+	assert.NotContains(t, obj.Object.Object, "status")
+}
+
+func TestHydrateClusterFixtureWithoutCluster(t *testing.T) {
+	env := NewEnvironment()
+
+	_, err := env.HydrateObject([]byte(`
+apiVersion: v1
+kind: Service
+metadata:
+  name: envoy-clone
+$apply:
+  fixture-from-cluster:
+    from: projectcontour/envoy
+`))
+	assert.EqualError(t, err, "cluster fixtures are not supported in this environment")
+}
+
+func TestHydrateWhen(t *testing.T) {
+	env := NewEnvironment()
+
+	obj, err := env.HydrateObject([]byte(`
+apiVersion: v1
+kind: Secret
+metadata:
+  name: stub
+$when: data.test.params.tls == "true"
+`))
+	require.NoError(t, err)
+	require.NotNil(t, obj.When)
+	assert.NotContains(t, obj.Object.Object, "$when")
+
+	_, err = env.HydrateObject([]byte(`
+apiVersion: v1
+kind: Secret
+metadata:
+  name: stub
+$when: data.test.params.tls ==
+`))
+	assert.Error(t, err)
+}
+
+func TestEnvironmentUniqueID(t *testing.T) {
+	assert.NotEqual(t, NewEnvironment().UniqueID(), NewEnvironment().UniqueID())
+
+	env := NewEnvironment(UniqueIDOpt("github-12345"))
+	assert.Equal(t, "github-12345", env.UniqueID())
+
+	obj, err := env.HydrateObject([]byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+`))
+	require.NoError(t, err)
+	assert.Equal(t, "github-12345", obj.Object.GetAnnotations()["integration-tester/run-id"])
+}
+
+func TestHydrateAlias(t *testing.T) {
+	env := NewEnvironment()
+
+	obj, err := env.HydrateObject([]byte(`
+apiVersion: v1
+kind: Service
+metadata:
+  name: echo
+$alias: echo_service
+`))
+	require.NoError(t, err)
+	assert.Equal(t, "echo_service", obj.Alias)
+
+	_, err = env.HydrateObject([]byte(`
+apiVersion: v1
+kind: Service
+metadata:
+  name: echo
+$alias: echo-service
+`))
+	assert.Error(t, err)
+}
+
+func TestHydrateExpectError(t *testing.T) {
+	env := NewEnvironment()
+
+	obj, err := env.HydrateObject([]byte(`
+apiVersion: v1
+kind: Service
+metadata:
+  name: echo
+$expect-error:
+  reason: Invalid
+  message-contains: "spec.ports"
+`))
+	require.NoError(t, err)
+	require.NotNil(t, obj.ExpectError)
+	assert.Equal(t, ExpectedError{Reason: "Invalid", MessageContains: "spec.ports"}, *obj.ExpectError)
+	require.NotNil(t, obj.Check)
+
+	var rules []string
+	for _, r := range obj.Check.Rules {
+		rules = append(rules, r.Head.Name.String())
+	}
+
+	assert.ElementsMatch(t, []string{
+		"error_operation_succeeded",
+		"error_unexpected_reason",
+		"error_unexpected_message",
+	}, rules)
+
+	obj, err = env.HydrateObject([]byte(`
+apiVersion: v1
+kind: Service
+metadata:
+  name: echo
+$expect-error: Forbidden
+`))
+	require.NoError(t, err)
+	assert.Equal(t, ExpectedError{Reason: "Forbidden"}, *obj.ExpectError)
+
+	_, err = env.HydrateObject([]byte(`
+apiVersion: v1
+kind: Service
+metadata:
+  name: echo
+$expect-error: Invalid
+$check: |
+  error[msg] { msg := "fail" }
+`))
+	assert.Error(t, err)
+}
+
+func TestHydrateDelay(t *testing.T) {
+	env := NewEnvironment()
+
+	obj, err := env.HydrateObject([]byte(`
+apiVersion: v1
+kind: Service
+metadata:
+  name: echo
+$delay: 2s
+`))
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Second, obj.Delay)
+
+	_, err = env.HydrateObject([]byte(`
+apiVersion: v1
+kind: Service
+metadata:
+  name: echo
+$delay: later
+`))
+	assert.Error(t, err)
+}
+
+func TestHydrateVersions(t *testing.T) {
+	env := NewEnvironment()
+
+	obj, err := env.HydrateObject([]byte(`
+apiVersion: example.com/v1alpha1
+kind: Widget
+metadata:
+  name: round-trip
+$versions: [v1alpha1, v1]
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"v1alpha1", "v1"}, obj.Versions)
+	_, found := obj.Object.Object["$versions"]
+	assert.False(t, found)
+
+	_, err = env.HydrateObject([]byte(`
+apiVersion: example.com/v1alpha1
+kind: Widget
+metadata:
+  name: round-trip
+$versions: [example.com/v1]
+`))
+	assert.Error(t, err)
+
+	_, err = env.HydrateObject([]byte(`
+apiVersion: example.com/v1alpha1
+kind: Widget
+metadata:
+  name: round-trip
+$apply: delete
+$versions: [v1]
+`))
+	assert.Error(t, err)
+}
+
+func TestHydrateTimeout(t *testing.T) {
+	env := NewEnvironment()
+
+	obj, err := env.HydrateObject([]byte(`
+apiVersion: v1
+kind: Service
+metadata:
+  name: echo
+spec:
+  type: LoadBalancer
+$timeout: 5m
+`))
+	require.NoError(t, err)
+	assert.Equal(t, 5*time.Minute, obj.Timeout)
+
+	_, err = env.HydrateObject([]byte(`
+apiVersion: v1
+kind: Service
+metadata:
+  name: echo
+$timeout: 0s
+`))
+	assert.Error(t, err)
+}
+
+func TestHydrateWait(t *testing.T) {
+	env := NewEnvironment()
+
+	obj, err := env.HydrateObject([]byte(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: echo
+$wait:
+  for: condition=Available
+  timeout: 3m
+`))
+	require.NoError(t, err)
+	assert.Equal(t, &Wait{Condition: "Available", Status: "True", Timeout: 3 * time.Minute}, obj.Wait)
+
+	obj, err = env.HydrateObject([]byte(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: echo
+$wait: condition=Available
+`))
+	require.NoError(t, err)
+	assert.Equal(t, &Wait{Condition: "Available", Status: "True"}, obj.Wait)
+
+	_, err = env.HydrateObject([]byte(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: echo
+$wait: ready
+`))
+	assert.Error(t, err)
+}
+
+func TestHydrateSkip(t *testing.T) {
+	env := NewEnvironment()
+
+	obj, err := env.HydrateObject([]byte(`
+apiVersion: projectcontour.io/v1
+kind: HTTPProxy
+metadata:
+  name: echo
+$skip: not data.resources[".versions"].httpproxies
+`))
+	require.NoError(t, err)
+	require.NotNil(t, obj.Skip)
+	assert.Equal(t, `not data.resources[".versions"].httpproxies`, obj.Skip.String())
+
+	_, err = env.HydrateObject([]byte(`
+apiVersion: projectcontour.io/v1
+kind: HTTPProxy
+metadata:
+  name: echo
+$skip: "not ["
+`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,139 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package driver
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// envVarRef matches an escaped "$${" or an environment variable
+// reference, "${NAME}".
+var envVarRef = regexp.MustCompile(`\$\$\{|\$\{([^}]*)\}`)
+
+// envVarName matches a valid environment variable name.
+var envVarName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidateEnvVarName checks that name is a valid environment
+// variable name.
+func ValidateEnvVarName(name string) error {
+	if !envVarName.MatchString(name) {
+		return fmt.Errorf("invalid environment variable name %q", name)
+	}
+
+	return nil
+}
+
+// EnvVarsOpt allows objects to refer to the named environment
+// variables as "${NAME}". The references are substituted in the
+// scalar values of the object after its YAML is parsed, so a value
+// can't change the structure of the object. If this option is not
+// given, objects are hydrated without substitution.
+func EnvVarsOpt(names []string) EnvironmentOpt {
+	return EnvironmentOpt(func(e *environ) {
+		if e.envVars == nil {
+			e.envVars = map[string]bool{}
+		}
+
+		for _, n := range names {
+			e.envVars[n] = true
+		}
+	})
+}
+
+// expandEnvRefs substitutes the values of the environment variable
+// references in s. "$${" is replaced with a literal "${". It is an
+// error to refer to a variable that is not allowed, or that is not set.
+func expandEnvRefs(s string, allowed map[string]bool, lookup func(string) (string, bool)) (string, error) {
+	var err error
+
+	expanded := envVarRef.ReplaceAllStringFunc(s, func(ref string) string {
+		if err != nil {
+			return ref
+		}
+
+		if ref == "$${" {
+			return "${"
+		}
+
+		name := ref[2 : len(ref)-1]
+
+		if err = ValidateEnvVarName(name); err != nil {
+			return ref
+		}
+
+		if !allowed[name] {
+			err = fmt.Errorf("environment variable %q is not allowed", name)
+			return ref
+		}
+
+		val, ok := lookup(name)
+		if !ok {
+			err = fmt.Errorf("environment variable %q is not set", name)
+			return ref
+		}
+
+		return val
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return expanded, nil
+}
+
+// expandEnvVars substitutes the environment variable references in
+// each of the scalar values below node. A plain scalar is resolved
+// again from its substituted value, so that "replicas: ${REPLICAS}"
+// is a number, but a quoted scalar is always a string.
+func expandEnvVars(node *yaml.Node, allowed map[string]bool, lookup func(string) (string, bool)) error {
+	if node.Kind == yaml.ScalarNode {
+		expanded, err := expandEnvRefs(node.Value, allowed, lookup)
+		if err != nil {
+			return err
+		}
+
+		if expanded != node.Value {
+			node.Value = expanded
+			if node.Style == 0 {
+				node.Tag = ""
+			}
+		}
+
+		return nil
+	}
+
+	for _, n := range node.Content {
+		if err := expandEnvVars(n, allowed, lookup); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// expandEnv substitutes environment variable references in the
+// parsed object, if the Environment allows any variables.
+func (e *environ) expandEnv(resource *yaml.RNode) error {
+	if len(e.envVars) == 0 {
+		return nil
+	}
+
+	return expandEnvVars(resource.YNode(), e.envVars, os.LookupEnv)
+}
@@ -0,0 +1,115 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package driver
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandEnvRefs(t *testing.T) {
+	allowed := map[string]bool{"REGISTRY": true, "EMPTY": true, "UNSET": true}
+	lookup := func(name string) (string, bool) {
+		switch name {
+		case "REGISTRY":
+			return "registry.example.com", true
+		case "EMPTY":
+			return "", true
+		default:
+			return "", false
+		}
+	}
+
+	expand := func(s string) (string, error) {
+		return expandEnvRefs(s, allowed, lookup)
+	}
+
+	out, err := expand(`image: ${REGISTRY}/echo:${EMPTY}latest`)
+	require.NoError(t, err)
+	assert.Equal(t, `image: registry.example.com/echo:latest`, out)
+
+	out, err = expand(`script: echo $${HOME} $HOME $$`)
+	require.NoError(t, err)
+	assert.Equal(t, `script: echo ${HOME} $HOME $$`, out)
+
+	_, err = expand(`image: ${HOME}/echo`)
+	assert.EqualError(t, err, `environment variable "HOME" is not allowed`)
+
+	_, err = expand(`image: ${UNSET}/echo`)
+	assert.EqualError(t, err, `environment variable "UNSET" is not set`)
+
+	_, err = expand(`image: ${not-a-name}`)
+	assert.EqualError(t, err, `invalid environment variable name "not-a-name"`)
+}
+
+func TestHydrateEnvVars(t *testing.T) {
+	require.NoError(t, os.Setenv("INTEGRATION_TESTER_REPLICAS", "3"))
+	defer os.Unsetenv("INTEGRATION_TESTER_REPLICAS")
+
+	data := []byte(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: echo
+spec:
+  replicas: ${INTEGRATION_TESTER_REPLICAS}
+`)
+
+	obj, err := NewEnvironment(EnvVarsOpt([]string{"INTEGRATION_TESTER_REPLICAS"})).HydrateObject(data)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), obj.Object.Object["spec"].(map[string]interface{})["replicas"])
+
+	// Without the option, references are not substituted.
+	obj, err = NewEnvironment().HydrateObject(data)
+	require.NoError(t, err)
+	assert.Equal(t, "${INTEGRATION_TESTER_REPLICAS}", obj.Object.Object["spec"].(map[string]interface{})["replicas"])
+
+	_, err = NewEnvironment(EnvVarsOpt([]string{"OTHER"})).HydrateObject(data)
+	assert.Error(t, err)
+}
+
+func TestHydrateEnvVarsMultiline(t *testing.T) {
+	// A multi-line value that looks like YAML stays a single
+	// string, rather than adding fields to the object.
+	value := "line one\nkind: Secret\n- item: ${NOT_EXPANDED}\n"
+
+	require.NoError(t, os.Setenv("INTEGRATION_TESTER_SCRIPT", value))
+	defer os.Unsetenv("INTEGRATION_TESTER_SCRIPT")
+
+	data := []byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: script
+data:
+  plain: ${INTEGRATION_TESTER_SCRIPT}
+  quoted: "prefix ${INTEGRATION_TESTER_SCRIPT}"
+  literal: |
+    ${INTEGRATION_TESTER_SCRIPT}
+`)
+
+	obj, err := NewEnvironment(EnvVarsOpt([]string{"INTEGRATION_TESTER_SCRIPT"})).HydrateObject(data)
+	require.NoError(t, err)
+
+	assert.Equal(t, "ConfigMap", obj.Object.GetKind())
+	assert.Equal(t, map[string]interface{}{
+		"plain":   value,
+		"quoted":  "prefix " + value,
+		"literal": value + "\n",
+	}, obj.Object.Object["data"])
+}
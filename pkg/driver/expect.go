@@ -0,0 +1,98 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package driver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/projectcontour/integration-tester/pkg/doc"
+
+	"github.com/open-policy-agent/opa/ast"
+)
+
+// ExpectedError describes how the API server is expected to reject
+// an object operation. It is derived from the "$expect-error"
+// pseudo-field.
+type ExpectedError struct {
+	// Reason is the expected status reason (e.g. "Invalid"). If
+	// it is empty, any reason is accepted.
+	Reason string `yaml:"reason"`
+
+	// MessageContains is a string that the status message is
+	// expected to contain.
+	MessageContains string `yaml:"message-contains"`
+}
+
+// Check generates the Rego check module that verifies that the
+// operation failed in the expected way.
+func (e *ExpectedError) Check() (*ast.Module, error) {
+	buf := bytes.Buffer{}
+
+	buf.WriteString(`
+error_operation_succeeded[msg] {
+  not input.error.message
+
+  msg := sprintf("expected %s '%s/%s' to be rejected", [
+    input.target.meta.kind,
+    input.target.namespace,
+    input.target.name,
+  ])
+}
+`)
+
+	if e.Reason != "" {
+		fmt.Fprintf(&buf, `
+error_unexpected_reason[msg] {
+  input.error.reason != %[1]s
+
+  msg := sprintf("expected rejection reason %%q, got %%q: %%s", [
+    %[1]s,
+    input.error.reason,
+    input.error.message,
+  ])
+}
+`, regoString(e.Reason))
+	}
+
+	if e.MessageContains != "" {
+		fmt.Fprintf(&buf, `
+error_unexpected_message[msg] {
+  input.error.message
+  not contains(input.error.message, %[1]s)
+
+  msg := sprintf("expected rejection message to contain %%q, got %%q", [
+    %[1]s,
+    input.error.message,
+  ])
+}
+`, regoString(e.MessageContains))
+	}
+
+	frag, err := doc.NewRegoFragment(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate %q check: %w", "$expect-error", err)
+	}
+
+	return frag.Rego(), nil
+}
+
+// regoString quotes s as a Rego string literal. Rego strings use
+// the JSON escaping rules.
+func regoString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
@@ -0,0 +1,93 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/projectcontour/integration-tester/pkg/filter"
+	"github.com/projectcontour/integration-tester/pkg/version"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// imagePullFailures are the container waiting reasons that mean
+// that the image can't be pulled.
+var imagePullFailures = map[string]bool{
+	"ErrImagePull":      true,
+	"ImagePullBackOff":  true,
+	"InvalidImageName":  true,
+	"ErrImageNeverPull": true,
+}
+
+// CheckImagePull verifies that the cluster can pull the given
+// container image. It runs a pod that uses the image in the given
+// namespace, and waits until the container has started, or until
+// pulling the image fails. The pod is deleted before returning.
+func CheckImagePull(k *KubeClient, namespace string, image string, timeout time.Duration) error {
+	pods := k.Client.CoreV1().Pods(namespace)
+
+	pod, err := pods.Create(context.Background(), &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "integration-tester-image-check-",
+			Labels: map[string]string{
+				filter.LabelManagedBy: version.Progname,
+			},
+		},
+		Spec: v1.PodSpec{
+			RestartPolicy: v1.RestartPolicyNever,
+			Containers: []v1.Container{{
+				Name:            "check",
+				Image:           image,
+				ImagePullPolicy: v1.PullAlways,
+			}},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to create image check pod: %w", err)
+	}
+
+	defer func() {
+		_ = pods.Delete(context.Background(), pod.GetName(), metav1.DeleteOptions{})
+	}()
+
+	deadline := time.Now().Add(timeout)
+
+	for {
+		current, err := pods.Get(context.Background(), pod.GetName(), metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to get image check pod: %w", err)
+		}
+
+		for _, s := range current.Status.ContainerStatuses {
+			switch {
+			case s.State.Running != nil, s.State.Terminated != nil:
+				return nil
+			case s.State.Waiting != nil && imagePullFailures[s.State.Waiting.Reason]:
+				return fmt.Errorf("failed to pull image %q: %s: %s",
+					image, s.State.Waiting.Reason, s.State.Waiting.Message)
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting to pull image %q", image)
+		}
+
+		time.Sleep(time.Second)
+	}
+}
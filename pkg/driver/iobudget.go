@@ -0,0 +1,233 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	// Register tls.probe before it is wrapped.
+	_ "github.com/projectcontour/integration-tester/pkg/builtin"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/topdown"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// IOBuiltins are the names of the Rego builtins that perform external
+// I/O, and whose calls are charged to the IOBudget of a check.
+var IOBuiltins = []string{
+	ast.HTTPSend.Name,
+	"tls.probe",
+}
+
+// IOBudget limits the external I/O that the builtins of a single check
+// can perform, across all the evaluations of the check. This keeps a
+// badly written polling check from overloading the system under test.
+// Zero limits are unlimited.
+type IOBudget struct {
+	// Requests is the maximum number of I/O builtin calls.
+	Requests int
+
+	// Bytes is the maximum number of response bytes.
+	Bytes int64
+
+	// Time is the maximum wall time spent in I/O builtins.
+	Time time.Duration
+}
+
+// IsZero returns whether the budget has no limits.
+func (b IOBudget) IsZero() bool {
+	return b == IOBudget{}
+}
+
+// ParseIOBudget parses I/O budget limits in "name=value" format. The
+// name is "requests", "bytes" (a quantity, e.g. "10Mi") or "time" (a
+// duration).
+func ParseIOBudget(limits []string) (IOBudget, error) {
+	var b IOBudget
+
+	for _, l := range limits {
+		parts := strings.SplitN(l, "=", 2)
+		if len(parts) != 2 {
+			return IOBudget{}, fmt.Errorf("missing value for I/O budget limit %q", parts[0])
+		}
+
+		switch parts[0] {
+		case "requests":
+			n, err := strconv.Atoi(parts[1])
+			if err != nil || n < 0 {
+				return IOBudget{}, fmt.Errorf("invalid requests budget %q", parts[1])
+			}
+
+			b.Requests = n
+		case "bytes":
+			q, err := resource.ParseQuantity(parts[1])
+			if err != nil || q.Sign() < 0 {
+				return IOBudget{}, fmt.Errorf("invalid bytes budget %q", parts[1])
+			}
+
+			b.Bytes = q.Value()
+		case "time":
+			d, err := time.ParseDuration(parts[1])
+			if err != nil || d < 0 {
+				return IOBudget{}, fmt.Errorf("invalid time budget %q", parts[1])
+			}
+
+			b.Time = d
+		default:
+			return IOBudget{}, fmt.Errorf("unknown I/O budget limit %q", parts[0])
+		}
+	}
+
+	return b, nil
+}
+
+// ioUsage is the I/O that a check has performed so far.
+type ioUsage struct {
+	lock     sync.Mutex
+	budget   IOBudget
+	requests int
+	bytes    int64
+	elapsed  time.Duration
+}
+
+type ioUsageKey struct{}
+
+// begin charges a request to the budget and returns a context that
+// expires when the remaining time budget is spent.
+func (u *ioUsage) begin(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	if err := u.exceeded(); err != nil {
+		return nil, nil, err
+	}
+
+	if u.budget.Requests > 0 && u.requests >= u.budget.Requests {
+		return nil, nil, fmt.Errorf("check exceeded its I/O budget of %d requests", u.budget.Requests)
+	}
+
+	u.requests++
+
+	if u.budget.Time > 0 {
+		ctx, cancel := context.WithTimeout(ctx, u.budget.Time-u.elapsed)
+		return ctx, cancel, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	return ctx, cancel, nil
+}
+
+// end charges the response size and the time of a request to the
+// budget, and returns an error if either budget is exceeded.
+func (u *ioUsage) end(bytes int64, elapsed time.Duration) error {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	u.bytes += bytes
+	u.elapsed += elapsed
+
+	return u.exceeded()
+}
+
+func (u *ioUsage) exceeded() error {
+	switch {
+	case u.budget.Bytes > 0 && u.bytes > u.budget.Bytes:
+		return fmt.Errorf("check exceeded its I/O budget of %d bytes", u.budget.Bytes)
+	case u.budget.Time > 0 && u.elapsed >= u.budget.Time:
+		return fmt.Errorf("check exceeded its I/O budget of %s", u.budget.Time)
+	default:
+		return nil
+	}
+}
+
+// responseSize returns the number of bytes that an I/O builtin
+// returned. For HTTP responses, that is the size of the raw body.
+func responseSize(t *ast.Term) int64 {
+	if obj, ok := t.Value.(ast.Object); ok {
+		if body := obj.Get(ast.StringTerm("raw_body")); body != nil {
+			if s, ok := body.Value.(ast.String); ok {
+				return int64(len(s))
+			}
+		}
+	}
+
+	return int64(len(t.String()))
+}
+
+// budgetedBuiltin wraps the implementation of an I/O builtin so that
+// its calls are charged to the I/O budget of the check that is being
+// evaluated, if there is one.
+func budgetedBuiltin(name string, fn topdown.BuiltinFunc) topdown.BuiltinFunc {
+	return func(bctx topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
+		usage, ok := bctx.Context.Value(ioUsageKey{}).(*ioUsage)
+		if !ok {
+			return fn(bctx, operands, iter)
+		}
+
+		budgetErr := func(err error) error {
+			return &topdown.Error{
+				Code:     topdown.BuiltinErr,
+				Message:  fmt.Sprintf("%s: %s", name, err),
+				Location: bctx.Location,
+			}
+		}
+
+		ctx, cancel, err := usage.begin(bctx.Context)
+		if err != nil {
+			return budgetErr(err)
+		}
+
+		defer cancel()
+
+		start := time.Now()
+		bctx.Context = ctx
+
+		var size int64
+		err = fn(bctx, operands, func(t *ast.Term) error {
+			size += responseSize(t)
+			return iter(t)
+		})
+
+		// A request that ran out of time reports the budget
+		// rather than the cancellation.
+		if budget := usage.end(size, time.Since(start)); budget != nil {
+			return budgetErr(budget)
+		}
+
+		return err
+	}
+}
+
+// The I/O builtins are wrapped once, when the package is initialized,
+// so that the builtin registry is never modified while checks are
+// being evaluated. Per-query functions (rego.Function) can't be used
+// instead, since OPA resolves globally registered builtins like
+// http.send before the functions of a query. The wrapped builtins
+// only charge the checks whose query context carries an I/O budget
+// (see regoDriver.evalContext), so checks without a budget are
+// unaffected.
+func init() {
+	for _, name := range IOBuiltins {
+		if fn := topdown.GetBuiltin(name); fn != nil {
+			topdown.RegisterBuiltinFunc(name, budgetedBuiltin(name, fn))
+		}
+	}
+}
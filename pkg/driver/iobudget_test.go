@@ -0,0 +1,127 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package driver
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/projectcontour/integration-tester/pkg/result"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIOBudget(t *testing.T) {
+	b, err := ParseIOBudget([]string{"requests=10", "bytes=1Ki", "time=30s"})
+	require.NoError(t, err)
+	assert.Equal(t, IOBudget{Requests: 10, Bytes: 1024, Time: 30 * time.Second}, b)
+	assert.False(t, b.IsZero())
+
+	for _, l := range []string{"requests", "requests=-1", "bytes=lots", "time=1", "files=2"} {
+		_, err := ParseIOBudget([]string{l})
+		assert.Error(t, err, l)
+	}
+}
+
+// evalIO evaluates a check that sends a request to url, and returns
+// the error results.
+func evalIO(t *testing.T, r RegoDriver, url string) []result.Result {
+	t.Helper()
+
+	results, err := r.Eval(parse(t, fmt.Sprintf(`
+package test
+
+error[msg] {
+  resp := http.send({"method": "GET", "url": %q})
+  resp.status_code != 200
+  msg := sprintf("status %%d", [resp.status_code])
+}
+`, url)))
+	require.NoError(t, err)
+
+	return results
+}
+
+func TestIOBudgetRequests(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	r := NewRegoDriver()
+	r.SetIOBudget(IOBudget{Requests: 2})
+
+	assert.Empty(t, evalIO(t, r, srv.URL))
+	assert.Empty(t, evalIO(t, r, srv.URL))
+
+	results := evalIO(t, r, srv.URL)
+	require.Len(t, results, 1)
+	assert.Equal(t, result.SeverityError, results[0].Severity)
+	assert.Contains(t, results[0].Message, "I/O budget of 2 requests")
+
+	// A new check gets a fresh budget.
+	r.ResetIOUsage()
+	assert.Empty(t, evalIO(t, r, srv.URL))
+}
+
+func TestIOBudgetBytes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, strings.Repeat("x", 100))
+	}))
+	defer srv.Close()
+
+	r := NewRegoDriver()
+	r.SetIOBudget(IOBudget{Bytes: 150})
+
+	assert.Empty(t, evalIO(t, r, srv.URL))
+
+	results := evalIO(t, r, srv.URL)
+	require.Len(t, results, 1)
+	assert.Contains(t, results[0].Message, "I/O budget of 150 bytes")
+}
+
+func TestIOBudgetTime(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	r := NewRegoDriver()
+	r.SetIOBudget(IOBudget{Time: 100 * time.Millisecond})
+
+	start := time.Now()
+	results := evalIO(t, r, srv.URL)
+	assert.Less(t, int64(time.Since(start)), int64(2*time.Second))
+
+	require.Len(t, results, 1)
+	assert.Contains(t, results[0].Message, "I/O budget of 100ms")
+}
+
+func TestIOBudgetUnlimited(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	r := NewRegoDriver()
+
+	for i := 0; i < 3; i++ {
+		assert.Empty(t, evalIO(t, r, srv.URL))
+	}
+}
@@ -17,18 +17,23 @@ package driver
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
+	"net/url"
 
 	"github.com/projectcontour/integration-tester/pkg/filter"
 	"github.com/projectcontour/integration-tester/pkg/must"
 	"github.com/projectcontour/integration-tester/pkg/utils"
+	"github.com/projectcontour/integration-tester/pkg/version"
 
 	v1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilversion "k8s.io/apimachinery/pkg/util/version"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/discovery/cached/memory"
 	"k8s.io/client-go/dynamic"
@@ -38,18 +43,35 @@ import (
 	"k8s.io/client-go/tools/clientcmd"
 )
 
-// KubeClient collects various Kubernetes client interfaces.
+// KubeClient collects various Kubernetes client interfaces. A
+// KubeClient is not modified after it is created, so it can be shared
+// by test documents that run concurrently. Each test run should use
+// its own Scoped copy. The discovery cache is safe for concurrent use,
+// and is shared by all the copies, so that each test document doesn't
+// repeat API discovery. It is invalidated whenever a test changes the
+// API resources that the API server serves, e.g. by applying a
+// CustomResourceDefinition.
 type KubeClient struct {
-	Config    *rest.Config // XXX(jpeach): remove this, it's only needed for init
 	Client    *kubernetes.Clientset
 	Dynamic   dynamic.Interface
 	Discovery discovery.CachedDiscoveryInterface
+
+	// Identifiers are the names of the current Kubernetes context
+	// and cluster, and the API server URL.
+	Identifiers []string
+
+	// Throttle backs off all the requests from this client when
+	// the API server throttles any of them.
+	Throttle *Throttle
 }
 
-// SetUserAgent sets the HTTP User-Agent on the Client.
-func (k *KubeClient) SetUserAgent(ua string) {
-	// XXX(jpeach): user agent is captured at create time, so keeping the config here doesn't help ...
-	k.Config.UserAgent = ua
+// Scoped returns a copy of the KubeClient that shares its API
+// clients, discovery cache and throttle.
+func (k *KubeClient) Scoped() *KubeClient {
+	scoped := *k
+	scoped.Identifiers = append([]string(nil), k.Identifiers...)
+
+	return &scoped
 }
 
 // NamespaceExists tests whether the given namespace is present.
@@ -65,6 +87,27 @@ func (k *KubeClient) NamespaceExists(nsName string) (bool, error) {
 	}
 }
 
+// ServerVersion returns the Kubernetes version of the API server.
+func (k *KubeClient) ServerVersion() (*utilversion.Version, error) {
+	info, err := k.Discovery.ServerVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	return utilversion.ParseGeneric(info.GitVersion)
+}
+
+// ClusterUID returns the UID of the kube-system namespace, which
+// identifies the cluster for as long as it exists.
+func (k *KubeClient) ClusterUID() (string, error) {
+	ns, err := k.Client.CoreV1().Namespaces().Get(context.Background(), "kube-system", metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	return string(ns.GetUID()), nil
+}
+
 func (k *KubeClient) findAPIResourceForKind(kind schema.GroupVersionKind) (metav1.APIResource, error) {
 	resources, err := k.Discovery.ServerResourcesForGroupVersion(
 		schema.GroupVersion{Group: kind.Group, Version: kind.Version}.String())
@@ -118,11 +161,56 @@ func (k *KubeClient) ResourceForKind(kind schema.GroupVersionKind) (schema.Group
 	}, nil
 }
 
+// GetObject fetches the named object of the given kind. The namespace
+// is ignored if the kind is not namespaced.
+func (k *KubeClient) GetObject(kind schema.GroupVersionKind, namespace string, name string) (*unstructured.Unstructured, error) {
+	res, err := k.findAPIResourceForKind(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	r := schema.GroupVersionResource{
+		Group:    res.Group,
+		Version:  res.Version,
+		Resource: res.Name,
+	}
+
+	if res.Namespaced {
+		return k.Dynamic.Resource(r).Namespace(namespace).Get(
+			context.Background(), name, metav1.GetOptions{})
+	}
+
+	return k.Dynamic.Resource(r).Get(context.Background(), name, metav1.GetOptions{})
+}
+
+// GetObjectVersions fetches the given object at each of the API
+// versions in its API group. For custom resources, the API server
+// converts the stored object to each version, so this exposes the
+// results of any conversion webhook.
+func (k *KubeClient) GetObjectVersions(u *unstructured.Unstructured, versions []string) (map[string]*unstructured.Unstructured, error) {
+	views := map[string]*unstructured.Unstructured{}
+
+	for _, v := range versions {
+		kind := u.GroupVersionKind()
+		kind.Version = v
+
+		view, err := k.GetObject(kind, u.GetNamespace(), u.GetName())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s %s/%s at version %q: %w",
+				u.GetKind(), u.GetNamespace(), u.GetName(), v, err)
+		}
+
+		views[v] = view
+	}
+
+	return views, nil
+}
+
 // ResourcesForName returns the possible set of schema.GroupVersionResource
 // corresponding to the given resource name.
 func (k *KubeClient) ResourcesForName(name string) ([]schema.GroupVersionResource, error) {
 	apiResources, err := k.ServerResources()
-	if err != nil {
+	if err != nil && DiscoveryFailures(err) == nil {
 		return nil, err
 	}
 
@@ -143,6 +231,34 @@ func (k *KubeClient) ResourcesForName(name string) ([]schema.GroupVersionResourc
 	return matched, nil
 }
 
+// PreferredResourceFor returns the schema.GroupVersionResource for
+// the version of the given resource that the API server prefers. If
+// the API server does not serve the resource, a NoResourceMatchError
+// is returned.
+func (k *KubeClient) PreferredResourceFor(gr schema.GroupResource) (schema.GroupVersionResource, error) {
+	groups, err := k.Discovery.ServerPreferredResources()
+	if err != nil {
+		return schema.GroupVersionResource{}, err
+	}
+
+	for _, g := range groups {
+		gv := must.GroupVersion(schema.ParseGroupVersion(g.GroupVersion))
+		if gv.Group != gr.Group {
+			continue
+		}
+
+		for _, r := range g.APIResources {
+			if r.Name == gr.Resource {
+				return gv.WithResource(r.Name), nil
+			}
+		}
+	}
+
+	return schema.GroupVersionResource{}, &meta.NoResourceMatchError{
+		PartialResource: gr.WithVersion(""),
+	}
+}
+
 // SelectObjects lists the objects matching the given kind and selector.
 func (k *KubeClient) SelectObjects(kind schema.GroupVersionKind, selector labels.Selector) (
 	[]*unstructured.Unstructured, error) {
@@ -181,11 +297,15 @@ func (k *KubeClient) SelectObjects(kind schema.GroupVersionKind, selector labels
 // ServerResources returns the list of all the resources supported
 // by the API server. Note that this method guarantees to populate the
 // Group and Version fields in the result.
+//
+// If discovery fails for some API groups (e.g. because an aggregated
+// API server is down), the resources of the other groups are returned
+// along with a discovery.ErrGroupDiscoveryFailed error.
 func (k *KubeClient) ServerResources() ([]metav1.APIResource, error) {
 	var resources []metav1.APIResource
 
 	_, apiList, err := k.Discovery.ServerGroupsAndResources()
-	if err != nil {
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
 		return nil, err
 	}
 
@@ -203,7 +323,24 @@ func (k *KubeClient) ServerResources() ([]metav1.APIResource, error) {
 		}
 	}
 
-	return resources, nil
+	return resources, err
+}
+
+// DiscoveryFailures returns the error message for each API group
+// version that failed discovery, if err is a partial discovery
+// failure. Otherwise it returns nil.
+func DiscoveryFailures(err error) map[string]string {
+	var failed *discovery.ErrGroupDiscoveryFailed
+	if !errors.As(err, &failed) {
+		return nil
+	}
+
+	failures := make(map[string]string, len(failed.Groups))
+	for gv, err := range failed.Groups {
+		failures[gv.String()] = err.Error()
+	}
+
+	return failures
 }
 
 // SelectObjectsByLabel lists all objects that are labeled as managed.
@@ -280,9 +417,20 @@ func (k *KubeClient) RunIDFor(u *unstructured.Unstructured) (string, error) {
 	return "", nil
 }
 
+// KubeClientOpt sets options for a KubeClient.
+type KubeClientOpt func(*rest.Config)
+
+// UserAgentOpt sets the HTTP User-Agent of the KubeClient requests.
+// The default is the program name and version.
+func UserAgentOpt(ua string) KubeClientOpt {
+	return KubeClientOpt(func(c *rest.Config) {
+		c.UserAgent = ua
+	})
+}
+
 // NewKubeClient returns a new set of Kubernetes client interfaces
 // that are configured to use the default Kubernetes context.
-func NewKubeClient() (*KubeClient, error) {
+func NewKubeClient(opts ...KubeClientOpt) (*KubeClient, error) {
 	rules := clientcmd.NewDefaultClientConfigLoadingRules()
 	overrides := &clientcmd.ConfigOverrides{}
 	config := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides)
@@ -292,6 +440,17 @@ func NewKubeClient() (*KubeClient, error) {
 		return nil, err
 	}
 
+	// The clients capture the configuration when they are
+	// created, so the options have to be applied first.
+	restConfig.UserAgent = fmt.Sprintf("%s/%s", version.Progname, version.Version)
+
+	for _, o := range opts {
+		o(restConfig)
+	}
+
+	throttle := NewThrottle()
+	restConfig.Wrap(throttle.Wrap)
+
 	clientSet, err := kubernetes.NewForConfig(restConfig)
 	if err != nil {
 		return nil, err
@@ -303,13 +462,33 @@ func NewKubeClient() (*KubeClient, error) {
 	}
 
 	return &KubeClient{
-		Config:    restConfig,
-		Client:    clientSet,
-		Dynamic:   dynamicIntf,
-		Discovery: memory.NewMemCacheClient(clientSet.Discovery()),
+		Client:      clientSet,
+		Dynamic:     dynamicIntf,
+		Discovery:   memory.NewMemCacheClient(clientSet.Discovery()),
+		Identifiers: clusterIdentifiers(config, restConfig),
+		Throttle:    throttle,
 	}, nil
 }
 
+// clusterIdentifiers returns the strings that identify the
+// Kubernetes cluster that the given configuration selects.
+func clusterIdentifiers(config clientcmd.ClientConfig, restConfig *rest.Config) []string {
+	var ids []string
+
+	if raw, err := config.RawConfig(); err == nil {
+		if ctx, ok := raw.Contexts[raw.CurrentContext]; ok {
+			ids = append(ids, raw.CurrentContext, ctx.Cluster, ctx.AuthInfo)
+		}
+	}
+
+	ids = append(ids, restConfig.Host)
+	if u, err := url.Parse(restConfig.Host); err == nil && u.Hostname() != "" {
+		ids = append(ids, u.Hostname())
+	}
+
+	return ids
+}
+
 // NewNamespace returns a v1/Namespace object named by nsName and
 // converted to an unstructured.Unstructured object.
 func NewNamespace(nsName string) *unstructured.Unstructured {
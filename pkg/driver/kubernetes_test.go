@@ -15,9 +15,16 @@
 package driver
 
 import (
+	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 )
 
 func TestNewNamespace(t *testing.T) {
@@ -29,3 +36,63 @@ func TestNewNamespace(t *testing.T) {
 	assert.Equal(t, u.GetKind(), "Namespace")
 	assert.Equal(t, u.GetAPIVersion(), "v1")
 }
+
+func TestDiscoveryFailures(t *testing.T) {
+	assert.Nil(t, DiscoveryFailures(nil))
+	assert.Nil(t, DiscoveryFailures(errors.New("connection refused")))
+
+	err := &discovery.ErrGroupDiscoveryFailed{
+		Groups: map[schema.GroupVersion]error{
+			{Group: "metrics.k8s.io", Version: "v1beta1"}: errors.New("service unavailable"),
+		},
+	}
+
+	assert.Equal(t,
+		map[string]string{"metrics.k8s.io/v1beta1": "service unavailable"},
+		DiscoveryFailures(fmt.Errorf("wrapped: %w", err)))
+}
+
+func TestScopedKubeClient(t *testing.T) {
+	clientSet, err := kubernetes.NewForConfig(&rest.Config{Host: "https://127.0.0.1:6443"})
+	assert.NoError(t, err)
+
+	kube := &KubeClient{
+		Client:      clientSet,
+		Discovery:   memory.NewMemCacheClient(clientSet.Discovery()),
+		Identifiers: []string{"kind-kind"},
+		Throttle:    NewThrottle(),
+	}
+
+	scoped := kube.Scoped()
+
+	// The API clients, discovery cache and throttle are
+	// shared, but the identifiers are not.
+	assert.True(t, kube.Client == scoped.Client)
+	assert.True(t, kube.Throttle == scoped.Throttle)
+	assert.True(t, kube.Discovery == scoped.Discovery)
+
+	scoped.Identifiers[0] = "changed"
+	assert.Equal(t, []string{"kind-kind"}, kube.Identifiers)
+}
+
+// countingDiscovery counts the invalidations of a discovery cache.
+type countingDiscovery struct {
+	discovery.CachedDiscoveryInterface
+	invalidated int
+}
+
+func (c *countingDiscovery) Invalidate() {
+	c.invalidated++
+}
+
+func TestInvalidateDiscovery(t *testing.T) {
+	d := &countingDiscovery{}
+	o := &objectDriver{kube: &KubeClient{Discovery: d}}
+
+	o.invalidateDiscovery(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"})
+	assert.Equal(t, 0, d.invalidated)
+
+	o.invalidateDiscovery(schema.GroupVersionKind{
+		Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"})
+	assert.Equal(t, 1, d.invalidated)
+}
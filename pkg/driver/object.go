@@ -18,6 +18,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -25,6 +26,7 @@ import (
 	"github.com/projectcontour/integration-tester/pkg/utils"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -44,6 +46,11 @@ type OperationResult struct {
 	Error  *metav1.Status             `json:"error"`
 	Latest *unstructured.Unstructured `json:"latest"`
 	Target ObjectReference            `json:"target"`
+
+	// Versions is the view of the object at each of the API
+	// versions that it was applied at (see Object.Versions),
+	// keyed by version.
+	Versions map[string]*unstructured.Unstructured `json:"versions,omitempty"`
 }
 
 // Succeeded returns true if the operation was successful.
@@ -58,15 +65,25 @@ type ObjectDriver interface {
 	// Eval creates or updates the specified object.
 	Apply(*unstructured.Unstructured) (*OperationResult, error)
 
+	// Create creates the specified object. Unlike Apply, it
+	// fails if the object already exists.
+	Create(*unstructured.Unstructured) (*OperationResult, error)
+
 	// Delete deleted the specified object.
 	Delete(*unstructured.Unstructured) (*OperationResult, error)
 
+	// Patch applies the RFC 6902 JSON patch to the existing
+	// object that is named by the specified object.
+	Patch(*unstructured.Unstructured, []byte) (*OperationResult, error)
+
 	// Adopt tells the driver to take ownership of and to start tracking
 	// the specified object. Any adopted objects will be included in a
 	// DeleteAll operation.
 	Adopt(*unstructured.Unstructured) error
 
-	// DeleteAll deletes all the objects that have been adopted by this driver.
+	// DeleteAll deletes all the objects that have been adopted by
+	// this driver. If any objects can't be deleted, the error is
+	// a *DeleteAllError that describes each failure.
 	DeleteAll() error
 
 	// InformOn establishes an informer for the given resource.
@@ -78,18 +95,41 @@ type ObjectDriver interface {
 	// by the driver have synced.
 	WaitForCacheSync(timeout time.Duration) error
 
+	// InformerStatus returns whether each of the informers
+	// created by the driver has synced.
+	InformerStatus() map[schema.GroupVersionResource]bool
+
 	// Watch registers an event handler to receive events from
 	// all the informers managed by the driver.
 	Watch(cache.ResourceEventHandler) func()
 
+	// Health returns an error if the informer event streams
+	// appear to be unhealthy, i.e. if watches are repeatedly
+	// failing or if events for changed objects are not arriving.
+	Health() error
+
 	// Done marks this driver session as complete. All informers
 	// are released, watchers are unregistered and adopted objects
 	// are forgotten.
 	Done()
 }
 
+// ObjectDriverOpt is a functional option for NewObjectDriver.
+type ObjectDriverOpt func(*objectDriver)
+
+// ServerDryRunOpt sends all the object operations to the API server
+// as server-side dry-run requests. The API server validates and
+// admits the objects, and returns the objects that it would have
+// persisted, but the cluster is not changed. Since nothing is
+// persisted, the objects are not adopted.
+func ServerDryRunOpt() ObjectDriverOpt {
+	return func(o *objectDriver) {
+		o.dryRun = []string{metav1.DryRunAll}
+	}
+}
+
 // NewObjectDriver returns a new ObjectDriver.
-func NewObjectDriver(client *KubeClient) ObjectDriver {
+func NewObjectDriver(client *KubeClient, opts ...ObjectDriverOpt) ObjectDriver {
 	// We used to inform with a managed-by=integration-tester filter
 	// so that we would only track objects that we create ourselves.
 	// However, in some cases, it is impossible to propagate labels
@@ -112,6 +152,7 @@ func NewObjectDriver(client *KubeClient) ObjectDriver {
 		kube:            client,
 		informerStopper: make(chan struct{}),
 		informerFactory: factory,
+		watchdog:        NewWatchdog(),
 
 		// watcherLock holds a lock over the watchers because
 		// we need to ensure watcher add and remove operations
@@ -124,6 +165,10 @@ func NewObjectDriver(client *KubeClient) ObjectDriver {
 		informerPool: make(map[schema.GroupVersionResource]informers.GenericInformer),
 	}
 
+	for _, opt := range opts {
+		opt(o)
+	}
+
 	return o
 }
 
@@ -137,10 +182,20 @@ type objectDriver struct {
 
 	watcherLock LockingResourceEventHandler
 
+	watchdog *Watchdog
+
+	// informerLock serializes access to the informer pool,
+	// since informers are started by apply operations while
+	// the health checks of the watchdog read the pool.
+	informerLock sync.Mutex
 	informerPool map[schema.GroupVersionResource]informers.GenericInformer
 
 	objectLock sync.Mutex
 	objectPool map[types.UID]*unstructured.Unstructured
+
+	// dryRun is the dry-run mode for API server requests. If
+	// it is set, objects are not persisted or adopted.
+	dryRun []string
 }
 
 // Done resets the object driver.
@@ -158,9 +213,10 @@ func (o *objectDriver) Done() {
 	o.objectPool = make(map[types.UID]*unstructured.Unstructured)
 	o.objectLock.Unlock()
 
-	// There is no locking on the informer pool since driver
-	// methods must not be called concurrently.
+	// Hold the informer lock while we clear the informer pool.
+	o.informerLock.Lock()
 	o.informerPool = make(map[schema.GroupVersionResource]informers.GenericInformer)
+	o.informerLock.Unlock()
 }
 
 func (o *objectDriver) Watch(e cache.ResourceEventHandler) func() {
@@ -178,16 +234,33 @@ func (o *objectDriver) Watch(e cache.ResourceEventHandler) func() {
 }
 
 func (o *objectDriver) InformOn(gvr schema.GroupVersionResource) error {
+	o.informerLock.Lock()
+	defer o.informerLock.Unlock()
+
 	if _, ok := o.informerPool[gvr]; ok {
 		return nil
 	}
 
 	// If we don't already have an informer for this resource, start one now.
 	genericInformer := o.informerFactory.ForResource(gvr)
+
+	// Count watch failures so that the watchdog can tell when
+	// the event stream is unhealthy, but still let the default
+	// handler log them.
+	if err := genericInformer.Informer().SetWatchErrorHandler(
+		func(r *cache.Reflector, err error) {
+			o.watchdog.WatchError(gvr)
+			cache.DefaultWatchErrorHandler(r, err)
+		}); err != nil {
+		return err
+	}
+
 	genericInformer.Informer().AddEventHandler(
 		&WrappingResourceEventHandlerFuncs{
 			Next: &o.watcherLock,
 			AddFunc: func(obj interface{}) {
+				o.notifyWatchdog(gvr, obj)
+
 				o.objectLock.Lock()
 				defer o.objectLock.Unlock()
 
@@ -196,6 +269,8 @@ func (o *objectDriver) InformOn(gvr schema.GroupVersionResource) error {
 				}
 			},
 			UpdateFunc: func(oldObj, newObj interface{}) {
+				o.notifyWatchdog(gvr, newObj)
+
 				o.objectLock.Lock()
 				defer o.objectLock.Unlock()
 
@@ -204,6 +279,8 @@ func (o *objectDriver) InformOn(gvr schema.GroupVersionResource) error {
 				}
 			},
 			DeleteFunc: func(obj interface{}) {
+				o.notifyWatchdog(gvr, obj)
+
 				o.objectLock.Lock()
 				defer o.objectLock.Unlock()
 
@@ -222,12 +299,75 @@ func (o *objectDriver) InformOn(gvr schema.GroupVersionResource) error {
 	return nil
 }
 
+func (o *objectDriver) notifyWatchdog(gvr schema.GroupVersionResource, obj interface{}) {
+	if key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj); err == nil {
+		o.watchdog.Event(gvr, key)
+	}
+}
+
+// expectEvent tells the watchdog that we changed the given object,
+// so we expect the informer to deliver an event for it. If the
+// informer has already cached this version of the object, then
+// either the event already arrived or the change was a no-op.
+func (o *objectDriver) expectEvent(gvr schema.GroupVersionResource, u *unstructured.Unstructured) {
+	o.informerLock.Lock()
+	informer, ok := o.informerPool[gvr]
+	o.informerLock.Unlock()
+
+	if !ok {
+		return
+	}
+
+	key, err := cache.MetaNamespaceKeyFunc(u)
+	if err != nil {
+		return
+	}
+
+	if cached, exists, err := informer.Informer().GetStore().GetByKey(key); err == nil && exists {
+		if c, ok := cached.(*unstructured.Unstructured); ok &&
+			c.GetResourceVersion() == u.GetResourceVersion() {
+			return
+		}
+	}
+
+	o.watchdog.Expect(gvr, key)
+}
+
+func (o *objectDriver) Health() error {
+	return o.watchdog.Health()
+}
+
+// informOnDependents establishes informers for the resources that
+// the controller for the given kind is expected to generate. Resources
+// that the API server doesn't serve are ignored, since not every
+// cluster supports every resource (e.g. EndpointSlices).
+func (o *objectDriver) informOnDependents(gk schema.GroupKind) error {
+	for _, gr := range DependentResourcesForKind(gk) {
+		gvr, err := o.kube.PreferredResourceFor(gr)
+		switch {
+		case err == nil:
+		case meta.IsNoMatchError(err):
+			continue
+		default:
+			return fmt.Errorf("failed to resolve resource %q: %s", gr, err)
+		}
+
+		if err := o.InformOn(gvr); err != nil {
+			return fmt.Errorf("failed to start informer for %q: %s", gvr, err)
+		}
+	}
+
+	return nil
+}
+
 func (o *objectDriver) WaitForCacheSync(timeout time.Duration) error {
 	var synced []cache.InformerSynced
 
+	o.informerLock.Lock()
 	for _, i := range o.informerPool {
 		synced = append(synced, i.Informer().HasSynced)
 	}
+	o.informerLock.Unlock()
 
 	stopChan := make(chan struct{})
 	timer := time.AfterFunc(timeout, func() { close(stopChan) })
@@ -240,7 +380,31 @@ func (o *objectDriver) WaitForCacheSync(timeout time.Duration) error {
 	return nil
 }
 
+func (o *objectDriver) InformerStatus() map[schema.GroupVersionResource]bool {
+	o.informerLock.Lock()
+	defer o.informerLock.Unlock()
+
+	status := make(map[schema.GroupVersionResource]bool, len(o.informerPool))
+
+	for gvr, i := range o.informerPool {
+		status[gvr] = i.Informer().HasSynced()
+	}
+
+	return status
+}
+
 func (o *objectDriver) Apply(obj *unstructured.Unstructured) (*OperationResult, error) {
+	return o.apply(obj, true)
+}
+
+func (o *objectDriver) Create(obj *unstructured.Unstructured) (*OperationResult, error) {
+	return o.apply(obj, false)
+}
+
+// apply creates the given object. If the object already exists and
+// patchExisting is true, the existing object is patched instead.
+// Otherwise, the AlreadyExists status is returned in the result.
+func (o *objectDriver) apply(obj *unstructured.Unstructured, patchExisting bool) (*OperationResult, error) {
 	obj = obj.DeepCopy() // Copy in case we set the namespace.
 	gvk := obj.GetObjectKind().GroupVersionKind()
 
@@ -260,6 +424,10 @@ func (o *objectDriver) Apply(obj *unstructured.Unstructured) (*OperationResult,
 		return nil, fmt.Errorf("failed to start informer for %q: %s", gvr, err)
 	}
 
+	if err := o.informOnDependents(gvk.GroupKind()); err != nil {
+		return nil, err
+	}
+
 	if isNamespaced {
 		if ns := obj.GetNamespace(); ns == "" {
 			obj.SetNamespace(metav1.NamespaceDefault)
@@ -270,17 +438,17 @@ func (o *objectDriver) Apply(obj *unstructured.Unstructured) (*OperationResult,
 
 	if isNamespaced {
 		latest, err = o.kube.Dynamic.Resource(gvr).Namespace(obj.GetNamespace()).Create(
-			context.Background(), obj, metav1.CreateOptions{})
+			context.Background(), obj, metav1.CreateOptions{DryRun: o.dryRun})
 	} else {
 		latest, err = o.kube.Dynamic.Resource(gvr).Create(
-			context.Background(), obj, metav1.CreateOptions{})
+			context.Background(), obj, metav1.CreateOptions{DryRun: o.dryRun})
 	}
 
 	// If the create was against an object that already existed,
 	// retry as an update.
-	if apierrors.IsAlreadyExists(err) {
+	if patchExisting && apierrors.IsAlreadyExists(err) {
 		name := obj.GetName()
-		opt := metav1.PatchOptions{}
+		opt := metav1.PatchOptions{DryRun: o.dryRun}
 		ptype := types.MergePatchType
 		data := must.Bytes(obj.MarshalJSON())
 
@@ -307,15 +475,23 @@ func (o *objectDriver) Apply(obj *unstructured.Unstructured) (*OperationResult,
 		Target: *(&ObjectReference{}).FromUnstructured(obj),
 	}
 
-	switch err {
-	case nil:
+	switch {
+	case err == nil && len(o.dryRun) > 0:
+		// Dry-run objects were not persisted, so
+		// there's nothing to adopt or watch for.
+		result.Latest = latest
+	case err == nil:
 		result.Latest = latest
+		o.invalidateDiscovery(gvk)
+
 		if err := o.Adopt(latest); err != nil {
 			return nil, fmt.Errorf("failed to adopt %s %s/%s: %w",
 				latest.GetKind(), latest.GetNamespace(), latest.GetName(), err)
 
 		}
 
+		o.expectEvent(gvr, latest)
+
 	default:
 		var statusError *apierrors.StatusError
 		if !errors.As(err, &statusError) {
@@ -356,22 +532,27 @@ func (o *objectDriver) Delete(obj *unstructured.Unstructured) (*OperationResult,
 		Target: *(&ObjectReference{}).FromUnstructured(obj),
 	}
 
-	// Scan for the latest update if we have adopted this object.
-	// The caller doesn't have to provide a complete object from
-	// the API server, so we can't match on the UID here.
-	//
-	// TODO(jpeach): maybe we should just fetch the object first?
-	o.objectLock.Lock()
-	for _, adopted := range o.objectPool {
-		if adopted.GetName() == obj.GetName() &&
-			adopted.GetNamespace() == obj.GetNamespace() &&
-			adopted.GetKind() == obj.GetKind() {
+	// Fetch the current state of the object, so that the delete
+	// check sees the full object, including its finalizers. If
+	// the object can't be fetched, fall back to the latest update
+	// if we have adopted this object. The caller doesn't have to
+	// provide a complete object from the API server, so we can't
+	// match on the UID here.
+	if current, err := o.getObject(gvr, isNamespaced, obj); err == nil {
+		result.Latest = current
+	} else {
+		o.objectLock.Lock()
+		for _, adopted := range o.objectPool {
+			if adopted.GetName() == obj.GetName() &&
+				adopted.GetNamespace() == obj.GetNamespace() &&
+				adopted.GetKind() == obj.GetKind() {
 
-			result.Latest = adopted.DeepCopy()
-			break
+				result.Latest = adopted.DeepCopy()
+				break
+			}
 		}
+		o.objectLock.Unlock()
 	}
-	o.objectLock.Unlock()
 
 	opts := utils.ImmediateDeletionOptions(metav1.DeletePropagationForeground)
 
@@ -382,6 +563,8 @@ func (o *objectDriver) Delete(obj *unstructured.Unstructured) (*OperationResult,
 		opts = utils.ImmediateDeletionOptions(metav1.DeletePropagationBackground)
 	}
 
+	opts.DryRun = o.dryRun
+
 	if isNamespaced {
 		err = o.kube.Dynamic.Resource(gvr).Namespace(obj.GetNamespace()).Delete(
 			context.Background(), obj.GetName(), opts)
@@ -393,6 +576,18 @@ func (o *objectDriver) Delete(obj *unstructured.Unstructured) (*OperationResult,
 	switch err {
 	case nil:
 		result.Error = nil
+
+		if len(o.dryRun) == 0 {
+			o.invalidateDiscovery(gvk)
+		}
+
+		// If finalizers are holding the object, the API
+		// server has set its deletion timestamp, so give the
+		// check that state. If the object has already gone,
+		// the check gets the state from before the delete.
+		if current, err := o.getObject(gvr, isNamespaced, obj); err == nil {
+			result.Latest = current
+		}
 	default:
 		var statusError *apierrors.StatusError
 		if !errors.As(err, &statusError) {
@@ -405,6 +600,101 @@ func (o *objectDriver) Delete(obj *unstructured.Unstructured) (*OperationResult,
 	return &result, nil
 }
 
+// getObject fetches the current state of the named object from the
+// API server.
+func (o *objectDriver) getObject(gvr schema.GroupVersionResource, isNamespaced bool, obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	if isNamespaced {
+		return o.kube.Dynamic.Resource(gvr).Namespace(obj.GetNamespace()).Get(
+			context.Background(), obj.GetName(), metav1.GetOptions{})
+	}
+
+	return o.kube.Dynamic.Resource(gvr).Get(
+		context.Background(), obj.GetName(), metav1.GetOptions{})
+}
+
+func (o *objectDriver) Patch(obj *unstructured.Unstructured, patch []byte) (*OperationResult, error) {
+	obj = obj.DeepCopy() // Copy in case we set the namespace.
+	gvk := obj.GetObjectKind().GroupVersionKind()
+
+	isNamespaced, err := o.kube.KindIsNamespaced(gvk)
+	if err != nil {
+		return nil, fmt.Errorf("failed check if resource kind %q is namespaced: %s",
+			gvk.Kind, err)
+	}
+
+	gvr, err := o.kube.ResourceForKind(gvk)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve resource for kind %s:%s: %s",
+			obj.GetAPIVersion(), obj.GetKind(), err)
+	}
+
+	if err := o.InformOn(gvr); err != nil {
+		return nil, fmt.Errorf("failed to start informer for %q: %s", gvr, err)
+	}
+
+	if isNamespaced {
+		if ns := obj.GetNamespace(); ns == "" {
+			obj.SetNamespace(metav1.NamespaceDefault)
+		}
+	}
+
+	var latest *unstructured.Unstructured
+
+	// Since the patch target already exists, we don't adopt
+	// it. If the test created it, it was adopted then.
+	if isNamespaced {
+		latest, err = o.kube.Dynamic.Resource(gvr).Namespace(obj.GetNamespace()).Patch(
+			context.Background(), obj.GetName(), types.JSONPatchType, patch, metav1.PatchOptions{DryRun: o.dryRun})
+	} else {
+		latest, err = o.kube.Dynamic.Resource(gvr).Patch(
+			context.Background(), obj.GetName(), types.JSONPatchType, patch, metav1.PatchOptions{DryRun: o.dryRun})
+	}
+
+	result := OperationResult{
+		Error:  nil,
+		Latest: obj,
+		Target: *(&ObjectReference{}).FromUnstructured(obj),
+	}
+
+	switch {
+	case err == nil && len(o.dryRun) > 0:
+		result.Latest = latest
+	case err == nil:
+		result.Latest = latest
+		o.invalidateDiscovery(gvk)
+		o.expectEvent(gvr, latest)
+	default:
+		var statusError *apierrors.StatusError
+		if !errors.As(err, &statusError) {
+			return nil, fmt.Errorf("failed to patch resource: %w", err)
+		}
+
+		result.Error = &statusError.ErrStatus
+	}
+
+	return &result, nil
+}
+
+// discoveryKinds are the kinds that add API resources to the
+// API server.
+var discoveryKinds = []schema.GroupKind{
+	{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"},
+	{Group: "apiregistration.k8s.io", Kind: "APIService"},
+}
+
+// invalidateDiscovery drops the cached API discovery after an object
+// of the given kind changed, if the change can add or remove API
+// resources. The discovery cache is shared by all the test documents
+// of a run, so the next lookup in any document fetches it again.
+func (o *objectDriver) invalidateDiscovery(gvk schema.GroupVersionKind) {
+	for _, gk := range discoveryKinds {
+		if gvk.GroupKind() == gk {
+			o.kube.Discovery.Invalidate()
+			return
+		}
+	}
+}
+
 func (o *objectDriver) updateAdoptedObject(obj *unstructured.Unstructured) {
 	uid := obj.GetUID()
 
@@ -440,9 +730,38 @@ func (o *objectDriver) Adopt(obj *unstructured.Unstructured) error {
 	return nil
 }
 
+// DeleteFailure describes an object that DeleteAll failed to delete.
+type DeleteFailure struct {
+	Target ObjectReference
+
+	// Reason is the API server status reason for the failure,
+	// or empty if the request didn't get a status response.
+	Reason metav1.StatusReason
+
+	Err error
+}
+
+// DeleteAllError is the error returned by DeleteAll. It has one
+// failure for each object that could not be deleted.
+type DeleteAllError struct {
+	Failures []DeleteFailure
+}
+
+func (e *DeleteAllError) Error() string {
+	msgs := make([]string, 0, len(e.Failures))
+
+	for _, f := range e.Failures {
+		msgs = append(msgs, fmt.Sprintf("%s '%s/%s': %s",
+			f.Target.Meta.Kind, f.Target.Namespace, f.Target.Name, f.Err))
+	}
+
+	return fmt.Sprintf("failed to delete %d object(s): %s",
+		len(e.Failures), strings.Join(msgs, "; "))
+}
+
 func (o *objectDriver) DeleteAll() error {
 	for {
-		var errs []error
+		var failures []DeleteFailure
 		targets := make([]*unstructured.Unstructured, 0, len(o.objectPool))
 
 		o.objectLock.Lock()
@@ -459,7 +778,10 @@ func (o *objectDriver) DeleteAll() error {
 			result, err := o.Delete(u)
 
 			if err != nil {
-				errs = append(errs, err)
+				failures = append(failures, DeleteFailure{
+					Target: *(&ObjectReference{}).FromUnstructured(u),
+					Err:    err,
+				})
 				continue
 			}
 
@@ -473,17 +795,18 @@ func (o *objectDriver) DeleteAll() error {
 					o.objectLock.Unlock()
 				default:
 					// Re-wrap the error that we unwrapped for status!
-					errs = append(errs, &apierrors.StatusError{
-						ErrStatus: *result.Error,
+					failures = append(failures, DeleteFailure{
+						Target: result.Target,
+						Reason: result.Error.Reason,
+						Err:    &apierrors.StatusError{ErrStatus: *result.Error},
 					})
 					continue
 				}
 			}
 		}
 
-		if len(errs) != 0 {
-			errs = append([]error{errors.New("failed to delete all objects")}, errs...)
-			return utils.ChainErrors(errs...)
+		if len(failures) != 0 {
+			return &DeleteAllError{Failures: failures}
 		}
 
 		time.Sleep(time.Second)
@@ -16,6 +16,7 @@ package driver
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"strings"
@@ -65,8 +66,28 @@ type RegoDriver interface {
 	// Eval evaluates the given module and returns and check results.
 	Eval(*ast.Module, ...RegoOpt) ([]result.Result, error)
 
+	// Test evaluates the given query and returns whether
+	// it is true (i.e. has any results).
+	Test(ast.Body, ...RegoOpt) (bool, error)
+
+	// Query evaluates the given query and returns the raw
+	// result set.
+	Query(ast.Body, ...RegoOpt) (rego.ResultSet, error)
+
 	Trace(RegoTracer)
 
+	// SetRuleSeverities sets the additional rule names that
+	// Eval treats as test results.
+	SetRuleSeverities(*RuleSeverities)
+
+	// SetIOBudget sets the limits on the external I/O that the
+	// builtins of each check can perform.
+	SetIOBudget(IOBudget)
+
+	// ResetIOUsage starts charging external I/O to a fresh
+	// budget. It is called at the start of each check.
+	ResetIOUsage()
+
 	// StoreItem stores the value at the given path in the Rego data document.
 	StoreItem(string, interface{}) error
 
@@ -75,6 +96,10 @@ type RegoDriver interface {
 
 	// RemovePath remove any object at the given path in the Rego data document.
 	RemovePath(where string) error
+
+	// ReadPath returns a copy of the object at the given path
+	// in the Rego data document.
+	ReadPath(where string) (interface{}, error)
 }
 
 // NewRegoDriver creates a new RegoDriver that evaluates checks
@@ -90,14 +115,46 @@ func NewRegoDriver() RegoDriver {
 var _ RegoDriver = &regoDriver{}
 
 type regoDriver struct {
-	store  storage.Store
-	tracer RegoTracer
+	store      storage.Store
+	tracer     RegoTracer
+	severities *RuleSeverities
+	ioBudget   IOBudget
+	ioUsage    *ioUsage
 }
 
 func (r *regoDriver) Trace(tracer RegoTracer) {
 	r.tracer = tracer
 }
 
+func (r *regoDriver) SetRuleSeverities(s *RuleSeverities) {
+	r.severities = s
+}
+
+func (r *regoDriver) SetIOBudget(b IOBudget) {
+	r.ioBudget = b
+	r.ResetIOUsage()
+}
+
+func (r *regoDriver) ResetIOUsage() {
+	r.ioUsage = nil
+
+	if !r.ioBudget.IsZero() {
+		r.ioUsage = &ioUsage{budget: r.ioBudget}
+	}
+}
+
+// evalContext returns the context for evaluating a query, which
+// carries the I/O usage of the current check.
+func (r *regoDriver) evalContext() context.Context {
+	ctx := context.Background()
+
+	if r.ioUsage != nil {
+		ctx = context.WithValue(ctx, ioUsageKey{}, r.ioUsage)
+	}
+
+	return ctx
+}
+
 // StoreItem stores the value at the given Rego store path.
 func (r *regoDriver) StoreItem(where string, what interface{}) error {
 	ctx := context.Background()
@@ -175,10 +232,38 @@ func (r *regoDriver) RemovePath(where string) error {
 	return nil
 }
 
+// ReadPath returns a copy of the object at the given path in the
+// Rego data document.
+func (r *regoDriver) ReadPath(where string) (interface{}, error) {
+	ctx := context.Background()
+	txn := storage.NewTransactionOrDie(ctx, r.store)
+	defer r.store.Abort(ctx, txn)
+
+	val, err := r.store.Read(ctx, txn, storage.MustParsePath(where))
+	if err != nil {
+		return nil, err
+	}
+
+	// The store returns its internal representation, which
+	// subsequent writes will modify, so we need to hand back
+	// a deep copy.
+	data, err := json.Marshal(val)
+	if err != nil {
+		return nil, err
+	}
+
+	var copied interface{}
+	if err := json.Unmarshal(data, &copied); err != nil {
+		return nil, err
+	}
+
+	return copied, nil
+}
+
 // Eval evaluates checks in the given module.
 func (r *regoDriver) Eval(m *ast.Module, opts ...RegoOpt) ([]result.Result, error) {
 	// Find the unique set of assertion rules to query.
-	ruleNames := findAssertionRules(m)
+	ruleNames := r.severities.findAssertionRules(m)
 	checkResults := make([]result.Result, 0, len(ruleNames))
 
 	for _, name := range ruleNames {
@@ -198,7 +283,7 @@ func (r *regoDriver) Eval(m *ast.Module, opts ...RegoOpt) ([]result.Result, erro
 			// Scope the query to the current module package.
 			rego.Package(pkg),
 			// Query for the result of this named rule.
-			rego.Query(queryForRuleName(name)),
+			rego.Query(r.severities.queryForRuleName(name)),
 			rego.Store(r.store),
 		}
 
@@ -209,7 +294,7 @@ func (r *regoDriver) Eval(m *ast.Module, opts ...RegoOpt) ([]result.Result, erro
 		}
 
 		regoObj := rego.New(options...)
-		resultSet, err := regoObj.Eval(context.Background())
+		resultSet, err := regoObj.Eval(r.evalContext())
 
 		if r.tracer != nil {
 			r.tracer.Write()
@@ -239,9 +324,9 @@ func (r *regoDriver) Eval(m *ast.Module, opts ...RegoOpt) ([]result.Result, erro
 
 		// In each result, the Text is the expression that we
 		// queried, and value is one or more bound messages.
-		for _, r := range resultSet {
-			for _, expr := range r.Expressions {
-				checkResults = append(checkResults, extractResult(expr)...)
+		for _, rs := range resultSet {
+			for _, expr := range rs.Expressions {
+				checkResults = append(checkResults, extractResult(r.severities, expr)...)
 			}
 		}
 
@@ -250,6 +335,39 @@ func (r *regoDriver) Eval(m *ast.Module, opts ...RegoOpt) ([]result.Result, erro
 	return checkResults, nil
 }
 
+// Test evaluates the given query and returns whether it is true.
+// Queries that are false or undefined have no results.
+func (r *regoDriver) Test(query ast.Body, opts ...RegoOpt) (bool, error) {
+	resultSet, err := r.Query(query, opts...)
+	if err != nil {
+		return false, err
+	}
+
+	return len(resultSet) > 0, nil
+}
+
+// Query evaluates the given query and returns the result set.
+func (r *regoDriver) Query(query ast.Body, opts ...RegoOpt) (rego.ResultSet, error) {
+	options := []RegoOpt{
+		rego.ParsedQuery(query),
+		rego.Store(r.store),
+	}
+
+	options = append(options, opts...)
+
+	if r.tracer != nil {
+		options = append(options, rego.Tracer(r.tracer))
+	}
+
+	resultSet, err := rego.New(options...).Eval(r.evalContext())
+
+	if r.tracer != nil {
+		r.tracer.Write()
+	}
+
+	return resultSet, err
+}
+
 // extractResult examines a rego.ExpressionValue to find the result
 // (message) of a rule that we queried . A Rego query has an optional
 // key term that can be of any type. In most cases, the term will be
@@ -263,37 +381,39 @@ func (r *regoDriver) Eval(m *ast.Module, opts ...RegoOpt) ([]result.Result, erro
 // "msg". In the future, we could accept other types, but
 //
 // See also https://github.com/instrumenta/conftest/pull/243.
-func extractResult(expr *rego.ExpressionValue) []result.Result {
+func extractResult(severities *RuleSeverities, expr *rego.ExpressionValue) []result.Result {
 	var results []result.Result
 
 	switch value := expr.Value.(type) {
 	case []interface{}:
 		for _, v := range value {
 			results = append(results,
-				extractOneResult(severityForRuleName(expr.Text), v),
+				extractOneResult(severities.severityForRuleName(expr.Text), v),
 			)
 		}
 
 	default:
 		results = append(results,
-			extractOneResult(severityForRuleName(expr.Text), value),
+			extractOneResult(severities.severityForRuleName(expr.Text), value),
 		)
 	}
 
-	// Prefix any results with the name of the query predicate that emitted them.
+	// Record the name of the query predicate that emitted the
+	// results, so that they can be rendered with it.
 	for i := range results {
-		prefix := fmt.Sprintf("raised predicate %q", expr.Text)
-		if results[i].Message == "" {
-			results[i].Message = prefix
-		} else {
-			results[i].Message = utils.JoinLines(prefix, results[i].Message)
-		}
+		results[i].Rule = expr.Text
 	}
 
 	return results
 }
 
 func extractOneResult(severity result.Severity, v interface{}) result.Result {
+	res := extractOneMessage(severity, v)
+	res.Value = v
+	return res
+}
+
+func extractOneMessage(severity result.Severity, v interface{}) result.Result {
 	// If this is a []string, then we have the result already.
 	if s, ok := utils.AsStringSlice(v); ok {
 		return result.Result{
@@ -336,6 +456,7 @@ func extractOneResult(severity result.Severity, v interface{}) result.Result {
 	//	 	...
 	//		msg := "this is a failing thing"
 	//	}`
+	// A "scope" field limits the extent of a Skip result.
 	case map[string]interface{}:
 		res := result.Result{
 			Severity: severity,
@@ -359,6 +480,12 @@ func extractOneResult(severity result.Severity, v interface{}) result.Result {
 			}
 		}
 
+		if s, ok := value["scope"].(string); ok {
+			if scope, err := result.ParseScope(s); err == nil {
+				res.Scope = scope
+			}
+		}
+
 		return res
 
 		// We don't know how to deal with this kind of result, so just puke it out as YAML.
@@ -16,6 +16,7 @@ package driver
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
 
 	"github.com/projectcontour/integration-tester/pkg/result"
@@ -81,21 +82,19 @@ fatal[msg] { msg = "this is the fatal error"}
 
 	expected := []result.Result{{
 		Severity: result.SeverityError,
-		Message: utils.JoinLines(
-			"raised predicate \"error\"",
-			"this is the error",
-		),
+		Rule:     "error",
+		Message:  "this is the error",
+		Value:    "this is the error",
 	}, {
 		Severity: result.SeverityError,
-		Message: utils.JoinLines(
-			"raised predicate \"error\"",
-			"this is the second error",
-		),
+		Rule:     "error",
+		Message:  "this is the second error",
+		Value:    "this is the second error",
 	}, {
 		Severity: result.SeverityFatal,
-		Message: utils.JoinLines(
-			"raised predicate \"fatal\"",
-			"this is the fatal error"),
+		Rule:     "fatal",
+		Message:  "this is the fatal error",
+		Value:    "this is the fatal error",
 	}}
 
 	assert.ElementsMatch(t, expected, results)
@@ -114,9 +113,9 @@ error [{"msg": msg, "foo": "bar"}] { msg = "this is the nested error"}
 
 	expected := []result.Result{{
 		Severity: result.SeverityError,
-		Message: utils.JoinLines(
-			"raised predicate \"error\"",
-			"this is the nested error"),
+		Rule:     "error",
+		Message:  "this is the nested error",
+		Value:    map[string]interface{}{"msg": "this is the nested error", "foo": "bar"},
 	}}
 
 	assert.ElementsMatch(t, expected, results)
@@ -135,7 +134,8 @@ error  { msg = "this error doesn't appear"}
 
 	expected := []result.Result{{
 		Severity: result.SeverityError,
-		Message:  "raised predicate \"error\"",
+		Rule:     "error",
+		Value:    true,
 	}}
 
 	assert.ElementsMatch(t, expected, results)
@@ -167,18 +167,20 @@ error [msg] {
 
 	expected := []result.Result{{
 		Severity: result.SeverityError,
+		Rule:     "error",
 		Message: utils.JoinLines(
-			"raised predicate \"error\"",
 			"message one",
 			"message two",
 		),
+		Value: []interface{}{"message one", "message two"},
 	}, {
 		Severity: result.SeverityError,
+		Rule:     "error",
 		Message: utils.JoinLines(
-			"raised predicate \"error\"",
 			"message three",
 			"message four",
 		),
+		Value: []interface{}{"message three", "message four"},
 	}}
 
 	assert.ElementsMatch(t, expected, results)
@@ -203,25 +205,28 @@ error[num] { num := sites[_].count }
 
 	expected := []result.Result{{
 		Severity: result.SeverityError,
+		Rule:     "error",
 		Message: utils.JoinLines(
-			"raised predicate \"error\"",
 			"unhandled result value type 'json.Number'",
 			"1\n", // Trailing newline because YAML.
 		),
+		Value: json.Number("1"),
 	}, {
 		Severity: result.SeverityError,
+		Rule:     "error",
 		Message: utils.JoinLines(
-			"raised predicate \"error\"",
 			"unhandled result value type 'json.Number'",
 			"2\n", // Trailing newline because YAML.
 		),
+		Value: json.Number("2"),
 	}, {
 		Severity: result.SeverityError,
+		Rule:     "error",
 		Message: utils.JoinLines(
-			"raised predicate \"error\"",
 			"unhandled result value type 'json.Number'",
 			"3\n", // Trailing newline because YAML.
 		),
+		Value: json.Number("3"),
 	}}
 
 	assert.ElementsMatch(t, expected, results)
@@ -259,21 +264,19 @@ check_something_else[result] {
 
 	expected := []result.Result{{
 		Severity: result.SeveritySkip,
-		Message: utils.JoinLines(
-			"raised predicate \"check\"",
-			"skipped message here",
-		),
+		Rule:     "check",
+		Message:  "skipped message here",
+		Value:    map[string]interface{}{"result": "Skip", "msg": "skipped message here"},
 	}, {
 		Severity: result.SeverityError,
-		Message: utils.JoinLines(
-			"raised predicate \"check_something_else\"",
-			"error message here",
-		),
+		Rule:     "check_something_else",
+		Message:  "error message here",
+		Value:    map[string]interface{}{"result": "Error", "msg": "error message here"},
 	}, {
 		Severity: result.SeverityPass,
-		Message: utils.JoinLines(
-			"raised predicate \"check_something_else\"",
-			"this check passed"),
+		Rule:     "check_something_else",
+		Message:  "this check passed",
+		Value:    map[string]interface{}{"result": "Pass", "msg": "this check passed"},
 	}}
 
 	assert.ElementsMatch(t, expected, results)
@@ -370,3 +373,23 @@ func TestStoreRemoveItem(t *testing.T) {
 
 	assert.True(t, storage.IsNotFound(r.RemovePath("/no/such/path")))
 }
+
+func TestQueryResultSet(t *testing.T) {
+	r := NewRegoDriver()
+
+	require.NoError(t, r.StoreItem("/resources", map[string]interface{}{
+		"pods": map[string]interface{}{
+			"one": map[string]interface{}{"phase": "Running"},
+			"two": map[string]interface{}{"phase": "Pending"},
+		},
+	}))
+
+	resultSet, err := r.Query(ast.MustParseBody(`data.resources.pods[name].phase == "Running"`))
+	require.NoError(t, err)
+	require.Len(t, resultSet, 1)
+	assert.Equal(t, "one", resultSet[0].Bindings["name"])
+
+	resultSet, err = r.Query(ast.MustParseBody(`data.resources.pods.three`))
+	require.NoError(t, err)
+	assert.Empty(t, resultSet)
+}
@@ -15,6 +15,8 @@
 package driver
 
 import (
+	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/projectcontour/integration-tester/pkg/result"
@@ -28,7 +30,7 @@ type ruleInfo struct {
 	severity result.Severity
 }
 
-var rules = []ruleInfo{
+var builtinRules = []ruleInfo{
 	// The following rules cause a tet failure if they are ever true.
 	{name: "error", prefix: "error_", severity: result.SeverityError},
 	{name: "fatal", prefix: "fatal_", severity: result.SeverityFatal},
@@ -36,9 +38,76 @@ var rules = []ruleInfo{
 	{name: "check", prefix: "check_", severity: result.SeverityNone},
 }
 
-// matchRuleByName finds the ruleInfo that matches the given query
-// name exactly, or by prefix.
-func matchRuleByName(name string) *ruleInfo {
+var ruleNameRegex = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]*$`)
+
+// RuleSeverities maps additional Rego rule names to result
+// severities, so that checks can use the rule naming conventions of
+// existing Rego libraries (e.g. "deny" or "violation"). A nil
+// RuleSeverities matches only the builtin rule names.
+type RuleSeverities struct {
+	rules []ruleInfo
+}
+
+// Add maps Rego rules that are named name, or that are prefixed with
+// name and an underscore, to the given result severity. Adding a
+// name that is already mapped replaces its severity. Names that the
+// builtin rules already match can't be remapped.
+func (s *RuleSeverities) Add(name string, severity result.Severity) error {
+	if !ruleNameRegex.MatchString(name) {
+		return fmt.Errorf("invalid rule name %q", name)
+	}
+
+	if _, err := result.ParseSeverity(string(severity)); err != nil {
+		return err
+	}
+
+	if q := matchRule(builtinRules, name); q != nil {
+		return fmt.Errorf("rule name %q is already matched by the builtin %q rules", name, q.name)
+	}
+
+	for i := range s.rules {
+		if s.rules[i].name == name {
+			s.rules[i].severity = severity
+			return nil
+		}
+	}
+
+	s.rules = append(s.rules, ruleInfo{
+		name:     name,
+		prefix:   name + "_",
+		severity: severity,
+	})
+
+	return nil
+}
+
+// Severity returns the test severity that the rule with the given
+// name raises, and whether the rule is a test result rule at all.
+func (s *RuleSeverities) Severity(name string) (result.Severity, bool) {
+	if q := s.match(name); q != nil {
+		return q.severity, true
+	}
+
+	return result.SeverityNone, false
+}
+
+// match finds the ruleInfo that matches the given query name exactly,
+// or by prefix. The builtin rules take precedence.
+func (s *RuleSeverities) match(name string) *ruleInfo {
+	if q := matchRule(builtinRules, name); q != nil {
+		return q
+	}
+
+	if s == nil {
+		return nil
+	}
+
+	return matchRule(s.rules, name)
+}
+
+// matchRule finds the ruleInfo that matches the given query name
+// exactly, or by prefix.
+func matchRule(rules []ruleInfo, name string) *ruleInfo {
 	for _, q := range rules {
 		if name == q.name || strings.HasPrefix(name, q.prefix) {
 			return &q
@@ -49,19 +118,16 @@ func matchRuleByName(name string) *ruleInfo {
 }
 
 // severityForRuleName returns the test severity for a given rule name.
-func severityForRuleName(name string) result.Severity {
-	if q := matchRuleByName(name); q != nil {
-		return q.severity
-	}
-
-	return result.SeverityNone
+func (s *RuleSeverities) severityForRuleName(name string) result.Severity {
+	severity, _ := s.Severity(name)
+	return severity
 }
 
 // queryForRuleName returns a Rego query for the given rule name. This
 // is currently a no-op, but is a placeholder for allowing non-identity
 // queries against rules.
-func queryForRuleName(name string) string {
-	if q := matchRuleByName(name); q != nil {
+func (s *RuleSeverities) queryForRuleName(name string) string {
+	if q := s.match(name); q != nil {
 		return name
 	}
 
@@ -70,7 +136,7 @@ func queryForRuleName(name string) string {
 
 // findAssertionRules searches the module for rules that match a
 // test assertion severity.
-func findAssertionRules(m *ast.Module) []string {
+func (s *RuleSeverities) findAssertionRules(m *ast.Module) []string {
 	// The rule names we match in a hash because the same rule
 	// name can appear more than once in a policy document.
 	found := map[string]struct{}{}
@@ -78,13 +144,9 @@ func findAssertionRules(m *ast.Module) []string {
 	for _, rule := range m.Rules {
 		name := rule.Head.Name.String()
 
-		switch {
-		case name == "check" || strings.HasPrefix(name, "check_"):
-			found[name] = struct{}{}
-		case severityForRuleName(name) != result.SeverityNone:
+		if s.match(name) != nil {
 			found[name] = struct{}{}
 		}
-
 	}
 
 	// Flatten query names back into the slice.
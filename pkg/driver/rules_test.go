@@ -0,0 +1,94 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package driver
+
+import (
+	"testing"
+
+	"github.com/projectcontour/integration-tester/pkg/result"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuleSeverities(t *testing.T) {
+	s := &RuleSeverities{}
+
+	assert.Error(t, s.Add("deny-all", result.SeverityError))
+	assert.Error(t, s.Add("deny", result.SeverityPass))
+	assert.Error(t, s.Add("error", result.SeverityFatal))
+	assert.Error(t, s.Add("skip_deny", result.SeverityError))
+
+	require.NoError(t, s.Add("deny", result.SeverityError))
+	require.NoError(t, s.Add("violation", result.SeverityFatal))
+	require.NoError(t, s.Add("violation", result.SeverityError))
+
+	assert.Equal(t, result.SeverityError, s.severityForRuleName("deny"))
+	assert.Equal(t, result.SeverityError, s.severityForRuleName("deny_privileged"))
+	assert.Equal(t, result.SeverityError, s.severityForRuleName("violation"))
+	assert.Equal(t, result.SeverityNone, s.severityForRuleName("denylist"))
+
+	// The extra rule names don't leak into other drivers.
+	var none *RuleSeverities
+	_, ok := none.Severity("deny")
+	assert.False(t, ok)
+
+	r := NewRegoDriver()
+	r.SetRuleSeverities(s)
+
+	results, err := r.Eval(parse(t, `
+package test
+
+deny[msg] { msg = "this is denied"}
+violation[{"msg": msg}] { msg = "this is a violation"}
+allow { true }
+`))
+
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []result.Result{{
+		Severity: result.SeverityError,
+		Rule:     "deny",
+		Message:  "this is denied",
+		Value:    "this is denied",
+	}, {
+		Severity: result.SeverityError,
+		Rule:     "violation",
+		Message:  "this is a violation",
+		Value:    map[string]interface{}{"msg": "this is a violation"},
+	}}, results)
+}
+
+func TestRuleSeveritiesPerDriver(t *testing.T) {
+	s := &RuleSeverities{}
+	require.NoError(t, s.Add("deny", result.SeverityError))
+
+	withSeverities := NewRegoDriver()
+	withSeverities.SetRuleSeverities(s)
+
+	m := parse(t, `
+package test
+
+deny[msg] { msg = "this is denied"}
+`)
+
+	results, err := withSeverities.Eval(m)
+	require.NoError(t, err)
+	assert.Len(t, results, 1)
+
+	results, err = NewRegoDriver().Eval(m)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+}
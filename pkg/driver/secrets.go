@@ -0,0 +1,223 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package driver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/projectcontour/integration-tester/pkg/utils"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// SecretTypeTLS is the type of a Kubernetes TLS Secret.
+const SecretTypeTLS = "kubernetes.io/tls"
+
+// RotateSecret regenerates the data of the given Secret in place.
+// The certificate and key of a TLS Secret are replaced with a new
+// self-signed certificate for the same subject and names. The
+// values of any other Secret are replaced with random strings.
+func RotateSecret(u *unstructured.Unstructured) error {
+	if u.GetAPIVersion() != "v1" || u.GetKind() != "Secret" {
+		return fmt.Errorf("cannot rotate %s:%s object, only Secrets can be rotated",
+			u.GetAPIVersion(), u.GetKind())
+	}
+
+	data, err := secretData(u)
+	if err != nil {
+		return err
+	}
+
+	secretType, _, _ := unstructured.NestedString(u.Object, "type")
+
+	if secretType == SecretTypeTLS {
+		certPEM, keyPEM, err := newCertificate(u.GetName(), data["tls.crt"])
+		if err != nil {
+			return fmt.Errorf("failed to generate certificate: %w", err)
+		}
+
+		data["tls.crt"] = certPEM
+		data["tls.key"] = keyPEM
+
+		// The new certificate is self-signed, so it is its own CA.
+		if _, ok := data["ca.crt"]; ok {
+			data["ca.crt"] = certPEM
+		}
+	} else {
+		for k, v := range data {
+			n := len(v)
+			if n < 16 {
+				n = 16
+			}
+
+			data[k] = []byte(utils.RandomStringN(n))
+		}
+	}
+
+	encoded := map[string]interface{}{}
+	for k, v := range data {
+		encoded[k] = base64.StdEncoding.EncodeToString(v)
+	}
+
+	unstructured.RemoveNestedField(u.Object, "stringData")
+	return unstructured.SetNestedMap(u.Object, encoded, "data")
+}
+
+// SecretDigest returns a summary of the Secret data that tests can
+// use to verify that rotated material was picked up, without
+// exposing the material itself. The summary contains the SHA256
+// hash of the whole Secret, the hash of each key and, for Secrets
+// that hold a certificate, the certificate serial number.
+func SecretDigest(u *unstructured.Unstructured) (map[string]interface{}, error) {
+	data, err := secretData(u)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	all := sha256.New()
+	hashes := map[string]interface{}{}
+
+	for _, k := range keys {
+		sum := sha256.Sum256(data[k])
+		hashes[k] = hex.EncodeToString(sum[:])
+
+		fmt.Fprintf(all, "%s=%x\n", k, sum)
+	}
+
+	digest := map[string]interface{}{
+		"sha256": hex.EncodeToString(all.Sum(nil)),
+		"keys":   hashes,
+	}
+
+	if cert, err := parseCertificate(data["tls.crt"]); err == nil {
+		digest["serial"] = CertificateSerial(cert)
+	}
+
+	return digest, nil
+}
+
+// CertificateSerial formats the serial number of the certificate
+// as a lower case hexadecimal string.
+func CertificateSerial(cert *x509.Certificate) string {
+	return cert.SerialNumber.Text(16)
+}
+
+// secretData returns the decoded data of the Secret, including any
+// values from the "stringData" field.
+func secretData(u *unstructured.Unstructured) (map[string][]byte, error) {
+	data := map[string][]byte{}
+
+	values, _, err := unstructured.NestedStringMap(u.Object, "data")
+	if err != nil {
+		return nil, fmt.Errorf("invalid Secret data: %w", err)
+	}
+
+	for k, v := range values {
+		raw, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Secret data key %q: %w", k, err)
+		}
+
+		data[k] = raw
+	}
+
+	stringValues, _, err := unstructured.NestedStringMap(u.Object, "stringData")
+	if err != nil {
+		return nil, fmt.Errorf("invalid Secret stringData: %w", err)
+	}
+
+	for k, v := range stringValues {
+		data[k] = []byte(v)
+	}
+
+	return data, nil
+}
+
+func parseCertificate(certPEM []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("no PEM certificate")
+	}
+
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// newCertificate generates a new self-signed certificate and key.
+// If the previous certificate can be parsed, the new certificate
+// reuses its subject and names. Otherwise, the subject is name.
+func newCertificate(name string, previous []byte) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{name},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(30 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	if prev, err := parseCertificate(previous); err == nil {
+		template.Subject = prev.Subject
+		template.DNSNames = prev.DNSNames
+		template.IPAddresses = prev.IPAddresses
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+
+	return certPEM, keyPEM, nil
+}
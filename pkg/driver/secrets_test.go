@@ -0,0 +1,135 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package driver
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func newSecret(secretType string, data map[string]string) *unstructured.Unstructured {
+	encoded := map[string]interface{}{}
+	for k, v := range data {
+		encoded[k] = base64.StdEncoding.EncodeToString([]byte(v))
+	}
+
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"type":       secretType,
+		"metadata": map[string]interface{}{
+			"name":      "credentials",
+			"namespace": "default",
+		},
+		"data": encoded,
+	}}
+}
+
+func TestRotateOpaqueSecret(t *testing.T) {
+	s := newSecret("Opaque", map[string]string{"password": "hunter2"})
+
+	before, err := SecretDigest(s)
+	require.NoError(t, err)
+
+	require.NoError(t, RotateSecret(s))
+
+	after, err := SecretDigest(s)
+	require.NoError(t, err)
+
+	assert.NotEqual(t, before["sha256"], after["sha256"])
+	assert.Contains(t, after["keys"], "password")
+	assert.NotContains(t, after, "serial")
+
+	data, err := secretData(s)
+	require.NoError(t, err)
+	assert.Len(t, data["password"], 16)
+}
+
+func TestRotateTLSSecret(t *testing.T) {
+	certPEM, keyPEM, err := newCertificate("echo.example.com", nil)
+	require.NoError(t, err)
+
+	s := newSecret(SecretTypeTLS, map[string]string{
+		"tls.crt": string(certPEM),
+		"tls.key": string(keyPEM),
+	})
+
+	before, err := SecretDigest(s)
+	require.NoError(t, err)
+	require.Contains(t, before, "serial")
+
+	require.NoError(t, RotateSecret(s))
+
+	after, err := SecretDigest(s)
+	require.NoError(t, err)
+
+	assert.NotEqual(t, before["sha256"], after["sha256"])
+	assert.NotEqual(t, before["serial"], after["serial"])
+
+	data, err := secretData(s)
+	require.NoError(t, err)
+
+	cert, err := parseCertificate(data["tls.crt"])
+	require.NoError(t, err)
+	assert.Equal(t, "echo.example.com", cert.Subject.CommonName)
+	assert.Equal(t, []string{"echo.example.com"}, cert.DNSNames)
+	assert.Equal(t, after["serial"], CertificateSerial(cert))
+}
+
+func TestRotateNonSecret(t *testing.T) {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+	}}
+
+	assert.EqualError(t, RotateSecret(u),
+		"cannot rotate v1:ConfigMap object, only Secrets can be rotated")
+}
+
+func TestHydrateRotate(t *testing.T) {
+	live := newSecret("Opaque", map[string]string{"token": "0123456789abcdef"})
+	live.SetResourceVersion("42")
+
+	env := NewClusterEnvironment(func(kind schema.GroupVersionKind, namespace string, name string) (*unstructured.Unstructured, error) {
+		assert.Equal(t, "Secret", kind.Kind)
+		assert.Equal(t, "default", namespace)
+		assert.Equal(t, "credentials", name)
+
+		return live, nil
+	})
+
+	obj, err := env.HydrateObject([]byte(`
+apiVersion: v1
+kind: Secret
+metadata:
+  name: credentials
+$apply: rotate
+`))
+	require.NoError(t, err)
+
+	assert.Equal(t, ObjectOperationType(ObjectOperationUpdate), obj.Operation)
+	assert.True(t, obj.Rotated)
+	assert.Equal(t, "", obj.Object.GetResourceVersion())
+
+	data, err := secretData(obj.Object)
+	require.NoError(t, err)
+	assert.Len(t, data["token"], 16)
+	assert.NotEqual(t, "0123456789abcdef", string(data["token"]))
+}
@@ -0,0 +1,164 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package driver
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultThrottleBackoff is the initial backoff after the API
+	// server throttles a request without a Retry-After header.
+	DefaultThrottleBackoff = time.Second
+
+	// DefaultMaxThrottleBackoff is the longest backoff after the
+	// API server throttles a request.
+	DefaultMaxThrottleBackoff = time.Second * 30
+)
+
+// Throttle detects API server throttling. When the API server
+// rejects a request with "429 Too Many Requests", all subsequent
+// requests wait until the backoff expires. Since every driver shares
+// the same Kubernetes client configuration, this makes the whole test
+// run back off, rather than each request retrying on its own and
+// adding to the load on a busy API server.
+type Throttle struct {
+	// Backoff is the initial backoff when the API server doesn't
+	// say how long to wait. It doubles for each consecutive
+	// throttled request.
+	Backoff time.Duration
+
+	// MaxBackoff is the longest backoff.
+	MaxBackoff time.Duration
+
+	now func() time.Time
+
+	lock        sync.Mutex
+	until       time.Time
+	consecutive int
+	throttled   int
+	waited      time.Duration
+}
+
+// NewThrottle returns a new Throttle with the default backoffs.
+func NewThrottle() *Throttle {
+	return &Throttle{
+		Backoff:    DefaultThrottleBackoff,
+		MaxBackoff: DefaultMaxThrottleBackoff,
+		now:        time.Now,
+	}
+}
+
+// Wrap returns a HTTP transport that sends requests with rt, subject
+// to the throttle.
+func (t *Throttle) Wrap(rt http.RoundTripper) http.RoundTripper {
+	return &throttleTransport{throttle: t, next: rt}
+}
+
+// Stats returns the number of throttled requests, and the total
+// time that requests were held back for.
+func (t *Throttle) Stats() (int, time.Duration) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return t.throttled, t.waited
+}
+
+// WriteReport writes a warning to out if the API server throttled
+// any requests.
+func (t *Throttle) WriteReport(out io.Writer) {
+	throttled, waited := t.Stats()
+	if throttled == 0 {
+		return
+	}
+
+	fmt.Fprintf(out, "\nWARNING: the API server throttled %d requests, and the test run backed off for %s\n",
+		throttled, waited.Round(time.Millisecond))
+}
+
+// delay returns how long a request needs to wait before it can be
+// sent.
+func (t *Throttle) delay() time.Duration {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return t.until.Sub(t.now())
+}
+
+// observe updates the throttle from the response to a request.
+func (t *Throttle) observe(resp *http.Response) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.consecutive = 0
+		return
+	}
+
+	backoff := t.Backoff << uint(t.consecutive)
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		backoff = time.Duration(seconds) * time.Second
+	}
+
+	if backoff <= 0 || backoff > t.MaxBackoff {
+		backoff = t.MaxBackoff
+	}
+
+	t.throttled++
+	t.consecutive++
+
+	// Only count the time that this response extends the
+	// current backoff by, since concurrent requests can be
+	// throttled at the same time.
+	now := t.now()
+	start := now
+	if t.until.After(start) {
+		start = t.until
+	}
+
+	if until := now.Add(backoff); until.After(start) {
+		t.waited += until.Sub(start)
+		t.until = until
+	}
+}
+
+type throttleTransport struct {
+	throttle *Throttle
+	next     http.RoundTripper
+}
+
+func (t *throttleTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if d := t.throttle.delay(); d > 0 {
+		timer := time.NewTimer(d)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err == nil {
+		t.throttle.observe(resp)
+	}
+
+	return resp, err
+}
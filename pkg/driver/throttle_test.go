@@ -0,0 +1,92 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package driver
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func throttleResponse(status int, retryAfter string) *http.Response {
+	resp := &http.Response{StatusCode: status, Header: http.Header{}}
+	if retryAfter != "" {
+		resp.Header.Set("Retry-After", retryAfter)
+	}
+
+	return resp
+}
+
+func TestThrottleRetryAfter(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	throttle := NewThrottle()
+	throttle.now = clock.Now
+
+	throttle.observe(throttleResponse(http.StatusOK, ""))
+	assert.LessOrEqual(t, int64(throttle.delay()), int64(0))
+
+	throttle.observe(throttleResponse(http.StatusTooManyRequests, "3"))
+	assert.Equal(t, 3*time.Second, throttle.delay())
+
+	// A concurrent throttled request only counts the time that
+	// it extends the backoff by.
+	clock.Advance(time.Second)
+	throttle.observe(throttleResponse(http.StatusTooManyRequests, "3"))
+	assert.Equal(t, 3*time.Second, throttle.delay())
+
+	throttled, waited := throttle.Stats()
+	assert.Equal(t, 2, throttled)
+	assert.Equal(t, 4*time.Second, waited)
+}
+
+func TestThrottleExponentialBackoff(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	throttle := NewThrottle()
+	throttle.now = clock.Now
+
+	for _, want := range []time.Duration{
+		time.Second,
+		2 * time.Second,
+		4 * time.Second,
+	} {
+		throttle.observe(throttleResponse(http.StatusTooManyRequests, ""))
+		assert.Equal(t, want, throttle.delay())
+		clock.Advance(want)
+	}
+
+	// A successful response resets the backoff.
+	throttle.observe(throttleResponse(http.StatusOK, ""))
+	throttle.observe(throttleResponse(http.StatusTooManyRequests, ""))
+	assert.Equal(t, time.Second, throttle.delay())
+
+	// The backoff is capped.
+	throttle.observe(throttleResponse(http.StatusTooManyRequests, "3600"))
+	assert.Equal(t, DefaultMaxThrottleBackoff, throttle.delay())
+}
+
+func TestThrottleReport(t *testing.T) {
+	throttle := NewThrottle()
+
+	buf := &bytes.Buffer{}
+	throttle.WriteReport(buf)
+	assert.Empty(t, buf.String())
+
+	throttle.observe(throttleResponse(http.StatusTooManyRequests, "2"))
+	throttle.WriteReport(buf)
+	assert.Contains(t, buf.String(), "throttled 1 requests")
+}
@@ -0,0 +1,131 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package driver
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Wait describes the state that an object must reach before the
+// test document continues. It is derived from the "$wait"
+// pseudo-field, whose "for" value is either "condition=NAME",
+// "condition=NAME=STATUS" or "delete".
+type Wait struct {
+	// Condition is the type of the status condition to wait for.
+	Condition string
+
+	// Status is the status that the condition must have.
+	Status string
+
+	// Deleted waits for the object to be deleted.
+	Deleted bool
+
+	// Timeout is how long to wait. If it is zero, the check
+	// timeout is used.
+	Timeout time.Duration
+}
+
+// waitSpec is the YAML form of the "$wait" pseudo-field.
+type waitSpec struct {
+	For     string `yaml:"for"`
+	Timeout string `yaml:"timeout"`
+}
+
+// ParseWait parses the "for" expression and timeout of a "$wait"
+// pseudo-field.
+func ParseWait(expr string, timeout string) (*Wait, error) {
+	w := &Wait{}
+
+	switch parts := strings.SplitN(expr, "=", 3); {
+	case expr == "delete":
+		w.Deleted = true
+	case len(parts) >= 2 && parts[0] == "condition" && parts[1] != "":
+		w.Condition = parts[1]
+		w.Status = "True"
+		if len(parts) == 3 {
+			w.Status = parts[2]
+		}
+	default:
+		return nil, fmt.Errorf("invalid %q condition %q", "$wait", expr)
+	}
+
+	if timeout != "" {
+		t, err := time.ParseDuration(timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %q timeout: %w", "$wait", err)
+		}
+
+		if t <= 0 {
+			return nil, fmt.Errorf("invalid %q timeout %q", "$wait", timeout)
+		}
+
+		w.Timeout = t
+	}
+
+	return w, nil
+}
+
+// String returns the "for" expression of the Wait.
+func (w *Wait) String() string {
+	if w.Deleted {
+		return "delete"
+	}
+
+	return fmt.Sprintf("condition=%s=%s", w.Condition, w.Status)
+}
+
+// Satisfied returns whether the object has reached the state that
+// the Wait is waiting for. The object is nil if it doesn't exist.
+// If the state hasn't been reached, the reason describes the
+// current state of the object.
+func (w *Wait) Satisfied(u *unstructured.Unstructured) (bool, string) {
+	if w.Deleted {
+		if u == nil {
+			return true, ""
+		}
+
+		return false, "object still exists"
+	}
+
+	if u == nil {
+		return false, "object does not exist"
+	}
+
+	conditions, _, _ := unstructured.NestedSlice(u.Object, "status", "conditions")
+	for _, c := range conditions {
+		cond, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		condType, _ := cond["type"].(string)
+		if !strings.EqualFold(condType, w.Condition) {
+			continue
+		}
+
+		status, _ := cond["status"].(string)
+		if strings.EqualFold(status, w.Status) {
+			return true, ""
+		}
+
+		return false, fmt.Sprintf("condition %s is %q", condType, status)
+	}
+
+	return false, fmt.Sprintf("condition %s is not present", w.Condition)
+}
@@ -0,0 +1,78 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package driver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestParseWait(t *testing.T) {
+	w, err := ParseWait("condition=Available", "3m")
+	require.NoError(t, err)
+	assert.Equal(t, &Wait{Condition: "Available", Status: "True", Timeout: 3 * time.Minute}, w)
+	assert.Equal(t, "condition=Available=True", w.String())
+
+	w, err = ParseWait("condition=Progressing=False", "")
+	require.NoError(t, err)
+	assert.Equal(t, &Wait{Condition: "Progressing", Status: "False"}, w)
+
+	w, err = ParseWait("delete", "")
+	require.NoError(t, err)
+	assert.True(t, w.Deleted)
+
+	for _, bad := range []string{"", "ready", "condition=", "jsonpath=.status"} {
+		_, err := ParseWait(bad, "")
+		assert.Error(t, err, bad)
+	}
+
+	_, err = ParseWait("delete", "never")
+	assert.Error(t, err)
+}
+
+func TestWaitSatisfied(t *testing.T) {
+	deployment := &unstructured.Unstructured{Object: map[string]interface{}{
+		"status": map[string]interface{}{
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "Available", "status": "True"},
+				map[string]interface{}{"type": "Progressing", "status": "False"},
+			},
+		},
+	}}
+
+	ok, _ := (&Wait{Condition: "available", Status: "True"}).Satisfied(deployment)
+	assert.True(t, ok)
+
+	ok, reason := (&Wait{Condition: "Progressing", Status: "True"}).Satisfied(deployment)
+	assert.False(t, ok)
+	assert.Equal(t, `condition Progressing is "False"`, reason)
+
+	ok, reason = (&Wait{Condition: "Ready", Status: "True"}).Satisfied(deployment)
+	assert.False(t, ok)
+	assert.Equal(t, "condition Ready is not present", reason)
+
+	ok, _ = (&Wait{Condition: "Ready", Status: "True"}).Satisfied(nil)
+	assert.False(t, ok)
+
+	ok, _ = (&Wait{Deleted: true}).Satisfied(nil)
+	assert.True(t, ok)
+
+	ok, _ = (&Wait{Deleted: true}).Satisfied(deployment)
+	assert.False(t, ok)
+}
@@ -0,0 +1,151 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/projectcontour/integration-tester/pkg/utils"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// DefaultMaxWatchErrors is the number of watch failures within
+	// the DefaultWatchErrorWindow that marks an event stream as unhealthy.
+	DefaultMaxWatchErrors = 3
+
+	// DefaultWatchErrorWindow is the interval over which watch
+	// failures are counted.
+	DefaultWatchErrorWindow = time.Minute
+
+	// DefaultStallTimeout is how long we wait for an informer to
+	// deliver an event for an object that we changed before we
+	// consider the event stream to be stalled.
+	DefaultStallTimeout = time.Second * 20
+)
+
+type streamKey struct {
+	gvr schema.GroupVersionResource
+	key string
+}
+
+// Watchdog tracks the health of the informer event streams. Since
+// checks depend on the informers to publish the current cluster
+// state, a stream that keeps dropping, or that stops delivering
+// events, causes checks to fail in misleading ways.
+type Watchdog struct {
+	// MaxWatchErrors is the number of watch failures within
+	// the WatchErrorWindow that marks a stream as unhealthy.
+	MaxWatchErrors int
+
+	// WatchErrorWindow is the interval over which watch
+	// failures are counted.
+	WatchErrorWindow time.Duration
+
+	// StallTimeout is how long to wait for the event that
+	// corresponds to an object change.
+	StallTimeout time.Duration
+
+	now func() time.Time
+
+	lock        sync.Mutex
+	watchErrors map[schema.GroupVersionResource][]time.Time
+	expected    map[streamKey]time.Time
+}
+
+// NewWatchdog returns a new Watchdog with the default thresholds.
+func NewWatchdog() *Watchdog {
+	return &Watchdog{
+		MaxWatchErrors:   DefaultMaxWatchErrors,
+		WatchErrorWindow: DefaultWatchErrorWindow,
+		StallTimeout:     DefaultStallTimeout,
+		now:              time.Now,
+		watchErrors:      map[schema.GroupVersionResource][]time.Time{},
+		expected:         map[streamKey]time.Time{},
+	}
+}
+
+// WatchError records a watch failure for the given resource.
+func (w *Watchdog) WatchError(gvr schema.GroupVersionResource) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.watchErrors[gvr] = append(w.watchErrors[gvr], w.now())
+}
+
+// Expect records that the object named by key was changed, so
+// we expect to receive an event for it.
+func (w *Watchdog) Expect(gvr schema.GroupVersionResource, key string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.expected[streamKey{gvr: gvr, key: key}] = w.now()
+}
+
+// Event records that an event for the object named by key was received.
+func (w *Watchdog) Event(gvr schema.GroupVersionResource, key string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	delete(w.expected, streamKey{gvr: gvr, key: key})
+}
+
+// Health returns an error describing any unhealthy event streams.
+func (w *Watchdog) Health() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	var problems []string
+
+	now := w.now()
+
+	for gvr, errs := range w.watchErrors {
+		// Expire errors that fell out of the window.
+		recent := errs[:0]
+		for _, t := range errs {
+			if now.Sub(t) < w.WatchErrorWindow {
+				recent = append(recent, t)
+			}
+		}
+
+		w.watchErrors[gvr] = recent
+
+		if len(recent) >= w.MaxWatchErrors {
+			problems = append(problems,
+				fmt.Sprintf("watch for %s failed %d times in the last %s",
+					gvr.GroupResource(), len(recent), w.WatchErrorWindow))
+		}
+	}
+
+	for k, t := range w.expected {
+		if now.Sub(t) >= w.StallTimeout {
+			problems = append(problems,
+				fmt.Sprintf("no %s event for %q received in %s",
+					k.gvr.GroupResource(), k.key, now.Sub(t).Round(time.Second)))
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	sort.Strings(problems)
+	return errors.New(utils.JoinLines(problems...))
+}
@@ -0,0 +1,80 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package driver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	return f.now
+}
+
+func (f *fakeClock) Advance(d time.Duration) {
+	f.now = f.now.Add(d)
+}
+
+func TestWatchdogWatchErrors(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	pods := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+
+	w := NewWatchdog()
+	w.now = clock.Now
+
+	assert.NoError(t, w.Health())
+
+	for i := 0; i < w.MaxWatchErrors-1; i++ {
+		w.WatchError(pods)
+		clock.Advance(time.Second)
+	}
+
+	assert.NoError(t, w.Health())
+
+	w.WatchError(pods)
+	assert.EqualError(t, w.Health(), "watch for pods failed 3 times in the last 1m0s")
+
+	// Once the errors age out of the window, we are healthy again.
+	clock.Advance(w.WatchErrorWindow)
+	assert.NoError(t, w.Health())
+}
+
+func TestWatchdogStall(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	pods := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+
+	w := NewWatchdog()
+	w.now = clock.Now
+
+	w.Expect(pods, "default/one")
+	w.Expect(pods, "default/two")
+	w.Event(pods, "default/one")
+
+	clock.Advance(w.StallTimeout / 2)
+	assert.NoError(t, w.Health())
+
+	clock.Advance(w.StallTimeout / 2)
+	assert.EqualError(t, w.Health(), `no pods event for "default/two" received in 20s`)
+
+	w.Event(pods, "default/two")
+	assert.NoError(t, w.Health())
+}
@@ -0,0 +1,336 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package external
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultInterval is the default interval between polls of an
+// external data source.
+const DefaultInterval = 10 * time.Second
+
+// Source is an external (i.e. non-Kubernetes) data source.
+type Source interface {
+	// Name returns the name of the source. This is the key
+	// the source data is published under.
+	Name() string
+
+	// Fetch returns the current data from the source. The
+	// returned data must be JSON-compatible generic data.
+	Fetch(ctx context.Context) (interface{}, error)
+}
+
+// sourceData is the published state of an external data source.
+type sourceData struct {
+	// Value is the data from the most recent successful fetch.
+	Value interface{}
+	// Updated is the time of the most recent successful fetch.
+	Updated string
+	// Error is the error from the most recent fetch, if it failed.
+	Error string
+}
+
+// StoreFunc publishes the data for the named source. The data has
+// a "value" field containing the most recently fetched value, an
+// "updated" field containing the RFC 3339 time of the most recent
+// successful fetch, and an "error" field if the most recent fetch
+// failed.
+type StoreFunc func(name string, data map[string]interface{}) error
+
+// Poller periodically fetches data from a set of external sources.
+type Poller struct {
+	Sources  []Source
+	Interval time.Duration
+	Store    StoreFunc
+
+	lock sync.Mutex
+	data map[string]*sourceData
+}
+
+// Start fetches data from all the sources once, then polls them in
+// the background until the returned function is called.
+func (p *Poller) Start() func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := sync.WaitGroup{}
+
+	interval := p.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	// Fetch once up front so that data is present as soon
+	// as the first checks run.
+	for _, s := range p.Sources {
+		p.poll(ctx, s)
+	}
+
+	for _, s := range p.Sources {
+		wg.Add(1)
+
+		go func(s Source) {
+			defer wg.Done()
+
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					p.poll(ctx, s)
+				}
+			}
+		}(s)
+	}
+
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+func (p *Poller) poll(ctx context.Context, s Source) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout())
+	defer cancel()
+
+	val, err := s.Fetch(ctx)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.data == nil {
+		p.data = map[string]*sourceData{}
+	}
+
+	d, ok := p.data[s.Name()]
+	if !ok {
+		d = &sourceData{}
+		p.data[s.Name()] = d
+	}
+
+	switch err {
+	case nil:
+		d.Value = val
+		d.Updated = time.Now().UTC().Format(time.RFC3339)
+		d.Error = ""
+	default:
+		// Keep the last good value, but publish the error
+		// so that checks can tell that it may be stale.
+		d.Error = err.Error()
+	}
+
+	if p.Store != nil {
+		// A failure to store means the Rego store is gone, so
+		// there's nothing more to do.
+		_ = p.Store(s.Name(), d.generic())
+	}
+}
+
+// timeout returns the timeout for a single fetch. Polls should not
+// overlap, so this is bounded by the poll interval.
+func (p *Poller) timeout() time.Duration {
+	if p.Interval > 0 {
+		return p.Interval
+	}
+
+	return DefaultInterval
+}
+
+func (d *sourceData) generic() map[string]interface{} {
+	g := map[string]interface{}{
+		"value": d.Value,
+	}
+
+	if d.Updated != "" {
+		g["updated"] = d.Updated
+	}
+
+	if d.Error != "" {
+		g["error"] = d.Error
+	}
+
+	return g
+}
+
+// HTTPSource is a Source that fetches a JSON document from an HTTP endpoint.
+type HTTPSource struct {
+	SourceName string
+	URL        string
+	Client     *http.Client
+}
+
+var _ Source = &HTTPSource{}
+
+// Name ...
+func (h *HTTPSource) Name() string {
+	return h.SourceName
+}
+
+// Fetch ...
+func (h *HTTPSource) Fetch(ctx context.Context) (interface{}, error) {
+	var val interface{}
+
+	if err := getJSON(ctx, h.Client, h.URL, &val); err != nil {
+		return nil, err
+	}
+
+	return val, nil
+}
+
+// PrometheusSource is a Source that evaluates an instant query against
+// the Prometheus HTTP API. Vector results are returned as an array of
+// objects with "metric" (i.e. the labels) and "value" fields, and
+// scalar results as a number.
+type PrometheusSource struct {
+	SourceName string
+	Server     string
+	Query      string
+	Client     *http.Client
+}
+
+var _ Source = &PrometheusSource{}
+
+// Name ...
+func (p *PrometheusSource) Name() string {
+	return p.SourceName
+}
+
+// Fetch ...
+func (p *PrometheusSource) Fetch(ctx context.Context) (interface{}, error) {
+	var resp struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+		Data   struct {
+			ResultType string          `json:"resultType"`
+			Result     json.RawMessage `json:"result"`
+		} `json:"data"`
+	}
+
+	u := fmt.Sprintf("%s/api/v1/query?query=%s", p.Server, url.QueryEscape(p.Query))
+	if err := getJSON(ctx, p.Client, u, &resp); err != nil {
+		return nil, err
+	}
+
+	if resp.Status != "success" {
+		return nil, fmt.Errorf("query %q failed: %s", p.Query, resp.Error)
+	}
+
+	switch resp.Data.ResultType {
+	case "vector":
+		var vector []struct {
+			Metric map[string]string `json:"metric"`
+			Value  []interface{}     `json:"value"`
+		}
+
+		if err := json.Unmarshal(resp.Data.Result, &vector); err != nil {
+			return nil, err
+		}
+
+		samples := make([]interface{}, 0, len(vector))
+		for _, v := range vector {
+			val, err := sampleValue(v.Value)
+			if err != nil {
+				return nil, err
+			}
+
+			metric := map[string]interface{}{}
+			for k, v := range v.Metric {
+				metric[k] = v
+			}
+
+			samples = append(samples, map[string]interface{}{
+				"metric": metric,
+				"value":  val,
+			})
+		}
+
+		return samples, nil
+
+	case "scalar":
+		var scalar []interface{}
+		if err := json.Unmarshal(resp.Data.Result, &scalar); err != nil {
+			return nil, err
+		}
+
+		return sampleValue(scalar)
+
+	default:
+		var val interface{}
+		if err := json.Unmarshal(resp.Data.Result, &val); err != nil {
+			return nil, err
+		}
+
+		return val, nil
+	}
+}
+
+// sampleValue converts a Prometheus [timestamp, "value"] sample to a number.
+func sampleValue(sample []interface{}) (float64, error) {
+	if len(sample) != 2 {
+		return 0, fmt.Errorf("invalid sample %v", sample)
+	}
+
+	str, ok := sample[1].(string)
+	if !ok {
+		return 0, fmt.Errorf("invalid sample value %v", sample[1])
+	}
+
+	return strconv.ParseFloat(str, 64)
+}
+
+func getJSON(ctx context.Context, client *http.Client, u string, into interface{}) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", u, resp.Status)
+	}
+
+	if err := json.Unmarshal(body, into); err != nil {
+		return fmt.Errorf("GET %s: invalid JSON: %w", u, err)
+	}
+
+	return nil
+}
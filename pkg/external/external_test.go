@@ -0,0 +1,122 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package external
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPSource(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/status" {
+			http.NotFound(w, r)
+			return
+		}
+
+		fmt.Fprint(w, `{"state": "active", "count": 2}`)
+	}))
+	defer srv.Close()
+
+	s := &HTTPSource{SourceName: "lb", URL: srv.URL + "/status"}
+	val, err := s.Fetch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"state": "active", "count": 2.0}, val)
+
+	s = &HTTPSource{SourceName: "lb", URL: srv.URL + "/missing"}
+	_, err = s.Fetch(context.Background())
+	assert.Error(t, err)
+}
+
+func TestPrometheusSource(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("query") {
+		case "up":
+			fmt.Fprint(w, `{"status": "success", "data": {"resultType": "vector", "result": [
+			  {"metric": {"job": "envoy"}, "value": [1600000000.1, "1"]}
+			]}}`)
+		case "scalar(1.5)":
+			fmt.Fprint(w, `{"status": "success", "data": {"resultType": "scalar", "result": [1600000000.1, "1.5"]}}`)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"status": "error", "error": "bad query"}`)
+		}
+	}))
+	defer srv.Close()
+
+	s := &PrometheusSource{SourceName: "up", Server: srv.URL, Query: "up"}
+	val, err := s.Fetch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{
+			"metric": map[string]interface{}{"job": "envoy"},
+			"value":  1.0,
+		},
+	}, val)
+
+	s = &PrometheusSource{SourceName: "scalar", Server: srv.URL, Query: "scalar(1.5)"}
+	val, err = s.Fetch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1.5, val)
+
+	s = &PrometheusSource{SourceName: "bad", Server: srv.URL, Query: "bad("}
+	_, err = s.Fetch(context.Background())
+	assert.Error(t, err)
+}
+
+type fakeSource struct {
+	values []interface{}
+	errs   []error
+}
+
+func (f *fakeSource) Name() string {
+	return "fake"
+}
+
+func (f *fakeSource) Fetch(context.Context) (interface{}, error) {
+	val, err := f.values[0], f.errs[0]
+	f.values, f.errs = f.values[1:], f.errs[1:]
+	return val, err
+}
+
+func TestPollerKeepsLastValue(t *testing.T) {
+	stored := map[string]map[string]interface{}{}
+	p := &Poller{
+		Store: func(name string, data map[string]interface{}) error {
+			stored[name] = data
+			return nil
+		},
+	}
+
+	s := &fakeSource{
+		values: []interface{}{"one", nil},
+		errs:   []error{nil, fmt.Errorf("unreachable")},
+	}
+
+	p.poll(context.Background(), s)
+	assert.Equal(t, "one", stored["fake"]["value"])
+	assert.NotContains(t, stored["fake"], "error")
+
+	p.poll(context.Background(), s)
+	assert.Equal(t, "one", stored["fake"]["value"])
+	assert.Equal(t, "unreachable", stored["fake"]["error"])
+	assert.Contains(t, stored["fake"], "updated")
+}
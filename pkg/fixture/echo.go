@@ -0,0 +1,107 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package fixture
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/projectcontour/integration-tester/pkg/doc"
+)
+
+// DefaultEchoImage is the container image of the builtin echo server
+// fixture. It can be overridden to use a mirror of the image, e.g. in
+// air-gapped environments. Overrides should pin the image by digest
+// so that test runs are reproducible.
+const DefaultEchoImage = "docker.io/agervais/ingress-conformance-echo:latest"
+
+// EchoName is the name of the builtin echo server fixture objects.
+const EchoName = "integration-tester-echo"
+
+// echoTemplate is the builtin echo server Deployment and Service.
+// The image is substituted when the fixtures are added.
+const echoTemplate = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: integration-tester-echo
+  labels:
+    app.kubernetes.io/name: integration-tester-echo
+spec:
+  replicas: 1
+  selector:
+    matchLabels:
+      app.kubernetes.io/name: integration-tester-echo
+  template:
+    metadata:
+      labels:
+        app.kubernetes.io/name: integration-tester-echo
+    spec:
+      containers:
+      - name: echo
+        image: %q
+        imagePullPolicy: IfNotPresent
+        ports:
+        - name: http-api
+          containerPort: 3000
+        readinessProbe:
+          httpGet:
+            path: /health
+            port: 3000
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: integration-tester-echo
+  labels:
+    app.kubernetes.io/name: integration-tester-echo
+spec:
+  ports:
+  - name: http
+    port: 80
+    targetPort: http-api
+  selector:
+    app.kubernetes.io/name: integration-tester-echo
+`
+
+// ValidateImage checks that the image reference can be used in a
+// container spec.
+func ValidateImage(image string) error {
+	if image == "" {
+		return fmt.Errorf("empty image reference")
+	}
+
+	if strings.ContainsAny(image, " \t\r\n") {
+		return fmt.Errorf("invalid image reference %q", image)
+	}
+
+	return nil
+}
+
+// AddEcho stores the builtin echo server fixtures, using the given
+// container image, in the default fixture set. Fixtures that are
+// added later with the same names replace the builtin fixtures.
+func AddEcho(image string) error {
+	if err := ValidateImage(image); err != nil {
+		return err
+	}
+
+	d, err := doc.ReadDocument(strings.NewReader(fmt.Sprintf(echoTemplate, image)))
+	if err != nil {
+		return fmt.Errorf("failed to read echo fixtures: %w", err)
+	}
+
+	return addDocument(d)
+}
@@ -77,6 +77,12 @@ func AddFromFile(filePath string) error {
 		return fmt.Errorf("failed to read %q`: %w", filePath, err)
 	}
 
+	return addDocument(d)
+}
+
+// addDocument stores all the YAML objects from the given document
+// in the default fixture set.
+func addDocument(d *doc.Document) error {
 	for i, p := range d.Parts {
 		ftype, err := p.Decode()
 		if err != nil {
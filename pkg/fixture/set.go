@@ -69,6 +69,21 @@ func (s *defaultFixtureSet) Match(u *unstructured.Unstructured) Fixture {
 	return s.fixtures[KeyFor(u)]
 }
 
+// MatchName matches the given object to an existing Fixture with the
+// same API version, kind and name, in any namespace.
+func (s *defaultFixtureSet) MatchName(u *unstructured.Unstructured) Fixture {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for k, f := range s.fixtures {
+		if k.apiVersion == u.GetAPIVersion() && k.kind == u.GetKind() && k.name == u.GetName() {
+			return f
+		}
+	}
+
+	return nil
+}
+
 // Set is the default FixtureSet.
 var Set = &defaultFixtureSet{
 	fixtures: map[Key]Fixture{},
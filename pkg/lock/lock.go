@@ -0,0 +1,126 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package lock
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/projectcontour/integration-tester/pkg/utils"
+)
+
+// Lock records the content digests of the Rego policy files that
+// a test suite was run with, so that later runs can verify that
+// they use exactly the same versions of shared policy libraries.
+type Lock struct {
+	// Policies maps each policy file path to its digest.
+	Policies map[string]string `json:"policies"`
+}
+
+// Digest returns the digest of the given data, in the form
+// "sha256:$HEX".
+func Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// DigestFiles returns the digests of all the files in the given
+// paths. Directories are walked the same way as when policies
+// are loaded.
+func DigestFiles(paths []string) (map[string]string, error) {
+	digests := map[string]string{}
+
+	for _, p := range paths {
+		err := utils.WalkFiles(p, func(filePath string) error {
+			data, err := ioutil.ReadFile(filePath)
+			if err != nil {
+				return err
+			}
+
+			digests[filePath] = Digest(data)
+			return nil
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return digests, nil
+}
+
+// Read reads a lock file.
+func Read(path string) (*Lock, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Lock{}
+	if err := json.Unmarshal(data, l); err != nil {
+		return nil, fmt.Errorf("failed to decode lock file %q: %w", path, err)
+	}
+
+	if l.Policies == nil {
+		l.Policies = map[string]string{}
+	}
+
+	return l, nil
+}
+
+// Write writes the lock file to the given path.
+func (l *Lock) Write(path string) error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Verify checks that the given policy digests exactly match the
+// locked digests. The returned error lists every policy file that
+// was changed, added or removed.
+func (l *Lock) Verify(digests map[string]string) error {
+	var problems []string
+
+	for path, digest := range digests {
+		locked, ok := l.Policies[path]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("policy %q is not locked", path))
+		case locked != digest:
+			problems = append(problems, fmt.Sprintf("policy %q has changed", path))
+		}
+	}
+
+	for path := range l.Policies {
+		if _, ok := digests[path]; !ok {
+			problems = append(problems, fmt.Sprintf("locked policy %q was not loaded", path))
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	sort.Strings(problems)
+	return fmt.Errorf("policy lock mismatch: %s", strings.Join(problems, ", "))
+}
@@ -0,0 +1,83 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package lock
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDigestFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lock")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	one := filepath.Join(dir, "one.rego")
+	two := filepath.Join(dir, "two.rego")
+
+	require.NoError(t, ioutil.WriteFile(one, []byte("package one\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(two, []byte("package two\n"), 0644))
+
+	digests, err := DigestFiles([]string{dir})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		one: Digest([]byte("package one\n")),
+		two: Digest([]byte("package two\n")),
+	}, digests)
+}
+
+func TestVerify(t *testing.T) {
+	l := &Lock{
+		Policies: map[string]string{
+			"a.rego": "sha256:aaaa",
+			"b.rego": "sha256:bbbb",
+		},
+	}
+
+	assert.NoError(t, l.Verify(map[string]string{
+		"a.rego": "sha256:aaaa",
+		"b.rego": "sha256:bbbb",
+	}))
+
+	err := l.Verify(map[string]string{
+		"a.rego": "sha256:0000",
+		"c.rego": "sha256:cccc",
+	})
+
+	require.Error(t, err)
+	assert.Equal(t,
+		`policy lock mismatch: locked policy "b.rego" was not loaded, policy "a.rego" has changed, policy "c.rego" is not locked`,
+		err.Error())
+}
+
+func TestReadWrite(t *testing.T) {
+	f, err := ioutil.TempFile("", "lock")
+	require.NoError(t, err)
+	f.Close()
+	defer os.Remove(f.Name())
+
+	l := &Lock{Policies: map[string]string{"a.rego": "sha256:aaaa"}}
+	require.NoError(t, l.Write(f.Name()))
+
+	read, err := Read(f.Name())
+	require.NoError(t, err)
+	assert.Equal(t, l, read)
+}
@@ -100,6 +100,15 @@ func Int(i int, err error) int {
 	return i
 }
 
+// Int64 panics if the error is set, otherwise returns i.
+func Int64(i int64, err error) int64 {
+	if err != nil {
+		panic(err.Error())
+	}
+
+	return i
+}
+
 // Unstructured ...
 func Unstructured(u *unstructured.Unstructured, err error) *unstructured.Unstructured {
 	if err != nil {
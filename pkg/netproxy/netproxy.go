@@ -0,0 +1,220 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package netproxy configures how the test harness connects to the
+// services that it probes directly, such as external data sources
+// and TLS servers. By default, probes honor the HTTPS_PROXY,
+// HTTP_PROXY and NO_PROXY environment variables. An explicit probe
+// proxy can be set for environments where the services under test
+// are only reachable through a bastion host.
+package netproxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+	"golang.org/x/net/proxy"
+)
+
+// probeProxy is the explicit proxy for all probe connections. If it
+// is nil, the proxy is chosen from the environment.
+var probeProxy *url.URL
+
+// SetProbeProxy directs all probe connections through the proxy at
+// the given URL, which can be a "socks5", "socks5h", "http" or "https"
+// URL. An empty URL restores the default of using the environment.
+func SetProbeProxy(proxyURL string) error {
+	if proxyURL == "" {
+		probeProxy = nil
+		return nil
+	}
+
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return fmt.Errorf("invalid proxy URL %q: %w", proxyURL, err)
+	}
+
+	switch u.Scheme {
+	case "socks5", "socks5h", "http", "https":
+	default:
+		return fmt.Errorf("unsupported proxy URL scheme %q", u.Scheme)
+	}
+
+	if u.Host == "" {
+		return fmt.Errorf("invalid proxy URL %q: missing host", proxyURL)
+	}
+
+	probeProxy = u
+	return nil
+}
+
+// ProxyFor returns the proxy to connect to the target URL through,
+// or nil if the connection should be direct.
+func ProxyFor(target *url.URL) (*url.URL, error) {
+	if probeProxy != nil {
+		return probeProxy, nil
+	}
+
+	return httpproxy.FromEnvironment().ProxyFunc()(target)
+}
+
+// DialContext connects to the TCP address through the proxy for a
+// URL with the given scheme and the address as its host.
+func DialContext(ctx context.Context, address string, scheme string) (net.Conn, error) {
+	p, err := ProxyFor(&url.URL{Scheme: scheme, Host: address})
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case p == nil:
+		return (&net.Dialer{}).DialContext(ctx, "tcp", address)
+	case isSOCKS(p):
+		return dialSOCKS(ctx, p, address)
+	default:
+		return dialConnect(ctx, p, address)
+	}
+}
+
+// Transport returns a new HTTP transport for probe requests.
+func Transport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+
+	t.Proxy = func(req *http.Request) (*url.URL, error) {
+		p, err := ProxyFor(req.URL)
+		if err != nil || p == nil || isSOCKS(p) {
+			return nil, err
+		}
+
+		return p, nil
+	}
+
+	dialer := &net.Dialer{}
+	t.DialContext = func(ctx context.Context, network string, address string) (net.Conn, error) {
+		if probeProxy != nil && isSOCKS(probeProxy) {
+			return dialSOCKS(ctx, probeProxy, address)
+		}
+
+		return dialer.DialContext(ctx, network, address)
+	}
+
+	return t
+}
+
+func isSOCKS(u *url.URL) bool {
+	return u.Scheme == "socks5" || u.Scheme == "socks5h"
+}
+
+// dialSOCKS connects to the address through a SOCKS5 proxy.
+func dialSOCKS(ctx context.Context, p *url.URL, address string) (net.Conn, error) {
+	d, err := proxy.FromURL(p, &net.Dialer{})
+	if err != nil {
+		return nil, err
+	}
+
+	if cd, ok := d.(proxy.ContextDialer); ok {
+		return cd.DialContext(ctx, "tcp", address)
+	}
+
+	return d.Dial("tcp", address)
+}
+
+// dialConnect connects to the address by tunneling through an HTTP
+// proxy with the CONNECT method.
+func dialConnect(ctx context.Context, p *url.URL, address string) (net.Conn, error) {
+	proxyAddr := p.Host
+	if p.Port() == "" {
+		switch p.Scheme {
+		case "https":
+			proxyAddr = net.JoinHostPort(p.Hostname(), "443")
+		default:
+			proxyAddr = net.JoinHostPort(p.Hostname(), "80")
+		}
+	}
+
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: http.Header{},
+	}
+
+	if p.User != nil {
+		req.Header.Set("Proxy-Authorization",
+			"Basic "+base64.StdEncoding.EncodeToString([]byte(p.User.String())))
+	}
+
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s: CONNECT %s: %s", p.Host, address, resp.Status)
+	}
+
+	// Clear the handshake deadline so that it doesn't apply
+	// to the tunneled connection.
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	// The proxy may have sent tunneled data along with its
+	// response, so keep reading from the buffer.
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+
+	return conn, nil
+}
+
+// bufferedConn is a net.Conn that reads through a buffer.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (b *bufferedConn) Read(p []byte) (int, error) {
+	return b.r.Read(p)
+}
@@ -16,6 +16,7 @@ package result
 
 import (
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -37,18 +38,93 @@ const SeveritySkip Severity = "Skip"
 // SeverityPass explicitly marks a result as recording a successful check.
 const SeverityPass Severity = "Pass"
 
+// ParseSeverity parses the name of a severity that a check can
+// raise. The name is not case sensitive.
+func ParseSeverity(name string) (Severity, error) {
+	for _, s := range []Severity{SeverityNone, SeverityError, SeverityFatal, SeveritySkip} {
+		if strings.EqualFold(name, string(s)) {
+			return s, nil
+		}
+	}
+
+	return SeverityNone, fmt.Errorf("invalid severity %q", name)
+}
+
+// Scope is the extent of a test document that a Skip result skips.
+type Scope string
+
+// ScopeDocument skips the rest of the test document. This is the
+// scope of Skip results that don't specify one.
+const ScopeDocument Scope = "document"
+
+// ScopeGroup skips the rest of the current group of fragments,
+// i.e. the remaining fragments that were read from the same file,
+// and from the files that it includes.
+const ScopeGroup Scope = "group"
+
+// ScopeStep only skips the step that raised the result.
+const ScopeStep Scope = "step"
+
+// ParseScope parses the name of a skip scope.
+func ParseScope(name string) (Scope, error) {
+	for _, s := range []Scope{ScopeDocument, ScopeGroup, ScopeStep} {
+		if strings.EqualFold(name, string(s)) {
+			return s, nil
+		}
+	}
+
+	return "", fmt.Errorf("invalid skip scope %q", name)
+}
+
 // Result ...
 type Result struct {
-	Severity  Severity
-	Message   string
+	Severity Severity
+
+	// Scope is the extent of the test document that a Skip
+	// result skips. If it is empty, the rest of the document
+	// is skipped.
+	Scope Scope
+
+	// Rule is the name of the Rego rule that raised this
+	// result, if it came from a Rego check.
+	Rule string
+
+	// Message is the message of the result. For results that
+	// were raised by a Rego rule, this is the message that the
+	// rule gave, if any.
+	Message string
+
+	// Value is the raw value of the Rego rule that raised this
+	// result, if it came from a Rego check.
+	Value interface{}
+
 	Timestamp time.Time
 }
 
+// Text formats the result message as text. If the result was
+// raised by a Rego rule, the message is prefixed with the name
+// of the rule.
+func (c Result) Text() string {
+	if c.Rule == "" {
+		return c.Message
+	}
+
+	prefix := fmt.Sprintf("raised predicate %q", c.Rule)
+	if c.Message == "" {
+		return prefix
+	}
+
+	return strings.Join([]string{prefix, c.Message}, "\n")
+}
+
 // IsTerminal returns true if this result should end the test.
+// Skip results are only terminal if they skip the whole document.
 func (c Result) IsTerminal() bool {
 	switch c.Severity {
-	case SeverityFatal, SeveritySkip:
+	case SeverityFatal:
 		return true
+	case SeveritySkip:
+		return c.Scope == "" || c.Scope == ScopeDocument
 	default:
 		return false
 	}
@@ -0,0 +1,196 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"regexp"
+)
+
+// DefaultPassEnv is the list of environment variables that are
+// passed through to sandboxed commands by default.
+var DefaultPassEnv = []string{"PATH", "KUBECONFIG"}
+
+// DefaultMaxOutput is the default limit on the number of bytes
+// of each of the standard output and standard error that are
+// captured from a sandboxed command.
+const DefaultMaxOutput = 64 * 1024
+
+// Options configures a Sandbox.
+type Options struct {
+	// PassEnv is the list of environment variables that
+	// are passed through from the integration-tester
+	// environment. If it is nil, DefaultPassEnv is used.
+	PassEnv []string
+
+	// Env is a set of additional environment variables in
+	// "key=value" format.
+	Env []string
+
+	// MaxOutput is the maximum number of bytes of output
+	// that is captured from each output stream. If it is
+	// zero, DefaultMaxOutput is used.
+	MaxOutput int
+}
+
+// Sandbox isolates the local commands that are run on behalf of
+// a single test document. Each Sandbox has its own temporary
+// working directory, and commands only see the environment
+// variables that are explicitly passed through, so that commands
+// can't leak state between test documents.
+type Sandbox struct {
+	// Dir is the working directory for sandboxed commands.
+	Dir string
+
+	env       []string
+	maxOutput int
+}
+
+// Output is the captured result of a sandboxed command.
+type Output struct {
+	Stdout    []byte
+	Stderr    []byte
+	ExitCode  int
+	Truncated bool
+}
+
+var unsafeNameChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// New creates a new Sandbox for the named test document.
+func New(name string, opts Options) (*Sandbox, error) {
+	prefix := fmt.Sprintf("integration-tester-%s-", unsafeNameChars.ReplaceAllString(name, "_"))
+
+	dir, err := ioutil.TempDir("", prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sandbox directory: %w", err)
+	}
+
+	passEnv := opts.PassEnv
+	if passEnv == nil {
+		passEnv = DefaultPassEnv
+	}
+
+	// Give commands a private home and temporary directory so
+	// that dotfiles and caches don't survive the document.
+	env := []string{
+		"HOME=" + dir,
+		"TMPDIR=" + dir,
+	}
+
+	for _, name := range passEnv {
+		if val, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+val)
+		}
+	}
+
+	env = append(env, opts.Env...)
+
+	maxOutput := opts.MaxOutput
+	if maxOutput <= 0 {
+		maxOutput = DefaultMaxOutput
+	}
+
+	return &Sandbox{
+		Dir:       dir,
+		env:       env,
+		maxOutput: maxOutput,
+	}, nil
+}
+
+// Environ returns the environment that sandboxed commands run with.
+func (s *Sandbox) Environ() []string {
+	env := make([]string, len(s.env))
+	copy(env, s.env)
+	return env
+}
+
+// Command returns a command that runs in the sandbox.
+func (s *Sandbox) Command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...) // nolint(gosec)
+	cmd.Dir = s.Dir
+	cmd.Env = s.Environ()
+
+	return cmd
+}
+
+// Run runs the command in the sandbox and captures its output, up
+// to the output limit. A command that runs but exits with a non-zero
+// status is not an error; the status is returned in the Output.
+func (s *Sandbox) Run(ctx context.Context, name string, args ...string) (*Output, error) {
+	stdout := &limitedBuffer{max: s.maxOutput}
+	stderr := &limitedBuffer{max: s.maxOutput}
+
+	cmd := s.Command(ctx, name, args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err := cmd.Run()
+
+	out := &Output{
+		Stdout:    stdout.Bytes(),
+		Stderr:    stderr.Bytes(),
+		Truncated: stdout.truncated || stderr.truncated,
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr):
+		out.ExitCode = exitErr.ExitCode()
+	default:
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// Close removes the sandbox working directory and everything in it.
+func (s *Sandbox) Close() error {
+	return os.RemoveAll(s.Dir)
+}
+
+// limitedBuffer is an io.Writer that keeps up to max bytes and
+// silently discards the rest, so that a noisy command doesn't
+// fail with a short write. The buffer isn't embedded, since its
+// ReadFrom method would let io.Copy bypass the limit.
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (l *limitedBuffer) Write(p []byte) (int, error) {
+	if room := l.max - l.buf.Len(); room < len(p) {
+		l.truncated = true
+		if room > 0 {
+			l.buf.Write(p[:room])
+		}
+
+		return len(p), nil
+	}
+
+	return l.buf.Write(p)
+}
+
+// Bytes returns the bytes that were kept.
+func (l *limitedBuffer) Bytes() []byte {
+	return l.buf.Bytes()
+}
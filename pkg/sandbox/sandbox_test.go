@@ -0,0 +1,85 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package sandbox
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSandboxEnvironment(t *testing.T) {
+	require.NoError(t, os.Setenv("SANDBOX_SECRET", "leak"))
+	defer os.Unsetenv("SANDBOX_SECRET")
+
+	s, err := New("test/one.yaml", Options{Env: []string{"EXTRA=1"}})
+	require.NoError(t, err)
+	defer s.Close()
+
+	out, err := s.Run(context.Background(), "sh", "-c", "echo $HOME $EXTRA $SANDBOX_SECRET; pwd")
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(out.Stdout)), "\n")
+	require.Len(t, lines, 2)
+
+	dir, err := filepath.EvalSymlinks(s.Dir)
+	require.NoError(t, err)
+
+	assert.Equal(t, s.Dir+" 1", lines[0])
+	assert.Equal(t, dir, lines[1])
+	assert.Equal(t, 0, out.ExitCode)
+}
+
+func TestSandboxExitCode(t *testing.T) {
+	s, err := New("exit", Options{})
+	require.NoError(t, err)
+	defer s.Close()
+
+	out, err := s.Run(context.Background(), "sh", "-c", "echo failed >&2; exit 3")
+	require.NoError(t, err)
+	assert.Equal(t, 3, out.ExitCode)
+	assert.Equal(t, "failed\n", string(out.Stderr))
+
+	_, err = s.Run(context.Background(), "/does/not/exist")
+	assert.Error(t, err)
+}
+
+func TestSandboxOutputLimit(t *testing.T) {
+	s, err := New("limit", Options{MaxOutput: 4})
+	require.NoError(t, err)
+	defer s.Close()
+
+	out, err := s.Run(context.Background(), "sh", "-c", "echo 0123456789")
+	require.NoError(t, err)
+	assert.Equal(t, "0123", string(out.Stdout))
+	assert.True(t, out.Truncated)
+}
+
+func TestSandboxClose(t *testing.T) {
+	s, err := New("close", Options{})
+	require.NoError(t, err)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(s.Dir, "state"), []byte("x"), 0600))
+	require.NoError(t, s.Close())
+
+	_, err = os.Stat(s.Dir)
+	assert.True(t, os.IsNotExist(err))
+}
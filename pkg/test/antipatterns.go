@@ -0,0 +1,252 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/projectcontour/integration-tester/pkg/doc"
+	"github.com/projectcontour/integration-tester/pkg/driver"
+	"github.com/projectcontour/integration-tester/pkg/fixture"
+	"github.com/projectcontour/integration-tester/pkg/result"
+	"github.com/projectcontour/integration-tester/pkg/utils"
+
+	"github.com/open-policy-agent/opa/ast"
+)
+
+// LintAntiPatterns checks a test document for patterns that are
+// valid, but that probably don't do what the author intended. It
+// returns the patterns that it finds as warnings. Like Preflight,
+// LintAntiPatterns expects the document fragments to have been
+// decoded already, e.g. by Lint.
+//
+// The patterns are checks that don't refer to input or to any data
+// other than the test parameters (so they can't depend on the
+// cluster state), objects that are applied without being checked,
+// objects with a hard-coded namespace that have the same name as a
+// fixture, objects that are applied by more than one fragment, and
+// fragments that are unreachable after an unconditional skip.
+func LintAntiPatterns(testDoc *doc.Document) []LintProblem {
+	var warnings []LintProblem
+
+	env := driver.NewEnvironment(driver.OfflineOpt())
+
+	warn := func(p *doc.Fragment, format string, args ...interface{}) {
+		warnings = append(warnings, LintProblem{
+			Location: fragmentLine(testDoc, p, 1),
+			Message:  fmt.Sprintf(format, args...),
+			Warning:  true,
+		})
+	}
+
+	// applied maps each object that was applied to the location
+	// of the fragment that applied it.
+	applied := map[string]string{}
+
+	// unchecked holds the object fragments that have no check
+	// of their own, until a Rego fragment follows them.
+	var unchecked []*doc.Fragment
+
+	// skipped is the location of the unconditional skip rule
+	// that stops the document, if there is one.
+	skipped := ""
+
+	for i := range testDoc.Parts {
+		p := &testDoc.Parts[i]
+
+		switch p.Type {
+		case doc.FragmentTypeObject, doc.FragmentTypeModule:
+		default:
+			continue
+		}
+
+		if skipped != "" {
+			warn(p, "fragment is unreachable after the unconditional skip rule at %s", skipped)
+			continue
+		}
+
+		switch p.Type {
+		case doc.FragmentTypeObject:
+			obj, err := env.HydrateObject(p.Bytes)
+			if err != nil {
+				continue
+			}
+
+			if obj.Check != nil && !refersToState(obj.Check) {
+				warn(p, "object check doesn't refer to input or data, so it can't depend on the cluster")
+			}
+
+			u := obj.Object
+			key := fmt.Sprintf("%s:%s '%s/%s'",
+				u.GetAPIVersion(), u.GetKind(), utils.NamespaceOrDefault(u), u.GetName())
+
+			switch obj.Operation {
+			case driver.ObjectOperationDelete:
+				delete(applied, key)
+			case driver.ObjectOperationCreate:
+				// Creating an object that is already applied
+				// is how tests check for conflicts, so it is
+				// not a problem.
+				if _, ok := applied[key]; !ok && u.GetName() != "" {
+					applied[key] = fragmentLine(testDoc, p, 1)
+				}
+			case driver.ObjectOperationUpdate:
+				if u.GetName() == "" {
+					break
+				}
+
+				if loc, ok := applied[key]; ok {
+					warn(p, "object %s is already applied at %s; use a patch to change it", key, loc)
+				} else {
+					applied[key] = fragmentLine(testDoc, p, 1)
+				}
+
+				if obj.Fixture == nil && u.GetNamespace() != "" && fixture.Set.MatchName(u) != nil {
+					warn(p, "object %s has a hard-coded namespace and the same name as a fixture", key)
+				}
+			}
+
+			if obj.Check == nil && obj.Operation != driver.ObjectOperationDelete {
+				unchecked = append(unchecked, p)
+			}
+
+		case doc.FragmentTypeModule:
+			m := p.Rego()
+			if !refersToState(m) {
+				warn(p, "check doesn't refer to input or data, so it can't depend on the cluster")
+			}
+
+			// Any object that a Rego fragment follows might be
+			// checked by it.
+			unchecked = nil
+
+			// Conditional fragments and scoped skips don't
+			// stop the document.
+			if when, err := moduleCondition(m); err != nil || when != nil {
+				continue
+			}
+
+			scope, err := moduleSkipScope(m)
+			if err != nil {
+				continue
+			}
+
+			if scope != "" && scope != result.ScopeDocument {
+				continue
+			}
+
+			if r := unconditionalSkip(m); r != nil {
+				skipped = fragmentLine(testDoc, p, 1)
+				if r.Location != nil {
+					skipped = fragmentLine(testDoc, p, r.Location.Row-1)
+				}
+			}
+		}
+	}
+
+	for _, p := range unchecked {
+		warn(p, "object is applied, but no check follows it")
+	}
+
+	return warnings
+}
+
+// isSkipRule returns whether the rule is a skip rule.
+func isSkipRule(r *ast.Rule) bool {
+	return strings.HasPrefix(string(r.Head.Name), "skip")
+}
+
+// refersToState returns whether the check rules of the module refer
+// to input, or to data other than the test parameters. Modules that
+// only have skip rules don't need to refer to the cluster state,
+// since skips commonly depend only on the parameters.
+func refersToState(m *ast.Module) bool {
+	params := ast.MustParseRef("data.test.params")
+	checks := false
+	found := false
+
+	visit := func(r ast.Ref) bool {
+		switch {
+		case r.HasPrefix(params):
+		case r.HasPrefix(ast.InputRootRef), r.HasPrefix(ast.DefaultRootRef):
+			found = true
+		}
+
+		return found
+	}
+
+	// Don't walk the whole module, since the package path
+	// is itself a data reference.
+	for _, i := range m.Imports {
+		ast.WalkRefs(i, visit)
+	}
+
+	for _, r := range m.Rules {
+		if !isSkipRule(r) {
+			checks = true
+		}
+
+		ast.WalkRefs(r, visit)
+	}
+
+	return found || !checks
+}
+
+// unconditionalSkip returns the first skip rule of the module whose
+// body is always true, or nil if there is no such rule.
+func unconditionalSkip(m *ast.Module) *ast.Rule {
+	for _, r := range m.Rules {
+		if isSkipRule(r) && !r.Default && isConstantBody(r.Body) {
+			return r
+		}
+	}
+
+	return nil
+}
+
+// isConstantBody returns whether every expression of the rule body
+// is either "true", or an assignment of a ground value to a variable.
+func isConstantBody(body ast.Body) bool {
+	for _, e := range body {
+		if e.Negated || len(e.With) > 0 {
+			return false
+		}
+
+		switch t := e.Terms.(type) {
+		case *ast.Term:
+			if t.Value.Compare(ast.Boolean(true)) != 0 {
+				return false
+			}
+		case []*ast.Term:
+			if !e.IsAssignment() && !e.IsEquality() {
+				return false
+			}
+
+			lhs, rhs := e.Operand(0), e.Operand(1)
+			if _, ok := lhs.Value.(ast.Var); !ok {
+				return false
+			}
+
+			if !rhs.IsGround() {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+
+	return true
+}
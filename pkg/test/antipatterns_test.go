@@ -0,0 +1,189 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/projectcontour/integration-tester/pkg/doc"
+	"github.com/projectcontour/integration-tester/pkg/fixture"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func lintAntiPatterns(t *testing.T, data string) []string {
+	t.Helper()
+
+	d, err := doc.ReadDocument(strings.NewReader(data))
+	require.NoError(t, err)
+
+	d.Name = "test.yaml"
+	require.Empty(t, Lint(d))
+
+	var warnings []string
+	for _, w := range LintAntiPatterns(d) {
+		assert.True(t, w.Warning)
+		warnings = append(warnings, w.String())
+	}
+
+	return warnings
+}
+
+func TestLintAntiPatternsClean(t *testing.T) {
+	warnings := lintAntiPatterns(t, `---
+apiVersion: v1
+kind: Service
+metadata:
+  name: echo
+---
+# $when: data.test.params.tls == "true"
+skip[msg] {
+  msg := "TLS is not enabled"
+}
+
+error_no_echo[msg] {
+  not data.resources.services.echo
+  msg := "no echo service"
+}
+`)
+	assert.Empty(t, warnings)
+}
+
+func TestLintAntiPatternsStatelessCheck(t *testing.T) {
+	warnings := lintAntiPatterns(t, `---
+apiVersion: v1
+kind: Service
+metadata:
+  name: echo
+$check: |
+  error[msg] {
+    msg := "fail"
+  }
+`)
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "test.yaml:2: warning: object check doesn't refer to input or data")
+}
+
+func TestLintAntiPatternsUncheckedObject(t *testing.T) {
+	warnings := lintAntiPatterns(t, `---
+error_no_echo[msg] {
+  data.resources.services.echo
+  msg := "echo service exists"
+}
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: echo
+`)
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "no check follows it")
+}
+
+func TestLintAntiPatternsDuplicateObject(t *testing.T) {
+	warnings := lintAntiPatterns(t, `---
+apiVersion: v1
+kind: Service
+metadata:
+  name: echo
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: echo
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: echo
+$apply: delete
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: echo
+---
+error_no_echo[msg] {
+  not data.resources.services.echo
+  msg := "no echo service"
+}
+`)
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "test.yaml:7: warning: object v1:Service 'default/echo' is already applied at test.yaml:2")
+}
+
+func TestLintAntiPatternsCreateConflict(t *testing.T) {
+	warnings := lintAntiPatterns(t, `---
+apiVersion: v1
+kind: Service
+metadata:
+  name: echo
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: echo
+$apply: create
+$expect-error: AlreadyExists
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: echo
+---
+error_no_echo[msg] {
+  not data.resources.services.echo
+  msg := "no echo service"
+}
+`)
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "test.yaml:14: warning: object v1:Service 'default/echo' is already applied at test.yaml:2")
+}
+
+func TestLintAntiPatternsUnreachable(t *testing.T) {
+	warnings := lintAntiPatterns(t, `---
+skip[msg] {
+  msg := "not ready yet"
+}
+---
+error_no_echo[msg] {
+  not data.resources.services.echo
+  msg := "no echo service"
+}
+`)
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "test.yaml:6: warning: fragment is unreachable after the unconditional skip rule at test.yaml:2")
+}
+
+func TestLintAntiPatternsFixtureNamespace(t *testing.T) {
+	require.NoError(t, fixture.AddEcho(fixture.DefaultEchoImage))
+
+	warnings := lintAntiPatterns(t, `---
+apiVersion: v1
+kind: Service
+metadata:
+  name: integration-tester-echo
+  namespace: projectcontour
+---
+error_no_echo[msg] {
+  not data.resources.services["integration-tester-echo"]
+  msg := "no echo service"
+}
+`)
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "hard-coded namespace and the same name as a fixture")
+}
@@ -0,0 +1,284 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/projectcontour/integration-tester/pkg/driver"
+	"github.com/projectcontour/integration-tester/pkg/filter"
+	"github.com/projectcontour/integration-tester/pkg/utils"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// artifactsMaxEvents is the number of most recent Kubernetes Events
+// that are collected from each namespace.
+const artifactsMaxEvents = 200
+
+// artifactsLogLines is the number of lines of each container log
+// that are collected.
+const artifactsLogLines int64 = 1000
+
+var unsafeArtifactChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// ArtifactsDirOpt sets the directory that diagnostics are written
+// to when a test document fails.
+func ArtifactsDirOpt(dir string) RunOpt {
+	return RunOpt(func(tc *testContext) {
+		tc.artifactsDir = dir
+	})
+}
+
+// artifactsPath returns the directory that the diagnostics for a
+// run of the named test document are written to.
+func artifactsPath(dir string, name string, runID string) string {
+	return filepath.Join(dir,
+		unsafeArtifactChars.ReplaceAllString(name, "_"),
+		unsafeArtifactChars.ReplaceAllString(runID, "_"))
+}
+
+// collectArtifacts writes failure diagnostics for the test run
+// identified by runID to dir. The diagnostics are the objects of
+// the test run (objects.yaml), the recent Kubernetes Events in the
+// namespaces of those objects (events.yaml), and the container logs
+// of the test run's pods (logs/$NAMESPACE_$POD_$CONTAINER.log).
+//
+// Diagnostics that can't be collected are skipped, and the errors
+// are returned once everything else has been written.
+func collectArtifacts(k *driver.KubeClient, r driver.RegoDriver, runID string, dir string) error {
+	resources, err := r.ReadPath("/resources")
+	if err := ignoreStorageNotFoundErr(err); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	var errs []error
+
+	objects := runObjects(resources, runID)
+
+	var namespaces []string
+	var contents []interface{}
+
+	for _, u := range objects {
+		contents = append(contents, u.Object)
+
+		if ns := u.GetNamespace(); ns != "" && !utils.ContainsString(namespaces, ns) {
+			namespaces = append(namespaces, ns)
+		}
+	}
+
+	if err := writeYAMLStream(filepath.Join(dir, "objects.yaml"), contents); err != nil {
+		errs = append(errs, err)
+	}
+
+	var events []interface{}
+	for _, ns := range namespaces {
+		recent, err := recentEvents(k, ns, artifactsMaxEvents)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to list events in namespace %q: %w", ns, err))
+			continue
+		}
+
+		for i := range recent {
+			events = append(events, &recent[i])
+		}
+	}
+
+	if err := writeYAMLStream(filepath.Join(dir, "events.yaml"), events); err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, u := range objects {
+		if u.GetAPIVersion() != "v1" || u.GetKind() != "Pod" {
+			continue
+		}
+
+		if err := writePodLogs(k, u, filepath.Join(dir, "logs")); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return utils.ChainErrors(errs...)
+	}
+
+	return nil
+}
+
+// runObjects finds the Kubernetes objects in the resources store
+// data that are annotated with the given test run ID. The objects
+// are sorted by namespace, kind and name.
+func runObjects(resources interface{}, runID string) []*unstructured.Unstructured {
+	seen := map[string]bool{}
+
+	var objects []*unstructured.Unstructured
+	var walk func(v interface{})
+
+	walk = func(v interface{}) {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return
+		}
+
+		if _, ok := m["apiVersion"]; ok {
+			if _, ok := m["kind"]; ok {
+				u := &unstructured.Unstructured{Object: m}
+				key := fmt.Sprintf("%s/%s/%s/%s", u.GetAPIVersion(), u.GetKind(), u.GetNamespace(), u.GetName())
+
+				if u.GetAnnotations()[filter.LabelRunID] == runID && !seen[key] {
+					seen[key] = true
+					objects = append(objects, u)
+				}
+
+				return
+			}
+		}
+
+		for _, child := range m {
+			walk(child)
+		}
+	}
+
+	walk(resources)
+
+	sort.Slice(objects, func(i int, j int) bool {
+		a, b := objects[i], objects[j]
+
+		if a.GetNamespace() != b.GetNamespace() {
+			return a.GetNamespace() < b.GetNamespace()
+		}
+
+		if a.GetKind() != b.GetKind() {
+			return a.GetKind() < b.GetKind()
+		}
+
+		return a.GetName() < b.GetName()
+	})
+
+	return objects
+}
+
+// recentEvents returns up to max of the most recent Events in the
+// given namespace, oldest first.
+func recentEvents(k *driver.KubeClient, namespace string, max int) ([]v1.Event, error) {
+	list, err := k.Client.CoreV1().Events(namespace).List(
+		context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	events := list.Items
+
+	lastSeen := func(e *v1.Event) metav1.Time {
+		if !e.LastTimestamp.IsZero() {
+			return e.LastTimestamp
+		}
+
+		return metav1.NewTime(e.EventTime.Time)
+	}
+
+	sort.SliceStable(events, func(i int, j int) bool {
+		a, b := lastSeen(&events[i]), lastSeen(&events[j])
+		return a.Before(&b)
+	})
+
+	if len(events) > max {
+		events = events[len(events)-max:]
+	}
+
+	return events, nil
+}
+
+// writePodLogs writes the recent logs of each container in the pod
+// to a separate file in dir.
+func writePodLogs(k *driver.KubeClient, pod *unstructured.Unstructured, dir string) error {
+	containers, _, err := unstructured.NestedSlice(pod.Object, "spec", "containers")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	var errs []error
+
+	for _, c := range containers {
+		name, _, _ := unstructured.NestedString(c.(map[string]interface{}), "name")
+		if name == "" {
+			continue
+		}
+
+		tail := artifactsLogLines
+		logs, err := k.Client.CoreV1().Pods(pod.GetNamespace()).GetLogs(
+			pod.GetName(), &v1.PodLogOptions{Container: name, TailLines: &tail},
+		).DoRaw(context.Background())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to get logs for container %q of pod '%s/%s': %w",
+				name, pod.GetNamespace(), pod.GetName(), err))
+			continue
+		}
+
+		path := filepath.Join(dir, unsafeArtifactChars.ReplaceAllString(
+			fmt.Sprintf("%s_%s_%s.log", pod.GetNamespace(), pod.GetName(), name), "_"))
+
+		if err := ioutil.WriteFile(path, logs, 0644); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return utils.ChainErrors(errs...)
+	}
+
+	return nil
+}
+
+// writeYAMLStream writes each of the items to a multi-document YAML
+// file at path.
+func writeYAMLStream(path string, items []interface{}) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range items {
+		data, err := yaml.Marshal(v)
+		if err != nil {
+			f.Close()
+			return err
+		}
+
+		if _, err := fmt.Fprintf(f, "---\n%s", data); err != nil {
+			f.Close()
+			return err
+		}
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,97 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/projectcontour/integration-tester/pkg/filter"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArtifactsPath(t *testing.T) {
+	assert.Equal(t,
+		filepath.Join("out", "test_echo.yaml_iteration_2_of_3_", "abc-123"),
+		artifactsPath("out", "test/echo.yaml (iteration 2 of 3)", "abc-123"))
+}
+
+func TestRunObjects(t *testing.T) {
+	object := func(kind string, ns string, name string, runID string) map[string]interface{} {
+		return map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       kind,
+			"metadata": map[string]interface{}{
+				"namespace": ns,
+				"name":      name,
+				"annotations": map[string]interface{}{
+					filter.LabelRunID: runID,
+				},
+			},
+		}
+	}
+
+	resources := map[string]interface{}{
+		"default": map[string]interface{}{
+			"pods": map[string]interface{}{
+				"echo":  object("Pod", "default", "echo", "run-1"),
+				"other": object("Pod", "default", "other", "run-2"),
+			},
+			"services": map[string]interface{}{
+				"echo": object("Service", "default", "echo", "run-1"),
+			},
+		},
+		"apps": map[string]interface{}{
+			"pods": map[string]interface{}{
+				"echo": object("Pod", "apps", "echo", "run-1"),
+			},
+		},
+		"applied": map[string]interface{}{
+			"last": object("Service", "default", "echo", "run-1"),
+		},
+	}
+
+	var names []string
+	for _, u := range runObjects(resources, "run-1") {
+		names = append(names, u.GetNamespace()+"/"+u.GetKind()+"/"+u.GetName())
+	}
+
+	assert.Equal(t, []string{
+		"apps/Pod/echo",
+		"default/Pod/echo",
+		"default/Service/echo",
+	}, names)
+}
+
+func TestWriteYAMLStream(t *testing.T) {
+	dir, err := ioutil.TempDir("", "artifacts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "objects.yaml")
+
+	require.NoError(t, writeYAMLStream(path, []interface{}{
+		map[string]interface{}{"name": "one"},
+		map[string]interface{}{"name": "two"},
+	}))
+
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "---\nname: one\n---\nname: two\n", string(data))
+}
@@ -0,0 +1,140 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/projectcontour/integration-tester/pkg/doc"
+
+	"github.com/open-policy-agent/opa/ast"
+)
+
+// DefaultCheckInterval is the initial interval between evaluations
+// of a failing check.
+const DefaultCheckInterval = 500 * time.Millisecond
+
+// checkBackoffFactor limits how far the check interval can back off,
+// as a multiple of the initial interval.
+const checkBackoffFactor = 8
+
+// CheckIntervalOpt sets the initial interval between evaluations of
+// a failing check.
+func CheckIntervalOpt(interval time.Duration) RunOpt {
+	return RunOpt(func(tc *testContext) {
+		tc.checkInterval = interval
+	})
+}
+
+// Backoff generates exponentially increasing, jittered delays.
+type Backoff struct {
+	// Initial is the delay before the first retry.
+	Initial time.Duration
+	// Max is the largest delay, before jitter.
+	Max time.Duration
+
+	attempt int
+}
+
+// NewCheckBackoff returns the backoff between evaluations of a
+// failing check that starts at the given interval.
+func NewCheckBackoff(interval time.Duration) *Backoff {
+	return &Backoff{
+		Initial: interval,
+		Max:     interval * checkBackoffFactor,
+	}
+}
+
+// Next returns the next delay. Each delay doubles the previous one,
+// up to the maximum, and is then reduced by up to half at random so
+// that concurrent checks don't evaluate in lockstep.
+func (b *Backoff) Next() time.Duration {
+	delay := b.Initial
+	for i := 0; i < b.attempt && delay < b.Max; i++ {
+		delay *= 2
+	}
+
+	if delay > b.Max {
+		delay = b.Max
+	}
+
+	b.attempt++
+
+	if half := int64(delay / 2); half > 0 {
+		delay -= time.Duration(rand.Int63n(half + 1))
+	}
+
+	return delay
+}
+
+// documentCheckInterval returns the check interval from a
+// "$check-interval:" comment in any Rego fragment of the test
+// document, or zero if there is no such comment.
+func documentCheckInterval(d *doc.Document) (time.Duration, error) {
+	for _, p := range d.Parts {
+		if p.Type != doc.FragmentTypeModule {
+			continue
+		}
+
+		for _, c := range p.Rego().Comments {
+			text := strings.TrimSpace(string(c.Text))
+			if !strings.HasPrefix(text, "$check-interval:") {
+				continue
+			}
+
+			value := strings.TrimSpace(strings.TrimPrefix(text, "$check-interval:"))
+			interval, err := time.ParseDuration(value)
+			if err != nil {
+				return 0, fmt.Errorf("failed to parse %q comment: %w", "$check-interval", err)
+			}
+
+			if interval <= 0 {
+				return 0, fmt.Errorf("invalid %q duration %q", "$check-interval", value)
+			}
+
+			return interval, nil
+		}
+	}
+
+	return 0, nil
+}
+
+// checkTimeout returns the timeout from a "$timeout:" comment in the
+// module, or the default timeout if the module has no such comment.
+func checkTimeout(m *ast.Module, timeout time.Duration) (time.Duration, error) {
+	for _, c := range m.Comments {
+		text := strings.TrimSpace(string(c.Text))
+		if !strings.HasPrefix(text, "$timeout:") {
+			continue
+		}
+
+		value := strings.TrimSpace(strings.TrimPrefix(text, "$timeout:"))
+		t, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse %q comment: %w", "$timeout", err)
+		}
+
+		if t <= 0 {
+			return 0, fmt.Errorf("invalid %q duration %q", "$timeout", value)
+		}
+
+		return t, nil
+	}
+
+	return timeout, nil
+}
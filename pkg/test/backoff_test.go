@@ -0,0 +1,112 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/projectcontour/integration-tester/pkg/doc"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckBackoff(t *testing.T) {
+	b := NewCheckBackoff(100 * time.Millisecond)
+
+	for _, max := range []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		800 * time.Millisecond,
+	} {
+		delay := b.Next()
+		assert.LessOrEqual(t, int64(delay), int64(max))
+		assert.GreaterOrEqual(t, int64(delay), int64(max/2))
+	}
+}
+
+func TestDocumentCheckInterval(t *testing.T) {
+	read := func(data string) *doc.Document {
+		d, err := doc.ReadDocument(strings.NewReader(data))
+		require.NoError(t, err)
+
+		for i := range d.Parts {
+			_, err := d.Parts[i].Decode()
+			require.NoError(t, err)
+		}
+
+		return d
+	}
+
+	interval, err := documentCheckInterval(read(`---
+error[msg] {
+  msg := "fail"
+}
+`))
+	require.NoError(t, err)
+	assert.Equal(t, time.Duration(0), interval)
+
+	interval, err = documentCheckInterval(read(`---
+# $check-interval: 2s
+error[msg] {
+  msg := "fail"
+}
+`))
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Second, interval)
+
+	_, err = documentCheckInterval(read(`---
+# $check-interval: often
+error[msg] {
+  msg := "fail"
+}
+`))
+	assert.Error(t, err)
+}
+
+func TestCheckTimeout(t *testing.T) {
+	m, err := ast.ParseModule("timeout.rego", `package timeout
+error[msg] { false; msg := "never" }
+`)
+	require.NoError(t, err)
+
+	timeout, err := checkTimeout(m, 10*time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Second, timeout)
+
+	m, err = ast.ParseModule("timeout.rego", `package timeout
+# $timeout: 2m
+error[msg] { false; msg := "never" }
+`)
+	require.NoError(t, err)
+
+	timeout, err = checkTimeout(m, 10*time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Minute, timeout)
+
+	m, err = ast.ParseModule("timeout.rego", `package timeout
+# $timeout: later
+error[msg] { false; msg := "never" }
+`)
+	require.NoError(t, err)
+
+	_, err = checkTimeout(m, 10*time.Second)
+	assert.Error(t, err)
+}
@@ -0,0 +1,338 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/projectcontour/integration-tester/pkg/driver"
+	"github.com/projectcontour/integration-tester/pkg/utils"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// BudgetObjects is the name of the budget limit on the number of
+// objects that a test document can create.
+const BudgetObjects = "objects"
+
+// Budget limits the cumulative resources that the objects created by
+// a test document can request. This keeps tests from overcommitting
+// shared clusters.
+type Budget struct {
+	// Objects is the maximum number of objects. Zero means
+	// there is no limit.
+	Objects int
+
+	// Requests is the maximum of each cumulative resource
+	// request (e.g. "cpu", "memory") of the pods created by
+	// the test document.
+	Requests v1.ResourceList
+}
+
+// ParseBudget parses budget limits in "name=quantity" format. The
+// name is either "objects" or the name of a compute resource.
+func ParseBudget(limits []string) (*Budget, error) {
+	b := &Budget{
+		Requests: v1.ResourceList{},
+	}
+
+	for _, l := range limits {
+		parts := strings.SplitN(l, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("missing value for budget limit %q", parts[0])
+		}
+
+		if parts[0] == BudgetObjects {
+			n, err := strconv.Atoi(parts[1])
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid object budget %q", parts[1])
+			}
+
+			b.Objects = n
+			continue
+		}
+
+		q, err := resource.ParseQuantity(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s budget %q: %w", parts[0], parts[1], err)
+		}
+
+		b.Requests[v1.ResourceName(parts[0])] = q
+	}
+
+	return b, nil
+}
+
+// BudgetOpt limits the resources that each test document can request.
+func BudgetOpt(b *Budget) RunOpt {
+	return RunOpt(func(tc *testContext) {
+		tc.budget = b
+	})
+}
+
+// IOBudgetOpt limits the external I/O that the builtins of each
+// check can perform.
+func IOBudgetOpt(b driver.IOBudget) RunOpt {
+	return RunOpt(func(tc *testContext) {
+		tc.ioBudget = b
+	})
+}
+
+// budgetTracker tracks the resources requested by each object
+// that a test document applies.
+type budgetTracker struct {
+	budget  *Budget
+	objects map[string]v1.ResourceList
+}
+
+func newBudgetTracker(b *Budget) *budgetTracker {
+	return &budgetTracker{
+		budget:  b,
+		objects: map[string]v1.ResourceList{},
+	}
+}
+
+func budgetKey(u *unstructured.Unstructured) string {
+	return fmt.Sprintf("%s %s/%s",
+		u.GroupVersionKind().GroupKind(), utils.NamespaceOrDefault(u), u.GetName())
+}
+
+// total returns the cumulative requests of all the tracked objects.
+func (b *budgetTracker) total() v1.ResourceList {
+	total := v1.ResourceList{}
+
+	for _, requests := range b.objects {
+		for name, q := range requests {
+			addQuantity(total, name, q)
+		}
+	}
+
+	return total
+}
+
+// admit records the resource requests of the given object, returning
+// an error if that would exceed the budget. If the object is already
+// tracked, its previous requests are replaced.
+func (b *budgetTracker) admit(u *unstructured.Unstructured) error {
+	requests, err := objectRequests(u)
+	if err != nil {
+		return fmt.Errorf("failed to compute resource requests: %w", err)
+	}
+
+	key := budgetKey(u)
+	previous, tracked := b.objects[key]
+
+	b.objects[key] = requests
+
+	var problems []string
+
+	if b.budget.Objects > 0 && len(b.objects) > b.budget.Objects {
+		problems = append(problems,
+			fmt.Sprintf("%s %d > %d", BudgetObjects, len(b.objects), b.budget.Objects))
+	}
+
+	total := b.total()
+	for name, limit := range b.budget.Requests {
+		if q := total[name]; q.Cmp(limit) > 0 {
+			problems = append(problems,
+				fmt.Sprintf("%s %s > %s", name, q.String(), limit.String()))
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	// Roll back, since we won't create this object.
+	if tracked {
+		b.objects[key] = previous
+	} else {
+		delete(b.objects, key)
+	}
+
+	sort.Strings(problems)
+
+	return fmt.Errorf("%s %s/%s exceeds the test budget: %s",
+		u.GetKind(), utils.NamespaceOrDefault(u), u.GetName(),
+		strings.Join(problems, ", "))
+}
+
+// release stops tracking the given object.
+func (b *budgetTracker) release(u *unstructured.Unstructured) {
+	delete(b.objects, budgetKey(u))
+}
+
+// patchedObject returns the object that applying the JSON patch to u
+// would give. The patch is sent to the API server as a dry run, so
+// that the budget can be checked before the patch is really applied.
+func patchedObject(k *driver.KubeClient, u *unstructured.Unstructured, patch []byte) (*unstructured.Unstructured, error) {
+	gvk := u.GroupVersionKind()
+
+	gvr, err := k.ResourceForKind(gvk)
+	if err != nil {
+		return nil, err
+	}
+
+	isNamespaced, err := k.KindIsNamespaced(gvk)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := metav1.PatchOptions{DryRun: []string{metav1.DryRunAll}}
+
+	if isNamespaced {
+		return k.Dynamic.Resource(gvr).Namespace(utils.NamespaceOrDefault(u)).Patch(
+			context.Background(), u.GetName(), types.JSONPatchType, patch, opts)
+	}
+
+	return k.Dynamic.Resource(gvr).Patch(
+		context.Background(), u.GetName(), types.JSONPatchType, patch, opts)
+}
+
+// objectRequests returns the cumulative resource requests of the
+// pods that the given object creates. For workload kinds, the pod
+// template requests are multiplied by the number of replicas.
+// DaemonSets are counted as a single replica, since the number of
+// nodes they run on is not known.
+func objectRequests(u *unstructured.Unstructured) (v1.ResourceList, error) {
+	var specPath []string
+	var replicaPath []string
+
+	switch u.GroupVersionKind().GroupKind().String() {
+	case "Pod":
+		specPath = []string{"spec"}
+	case "ReplicationController", "Deployment.apps", "ReplicaSet.apps", "StatefulSet.apps":
+		specPath = []string{"spec", "template", "spec"}
+		replicaPath = []string{"spec", "replicas"}
+	case "DaemonSet.apps":
+		specPath = []string{"spec", "template", "spec"}
+	case "Job.batch":
+		specPath = []string{"spec", "template", "spec"}
+		replicaPath = []string{"spec", "parallelism"}
+	default:
+		return v1.ResourceList{}, nil
+	}
+
+	spec, found, err := unstructured.NestedMap(u.Object, specPath...)
+	if err != nil || !found {
+		return v1.ResourceList{}, err
+	}
+
+	podSpec := v1.PodSpec{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(spec, &podSpec); err != nil {
+		return nil, err
+	}
+
+	replicas := int64(1)
+	if replicaPath != nil {
+		// Decoded YAML numbers may be integers or floats.
+		val, found, err := unstructured.NestedFieldNoCopy(u.Object, replicaPath...)
+		if err != nil {
+			return nil, err
+		}
+
+		switch n := val.(type) {
+		case int64:
+			replicas = n
+		case float64:
+			replicas = int64(n)
+		default:
+			if found {
+				return nil, fmt.Errorf("invalid %s field type %T",
+					strings.Join(replicaPath, "."), val)
+			}
+		}
+	}
+
+	requests := podRequests(&podSpec)
+	for name, q := range requests {
+		requests[name] = scaleQuantity(q, replicas)
+	}
+
+	return requests, nil
+}
+
+// scaleQuantity returns q multiplied by n. The product is computed
+// in milli-units, unless that would overflow, in which case it is
+// computed in whole units (and saturates at the largest quantity).
+func scaleQuantity(q resource.Quantity, n int64) resource.Quantity {
+	switch {
+	case n <= 0:
+		return resource.Quantity{Format: q.Format}
+	case q.Value() <= math.MaxInt64/1000/n:
+		return *resource.NewMilliQuantity(q.MilliValue()*n, q.Format)
+	case q.Value() <= math.MaxInt64/n:
+		return *resource.NewQuantity(q.Value()*n, q.Format)
+	default:
+		return *resource.NewQuantity(math.MaxInt64, q.Format)
+	}
+}
+
+// podRequests returns the effective resource requests of a pod. This
+// is the greater of the sum of the container requests and the largest
+// init container request. Containers that only specify resource
+// limits implicitly request the limit.
+func podRequests(spec *v1.PodSpec) v1.ResourceList {
+	containerRequests := func(c *v1.Container) v1.ResourceList {
+		requests := v1.ResourceList{}
+		for name, q := range c.Resources.Limits {
+			requests[name] = q.DeepCopy()
+		}
+		for name, q := range c.Resources.Requests {
+			requests[name] = q.DeepCopy()
+		}
+		return requests
+	}
+
+	total := v1.ResourceList{}
+
+	for i := range spec.Containers {
+		for name, q := range containerRequests(&spec.Containers[i]) {
+			addQuantity(total, name, q)
+		}
+	}
+
+	for i := range spec.InitContainers {
+		for name, q := range containerRequests(&spec.InitContainers[i]) {
+			if q.Cmp(total[name]) > 0 {
+				total[name] = q
+			}
+		}
+	}
+
+	return total
+}
+
+// addQuantity adds q to the named resource in the list.
+func addQuantity(list v1.ResourceList, name v1.ResourceName, q resource.Quantity) {
+	sum, ok := list[name]
+	if !ok {
+		list[name] = q.DeepCopy()
+		return
+	}
+
+	sum.Add(q)
+	list[name] = sum
+}
@@ -0,0 +1,156 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+func mustUnstructured(t *testing.T, data string) *unstructured.Unstructured {
+	t.Helper()
+
+	u := &unstructured.Unstructured{}
+	require.NoError(t, yaml.Unmarshal([]byte(data), &u.Object))
+	return u
+}
+
+func TestParseBudget(t *testing.T) {
+	b, err := ParseBudget([]string{"objects=10", "cpu=500m", "memory=1Gi"})
+	require.NoError(t, err)
+
+	assert.Equal(t, 10, b.Objects)
+	assert.Equal(t, "500m", b.Requests.Cpu().String())
+	assert.Equal(t, "1Gi", b.Requests.Memory().String())
+
+	_, err = ParseBudget([]string{"cpu"})
+	assert.Error(t, err)
+
+	_, err = ParseBudget([]string{"objects=many"})
+	assert.Error(t, err)
+
+	_, err = ParseBudget([]string{"memory=lots"})
+	assert.Error(t, err)
+}
+
+func TestBudgetRequests(t *testing.T) {
+	deploy := mustUnstructured(t, `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: echo
+spec:
+  replicas: 3
+  template:
+    spec:
+      initContainers:
+      - name: init
+        resources:
+          requests:
+            cpu: 1
+      containers:
+      - name: echo
+        resources:
+          requests:
+            cpu: 100m
+            memory: 64Mi
+      - name: sidecar
+        resources:
+          limits:
+            cpu: 50m
+`)
+
+	requests, err := objectRequests(deploy)
+	require.NoError(t, err)
+
+	// The init container request is larger than the 150m sum
+	// of the container requests.
+	assert.Equal(t, "3", requests.Cpu().String())
+	assert.Equal(t, "192Mi", requests.Memory().String())
+
+	secret := mustUnstructured(t, `
+apiVersion: v1
+kind: Secret
+metadata:
+  name: secret
+`)
+
+	requests, err = objectRequests(secret)
+	require.NoError(t, err)
+	assert.Empty(t, requests)
+}
+
+func TestBudgetScaleQuantity(t *testing.T) {
+	scaled := scaleQuantity(resource.MustParse("100m"), 3)
+	assert.Equal(t, "300m", scaled.String())
+
+	scaled = scaleQuantity(resource.MustParse("64Mi"), 0)
+	assert.True(t, scaled.IsZero())
+
+	// A large replica count is multiplied, not counted.
+	scaled = scaleQuantity(resource.MustParse("1"), 1000000000)
+	assert.Equal(t, int64(1000000000), scaled.Value())
+
+	// Milli-units of large quantities would overflow.
+	scaled = scaleQuantity(resource.MustParse("1Ti"), 1000000)
+	assert.Equal(t, int64(1<<40)*1000000, scaled.Value())
+
+	scaled = scaleQuantity(resource.MustParse("1Ei"), 1000000)
+	assert.Equal(t, int64(math.MaxInt64), scaled.Value())
+}
+
+func TestBudgetAdmit(t *testing.T) {
+	b, err := ParseBudget([]string{"objects=2", "cpu=1"})
+	require.NoError(t, err)
+
+	tracker := newBudgetTracker(b)
+
+	pod := func(name string, cpu string) *unstructured.Unstructured {
+		return mustUnstructured(t, `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: `+name+`
+spec:
+  containers:
+  - name: c
+    resources:
+      requests:
+        cpu: `+cpu+`
+`)
+	}
+
+	require.NoError(t, tracker.admit(pod("one", "500m")))
+
+	// Updating the same object replaces its requests.
+	require.NoError(t, tracker.admit(pod("one", "800m")))
+
+	assert.EqualError(t, tracker.admit(pod("two", "500m")),
+		"Pod default/two exceeds the test budget: cpu 1300m > 1")
+
+	require.NoError(t, tracker.admit(pod("two", "200m")))
+
+	assert.EqualError(t, tracker.admit(pod("three", "0")),
+		"Pod default/three exceeds the test budget: objects 3 > 2")
+
+	tracker.release(pod("two", "200m"))
+	require.NoError(t, tracker.admit(pod("three", "0")))
+}
@@ -0,0 +1,152 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/projectcontour/integration-tester/pkg/must"
+	"github.com/projectcontour/integration-tester/pkg/result"
+)
+
+// DocumentBuffer is a Recorder that buffers the records of a single
+// test document, so that test documents can run concurrently. When
+// the document is complete, Flush replays the records into the
+// recorders that produce the test output. The caller must serialize
+// calls to Flush, which means that the output recorders never run
+// concurrently and the output of each document is not interleaved
+// with the output of other documents.
+type DocumentBuffer struct {
+	events []bufferedEvent
+
+	terminal bool
+	failed   bool
+}
+
+type bufferedEvent struct {
+	when   time.Time
+	replay func(r Recorder, closers *[]Closer)
+}
+
+var _ Recorder = &DocumentBuffer{}
+
+func (b *DocumentBuffer) record(f func(r Recorder, closers *[]Closer)) {
+	b.events = append(b.events, bufferedEvent{when: time.Now(), replay: f})
+}
+
+// pushCloser records an event that opens a Closer.
+func (b *DocumentBuffer) pushCloser(open func(r Recorder) Closer) Closer {
+	b.record(func(r Recorder, closers *[]Closer) {
+		*closers = append(*closers, open(r))
+	})
+
+	return CloserFunc(func() {
+		b.record(func(r Recorder, closers *[]Closer) {
+			n := len(*closers)
+			must.Check(n > 0, fmt.Errorf("unbalanced buffered closers"))
+
+			(*closers)[n-1].Close()
+			*closers = (*closers)[:n-1]
+		})
+	})
+}
+
+// ShouldContinue returns false if a fatal error has been recorded
+// in the buffered document.
+func (b *DocumentBuffer) ShouldContinue() bool {
+	return !b.terminal
+}
+
+// Failed returns true if any errors have been recorded in the
+// buffered document.
+func (b *DocumentBuffer) Failed() bool {
+	return b.failed
+}
+
+// NewDocument ...
+func (b *DocumentBuffer) NewDocument(desc string) Closer {
+	return b.pushCloser(func(r Recorder) Closer {
+		return r.NewDocument(desc)
+	})
+}
+
+// NewStep ...
+func (b *DocumentBuffer) NewStep(id string, desc string) Closer {
+	return b.pushCloser(func(r Recorder) Closer {
+		return r.NewStep(id, desc)
+	})
+}
+
+// Update ...
+func (b *DocumentBuffer) Update(results ...result.Result) {
+	buffered := make([]result.Result, len(results))
+	copy(buffered, results)
+
+	for _, r := range buffered {
+		if r.IsTerminal() {
+			b.terminal = true
+		}
+
+		if r.IsFailed() {
+			b.failed = true
+		}
+	}
+
+	b.record(func(r Recorder, closers *[]Closer) {
+		r.Update(buffered...)
+	})
+}
+
+// RetryDocument records that the buffered document is retried. The
+// failures recorded so far no longer count, so that the retry can run.
+func (b *DocumentBuffer) RetryDocument() {
+	b.terminal = false
+	b.failed = false
+
+	b.record(func(r Recorder, closers *[]Closer) {
+		r.RetryDocument()
+	})
+}
+
+// captureDocument records the captured suite document, so that it
+// is replayed into the document that it belongs to.
+func (b *DocumentBuffer) captureDocument(runID string, resources interface{}) {
+	b.record(func(r Recorder, closers *[]Closer) {
+		captureDocument(r, runID, resources)
+	})
+}
+
+// Flush replays the buffered records into r, in the order they were
+// recorded and with the time that they were recorded. Any recorders
+// that are left open are closed, and the buffer is emptied.
+func (b *DocumentBuffer) Flush(r Recorder) {
+	var closers []Closer
+
+	// Only the clocks of r and the recorders that it wraps are
+	// set, so documents that are still running are unaffected.
+	defer setClock(r, time.Time{})
+
+	for _, e := range b.events {
+		setClock(r, e.when)
+		e.replay(r, &closers)
+	}
+
+	for i := len(closers) - 1; i >= 0; i-- {
+		closers[i].Close()
+	}
+
+	b.events = nil
+}
@@ -0,0 +1,92 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/projectcontour/integration-tester/pkg/result"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocumentBuffer(t *testing.T) {
+	b := &DocumentBuffer{}
+
+	docCloser := b.NewDocument("one.yaml")
+	stepCloser := b.NewStep("one.yaml#compile", "compiling")
+	b.Update(result.Infof("compiled"))
+	stepCloser.Close()
+
+	assert.True(t, b.ShouldContinue())
+	assert.False(t, b.Failed())
+
+	stepCloser = b.NewStep("one.yaml#0:check", "checking")
+	b.Update(result.Fatalf("failed"))
+
+	assert.False(t, b.ShouldContinue())
+	assert.True(t, b.Failed())
+
+	// Leave the step and document open, so that Flush closes them.
+	_ = stepCloser
+	_ = docCloser
+
+	recorded := time.Now()
+	time.Sleep(10 * time.Millisecond)
+
+	r := &defaultRecorder{}
+	b.Flush(r)
+
+	require.Len(t, r.docs, 1)
+	require.Len(t, r.docs[0].Steps, 2)
+	assert.Nil(t, r.currentDoc)
+	assert.Nil(t, r.currentStep)
+
+	assert.Equal(t, "one.yaml#0:check", r.docs[0].Steps[1].ID)
+	assert.Equal(t, "failed", r.docs[0].Steps[1].Results[0].Message)
+
+	// The replayed steps keep the time they were recorded.
+	assert.False(t, r.docs[0].Steps[0].Start.After(recorded))
+	assert.False(t, r.docs[0].Steps[1].End.After(recorded))
+	assert.True(t, r.Failed())
+
+	// Once the buffer is flushed, the clock is live again.
+	assert.True(t, r.now().After(recorded))
+}
+
+func TestDocumentBufferStackedClocks(t *testing.T) {
+	b := &DocumentBuffer{}
+
+	docCloser := b.NewDocument("one.yaml")
+	b.NewStep("one.yaml#compile", "compiling").Close()
+	docCloser.Close()
+
+	recorded := time.Now()
+	time.Sleep(10 * time.Millisecond)
+
+	top := &defaultRecorder{}
+	next := &defaultRecorder{}
+
+	b.Flush(StackRecorders(top, next))
+
+	for _, r := range []*defaultRecorder{top, next} {
+		require.Len(t, r.docs, 1)
+		require.Len(t, r.docs[0].Steps, 1)
+		assert.False(t, r.docs[0].Steps[0].End.After(recorded))
+		assert.True(t, r.now().After(recorded))
+	}
+}
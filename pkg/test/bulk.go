@@ -0,0 +1,239 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/projectcontour/integration-tester/pkg/doc"
+	"github.com/projectcontour/integration-tester/pkg/driver"
+	"github.com/projectcontour/integration-tester/pkg/result"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/rego"
+)
+
+// BulkApplyOpt applies each run of at least n consecutive plain
+// object fragments of the same kind in a single test step, followed
+// by a single check step, instead of a set of steps for each object.
+// Plain object fragments are named objects with no pseudo-fields.
+func BulkApplyOpt(n int) RunOpt {
+	return RunOpt(func(tc *testContext) {
+		tc.bulkApply = n
+	})
+}
+
+// bulkProgressSteps is the number of progress messages that are
+// recorded while a bulk run is applied.
+const bulkProgressSteps = 10
+
+// bulkRun is a run of consecutive object fragments that are applied
+// together.
+type bulkRun struct {
+	// document is the name of the test document.
+	document string
+	// id is the fragment ID of the bulk and check steps.
+	id string
+	// what describes the objects in the step messages.
+	what  string
+	parts []doc.Fragment
+	ids   []string
+}
+
+// bulkRuns returns the runs of at least min consecutive plain object
+// fragments of the same kind. The runs are keyed by the index of their
+// first fragment, and the value is the index after their last fragment.
+func bulkRuns(parts []doc.Fragment, min int) map[int]int {
+	runs := map[int]int{}
+
+	for start := 0; start < len(parts); {
+		kind := bulkKind(&parts[start])
+		end := start + 1
+
+		for kind != "" && end < len(parts) && bulkKind(&parts[end]) == kind {
+			end++
+		}
+
+		if kind != "" && end-start >= min {
+			runs[start] = end
+		}
+
+		start = end
+	}
+
+	return runs
+}
+
+// bulkKind returns the API version and kind of a plain object fragment,
+// or an empty string if the fragment can't be applied in bulk.
+func bulkKind(p *doc.Fragment) string {
+	u := p.Object()
+	if u == nil || u.GetName() == "" || p.Items() != nil {
+		return ""
+	}
+
+	for k := range u.Object {
+		if strings.HasPrefix(k, "$") {
+			return ""
+		}
+	}
+
+	return u.GetAPIVersion() + ":" + u.GetKind()
+}
+
+// applyBulk applies the objects of a bulk run, recording progress as
+// it goes, and then checks each of them with the default update check.
+// It returns the result of the last operation.
+func applyBulk(
+	tc *testContext,
+	run bulkRun,
+	compiler *ast.Compiler,
+	budget *budgetTracker,
+	mutations *MutationTimeline,
+) *driver.OperationResult {
+	var opResults []*driver.OperationResult
+	var opIDs []string
+
+	total := len(run.parts)
+	progress := total / bulkProgressSteps
+	if progress < 1 {
+		progress = 1
+	}
+
+	step(tc.recorder,
+		StepID(run.document, run.id, "bulk"),
+		fmt.Sprintf("applying %d %s", total, run.what),
+		func() {
+			for i := range run.parts {
+				p := &run.parts[i]
+				id := run.ids[i]
+
+				if tc.interrupted() {
+					tc.recorder.Update(result.Fatalf("test run was interrupted"))
+					return
+				}
+
+				obj, err := tc.envDriver.HydrateObject(p.Bytes)
+				if err != nil {
+					tc.recorder.Update(result.Fatalf(
+						"failed to hydrate object lines %s: %s", p.Location, err))
+					return
+				}
+
+				if err := defaultNamespace(tc.kubeDriver, obj.Object, tc.namespace); err != nil {
+					tc.recorder.Update(result.Fatalf(
+						"failed to default object namespace: %s", err))
+					return
+				}
+
+				if budget != nil {
+					if err := budget.admit(obj.Object); err != nil {
+						tc.recorder.Update(result.Fatalf("%s", err))
+						return
+					}
+				}
+
+				if !paceKind(tc, obj.Object.GetKind()) {
+					return
+				}
+
+				opResult, err := applyObject(tc.kubeDriver, tc.objectDriver, obj.Object)
+				if err != nil {
+					tc.recorder.Update(result.Fatalf(
+						"unable to update object lines %s: %s", p.Location, err))
+					return
+				}
+
+				opResults = append(opResults, opResult)
+				opIDs = append(opIDs, id)
+
+				if opResult.Succeeded() {
+					tc.recorder.Update(appliedObjectResult(obj.Operation, obj.Object, opResult.Latest))
+
+					if err := storeAppliedIdentity(tc.regoDriver, id, opResult.Latest); err != nil {
+						tc.recorder.Update(result.Fatalf(
+							"failed to store applied object identity: %s", err))
+						return
+					}
+
+					mutations.Record(StepID(run.document, id, "update"), obj.Object, opResult.Latest)
+				}
+
+				if (i+1)%progress == 0 || i+1 == total {
+					tc.recorder.Update(result.Infof(
+						"applied %d/%d %s", i+1, total, run.what))
+				}
+			}
+
+			if err := syncInformers(tc, tc.checkTimeout); err != nil {
+				tc.recorder.Update(result.Fatalf(
+					"failed to store informer status: %s", err))
+				return
+			}
+
+			if len(opResults) > 0 {
+				last := opResults[len(opResults)-1]
+				if last.Latest != nil {
+					if err := storeItem(tc.regoDriver, "/resources/applied/last",
+						last.Latest.UnstructuredContent()); err != nil {
+						tc.recorder.Update(result.Fatalf(
+							"failed to store result: %s", err))
+						return
+					}
+				}
+			}
+
+			if err := storeItem(tc.regoDriver, "/test/mutations", mutations.Entries); err != nil {
+				tc.recorder.Update(result.Fatalf(
+					"failed to store object mutations: %s", err))
+			}
+		})
+
+	if len(opResults) == 0 {
+		return nil
+	}
+
+	step(tc.recorder,
+		StepID(run.document, run.id, "check"),
+		fmt.Sprintf("running update checks for %d %s", len(opResults), run.what),
+		func() {
+			check := DefaultObjectCheckForOperation(driver.ObjectOperationUpdate)
+
+			for i, opResult := range opResults {
+				input := &CheckInput{
+					OperationResult: opResult,
+					Step: CheckStep{
+						ID:       StepID(run.document, opIDs[i], "check"),
+						Document: run.document,
+						Fragment: opIDs[i],
+						RunID:    tc.envDriver.UniqueID(),
+					},
+				}
+
+				checkResults, err := runCheck(
+					tc.regoDriver, tc.objectDriver, check, tc.checkTimeout, tc.checkInterval, tc.interrupt,
+					rego.Compiler(compiler), rego.Input(input))
+				if err != nil {
+					tc.recorder.Update(result.Fatalf("%s", err))
+					return
+				}
+
+				tc.recorder.Update(checkResults...)
+			}
+		})
+
+	return opResults[len(opResults)-1]
+}
@@ -0,0 +1,86 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/projectcontour/integration-tester/pkg/doc"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBulkRuns(t *testing.T) {
+	d, err := doc.ReadDocument(strings.NewReader(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: one
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: two
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: three
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: echo
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: one
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: two
+$check: false
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: three
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: four
+---
+import data.resources
+`))
+	require.NoError(t, err)
+
+	for i := range d.Parts {
+		_, err := d.Parts[i].Decode()
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, map[int]int{0: 3}, bulkRuns(d.Parts, 3))
+	assert.Equal(t, map[int]int{0: 3, 6: 8}, bulkRuns(d.Parts, 2))
+	assert.Empty(t, bulkRuns(d.Parts, 4))
+
+	assert.Equal(t, "v1:ConfigMap", bulkKind(&d.Parts[0]))
+	assert.Equal(t, "", bulkKind(&d.Parts[5]))
+	assert.Equal(t, "", bulkKind(&d.Parts[8]))
+}
@@ -0,0 +1,118 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"time"
+
+	"github.com/projectcontour/integration-tester/pkg/result"
+)
+
+// CoalesceRecorders returns a new Recorder that collapses consecutive
+// identical informational results before passing them to next. The
+// first occurrence of a message is passed through immediately, and
+// any repeats are reported as a count once a different message is
+// recorded or the current step is closed.
+//
+// This is intended to wrap output recorders (like TreeWriter), so
+// that polling loops don't flood the output with identical lines.
+func CoalesceRecorders(next Recorder) Recorder {
+	return &coalesceRecorder{next: next}
+}
+
+type coalesceRecorder struct {
+	next Recorder
+
+	last    *result.Result
+	repeats int
+}
+
+var _ Recorder = &coalesceRecorder{}
+
+// flush reports the repeat count for the last message, if there
+// were any repeats, and forgets the last message.
+func (c *coalesceRecorder) flush() {
+	switch c.repeats {
+	case 0:
+	case 1:
+		c.next.Update(result.Infof("(previous message repeated 1 more time)"))
+	default:
+		c.next.Update(result.Infof("(previous message repeated %d more times)", c.repeats))
+	}
+
+	c.last = nil
+	c.repeats = 0
+}
+
+func (c *coalesceRecorder) ShouldContinue() bool {
+	return c.next.ShouldContinue()
+}
+
+func (c *coalesceRecorder) Failed() bool {
+	return c.next.Failed()
+}
+
+func (c *coalesceRecorder) NewDocument(desc string) Closer {
+	c.flush()
+	closer := c.next.NewDocument(desc)
+
+	return CloserFunc(func() {
+		c.flush()
+		closer.Close()
+	})
+}
+
+func (c *coalesceRecorder) NewStep(id string, desc string) Closer {
+	c.flush()
+	closer := c.next.NewStep(id, desc)
+
+	return CloserFunc(func() {
+		c.flush()
+		closer.Close()
+	})
+}
+
+func (c *coalesceRecorder) Update(results ...result.Result) {
+	for _, r := range results {
+		r := r
+
+		if r.Severity == result.SeverityNone &&
+			c.last != nil && c.last.Text() == r.Text() {
+			c.repeats++
+			continue
+		}
+
+		c.flush()
+		c.next.Update(r)
+
+		// Only informational messages are coalesced, since each
+		// error is counted separately.
+		if r.Severity == result.SeverityNone {
+			c.last = &r
+		}
+	}
+}
+
+func (c *coalesceRecorder) RetryDocument() {
+	c.next.RetryDocument()
+}
+
+func (c *coalesceRecorder) setClock(when time.Time) {
+	setClock(c.next, when)
+}
+
+func (c *coalesceRecorder) captureDocument(runID string, resources interface{}) {
+	captureDocument(c.next, runID, resources)
+}
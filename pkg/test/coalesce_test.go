@@ -0,0 +1,67 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"testing"
+
+	"github.com/projectcontour/integration-tester/pkg/result"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoalesceRecorders(t *testing.T) {
+	r := &defaultRecorder{}
+	c := CoalesceRecorders(r)
+
+	docCloser := c.NewDocument("one.yaml")
+
+	stepCloser := c.NewStep("one.yaml#check", "first step")
+	c.Update(result.Infof("waiting"), result.Infof("waiting"))
+	c.Update(result.Infof("waiting"))
+	c.Update(result.Infof("ready"), result.Errorf("failed"), result.Errorf("failed"))
+	c.Update(result.Infof("ready"), result.Infof("ready"))
+	stepCloser.Close()
+
+	stepCloser = c.NewStep("one.yaml#cleanup", "second step")
+	c.Update(result.Infof("ready"))
+	stepCloser.Close()
+
+	docCloser.Close()
+
+	require.Len(t, r.docs, 1)
+	require.Len(t, r.docs[0].Steps, 2)
+
+	messages := func(s *Step) []string {
+		var m []string
+		for _, r := range s.Results {
+			m = append(m, r.Message)
+		}
+		return m
+	}
+
+	assert.Equal(t, []string{
+		"waiting",
+		"(previous message repeated 2 more times)",
+		"ready",
+		"failed",
+		"failed",
+		"ready",
+		"(previous message repeated 1 more time)",
+	}, messages(r.docs[0].Steps[0]))
+
+	assert.Equal(t, []string{"ready"}, messages(r.docs[0].Steps[1]))
+}
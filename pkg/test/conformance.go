@@ -0,0 +1,315 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/projectcontour/integration-tester/pkg/doc"
+	"github.com/projectcontour/integration-tester/pkg/result"
+
+	"sigs.k8s.io/yaml"
+)
+
+// ConformanceReport is the Gateway API conformance report
+// (gateway.networking.k8s.io/v1alpha1 ConformanceReport).
+type ConformanceReport struct {
+	APIVersion        string                    `json:"apiVersion"`
+	Kind              string                    `json:"kind"`
+	Date              string                    `json:"date"`
+	Implementation    ConformanceImplementation `json:"implementation"`
+	GatewayAPIVersion string                    `json:"gatewayAPIVersion"`
+	GatewayAPIChannel string                    `json:"gatewayAPIChannel,omitempty"`
+	Profiles          []ConformanceProfile      `json:"profiles"`
+}
+
+// ConformanceImplementation identifies the implementation that a
+// conformance report is for.
+type ConformanceImplementation struct {
+	Organization string   `json:"organization"`
+	Project      string   `json:"project"`
+	URL          string   `json:"url"`
+	Version      string   `json:"version"`
+	Contact      []string `json:"contact"`
+}
+
+// ConformanceProfile reports the results of a conformance profile.
+type ConformanceProfile struct {
+	Name     string                     `json:"name"`
+	Core     ConformanceStatus          `json:"core"`
+	Extended *ConformanceExtendedStatus `json:"extended,omitempty"`
+}
+
+// ConformanceStatistics counts the conformance tests by result.
+type ConformanceStatistics struct {
+	Passed  int `json:"Passed"`
+	Skipped int `json:"Skipped"`
+	Failed  int `json:"Failed"`
+}
+
+// ConformanceStatus reports the results of the core or extended
+// conformance tests of a profile.
+type ConformanceStatus struct {
+	Result       string                `json:"result"`
+	Statistics   ConformanceStatistics `json:"statistics"`
+	FailedTests  []string              `json:"failedTests,omitempty"`
+	SkippedTests []string              `json:"skippedTests,omitempty"`
+}
+
+// ConformanceExtendedStatus reports the results of the extended
+// conformance tests of a profile, and which extended features the
+// implementation supports.
+type ConformanceExtendedStatus struct {
+	ConformanceStatus
+	SupportedFeatures   []string `json:"supportedFeatures,omitempty"`
+	UnsupportedFeatures []string `json:"unsupportedFeatures,omitempty"`
+}
+
+// coreConformanceFeatures are the Gateway API features that have
+// core support. A test document that only exercises core features
+// is reported as a core conformance test. Any other features are
+// extended.
+var coreConformanceFeatures = map[string]bool{
+	"Gateway":        true,
+	"HTTPRoute":      true,
+	"ReferenceGrant": true,
+}
+
+// DocumentConformanceFeatures returns the Gateway API conformance
+// features that a test document is tagged with by a
+// "$conformance-features:" comment in a Rego fragment, e.g.:
+//
+//	# $conformance-features: HTTPRoute, HTTPRouteQueryParamMatching
+func DocumentConformanceFeatures(d *doc.Document) ([]string, error) {
+	for _, p := range d.Parts {
+		if p.Type != doc.FragmentTypeModule {
+			continue
+		}
+
+		for _, c := range p.Rego().Comments {
+			text := strings.TrimSpace(string(c.Text))
+			if !strings.HasPrefix(text, "$conformance-features:") {
+				continue
+			}
+
+			var features []string
+			for _, f := range strings.Split(strings.TrimPrefix(text, "$conformance-features:"), ",") {
+				if f = strings.TrimSpace(f); f != "" {
+					features = append(features, f)
+				}
+			}
+
+			if len(features) == 0 {
+				return nil, fmt.Errorf("empty %q comment", "$conformance-features")
+			}
+
+			return features, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// ReadConformanceReport reads a YAML conformance report.
+func ReadConformanceReport(r io.Reader) (*ConformanceReport, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var report ConformanceReport
+	if err := yaml.UnmarshalStrict(data, &report); err != nil {
+		return nil, err
+	}
+
+	if report.Kind != "ConformanceReport" {
+		return nil, fmt.Errorf("unexpected report kind %q", report.Kind)
+	}
+
+	return &report, nil
+}
+
+// ConformanceWriter is a Recorder that collects the results of the
+// test documents that are tagged with conformance features, and
+// writes them as a Gateway API conformance report at the end of the
+// test run. Each tagged document is a conformance test. Documents
+// that aren't tagged are not reported.
+type ConformanceWriter struct {
+	JSONWriter
+
+	// Report holds the report metadata. If it already has
+	// profile results (e.g. from the upstream conformance
+	// suite), the results of the test documents are merged
+	// into them.
+	Report ConformanceReport
+
+	// Profile is the name of the profile that the test documents
+	// are reported under.
+	Profile string
+
+	// Features maps the description of each test document to
+	// its conformance features.
+	Features map[string][]string
+}
+
+var _ Recorder = &ConformanceWriter{}
+
+// Write writes the conformance report to w as YAML.
+func (c *ConformanceWriter) Write(w io.Writer) error {
+	report := c.Report
+	report.APIVersion = "gateway.networking.k8s.io/v1alpha1"
+	report.Kind = "ConformanceReport"
+	report.Date = c.now().UTC().Format("2006-01-02T15:04:05Z")
+
+	profile := ConformanceProfile{Name: c.Profile}
+	index := -1
+
+	for i, p := range report.Profiles {
+		if p.Name == c.Profile {
+			profile = p
+			index = i
+		}
+	}
+
+	// Copy the extended status so that we don't modify the
+	// imported report.
+	extended := ConformanceExtendedStatus{}
+	if profile.Extended != nil {
+		extended = *profile.Extended
+	}
+
+	profile.Extended = &extended
+
+	for _, status := range []*ConformanceStatus{&profile.Core, &profile.Extended.ConformanceStatus} {
+		status.FailedTests = append([]string{}, status.FailedTests...)
+		status.SkippedTests = append([]string{}, status.SkippedTests...)
+	}
+
+	supported := stringSet(profile.Extended.SupportedFeatures)
+	unsupported := stringSet(profile.Extended.UnsupportedFeatures)
+
+	for _, d := range c.Documents {
+		features, ok := c.Features[d.Description]
+		if d.Retried || !ok || len(features) == 0 {
+			continue
+		}
+
+		status := &profile.Extended.ConformanceStatus
+
+		core := true
+		for _, f := range features {
+			if !coreConformanceFeatures[f] {
+				core = false
+			}
+		}
+
+		if core {
+			status = &profile.Core
+		}
+
+		outcome := d.Outcome()
+		switch outcome {
+		case result.SeverityError:
+			status.Statistics.Failed++
+			status.FailedTests = append(status.FailedTests, d.Description)
+		case result.SeveritySkip:
+			status.Statistics.Skipped++
+			status.SkippedTests = append(status.SkippedTests, d.Description)
+		default:
+			status.Statistics.Passed++
+		}
+
+		if core {
+			continue
+		}
+
+		for _, f := range features {
+			if coreConformanceFeatures[f] {
+				continue
+			}
+
+			if outcome == result.SeveritySkip {
+				unsupported[f] = true
+			} else {
+				supported[f] = true
+			}
+		}
+	}
+
+	for f := range supported {
+		delete(unsupported, f)
+	}
+
+	profile.Extended.SupportedFeatures = sortedSet(supported)
+	profile.Extended.UnsupportedFeatures = sortedSet(unsupported)
+
+	profile.Core.Result = conformanceResult(profile.Core.Statistics)
+	profile.Extended.Result = conformanceResult(profile.Extended.Statistics)
+
+	if profile.Extended.Statistics == (ConformanceStatistics{}) &&
+		len(supported) == 0 && len(unsupported) == 0 {
+		profile.Extended = nil
+	}
+
+	if index < 0 {
+		report.Profiles = append(report.Profiles, profile)
+	} else {
+		report.Profiles = append([]ConformanceProfile{}, report.Profiles...)
+		report.Profiles[index] = profile
+	}
+
+	data, err := yaml.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+	return err
+}
+
+// conformanceResult returns the overall result for the statistics.
+func conformanceResult(s ConformanceStatistics) string {
+	switch {
+	case s.Failed > 0:
+		return "failure"
+	case s.Skipped > 0:
+		return "partial"
+	default:
+		return "success"
+	}
+}
+
+func stringSet(values []string) map[string]bool {
+	set := map[string]bool{}
+	for _, v := range values {
+		set[v] = true
+	}
+
+	return set
+}
+
+func sortedSet(set map[string]bool) []string {
+	var values []string
+	for v := range set {
+		values = append(values, v)
+	}
+
+	sort.Strings(values)
+	return values
+}
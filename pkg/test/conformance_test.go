@@ -0,0 +1,141 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/projectcontour/integration-tester/pkg/doc"
+	"github.com/projectcontour/integration-tester/pkg/result"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocumentConformanceFeatures(t *testing.T) {
+	d, err := doc.ReadDocument(strings.NewReader(`---
+# $conformance-features: HTTPRoute, HTTPRouteQueryParamMatching
+error[msg] { msg := "fail" }
+`))
+	require.NoError(t, err)
+
+	for i := range d.Parts {
+		_, err := d.Parts[i].Decode()
+		require.NoError(t, err)
+	}
+
+	features, err := DocumentConformanceFeatures(d)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"HTTPRoute", "HTTPRouteQueryParamMatching"}, features)
+}
+
+func TestConformanceWriter(t *testing.T) {
+	c := &ConformanceWriter{
+		Profile: "GATEWAY-HTTP",
+		Features: map[string][]string{
+			"core.yaml":     {"HTTPRoute"},
+			"query.yaml":    {"HTTPRoute", "HTTPRouteQueryParamMatching"},
+			"mirror.yaml":   {"HTTPRouteRequestMirror"},
+			"redirect.yaml": {"HTTPRouteSchemeRedirect"},
+		},
+	}
+
+	run := func(desc string, results ...result.Result) {
+		closer := c.NewDocument(desc)
+		step := c.NewStep("id", "step")
+		c.Update(results...)
+		step.Close()
+		closer.Close()
+	}
+
+	run("core.yaml", result.Infof("ok"))
+	run("query.yaml", result.Infof("ok"))
+	run("mirror.yaml", result.Skipf("mirroring not supported"))
+	run("redirect.yaml", result.Errorf("broken"))
+	run("untagged.yaml", result.Errorf("broken"))
+
+	var out bytes.Buffer
+	require.NoError(t, c.Write(&out))
+
+	report, err := ReadConformanceReport(&out)
+	require.NoError(t, err)
+	require.Len(t, report.Profiles, 1)
+
+	p := report.Profiles[0]
+	assert.Equal(t, "GATEWAY-HTTP", p.Name)
+	assert.Equal(t, "success", p.Core.Result)
+	assert.Equal(t, ConformanceStatistics{Passed: 1}, p.Core.Statistics)
+
+	require.NotNil(t, p.Extended)
+	assert.Equal(t, "failure", p.Extended.Result)
+	assert.Equal(t, ConformanceStatistics{Passed: 1, Skipped: 1, Failed: 1}, p.Extended.Statistics)
+	assert.Equal(t, []string{"redirect.yaml"}, p.Extended.FailedTests)
+	assert.Equal(t, []string{"mirror.yaml"}, p.Extended.SkippedTests)
+	assert.Equal(t, []string{"HTTPRouteQueryParamMatching", "HTTPRouteSchemeRedirect"}, p.Extended.SupportedFeatures)
+	assert.Equal(t, []string{"HTTPRouteRequestMirror"}, p.Extended.UnsupportedFeatures)
+}
+
+func TestConformanceWriterImport(t *testing.T) {
+	base, err := ReadConformanceReport(strings.NewReader(`
+apiVersion: gateway.networking.k8s.io/v1alpha1
+kind: ConformanceReport
+date: "2023-10-01T00:00:00Z"
+implementation:
+  organization: projectcontour
+  project: contour
+  url: https://projectcontour.io
+  version: v1.28.0
+  contact: []
+gatewayAPIVersion: v1.0.0
+profiles:
+- name: GATEWAY-HTTP
+  core:
+    result: success
+    statistics:
+      Passed: 30
+      Skipped: 0
+      Failed: 0
+`))
+	require.NoError(t, err)
+
+	c := &ConformanceWriter{
+		Report:   *base,
+		Profile:  "GATEWAY-HTTP",
+		Features: map[string][]string{"core.yaml": {"Gateway"}},
+	}
+
+	closer := c.NewDocument("core.yaml")
+	step := c.NewStep("id", "step")
+	c.Update(result.Errorf("broken"))
+	step.Close()
+	closer.Close()
+
+	var out bytes.Buffer
+	require.NoError(t, c.Write(&out))
+
+	report, err := ReadConformanceReport(&out)
+	require.NoError(t, err)
+	require.Len(t, report.Profiles, 1)
+
+	assert.Equal(t, "contour", report.Implementation.Project)
+	assert.Equal(t, "failure", report.Profiles[0].Core.Result)
+	assert.Equal(t, ConformanceStatistics{Passed: 30, Failed: 1}, report.Profiles[0].Core.Statistics)
+	assert.Nil(t, report.Profiles[0].Extended)
+
+	// The imported report is not modified.
+	assert.Equal(t, 0, base.Profiles[0].Core.Statistics.Failed)
+}
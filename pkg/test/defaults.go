@@ -29,8 +29,10 @@ func DefaultObjectCheckForOperation(op driver.ObjectOperationType) *ast.Module {
 	var name string
 
 	switch op {
-	case driver.ObjectOperationUpdate:
+	case driver.ObjectOperationUpdate, driver.ObjectOperationPatch:
 		name = "pkg/builtin/objectUpdateCheck.rego"
+	case driver.ObjectOperationCreate:
+		name = "pkg/builtin/objectCreateCheck.rego"
 	case driver.ObjectOperationDelete:
 		name = "pkg/builtin/objectDeleteCheck.rego"
 	}
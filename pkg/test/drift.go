@@ -0,0 +1,215 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/projectcontour/integration-tester/pkg/result"
+)
+
+const (
+	// TransitionFailed is the transition of a test document that
+	// used to pass (or has never run) to failing.
+	TransitionFailed = "failed"
+
+	// TransitionRecovered is the transition of a failing test
+	// document to passing.
+	TransitionRecovered = "recovered"
+)
+
+// DriftState is the last known state of a test document that is run
+// repeatedly against a long-lived cluster.
+type DriftState struct {
+	// Outcome is SeverityError if the document failed on its
+	// last run, and SeverityNone if it passed.
+	Outcome result.Severity `json:"outcome"`
+
+	// Since is the start of the run that first had the outcome.
+	Since time.Time `json:"since"`
+
+	// LastGood is the last run in which the document passed.
+	LastGood *HistoryEntry `json:"lastGood,omitempty"`
+
+	// FailedSteps are the steps that failed on the last run.
+	FailedSteps []string `json:"failedSteps,omitempty"`
+}
+
+// Transition is a change in the outcome of a test document.
+type Transition struct {
+	Test        string        `json:"test"`
+	Transition  string        `json:"transition"`
+	RunID       string        `json:"runID,omitempty"`
+	Start       time.Time     `json:"start"`
+	LastGood    *HistoryEntry `json:"lastGood,omitempty"`
+	FailedSteps []string      `json:"failedSteps,omitempty"`
+}
+
+// DriftTracker keeps the state of each test document across repeated
+// runs, so that only the changes in their outcomes are reported.
+type DriftTracker struct {
+	States map[string]*DriftState `json:"states"`
+}
+
+// NewDriftTracker returns a DriftTracker with no known states.
+func NewDriftTracker() *DriftTracker {
+	return &DriftTracker{States: map[string]*DriftState{}}
+}
+
+// ReadDriftTracker reads the tracker state from the file at path. If
+// the file doesn't exist, the tracker has no known states.
+func ReadDriftTracker(path string) (*DriftTracker, error) {
+	data, err := ioutil.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		return NewDriftTracker(), nil
+	case err != nil:
+		return nil, err
+	}
+
+	d := NewDriftTracker()
+	if err := json.Unmarshal(data, d); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if d.States == nil {
+		d.States = map[string]*DriftState{}
+	}
+
+	return d, nil
+}
+
+// WriteFile writes the tracker state to the file at path.
+func (d *DriftTracker) WriteFile(path string) error {
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, append(data, '\n'), 0644) //nolint:gosec
+}
+
+// Observe updates the state of each test document from the outcome
+// of its last attempt in a run, and returns the transitions, sorted
+// by test. A document that fails on its first observed run is a new
+// failure. Skipped documents don't change state, since they don't
+// show whether the cluster still works.
+func (d *DriftTracker) Observe(entries []HistoryEntry) []Transition {
+	// Only the last attempt of a retried document counts.
+	last := map[string]HistoryEntry{}
+	for _, e := range entries {
+		if prev, ok := last[e.Test]; !ok || e.Retry >= prev.Retry {
+			last[e.Test] = e
+		}
+	}
+
+	var transitions []Transition
+
+	for name, e := range last {
+		state, known := d.States[name]
+		if !known {
+			state = &DriftState{Outcome: result.SeverityNone, Since: e.Start}
+			d.States[name] = state
+		}
+
+		switch e.Outcome {
+		case result.SeverityNone:
+			entry := e
+			entry.FailedSteps = nil
+
+			if state.Outcome == result.SeverityError {
+				transitions = append(transitions, Transition{
+					Test:       name,
+					Transition: TransitionRecovered,
+					RunID:      e.RunID,
+					Start:      e.Start,
+					LastGood:   state.LastGood,
+				})
+
+				state.Since = e.Start
+			}
+
+			state.Outcome = result.SeverityNone
+			state.LastGood = &entry
+			state.FailedSteps = nil
+
+		case result.SeverityError:
+			if state.Outcome != result.SeverityError {
+				transitions = append(transitions, Transition{
+					Test:        name,
+					Transition:  TransitionFailed,
+					RunID:       e.RunID,
+					Start:       e.Start,
+					LastGood:    state.LastGood,
+					FailedSteps: e.FailedSteps,
+				})
+
+				state.Since = e.Start
+			}
+
+			state.Outcome = result.SeverityError
+			state.FailedSteps = e.FailedSteps
+		}
+	}
+
+	sort.Slice(transitions, func(i, j int) bool {
+		return transitions[i].Test < transitions[j].Test
+	})
+
+	return transitions
+}
+
+// NotifyTransitions posts the transitions to the webhook at url, as a
+// JSON object with a "transitions" array.
+func NotifyTransitions(ctx context.Context, client *http.Client, url string, transitions []Transition) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	body, err := json.Marshal(struct {
+		Transitions []Transition `json:"transitions"`
+	}{transitions})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("POST %s: %s", url, resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/projectcontour/integration-tester/pkg/result"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDriftTrackerObserve(t *testing.T) {
+	d := NewDriftTracker()
+	t0 := time.Date(2020, 11, 2, 10, 0, 0, 0, time.UTC)
+
+	entry := func(test string, outcome result.Severity, n int) HistoryEntry {
+		e := HistoryEntry{Test: test, Start: t0.Add(time.Duration(n) * time.Minute), Outcome: outcome}
+		if outcome == result.SeverityError {
+			e.FailedSteps = []string{test + "#check"}
+		}
+		return e
+	}
+
+	// The first run only reports failures.
+	transitions := d.Observe([]HistoryEntry{
+		entry("a.yaml", result.SeverityNone, 0),
+		entry("b.yaml", result.SeverityError, 0),
+		entry("c.yaml", result.SeveritySkip, 0),
+	})
+	require.Len(t, transitions, 1)
+	assert.Equal(t, "b.yaml", transitions[0].Test)
+	assert.Equal(t, TransitionFailed, transitions[0].Transition)
+	assert.Nil(t, transitions[0].LastGood)
+
+	// An unchanged outcome is not reported again.
+	transitions = d.Observe([]HistoryEntry{
+		entry("a.yaml", result.SeverityNone, 1),
+		entry("b.yaml", result.SeverityError, 1),
+	})
+	assert.Empty(t, transitions)
+
+	// Only the last attempt of a retried document counts.
+	retried := entry("a.yaml", result.SeverityNone, 2)
+	retried.Retry = 1
+
+	transitions = d.Observe([]HistoryEntry{
+		entry("a.yaml", result.SeverityError, 2),
+		retried,
+		entry("b.yaml", result.SeverityNone, 2),
+	})
+	require.Len(t, transitions, 1)
+	assert.Equal(t, "b.yaml", transitions[0].Test)
+	assert.Equal(t, TransitionRecovered, transitions[0].Transition)
+
+	transitions = d.Observe([]HistoryEntry{
+		entry("a.yaml", result.SeverityError, 3),
+		entry("c.yaml", result.SeveritySkip, 3),
+	})
+	require.Len(t, transitions, 1)
+	assert.Equal(t, TransitionFailed, transitions[0].Transition)
+	require.NotNil(t, transitions[0].LastGood)
+	assert.Equal(t, t0.Add(2*time.Minute), transitions[0].LastGood.Start)
+	assert.Equal(t, []string{"a.yaml#check"}, transitions[0].FailedSteps)
+
+	assert.Equal(t, result.SeverityError, d.States["a.yaml"].Outcome)
+	assert.Equal(t, t0.Add(3*time.Minute), d.States["a.yaml"].Since)
+	assert.Equal(t, result.SeverityNone, d.States["c.yaml"].Outcome)
+}
+
+func TestDriftTrackerFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "drift")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "state.json")
+
+	d, err := ReadDriftTracker(path)
+	require.NoError(t, err)
+	assert.Empty(t, d.States)
+
+	d.Observe([]HistoryEntry{{Test: "a.yaml", Outcome: result.SeverityError}})
+	require.NoError(t, d.WriteFile(path))
+
+	d, err = ReadDriftTracker(path)
+	require.NoError(t, err)
+	assert.Equal(t, result.SeverityError, d.States["a.yaml"].Outcome)
+
+	// A restarted tracker doesn't report the same failure again.
+	assert.Empty(t, d.Observe([]HistoryEntry{{Test: "a.yaml", Outcome: result.SeverityError}}))
+}
+
+func TestNotifyTransitions(t *testing.T) {
+	var got struct {
+		Transitions []Transition `json:"transitions"`
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	transitions := []Transition{{Test: "a.yaml", Transition: TransitionFailed}}
+
+	require.NoError(t, NotifyTransitions(context.Background(), srv.Client(), srv.URL, transitions))
+	assert.Equal(t, transitions, got.Transitions)
+
+	assert.Error(t, NotifyTransitions(context.Background(), srv.Client(), srv.URL+"/fail", transitions))
+}
@@ -0,0 +1,158 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/projectcontour/integration-tester/pkg/doc"
+	"github.com/projectcontour/integration-tester/pkg/driver"
+	"github.com/projectcontour/integration-tester/pkg/must"
+	"github.com/projectcontour/integration-tester/pkg/result"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/rego"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Evaluator evaluates ad hoc Rego queries and modules against a
+// Rego data document that is populated from the cluster in the same
+// way as it is for a test run. This is useful when developing new
+// checks, since the data that the checks see can be inspected
+// without running a whole test document.
+type Evaluator struct {
+	tc       testContext
+	compiler *ast.Compiler
+	cancel   func()
+}
+
+// NewEvaluator starts informers for the watched resources (see
+// WatchResourceOpt), waits for them to sync and compiles the builtin
+// and policy modules (see RegoModuleOpt). The caller must Close the
+// Evaluator to stop the informers.
+func NewEvaluator(opts ...RunOpt) (*Evaluator, error) {
+	e := &Evaluator{
+		tc: testContext{
+			regoDriver: driver.NewRegoDriver(),
+			namespace:  metav1.NamespaceDefault,
+		},
+	}
+
+	for _, o := range opts {
+		o(&e.tc)
+	}
+
+	tc := &e.tc
+
+	if tc.objectDriver == nil {
+		return nil, fmt.Errorf("missing Kubernetes object driver")
+	}
+
+	e.cancel = tc.objectDriver.Watch(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(o interface{}) {
+			if u, ok := o.(*unstructured.Unstructured); ok && e.watching(u) {
+				must.Must(storeResource(tc.kubeDriver, tc.regoDriver, tc.namespace, u))
+			}
+		}, UpdateFunc: func(oldObj interface{}, newObj interface{}) {
+			if u, ok := newObj.(*unstructured.Unstructured); ok && e.watching(u) {
+				must.Must(storeResource(tc.kubeDriver, tc.regoDriver, tc.namespace, u))
+			}
+		}, DeleteFunc: func(o interface{}) {
+			if u, ok := o.(*unstructured.Unstructured); ok && e.watching(u) {
+				must.Must(removeResource(tc.kubeDriver, tc.regoDriver, tc.namespace, u))
+			}
+		},
+	})
+
+	for _, gvr := range tc.watchedResources {
+		tc.objectDriver.InformOn(gvr)
+	}
+
+	if err := tc.objectDriver.WaitForCacheSync(5 * time.Minute); err != nil {
+		e.Close()
+		return nil, err
+	}
+
+	if err := storeInformerStatus(tc.regoDriver, tc.objectDriver.InformerStatus()); err != nil {
+		e.Close()
+		return nil, err
+	}
+
+	if _, err := storeResourceVersions(tc.kubeDriver, tc.regoDriver); err != nil {
+		e.Close()
+		return nil, err
+	}
+
+	tc.regoDriver.StoreItem("/test/params/dry-run", false)
+	tc.regoDriver.StoreItem("/test/params/namespace", tc.namespace)
+
+	compiler, err := compileDocument(&doc.Document{}, tc.policyModules, tc.capabilities)
+	if err != nil {
+		e.Close()
+		return nil, err
+	}
+
+	e.compiler = compiler
+	return e, nil
+}
+
+// watching returns whether the object should be stored. If any
+// namespaces were given with WatchNamespacesOpt, namespaced objects
+// in other namespaces are ignored.
+func (e *Evaluator) watching(u *unstructured.Unstructured) bool {
+	ns := u.GetNamespace()
+	if ns == "" || len(e.tc.watchedNamespaces) == 0 {
+		return true
+	}
+
+	for _, n := range e.tc.watchedNamespaces {
+		if n == ns {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Query evaluates a Rego query and returns the result set. The
+// query can refer to any of the builtin or policy packages.
+func (e *Evaluator) Query(query string) (rego.ResultSet, error) {
+	body, err := ast.ParseBody(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.tc.regoDriver.Query(body, rego.Compiler(e.compiler))
+}
+
+// Eval evaluates the check rules of a module in the same way that
+// the check fragments of a test document are evaluated. The module
+// must have been given to NewEvaluator with RegoModuleOpt.
+func (e *Evaluator) Eval(m *ast.Module) ([]result.Result, error) {
+	return e.tc.regoDriver.Eval(m, rego.Compiler(e.compiler))
+}
+
+// Close stops the Evaluator informers.
+func (e *Evaluator) Close() {
+	if e.cancel != nil {
+		e.cancel()
+		e.cancel = nil
+	}
+
+	e.tc.objectDriver.Done()
+}
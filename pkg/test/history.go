@@ -0,0 +1,200 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/projectcontour/integration-tester/pkg/result"
+)
+
+// HistoryEntry is the outcome of one attempt of a test document,
+// as it is appended to a history file. Entries are keyed by the
+// test document description, which is stable across runs.
+type HistoryEntry struct {
+	Test        string          `json:"test"`
+	RunID       string          `json:"runID,omitempty"`
+	Start       time.Time       `json:"start"`
+	Duration    time.Duration   `json:"duration"`
+	Outcome     result.Severity `json:"outcome"`
+	Retry       int             `json:"retry,omitempty"`
+	FailedSteps []string        `json:"failedSteps,omitempty"`
+}
+
+// HistoryEntries returns a history entry for each attempt of each
+// test document that the JSONWriter recorded.
+func HistoryEntries(j *JSONWriter) []HistoryEntry {
+	entries := make([]HistoryEntry, 0, len(j.Documents))
+
+	for _, d := range j.Documents {
+		e := HistoryEntry{
+			Test:     d.Description,
+			RunID:    j.RunID,
+			Start:    d.Start,
+			Duration: d.Duration,
+			Outcome:  d.Outcome(),
+			Retry:    d.Retries,
+		}
+
+		for _, s := range d.Steps {
+			if s.Outcome() == result.SeverityError {
+				e.FailedSteps = append(e.FailedSteps, s.ID)
+			}
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries
+}
+
+// AppendHistory appends the entries to the history file at path,
+// one JSON object per line. The file is created if it doesn't exist.
+func AppendHistory(path string, entries []HistoryEntry) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644) //nolint:gosec
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(f)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			f.Close() // nolint:gosec
+			return err
+		}
+	}
+
+	return f.Close()
+}
+
+// ReadHistory reads the entries of a history file.
+func ReadHistory(r io.Reader) ([]HistoryEntry, error) {
+	var entries []HistoryEntry
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var e HistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		entries = append(entries, e)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// HistoryStats summarizes the history of a single test.
+type HistoryStats struct {
+	Test    string
+	Runs    int
+	Passed  int
+	Failed  int
+	Skipped int
+
+	// AverageDuration is the average duration of the attempts
+	// that were not skipped.
+	AverageDuration time.Duration
+
+	// RecentFailures are the most recent failed attempts, most
+	// recent first.
+	RecentFailures []HistoryEntry
+}
+
+// PassRate returns the fraction of the attempts that were not
+// skipped that passed.
+func (h *HistoryStats) PassRate() float64 {
+	if h.Passed+h.Failed == 0 {
+		return 0
+	}
+
+	return float64(h.Passed) / float64(h.Passed+h.Failed)
+}
+
+// Flaky returns whether the test has both passed and failed.
+func (h *HistoryStats) Flaky() bool {
+	return h.Passed > 0 && h.Failed > 0
+}
+
+// SummarizeHistory returns the statistics of each test in the
+// history entries, sorted by test. At most recent failures are
+// kept for each test.
+func SummarizeHistory(entries []HistoryEntry, recent int) []*HistoryStats {
+	byTest := map[string]*HistoryStats{}
+	total := map[string]time.Duration{}
+
+	// Sort the entries so that the most recent failures come first.
+	sorted := make([]HistoryEntry, len(entries))
+	copy(sorted, entries)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Start.After(sorted[j].Start)
+	})
+
+	for _, e := range sorted {
+		h, ok := byTest[e.Test]
+		if !ok {
+			h = &HistoryStats{Test: e.Test}
+			byTest[e.Test] = h
+		}
+
+		h.Runs++
+
+		switch e.Outcome {
+		case result.SeverityError:
+			h.Failed++
+			if len(h.RecentFailures) < recent {
+				h.RecentFailures = append(h.RecentFailures, e)
+			}
+		case result.SeveritySkip:
+			h.Skipped++
+			continue
+		default:
+			h.Passed++
+		}
+
+		total[e.Test] += e.Duration
+	}
+
+	stats := make([]*HistoryStats, 0, len(byTest))
+	for name, h := range byTest {
+		if n := h.Passed + h.Failed; n > 0 {
+			h.AverageDuration = total[name] / time.Duration(n)
+		}
+
+		stats = append(stats, h)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Test < stats[j].Test
+	})
+
+	return stats
+}
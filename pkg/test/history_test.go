@@ -0,0 +1,100 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/projectcontour/integration-tester/pkg/result"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistoryRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	start := time.Date(2020, 11, 2, 10, 0, 0, 0, time.UTC)
+	j := &JSONWriter{
+		RunID: "nightly-1",
+		Documents: []*JSONDocument{{
+			Description: "one.yaml",
+			Start:       start,
+			Duration:    time.Second,
+			Retried:     true,
+			Steps: []JSONStep{{
+				ID:      "one.yaml#0:check",
+				Results: []JSONResult{{Severity: result.SeverityError}},
+			}},
+		}, {
+			Description: "one.yaml",
+			Start:       start.Add(time.Minute),
+			Duration:    3 * time.Second,
+			Retries:     1,
+		}},
+	}
+
+	path := filepath.Join(dir, "history.jsonl")
+	require.NoError(t, AppendHistory(path, HistoryEntries(j)))
+	require.NoError(t, AppendHistory(path, HistoryEntries(j)))
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	entries, err := ReadHistory(f)
+	require.NoError(t, err)
+	require.Len(t, entries, 4)
+
+	assert.Equal(t, result.SeverityError, entries[0].Outcome)
+	assert.Equal(t, []string{"one.yaml#0:check"}, entries[0].FailedSteps)
+	assert.Equal(t, 1, entries[1].Retry)
+	assert.Equal(t, "nightly-1", entries[1].RunID)
+}
+
+func TestSummarizeHistory(t *testing.T) {
+	start := time.Date(2020, 11, 2, 10, 0, 0, 0, time.UTC)
+	entries := []HistoryEntry{
+		{Test: "b", Start: start, Duration: time.Second, Outcome: result.SeverityNone},
+		{Test: "a", Start: start, Duration: time.Second, Outcome: result.SeverityError, RunID: "1"},
+		{Test: "a", Start: start.Add(time.Hour), Duration: 3 * time.Second, Outcome: result.SeverityNone},
+		{Test: "a", Start: start.Add(2 * time.Hour), Duration: 2 * time.Second, Outcome: result.SeverityError, RunID: "3"},
+		{Test: "a", Start: start.Add(3 * time.Hour), Outcome: result.SeveritySkip},
+	}
+
+	stats := SummarizeHistory(entries, 1)
+	require.Len(t, stats, 2)
+
+	a := stats[0]
+	assert.Equal(t, "a", a.Test)
+	assert.Equal(t, 4, a.Runs)
+	assert.Equal(t, 1, a.Passed)
+	assert.Equal(t, 2, a.Failed)
+	assert.Equal(t, 1, a.Skipped)
+	assert.Equal(t, 2*time.Second, a.AverageDuration)
+	assert.InDelta(t, 1.0/3.0, a.PassRate(), 0.001)
+	assert.True(t, a.Flaky())
+	require.Len(t, a.RecentFailures, 1)
+	assert.Equal(t, "3", a.RecentFailures[0].RunID)
+
+	assert.False(t, stats[1].Flaky())
+	assert.Equal(t, 1.0, stats[1].PassRate())
+}
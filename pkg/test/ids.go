@@ -0,0 +1,94 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/projectcontour/integration-tester/pkg/doc"
+)
+
+// StepID returns the stable identifier of a test step. The ID is
+// derived from the structure of the test document rather than from
+// the step description, so that it doesn't change when the document
+// is edited and can be used to track test steps over time. The
+// fragment is empty for steps that apply to the whole document.
+func StepID(docName string, fragment string, action string) string {
+	if fragment == "" {
+		return fmt.Sprintf("%s#%s", docName, action)
+	}
+
+	return fmt.Sprintf("%s#%s:%s", docName, fragment, action)
+}
+
+// FragmentIDs returns a stable identifier for each part of the
+// document. Kubernetes objects that have a name are identified by
+// their lower-cased kind and name, e.g. "deployment/echo". Other
+// fragments are identified by their index in the document. If a
+// name is repeated, the later fragments are numbered, e.g.
+// "deployment/echo.2".
+func FragmentIDs(d *doc.Document) []string {
+	ids := make([]string, len(d.Parts))
+	seen := map[string]int{}
+
+	for i := range d.Parts {
+		id := fmt.Sprint(i)
+
+		if obj := d.Parts[i].Object(); obj != nil && obj.GetName() != "" {
+			id = fmt.Sprintf("%s/%s", strings.ToLower(obj.GetKind()), obj.GetName())
+		}
+
+		seen[id]++
+		if n := seen[id]; n > 1 {
+			id = fmt.Sprintf("%s.%d", id, n)
+		}
+
+		ids[i] = id
+	}
+
+	return ids
+}
+
+// ItemIDs returns a stable identifier for each item of a List
+// fragment. Items that have a name are identified like a fragment for
+// the same object, e.g. "configmap/echo". Other items are identified
+// by the fragment ID and their index in the list, e.g. "3[0]". Items
+// whose name would repeat an earlier item, or any of the document
+// fragment IDs in taken, are also identified by their index, so that
+// every step ID stays unique.
+func ItemIDs(fragmentID string, items []doc.Fragment, taken []string) []string {
+	ids := make([]string, len(items))
+	seen := make(map[string]bool, len(taken)+len(items))
+
+	for _, id := range taken {
+		seen[id] = true
+	}
+
+	for i := range items {
+		ids[i] = fmt.Sprintf("%s[%d]", fragmentID, i)
+
+		if obj := items[i].Object(); obj != nil && obj.GetName() != "" {
+			id := fmt.Sprintf("%s/%s", strings.ToLower(obj.GetKind()), obj.GetName())
+			if !seen[id] {
+				ids[i] = id
+			}
+		}
+
+		seen[ids[i]] = true
+	}
+
+	return ids
+}
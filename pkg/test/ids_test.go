@@ -0,0 +1,117 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/projectcontour/integration-tester/pkg/doc"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStepID(t *testing.T) {
+	assert.Equal(t, "a.yaml#compile", StepID("a.yaml", "", "compile"))
+	assert.Equal(t, "a.yaml#service/echo:update", StepID("a.yaml", "service/echo", "update"))
+}
+
+func TestFragmentIDs(t *testing.T) {
+	d, err := doc.ReadDocument(strings.NewReader(`---
+# Empty fragment.
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: echo
+---
+error[msg] {
+  msg := "fail"
+}
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: echo
+$apply: delete
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  labels:
+    app: echo
+`))
+	require.NoError(t, err)
+
+	for i := range d.Parts {
+		_, err := d.Parts[i].Decode()
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t,
+		[]string{"0", "service/echo", "2", "service/echo.2", "4"},
+		FragmentIDs(d))
+}
+
+func TestItemIDs(t *testing.T) {
+	f := doc.Fragment{Bytes: []byte(`
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: echo
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    generateName: echo-
+`)}
+
+	_, err := f.Decode()
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"configmap/echo", "3[1]"}, ItemIDs("3", f.Items(), nil))
+
+	// Items for the same object as an earlier item or a document
+	// fragment are identified by their index.
+	f = doc.Fragment{Bytes: []byte(`
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Service
+  metadata:
+    name: echo
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: echo
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: echo
+    labels:
+      app: echo
+`)}
+
+	_, err = f.Decode()
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		[]string{"2[0]", "configmap/echo", "2[2]"},
+		ItemIDs("2", f.Items(), []string{"0", "service/echo", "2"}))
+}
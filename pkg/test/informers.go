@@ -0,0 +1,95 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/projectcontour/integration-tester/pkg/driver"
+	"github.com/projectcontour/integration-tester/pkg/result"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// informerKey returns the name that the informer for the given
+// resource is published under, in the "resource.version.group"
+// form that kubectl uses.
+func informerKey(gvr schema.GroupVersionResource) string {
+	return strings.TrimSuffix(
+		fmt.Sprintf("%s.%s.%s", gvr.Resource, gvr.Version, gvr.Group), ".")
+}
+
+// storeInformerStatus publishes the sync status of each informer to
+// '/test/informers/$KEY', where the key is from informerKey.
+func storeInformerStatus(c driver.RegoDriver, status map[schema.GroupVersionResource]bool) error {
+	informers := map[string]interface{}{}
+
+	for gvr, synced := range status {
+		informers[informerKey(gvr)] = map[string]interface{}{
+			"group":    gvr.Group,
+			"version":  gvr.Version,
+			"resource": gvr.Resource,
+			"synced":   synced,
+		}
+	}
+
+	return storeItem(c, "/test/informers", informers)
+}
+
+// syncInformers waits for up to timeout for any informers that have
+// not synced yet, such as the informers started for the kind of an
+// object that was just applied. This stops checks from evaluating
+// before the informer caches are populated. The sync status of each
+// of those informers is recorded as an Info result, and the status of
+// all the informers is published to 'data.test.informers'.
+func syncInformers(tc *testContext, timeout time.Duration) error {
+	var pending []schema.GroupVersionResource
+
+	for gvr, synced := range tc.objectDriver.InformerStatus() {
+		if !synced {
+			pending = append(pending, gvr)
+		}
+	}
+
+	sort.Slice(pending, func(i, j int) bool {
+		return informerKey(pending[i]) < informerKey(pending[j])
+	})
+
+	if len(pending) > 0 {
+		start := time.Now()
+
+		// A sync timeout isn't fatal, since checks can
+		// still converge when the informer catches up.
+		_ = tc.objectDriver.WaitForCacheSync(timeout)
+
+		elapsed := time.Since(start).Round(time.Millisecond)
+		status := tc.objectDriver.InformerStatus()
+
+		for _, gvr := range pending {
+			if status[gvr] {
+				tc.recorder.Update(result.Infof(
+					"informer for %s synced in %s", informerKey(gvr), elapsed))
+			} else {
+				tc.recorder.Update(result.Infof(
+					"informer for %s did not sync within %s", informerKey(gvr), timeout))
+			}
+		}
+	}
+
+	return storeInformerStatus(tc.regoDriver, tc.objectDriver.InformerStatus())
+}
@@ -0,0 +1,61 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"github.com/projectcontour/integration-tester/pkg/driver"
+)
+
+// CheckInput is the Rego input document for test checks. Both the
+// checks on Kubernetes object fragments and standalone Rego module
+// fragments are evaluated with this input. For object checks, the
+// operation result is the result of applying the object. For module
+// fragments, it is the result of the most recent object operation in
+// the test document, and the operation fields are absent if there
+// hasn't been one.
+type CheckInput struct {
+	*driver.OperationResult
+
+	// Step describes the test step that is evaluating the check.
+	Step CheckStep `json:"step"`
+}
+
+// CheckStep describes the test step that evaluates a check.
+type CheckStep struct {
+	ID       string `json:"id"`
+	Document string `json:"document"`
+	Fragment string `json:"fragment"`
+	RunID    string `json:"run_id"`
+}
+
+// InputField describes a field of the check input document.
+type InputField struct {
+	Name        string
+	Description string
+}
+
+// DescribeCheckInput returns a description of each field of the
+// check input document.
+func DescribeCheckInput() []InputField {
+	return []InputField{
+		{"input.error", "Kubernetes API status of the last object operation, if it failed"},
+		{"input.latest", "Latest version of the object from the last object operation"},
+		{"input.target", "Reference to the object of the last object operation"},
+		{"input.step.id", "Stable ID of the test step that evaluates the check"},
+		{"input.step.document", "Name of the test document"},
+		{"input.step.fragment", "ID of the test document fragment that contains the check"},
+		{"input.step.run_id", "Test run ID that the Kubernetes objects are annotated with"},
+	}
+}
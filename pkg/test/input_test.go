@@ -0,0 +1,64 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/projectcontour/integration-tester/pkg/driver"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckInputFields(t *testing.T) {
+	step := CheckStep{
+		ID:       StepID("one.yaml", "0", "check"),
+		Document: "one.yaml",
+		Fragment: "0",
+		RunID:    "run",
+	}
+
+	decode := func(in *CheckInput) map[string]interface{} {
+		data, err := json.Marshal(in)
+		require.NoError(t, err)
+
+		fields := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(data, &fields))
+		return fields
+	}
+
+	// Without an operation, only the step is present.
+	fields := decode(&CheckInput{Step: step})
+	assert.Len(t, fields, 1)
+	assert.Equal(t, map[string]interface{}{
+		"id":       "one.yaml#0:check",
+		"document": "one.yaml",
+		"fragment": "0",
+		"run_id":   "run",
+	}, fields["step"])
+
+	// The operation result fields are inlined.
+	fields = decode(&CheckInput{OperationResult: &driver.OperationResult{}, Step: step})
+	assert.Contains(t, fields, "error")
+	assert.Contains(t, fields, "latest")
+	assert.Contains(t, fields, "target")
+	assert.Contains(t, fields, "step")
+
+	for _, f := range DescribeCheckInput() {
+		assert.NotEmpty(t, f.Description, f.Name)
+	}
+}
@@ -0,0 +1,284 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/projectcontour/integration-tester/pkg/result"
+)
+
+// JSONResult is the JSON representation of a result.Result. Rule
+// and Value are set for results that were raised by a Rego rule.
+type JSONResult struct {
+	Severity  result.Severity `json:"severity"`
+	Scope     result.Scope    `json:"scope,omitempty"`
+	Rule      string          `json:"rule,omitempty"`
+	Message   string          `json:"message"`
+	Value     interface{}     `json:"value,omitempty"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// Text formats the result message as text, in the same way as
+// result.Result.Text.
+func (r JSONResult) Text() string {
+	return result.Result{Rule: r.Rule, Message: r.Message}.Text()
+}
+
+// JSONObject is the JSON representation of an object that a test
+// document successfully operated on. The runner records it as the
+// Value of an informational result.
+type JSONObject struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+	Operation  string `json:"operation"`
+	Step       string `json:"step,omitempty"`
+}
+
+// JSONStep is the JSON representation of a Step. The duration is
+// in nanoseconds.
+type JSONStep struct {
+	ID          string                 `json:"id"`
+	Description string                 `json:"description"`
+	Start       time.Time              `json:"start"`
+	End         time.Time              `json:"end"`
+	Duration    time.Duration          `json:"duration"`
+	Results     []JSONResult           `json:"results"`
+	Diagnostics map[string]interface{} `json:"diagnostics,omitempty"`
+}
+
+// JSONDocument is the JSON representation of a Document. Retried
+// is set if the document was a failed attempt that was retried,
+// and Retries counts the failed attempts that preceded it.
+type JSONDocument struct {
+	Description string        `json:"description"`
+	Start       time.Time     `json:"start"`
+	End         time.Time     `json:"end"`
+	Duration    time.Duration `json:"duration"`
+	Retried     bool          `json:"retried,omitempty"`
+	Retries     int           `json:"retries,omitempty"`
+	Steps       []JSONStep    `json:"steps"`
+	Objects     []JSONObject  `json:"objects,omitempty"`
+}
+
+// Outcome returns SeverityError if the step failed, SeveritySkip
+// if it skipped the rest of the document, and SeverityNone if it
+// passed.
+func (s *JSONStep) Outcome() result.Severity {
+	outcome := result.SeverityNone
+
+	for _, r := range s.Results {
+		switch r.Severity {
+		case result.SeverityError, result.SeverityFatal:
+			return result.SeverityError
+		case result.SeveritySkip:
+			outcome = result.SeveritySkip
+		}
+	}
+
+	return outcome
+}
+
+// Outcome returns SeverityError if the document failed,
+// SeveritySkip if it was skipped, and SeverityNone if it passed.
+func (d *JSONDocument) Outcome() result.Severity {
+	outcome := result.SeverityNone
+
+	for _, s := range d.Steps {
+		for _, r := range s.Results {
+			switch r.Severity {
+			case result.SeverityError, result.SeverityFatal:
+				return result.SeverityError
+			case result.SeveritySkip:
+				if (result.Result{Severity: r.Severity, Scope: r.Scope}).IsTerminal() {
+					outcome = result.SeveritySkip
+				}
+			}
+		}
+	}
+
+	return outcome
+}
+
+// JSONResults is the JSON object that JSONWriter writes for a
+// test run.
+type JSONResults struct {
+	RunID     string            `json:"runID,omitempty"`
+	Failed    bool              `json:"failed"`
+	Policies  map[string]string `json:"policies,omitempty"`
+	Documents []*JSONDocument   `json:"documents"`
+}
+
+// ReadJSONResults reads the results of a test run that were
+// written by JSONWriter.
+func ReadJSONResults(r io.Reader) (*JSONResults, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var results JSONResults
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, err
+	}
+
+	return &results, nil
+}
+
+// JSONWriter is a Recorder that collects the full tree of test
+// documents, steps and results, and writes it as a single JSON
+// object at the end of the test run.
+type JSONWriter struct {
+	clock
+
+	// RunID is the test run ID, if the run ID was given
+	// for the whole test run.
+	RunID string
+
+	// Policies maps the path of each Rego policy file that the
+	// test run loaded to its content digest.
+	Policies map[string]string
+
+	Documents []*JSONDocument
+
+	currentDoc  *JSONDocument
+	currentStep *JSONStep
+	nextRetries int
+}
+
+var _ Recorder = &JSONWriter{}
+
+// ShouldContinue ...
+func (j *JSONWriter) ShouldContinue() bool {
+	return true
+}
+
+// Failed ...
+func (j *JSONWriter) Failed() bool {
+	return false
+}
+
+// NewDocument ...
+func (j *JSONWriter) NewDocument(desc string) Closer {
+	doc := &JSONDocument{
+		Description: desc,
+		Start:       j.now(),
+		Retries:     j.nextRetries,
+		Steps:       []JSONStep{},
+	}
+
+	j.nextRetries = 0
+	j.currentDoc = doc
+	j.Documents = append(j.Documents, doc)
+
+	return CloserFunc(func() {
+		doc.End = j.now()
+		doc.Duration = doc.End.Sub(doc.Start)
+		j.currentDoc = nil
+	})
+}
+
+// NewStep ...
+func (j *JSONWriter) NewStep(id string, desc string) Closer {
+	j.currentStep = &JSONStep{
+		ID:          id,
+		Description: desc,
+		Start:       j.now(),
+		Results:     []JSONResult{},
+	}
+
+	return CloserFunc(func() {
+		s := j.currentStep
+		s.End = j.now()
+		s.Duration = s.End.Sub(s.Start)
+
+		j.currentDoc.Steps = append(j.currentDoc.Steps, *s)
+		j.currentStep = nil
+	})
+}
+
+// Update ...
+func (j *JSONWriter) Update(results ...result.Result) {
+	for _, r := range results {
+		if o, ok := r.Value.(JSONObject); ok && j.currentDoc != nil {
+			o.Step = j.currentStep.ID
+			j.currentDoc.Objects = append(j.currentDoc.Objects, o)
+		}
+
+		j.currentStep.Results = append(j.currentStep.Results, JSONResult{
+			Severity:  r.Severity,
+			Scope:     r.Scope,
+			Rule:      r.Rule,
+			Message:   r.Message,
+			Value:     r.Value,
+			Timestamp: r.Timestamp,
+		})
+	}
+}
+
+// RetryDocument ...
+func (j *JSONWriter) RetryDocument() {
+	if n := len(j.Documents); n > 0 {
+		j.Documents[n-1].Retried = true
+		j.nextRetries = j.Documents[n-1].Retries + 1
+	}
+}
+
+// Write writes the test documents to w as a single JSON object.
+func (j *JSONWriter) Write(w io.Writer) error {
+	failed := false
+
+	for _, d := range j.Documents {
+		if d.Retried {
+			continue
+		}
+
+		for _, s := range d.Steps {
+			for _, r := range s.Results {
+				if (result.Result{Severity: r.Severity}).IsFailed() {
+					failed = true
+				}
+			}
+		}
+	}
+
+	documents := j.Documents
+	if documents == nil {
+		documents = []*JSONDocument{}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	out := map[string]interface{}{
+		"failed":    failed,
+		"documents": documents,
+	}
+
+	if j.RunID != "" {
+		out["runID"] = j.RunID
+	}
+
+	if len(j.Policies) > 0 {
+		out["policies"] = j.Policies
+	}
+
+	return enc.Encode(out)
+}
@@ -0,0 +1,214 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/projectcontour/integration-tester/pkg/result"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONWriter(t *testing.T) {
+	j := &JSONWriter{}
+
+	docCloser := j.NewDocument("one.yaml")
+	stepCloser := j.NewStep("one.yaml#compile", "compiling")
+	j.Update(result.Infof("info"))
+	stepCloser.Close()
+	stepCloser = j.NewStep("one.yaml#0:check", "checking")
+	j.Update(result.Errorf("failed"))
+	stepCloser.Close()
+	docCloser.Close()
+
+	buf := bytes.Buffer{}
+	require.NoError(t, j.Write(&buf))
+
+	var out struct {
+		Failed    bool `json:"failed"`
+		Documents []struct {
+			Description string `json:"description"`
+			Steps       []struct {
+				ID       string `json:"id"`
+				Duration int64  `json:"duration"`
+				Results  []struct {
+					Severity string `json:"severity"`
+					Message  string `json:"message"`
+				} `json:"results"`
+			} `json:"steps"`
+		} `json:"documents"`
+	}
+
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &out))
+
+	assert.True(t, out.Failed)
+	require.Len(t, out.Documents, 1)
+	assert.Equal(t, "one.yaml", out.Documents[0].Description)
+	require.Len(t, out.Documents[0].Steps, 2)
+	assert.Equal(t, "one.yaml#0:check", out.Documents[0].Steps[1].ID)
+	assert.Equal(t, "Error", out.Documents[0].Steps[1].Results[0].Severity)
+	assert.Equal(t, "failed", out.Documents[0].Steps[1].Results[0].Message)
+	assert.True(t, out.Documents[0].Steps[0].Duration >= 0)
+}
+
+func TestJSONWriterRunID(t *testing.T) {
+	var out map[string]interface{}
+
+	buf := bytes.Buffer{}
+	require.NoError(t, (&JSONWriter{}).Write(&buf))
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &out))
+	assert.NotContains(t, out, "runID")
+
+	buf.Reset()
+	require.NoError(t, (&JSONWriter{RunID: "github-12345"}).Write(&buf))
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &out))
+	assert.Equal(t, "github-12345", out["runID"])
+}
+
+func TestJSONWriterPolicies(t *testing.T) {
+	var out map[string]interface{}
+
+	buf := bytes.Buffer{}
+	require.NoError(t, (&JSONWriter{}).Write(&buf))
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &out))
+	assert.NotContains(t, out, "policies")
+
+	buf.Reset()
+	require.NoError(t, (&JSONWriter{
+		Policies: map[string]string{"lib/k8s.rego": "sha256:abcd"},
+	}).Write(&buf))
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &out))
+	assert.Equal(t,
+		map[string]interface{}{"lib/k8s.rego": "sha256:abcd"},
+		out["policies"])
+}
+
+func TestJSONWriterRetries(t *testing.T) {
+	j := &JSONWriter{}
+
+	run := func(results ...result.Result) {
+		docCloser := j.NewDocument("one.yaml")
+		stepCloser := j.NewStep("one.yaml#0:check", "checking")
+		j.Update(results...)
+		stepCloser.Close()
+		docCloser.Close()
+	}
+
+	run(result.Errorf("failed"))
+	j.RetryDocument()
+	run(result.Infof("passed"))
+
+	buf := bytes.Buffer{}
+	require.NoError(t, j.Write(&buf))
+
+	var out struct {
+		Failed    bool `json:"failed"`
+		Documents []struct {
+			Retried bool `json:"retried"`
+			Retries int  `json:"retries"`
+		} `json:"documents"`
+	}
+
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &out))
+
+	assert.False(t, out.Failed)
+	require.Len(t, out.Documents, 2)
+	assert.True(t, out.Documents[0].Retried)
+	assert.Equal(t, 0, out.Documents[0].Retries)
+	assert.False(t, out.Documents[1].Retried)
+	assert.Equal(t, 1, out.Documents[1].Retries)
+}
+
+func TestJSONWriterRuleResult(t *testing.T) {
+	j := &JSONWriter{}
+
+	docCloser := j.NewDocument("one.yaml")
+	stepCloser := j.NewStep("one.yaml#0:check", "checking")
+	j.Update(result.Result{
+		Severity: result.SeverityError,
+		Rule:     "error",
+		Message:  "this is the error",
+		Value:    map[string]interface{}{"msg": "this is the error"},
+	})
+	stepCloser.Close()
+	docCloser.Close()
+
+	buf := bytes.Buffer{}
+	require.NoError(t, j.Write(&buf))
+
+	var out struct {
+		Documents []struct {
+			Steps []struct {
+				Results []map[string]interface{} `json:"results"`
+			} `json:"steps"`
+		} `json:"documents"`
+	}
+
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &out))
+
+	res := out.Documents[0].Steps[0].Results[0]
+	assert.Equal(t, "error", res["rule"])
+	assert.Equal(t, "this is the error", res["message"])
+	assert.Equal(t, map[string]interface{}{"msg": "this is the error"}, res["value"])
+}
+
+func TestJSONWriterObjects(t *testing.T) {
+	j := &JSONWriter{RunID: "github-12345"}
+
+	docCloser := j.NewDocument("one.yaml")
+	stepCloser := j.NewStep("one.yaml#service/echo:update", "updating")
+	j.Update(result.Result{
+		Severity: result.SeverityNone,
+		Message:  "update v1:Service 'default/echo' succeeded",
+		Value: JSONObject{
+			APIVersion: "v1",
+			Kind:       "Service",
+			Namespace:  "default",
+			Name:       "echo",
+			Operation:  "update",
+		},
+	})
+	stepCloser.Close()
+	stepCloser = j.NewStep("one.yaml#service/echo:check", "checking")
+	j.Update(result.Skipf("skipped"))
+	stepCloser.Close()
+	docCloser.Close()
+
+	buf := bytes.Buffer{}
+	require.NoError(t, j.Write(&buf))
+
+	out, err := ReadJSONResults(&buf)
+	require.NoError(t, err)
+
+	assert.Equal(t, "github-12345", out.RunID)
+	assert.False(t, out.Failed)
+	require.Len(t, out.Documents, 1)
+	assert.Equal(t, []JSONObject{{
+		APIVersion: "v1",
+		Kind:       "Service",
+		Namespace:  "default",
+		Name:       "echo",
+		Operation:  "update",
+		Step:       "one.yaml#service/echo:update",
+	}}, out.Documents[0].Objects)
+
+	assert.Equal(t, result.SeverityNone, out.Documents[0].Steps[0].Outcome())
+	assert.Equal(t, result.SeveritySkip, out.Documents[0].Steps[1].Outcome())
+	assert.Equal(t, result.SeveritySkip, out.Documents[0].Outcome())
+}
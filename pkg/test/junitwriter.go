@@ -0,0 +1,202 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/projectcontour/integration-tester/pkg/result"
+)
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Error     *junitFailure `xml:"error,omitempty"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Errors     int             `xml:"errors,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	TestCases  []junitTestCase `xml:"testcase"`
+}
+
+type junitTestSuites struct {
+	XMLName    xml.Name         `xml:"testsuites"`
+	Name       string           `xml:"name,attr,omitempty"`
+	TestSuites []junitTestSuite `xml:"testsuite"`
+}
+
+// JUnitWriter is a Recorder that collects the full tree of test
+// documents, steps and results, and writes it as a JUnit XML report
+// at the end of the test run. Each test document is reported as a
+// test suite, and each of its steps as a test case named by the
+// step ID. Fatal results are reported as errors, other failing
+// results as failures. Policy digests are reported as properties
+// of each test suite. Retried documents are omitted, and the number
+// of retries of the final attempt is reported as a property.
+type JUnitWriter struct {
+	JSONWriter
+}
+
+var _ Recorder = &JUnitWriter{}
+
+// Write writes the test documents to w as a JUnit XML report.
+func (j *JUnitWriter) Write(w io.Writer) error {
+	report := junitTestSuites{Name: j.RunID}
+
+	var policies []junitProperty
+	for path, digest := range j.Policies {
+		policies = append(policies, junitProperty{
+			Name:  "policy:" + path,
+			Value: digest,
+		})
+	}
+
+	sort.Slice(policies, func(i, k int) bool {
+		return policies[i].Name < policies[k].Name
+	})
+
+	for _, d := range j.Documents {
+		if d.Retried {
+			continue
+		}
+
+		suite := junitTestSuite{
+			Name:       d.Description,
+			Time:       junitSeconds(d.Duration.Seconds()),
+			Timestamp:  d.Start.UTC().Format("2006-01-02T15:04:05"),
+			Properties: policies,
+		}
+
+		if d.Retries > 0 {
+			suite.Properties = append(policies[:len(policies):len(policies)], junitProperty{
+				Name:  "retries",
+				Value: fmt.Sprint(d.Retries),
+			})
+		}
+
+		for _, s := range d.Steps {
+			suite.TestCases = append(suite.TestCases, junitCase(d.Description, s))
+			suite.Tests++
+
+			c := suite.TestCases[len(suite.TestCases)-1]
+			switch {
+			case c.Error != nil:
+				suite.Errors++
+			case c.Failure != nil:
+				suite.Failures++
+			case c.Skipped != nil:
+				suite.Skipped++
+			}
+		}
+
+		report.TestSuites = append(report.TestSuites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// junitCase converts a test step to a JUnit test case.
+func junitCase(class string, s JSONStep) junitTestCase {
+	c := junitTestCase{
+		Name:      s.ID,
+		ClassName: class,
+		Time:      junitSeconds(s.Duration.Seconds()),
+	}
+
+	var output []string
+	var errors []string
+	var failures []string
+
+	for _, r := range s.Results {
+		output = append(output, fmt.Sprintf("%s: %s", r.Severity, r.Text()))
+
+		switch r.Severity {
+		case result.SeverityFatal:
+			errors = append(errors, r.Text())
+		case result.SeverityError:
+			failures = append(failures, r.Text())
+		case result.SeveritySkip:
+			if c.Skipped == nil {
+				c.Skipped = &junitSkipped{Message: firstLine(r.Text())}
+			}
+		}
+	}
+
+	if len(errors) > 0 {
+		c.Error = &junitFailure{
+			Message: firstLine(errors[0]),
+			Type:    string(result.SeverityFatal),
+			Text:    strings.Join(errors, "\n"),
+		}
+	}
+
+	if len(failures) > 0 {
+		c.Failure = &junitFailure{
+			Message: firstLine(failures[0]),
+			Type:    string(result.SeverityError),
+			Text:    strings.Join(failures, "\n"),
+		}
+	}
+
+	c.SystemOut = strings.Join(output, "\n")
+	return c
+}
+
+// junitSeconds formats a duration in seconds for a JUnit report.
+func junitSeconds(s float64) string {
+	return fmt.Sprintf("%.3f", s)
+}
@@ -0,0 +1,105 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+
+	"github.com/projectcontour/integration-tester/pkg/result"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJUnitWriter(t *testing.T) {
+	j := &JUnitWriter{}
+
+	docCloser := j.NewDocument("one.yaml")
+	stepCloser := j.NewStep("one.yaml#compile", "compiling")
+	j.Update(result.Infof("info"))
+	stepCloser.Close()
+	stepCloser = j.NewStep("one.yaml#0:check", "checking")
+	j.Update(result.Errorf("failed\nsecond line"))
+	stepCloser.Close()
+	stepCloser = j.NewStep("one.yaml#1:check", "checking")
+	j.Update(result.Fatalf("fatal"))
+	stepCloser.Close()
+	docCloser.Close()
+
+	docCloser = j.NewDocument("two.yaml")
+	stepCloser = j.NewStep("two.yaml#compile", "compiling")
+	j.Update(result.Skipf("skipped"))
+	stepCloser.Close()
+	docCloser.Close()
+
+	buf := bytes.Buffer{}
+	require.NoError(t, j.Write(&buf))
+
+	var out junitTestSuites
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &out))
+
+	require.Len(t, out.TestSuites, 2)
+
+	one := out.TestSuites[0]
+	assert.Equal(t, "one.yaml", one.Name)
+	assert.Equal(t, 3, one.Tests)
+	assert.Equal(t, 1, one.Failures)
+	assert.Equal(t, 1, one.Errors)
+	assert.Equal(t, 0, one.Skipped)
+
+	require.Len(t, one.TestCases, 3)
+	assert.Equal(t, "one.yaml#compile", one.TestCases[0].Name)
+	assert.Equal(t, "one.yaml", one.TestCases[0].ClassName)
+	assert.Nil(t, one.TestCases[0].Failure)
+	assert.Equal(t, "None: info", one.TestCases[0].SystemOut)
+	require.NotNil(t, one.TestCases[1].Failure)
+	assert.Equal(t, "failed", one.TestCases[1].Failure.Message)
+	assert.Equal(t, "failed\nsecond line", one.TestCases[1].Failure.Text)
+	require.NotNil(t, one.TestCases[2].Error)
+	assert.Equal(t, "fatal", one.TestCases[2].Error.Message)
+
+	two := out.TestSuites[1]
+	assert.Equal(t, 1, two.Skipped)
+	require.NotNil(t, two.TestCases[0].Skipped)
+	assert.Equal(t, "skipped", two.TestCases[0].Skipped.Message)
+}
+
+func TestJUnitWriterPolicies(t *testing.T) {
+	j := &JUnitWriter{JSONWriter: JSONWriter{
+		Policies: map[string]string{
+			"lib/two.rego": "sha256:2222",
+			"lib/one.rego": "sha256:1111",
+		},
+	}}
+
+	docCloser := j.NewDocument("one.yaml")
+	stepCloser := j.NewStep("one.yaml#compile", "compiling")
+	stepCloser.Close()
+	docCloser.Close()
+
+	buf := bytes.Buffer{}
+	require.NoError(t, j.Write(&buf))
+
+	var out junitTestSuites
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &out))
+
+	require.Len(t, out.TestSuites, 1)
+	assert.Equal(t, []junitProperty{
+		{Name: "policy:lib/one.rego", Value: "sha256:1111"},
+		{Name: "policy:lib/two.rego", Value: "sha256:2222"},
+	}, out.TestSuites[0].Properties)
+}
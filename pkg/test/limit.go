@@ -0,0 +1,157 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/projectcontour/integration-tester/pkg/result"
+)
+
+// ResultLimits bounds the size of the results that are recorded
+// for each test step. A zero limit is unlimited.
+type ResultLimits struct {
+	// MaxMessageSize is the maximum size in bytes of a result
+	// message. Longer messages are truncated.
+	MaxMessageSize int
+
+	// MaxStepResults is the maximum number of results that are
+	// recorded for a step. Further results are dropped, and
+	// counted when the step is closed.
+	MaxStepResults int
+}
+
+// DefaultResultLimits are the result limits that are applied
+// unless configured otherwise.
+var DefaultResultLimits = ResultLimits{
+	MaxMessageSize: 64 * 1024,
+	MaxStepResults: 1000,
+}
+
+// LimitRecorders returns a new Recorder that applies the limits to
+// the results it passes to next. When a step drops results, a single
+// result that counts the dropped results is recorded when the step
+// is closed. That result has the highest severity of the dropped
+// results, so that dropping results never hides a failure.
+func LimitRecorders(next Recorder, limits ResultLimits) Recorder {
+	return &limitRecorder{next: next, limits: limits}
+}
+
+type limitRecorder struct {
+	next   Recorder
+	limits ResultLimits
+
+	count    int
+	dropped  int
+	severity result.Severity
+}
+
+var _ Recorder = &limitRecorder{}
+
+// severityRank orders severities so that the most severe dropped
+// result can be reported.
+var severityRank = map[result.Severity]int{
+	result.SeverityNone:  0,
+	result.SeverityPass:  0,
+	result.SeveritySkip:  1,
+	result.SeverityError: 2,
+	result.SeverityFatal: 3,
+}
+
+func (l *limitRecorder) ShouldContinue() bool {
+	return l.next.ShouldContinue()
+}
+
+func (l *limitRecorder) Failed() bool {
+	return l.next.Failed()
+}
+
+func (l *limitRecorder) NewDocument(desc string) Closer {
+	return l.next.NewDocument(desc)
+}
+
+func (l *limitRecorder) NewStep(id string, desc string) Closer {
+	l.reset()
+	closer := l.next.NewStep(id, desc)
+
+	return CloserFunc(func() {
+		if l.dropped > 0 {
+			r := result.Infof("(%d more results dropped, limit is %d results per step)",
+				l.dropped, l.limits.MaxStepResults)
+			r.Severity = l.severity
+
+			l.next.Update(r)
+		}
+
+		l.reset()
+		closer.Close()
+	})
+}
+
+func (l *limitRecorder) RetryDocument() {
+	l.next.RetryDocument()
+}
+
+func (l *limitRecorder) setClock(when time.Time) {
+	setClock(l.next, when)
+}
+
+func (l *limitRecorder) captureDocument(runID string, resources interface{}) {
+	captureDocument(l.next, runID, resources)
+}
+
+func (l *limitRecorder) Update(results ...result.Result) {
+	for _, r := range results {
+		if l.limits.MaxStepResults > 0 && l.count >= l.limits.MaxStepResults {
+			l.dropped++
+
+			if severityRank[r.Severity] > severityRank[l.severity] {
+				l.severity = r.Severity
+			}
+
+			continue
+		}
+
+		l.count++
+		l.next.Update(truncateResult(r, l.limits.MaxMessageSize))
+	}
+}
+
+func (l *limitRecorder) reset() {
+	l.count = 0
+	l.dropped = 0
+	l.severity = result.SeverityNone
+}
+
+// truncateResult truncates the result message to the given size,
+// appending an indicator of how much was removed.
+func truncateResult(r result.Result, size int) result.Result {
+	if size <= 0 || len(r.Message) <= size {
+		return r
+	}
+
+	// Don't split a multi-byte UTF-8 sequence.
+	end := size
+	for end > 0 && !utf8.RuneStart(r.Message[end]) {
+		end--
+	}
+
+	r.Message = fmt.Sprintf("%s... (truncated %d bytes)",
+		r.Message[:end], len(r.Message)-end)
+
+	return r
+}
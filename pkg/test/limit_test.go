@@ -0,0 +1,68 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/projectcontour/integration-tester/pkg/result"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimitRecorders(t *testing.T) {
+	r := &defaultRecorder{}
+	l := LimitRecorders(r, ResultLimits{MaxMessageSize: 8, MaxStepResults: 2})
+
+	docCloser := l.NewDocument("one.yaml")
+
+	stepCloser := l.NewStep("one.yaml#check", "first step")
+	l.Update(result.Infof("short"), result.Infof("this message is long"))
+	l.Update(result.Infof("dropped"), result.Errorf("dropped"), result.Infof("dropped"))
+	stepCloser.Close()
+
+	stepCloser = l.NewStep("one.yaml#cleanup", "second step")
+	l.Update(result.Infof("one"), result.Infof("two"), result.Infof("three"))
+	stepCloser.Close()
+
+	docCloser.Close()
+
+	require.Len(t, r.docs, 1)
+	require.Len(t, r.docs[0].Steps, 2)
+
+	first := r.docs[0].Steps[0].Results
+	require.Len(t, first, 3)
+	assert.Equal(t, "short", first[0].Message)
+	assert.Equal(t, "this mes... (truncated 12 bytes)", first[1].Message)
+	assert.Equal(t, result.SeverityError, first[2].Severity)
+	assert.Equal(t, "(3 more results dropped, limit is 2 results per step)", first[2].Message)
+
+	// Limits are per step, and the dropped count only inherits
+	// the severity of the results that were dropped.
+	second := r.docs[0].Steps[1].Results
+	require.Len(t, second, 3)
+	assert.Equal(t, result.SeverityNone, second[2].Severity)
+	assert.True(t, r.Failed())
+}
+
+func TestTruncateResultUTF8(t *testing.T) {
+	r := truncateResult(result.Infof("日本語"), 4)
+	assert.Equal(t, "日... (truncated 6 bytes)", r.Message)
+
+	r = truncateResult(result.Infof(strings.Repeat("x", 10)), 0)
+	assert.Equal(t, strings.Repeat("x", 10), r.Message)
+}
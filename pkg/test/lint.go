@@ -0,0 +1,216 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"fmt"
+
+	"github.com/projectcontour/integration-tester/pkg/doc"
+	"github.com/projectcontour/integration-tester/pkg/driver"
+	"github.com/projectcontour/integration-tester/pkg/utils"
+
+	"github.com/open-policy-agent/opa/ast"
+)
+
+// LintProblem is a problem in a test document that would stop it
+// from running. Warnings are problems that don't stop the document
+// from running, but that probably make it less useful.
+type LintProblem struct {
+	// Location is the "file:line" location of the problem.
+	Location string `json:"location"`
+	Message  string `json:"message"`
+	Warning  bool   `json:"warning,omitempty"`
+}
+
+func (p LintProblem) String() string {
+	if p.Warning {
+		return fmt.Sprintf("%s: warning: %s", p.Location, p.Message)
+	}
+
+	return fmt.Sprintf("%s: %s", p.Location, p.Message)
+}
+
+// Lint checks a test document for problems without contacting a
+// cluster. It decodes every fragment, hydrates the Kubernetes
+// objects (resolving any fixtures), and compiles all the Rego in
+// the document together with the policy modules. Only the
+// RegoModuleOpt and RegoCapabilitiesOpt options are used.
+//
+// Unlike Preflight, Lint expects that the document fragments have
+// not been decoded yet, so that it can report all the fragments that
+// fail to decode.
+func Lint(testDoc *doc.Document, opts ...RunOpt) []LintProblem {
+	var problems []LintProblem
+
+	tc := testContext{
+		regoDriver: driver.NewRegoDriver(),
+	}
+
+	for _, o := range opts {
+		o(&tc)
+	}
+
+	env := driver.NewEnvironment(driver.OfflineOpt(), driver.EnvVarsOpt(tc.envVars))
+
+	problem := func(p *doc.Fragment, format string, args ...interface{}) {
+		problems = append(problems, LintProblem{
+			Location: fragmentLine(testDoc, p, 1),
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	// Object checks are compiled separately, since they
+	// aren't part of the document compilation.
+	var checks []*ast.Module
+	var checkFragments []*doc.Fragment
+
+	for i := range testDoc.Parts {
+		p := &testDoc.Parts[i]
+
+		fragType, err := p.Decode()
+		if err != nil {
+			if regoErr := utils.AsRegoCompilationErr(err); regoErr != nil {
+				problems = append(problems, regoProblems(testDoc, p, regoErr)...)
+			} else {
+				problem(p, "%s", err)
+			}
+
+			continue
+		}
+
+		switch fragType {
+		case doc.FragmentTypeObject:
+			obj, err := env.HydrateObject(p.Bytes)
+			if err != nil {
+				problem(p, "failed to hydrate object: %s", err)
+				continue
+			}
+
+			if obj.Check != nil {
+				checks = append(checks, obj.Check)
+				checkFragments = append(checkFragments, p)
+			}
+
+		case doc.FragmentTypeModule:
+			if _, err := moduleCondition(p.Rego()); err != nil {
+				problem(p, "%s", err)
+			}
+
+			if _, err := moduleSnapshot(p.Rego()); err != nil {
+				problem(p, "%s", err)
+			}
+
+			if _, err := moduleSkipScope(p.Rego()); err != nil {
+				problem(p, "%s", err)
+			}
+
+			if _, err := moduleDelay(p.Rego()); err != nil {
+				problem(p, "%s", err)
+			}
+
+			if _, err := checkTimeout(p.Rego(), 0); err != nil {
+				problem(p, "%s", err)
+			}
+		}
+	}
+
+	// Compiling needs all the fragments to have decoded.
+	if len(problems) > 0 {
+		return problems
+	}
+
+	if _, err := documentCheckInterval(testDoc); err != nil {
+		problems = append(problems, LintProblem{
+			Location: testDoc.Name,
+			Message:  err.Error(),
+		})
+	}
+
+	compile := func(p *doc.Fragment, modules []*ast.Module) bool {
+		_, err := compileDocument(testDoc, modules, tc.capabilities)
+		switch {
+		case err == nil:
+			return true
+		case utils.AsRegoCompilationErr(err) != nil:
+			problems = append(problems, regoProblems(testDoc, p, utils.AsRegoCompilationErr(err))...)
+		case p != nil:
+			problem(p, "%s", err)
+		default:
+			problems = append(problems, LintProblem{
+				Location: testDoc.Name,
+				Message:  err.Error(),
+			})
+		}
+
+		return false
+	}
+
+	if !compile(nil, tc.policyModules) {
+		return problems
+	}
+
+	for i, check := range checks {
+		modules := append([]*ast.Module{check}, tc.policyModules...)
+		compile(checkFragments[i], modules)
+	}
+
+	return problems
+}
+
+// fragmentLine returns the "file:line" location of the given line
+// (counting from 1) of the fragment.
+func fragmentLine(testDoc *doc.Document, p *doc.Fragment, line int) string {
+	return fmt.Sprintf("%s:%d", testDoc.Name, p.Location.Start+line-1)
+}
+
+// regoProblems converts Rego errors to lint problems. Errors in
+// document fragments are located by their line in the document. If
+// p is not nil, other errors are located at p. Otherwise, they are
+// located by their line in their module file.
+func regoProblems(testDoc *doc.Document, p *doc.Fragment, errs ast.Errors) []LintProblem {
+	var problems []LintProblem
+
+	for _, e := range errs {
+		problem := LintProblem{
+			Location: testDoc.Name,
+			Message:  fmt.Sprintf("%s: %s", e.Code, e.Message),
+		}
+
+		if p != nil {
+			problem.Location = fragmentLine(testDoc, p, 1)
+		}
+
+		if e.Location != nil {
+			if p == nil {
+				problem.Location = fmt.Sprintf("%s:%d", e.Location.File, e.Location.Row)
+			}
+
+			for i := range testDoc.Parts {
+				f := &testDoc.Parts[i]
+				if e.Location.File == f.Location.String() {
+					// Check fragments are parsed with a
+					// leading package line, so row 2 is
+					// the first line of the fragment.
+					problem.Location = fragmentLine(testDoc, f, e.Location.Row-1)
+					break
+				}
+			}
+		}
+
+		problems = append(problems, problem)
+	}
+
+	return problems
+}
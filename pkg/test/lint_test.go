@@ -0,0 +1,164 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/projectcontour/integration-tester/pkg/doc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func lintDocument(t *testing.T, data string) []LintProblem {
+	t.Helper()
+
+	d, err := doc.ReadDocument(strings.NewReader(data))
+	require.NoError(t, err)
+
+	d.Name = "test.yaml"
+	return Lint(d)
+}
+
+func TestLintValid(t *testing.T) {
+	problems := lintDocument(t, `---
+apiVersion: v1
+kind: Service
+metadata:
+  name: echo
+$check: |
+  error[msg] {
+    msg := "fail"
+  }
+---
+# $when: data.test.params.tls == "true"
+error_no_echo[msg] {
+  not data.resources.services.echo
+  msg := "no echo service"
+}
+`)
+	assert.Empty(t, problems)
+}
+
+func TestLintSyntaxError(t *testing.T) {
+	problems := lintDocument(t, `---
+apiVersion: v1
+kind: Service
+metadata:
+  name: echo
+---
+error[msg] {
+  msg := "fail"
+  }}
+}
+`)
+	require.NotEmpty(t, problems)
+	assert.Equal(t, "test.yaml:9", problems[0].Location)
+}
+
+func TestLintCompileError(t *testing.T) {
+	problems := lintDocument(t, `---
+error[msg] {
+  msg := unknown
+}
+`)
+	require.Len(t, problems, 1)
+	assert.Equal(t, "test.yaml:3", problems[0].Location)
+	assert.Contains(t, problems[0].Message, "unknown")
+}
+
+func TestLintObjectCheckError(t *testing.T) {
+	problems := lintDocument(t, `---
+apiVersion: v1
+kind: Service
+metadata:
+  name: echo
+$check: |
+  error[msg] {
+    msg := unknown
+  }
+`)
+	require.Len(t, problems, 1)
+	assert.Equal(t, "test.yaml:2", problems[0].Location)
+}
+
+func TestLintMissingFixture(t *testing.T) {
+	problems := lintDocument(t, `---
+apiVersion: v1
+kind: Service
+metadata:
+  name: no-such-fixture
+$apply: fixture
+`)
+	require.Len(t, problems, 1)
+	assert.Equal(t, "test.yaml:2", problems[0].Location)
+	assert.Contains(t, problems[0].Message, "failed to match fixture")
+}
+
+func TestLintWhenError(t *testing.T) {
+	problems := lintDocument(t, `---
+# $when: data.test.params.tls ==
+error[msg] {
+  msg := "fail"
+}
+`)
+	require.Len(t, problems, 1)
+	assert.Contains(t, problems[0].Message, "$when")
+}
+
+func TestLintSnapshotError(t *testing.T) {
+	problems := lintDocument(t, `---
+# $store-snapshot: not-a-name
+error[msg] {
+  msg := "fail"
+}
+`)
+	require.Len(t, problems, 1)
+	assert.Contains(t, problems[0].Message, "$store-snapshot")
+}
+
+func TestLintSkipScopeError(t *testing.T) {
+	problems := lintDocument(t, `---
+# $skip-scope: everything
+skip[msg] {
+  msg := "skip"
+}
+`)
+	require.Len(t, problems, 1)
+	assert.Contains(t, problems[0].Message, "$skip-scope")
+}
+
+func TestLintDelayError(t *testing.T) {
+	problems := lintDocument(t, `---
+# $delay: soon
+error[msg] {
+  msg := "fail"
+}
+`)
+	require.Len(t, problems, 1)
+	assert.Contains(t, problems[0].Message, "$delay")
+}
+
+func TestLintTimeoutError(t *testing.T) {
+	problems := lintDocument(t, `---
+# $timeout: 0s
+error[msg] {
+  msg := "fail"
+}
+`)
+	require.Len(t, problems, 1)
+	assert.Contains(t, problems[0].Message, "$timeout")
+}
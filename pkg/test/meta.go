@@ -0,0 +1,114 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/projectcontour/integration-tester/pkg/doc"
+	"github.com/projectcontour/integration-tester/pkg/driver"
+	"github.com/projectcontour/integration-tester/pkg/result"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilversion "k8s.io/apimachinery/pkg/util/version"
+)
+
+// applyDocumentMeta applies the front-matter of the test document to
+// the test context. The document is skipped if the cluster does not
+// meet any of its requirements.
+func applyDocumentMeta(tc *testContext, meta doc.Meta) {
+	if meta.Description != "" {
+		tc.recorder.Update(result.Infof("%s", meta.Description))
+	}
+
+	if len(meta.Tags) > 0 {
+		tc.recorder.Update(result.Infof("document tags: %s", strings.Join(meta.Tags, ", ")))
+	}
+
+	if meta.CheckTimeout > 0 {
+		tc.checkTimeout = meta.CheckTimeout
+	}
+
+	if len(meta.Requires) > 0 {
+		checkRequirements(tc, meta.Requires)
+	}
+}
+
+// checkRequirements records a Skip result for the first requirement
+// that the cluster doesn't meet. Kinds are checked against the
+// versions that were stored at '/resources/$RESOURCE/.versions', so
+// the check sees the same cluster as the document's Rego does.
+func checkRequirements(tc *testContext, requires []doc.Requirement) {
+	kinds, err := storedResourceVersions(tc.regoDriver)
+	if err != nil {
+		tc.recorder.Update(result.Fatalf("failed to read resource versions: %s", err))
+		return
+	}
+
+	var serverVersion *utilversion.Version
+
+	for _, r := range requires {
+		if r.MinVersion != nil && serverVersion == nil {
+			serverVersion, err = tc.kubeDriver.ServerVersion()
+			if err != nil {
+				tc.recorder.Update(result.Fatalf("failed to query Kubernetes version: %s", err))
+				return
+			}
+		}
+
+		if !r.Satisfied(serverVersion, kinds) {
+			if r.MinVersion != nil {
+				tc.recorder.Update(result.Skipf(
+					"requires %s, but the cluster is running %s", r, serverVersion))
+			} else {
+				tc.recorder.Update(result.Skipf(
+					"requires %s, which the cluster does not serve", r))
+			}
+
+			return
+		}
+	}
+}
+
+// storedResourceVersions returns all the resource versions that were
+// stored by storeResourceVersions.
+func storedResourceVersions(r driver.RegoDriver) ([]schema.GroupVersionKind, error) {
+	resources, err := r.ReadPath("/resources")
+	if err != nil {
+		return nil, ignoreStorageNotFoundErr(err)
+	}
+
+	// Round trip through JSON to decode the generic store data.
+	data, err := json.Marshal(resources)
+	if err != nil {
+		return nil, err
+	}
+
+	var stored map[string]struct {
+		Versions []schema.GroupVersionKind `json:".versions"`
+	}
+
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, err
+	}
+
+	var kinds []schema.GroupVersionKind
+	for _, s := range stored {
+		kinds = append(kinds, s.Versions...)
+	}
+
+	return kinds, nil
+}
@@ -0,0 +1,101 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"encoding/json"
+
+	"github.com/projectcontour/integration-tester/pkg/utils"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// mutationIgnoredFields are the object fields that the API server
+// always sets on persisted objects, so they are not mutations.
+var mutationIgnoredFields = []string{
+	"metadata.creationTimestamp",
+	"metadata.generation",
+	"metadata.managedFields",
+	"metadata.resourceVersion",
+	"metadata.selfLink",
+	"metadata.uid",
+	"status",
+}
+
+// MutationTimeline records the differences between the objects that
+// a test document submits to the API server and the objects that the
+// API server persists. These differences are caused by mutating
+// admission webhooks and by API defaulting. The entries are in the
+// order the objects were applied, so that the interactions of
+// multiple webhooks can be asserted.
+type MutationTimeline struct {
+	Entries []interface{}
+}
+
+// Record appends the mutations between the submitted and persisted
+// versions of an object that was applied by the given step.
+func (m *MutationTimeline) Record(stepID string, submitted *unstructured.Unstructured, persisted *unstructured.Unstructured) {
+	before := flattenFields(submitted.UnstructuredContent(), mutationIgnoredFields)
+	after := flattenFields(persisted.UnstructuredContent(), mutationIgnoredFields)
+
+	changes := []interface{}{}
+
+	for _, name := range unionKeys(before, after) {
+		prev, hadPrev := before[name]
+		next, hasNext := after[name]
+
+		change := map[string]interface{}{"path": name}
+
+		switch {
+		case hadPrev && !hasNext:
+			change["op"] = "remove"
+			change["submitted"] = decodeField(prev)
+		case !hadPrev && hasNext:
+			change["op"] = "add"
+			change["persisted"] = decodeField(next)
+		case prev != next:
+			change["op"] = "replace"
+			change["submitted"] = decodeField(prev)
+			change["persisted"] = decodeField(next)
+		default:
+			continue
+		}
+
+		changes = append(changes, change)
+	}
+
+	entry := map[string]interface{}{
+		"sequence":  len(m.Entries),
+		"step":      stepID,
+		"kind":      persisted.GetKind(),
+		"namespace": utils.NamespaceOrDefault(persisted),
+		"name":      persisted.GetName(),
+		"uid":       string(persisted.GetUID()),
+		"changes":   changes,
+	}
+
+	m.Entries = append(m.Entries, entry)
+}
+
+// decodeField decodes a JSON field value from flattenFields.
+func decodeField(data string) interface{} {
+	var val interface{}
+
+	if err := json.Unmarshal([]byte(data), &val); err != nil {
+		return data
+	}
+
+	return val
+}
@@ -0,0 +1,77 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestMutationTimeline(t *testing.T) {
+	submitted := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Pod",
+			"metadata": map[string]interface{}{
+				"name": "echo",
+				"labels": map[string]interface{}{
+					"app":  "echo",
+					"tier": "web",
+				},
+			},
+			"spec": map[string]interface{}{
+				"priority": int64(1),
+			},
+		},
+	}
+
+	persisted := submitted.DeepCopy()
+	persisted.SetUID("1234")
+	persisted.SetResourceVersion("7")
+	persisted.SetLabels(map[string]string{"app": "echo", "injected": "true"})
+	unstructured.SetNestedField(persisted.Object, int64(100), "spec", "priority") //nolint(errcheck)
+
+	m := &MutationTimeline{}
+	m.Record("echo.yaml#pod/echo:update", submitted, persisted)
+
+	require.Len(t, m.Entries, 1)
+
+	entry := m.Entries[0].(map[string]interface{})
+	assert.Equal(t, 0, entry["sequence"])
+	assert.Equal(t, "echo.yaml#pod/echo:update", entry["step"])
+	assert.Equal(t, "default", entry["namespace"])
+	assert.Equal(t, "1234", entry["uid"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{
+			"path":      "metadata.labels.injected",
+			"op":        "add",
+			"persisted": "true",
+		},
+		map[string]interface{}{
+			"path":      "metadata.labels.tier",
+			"op":        "remove",
+			"submitted": "web",
+		},
+		map[string]interface{}{
+			"path":      "spec.priority",
+			"op":        "replace",
+			"submitted": float64(1),
+			"persisted": float64(100),
+		},
+	}, entry["changes"])
+}
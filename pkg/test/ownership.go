@@ -0,0 +1,206 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"encoding/json"
+	"path"
+	"sort"
+	"sync"
+
+	"github.com/projectcontour/integration-tester/pkg/driver"
+	"github.com/projectcontour/integration-tester/pkg/utils"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ownershipIgnoredFields are the object fields that are expected to
+// change on every update, or that are owned by the controller, so
+// they don't indicate a conflict over the object.
+var ownershipIgnoredFields = []string{
+	"metadata.generation",
+	"metadata.managedFields",
+	"metadata.resourceVersion",
+	"status",
+}
+
+// FieldHistory counts the changes to an object field.
+type FieldHistory struct {
+	// Changes is the number of times the field value changed.
+	Changes int
+	// Reverts is the number of times the field changed back
+	// to a value it previously had.
+	Reverts int
+
+	seen map[string]struct{}
+}
+
+// OwnershipTracker counts the changes to the fields of the Kubernetes
+// objects that are observed by the object informers. If the tester
+// and a controller both try to own a field, the field flaps between
+// their values, which shows up as repeated reverts.
+type OwnershipTracker struct {
+	lock    sync.Mutex
+	objects map[string]map[string]*FieldHistory
+}
+
+// Observe records the field changes between two versions of the
+// object identified by key. It returns the histories of the changed
+// fields of the object, or nil if no tracked fields changed.
+func (o *OwnershipTracker) Observe(key string, oldObj *unstructured.Unstructured, newObj *unstructured.Unstructured) map[string]FieldHistory {
+	// If the object was recreated, the field values aren't
+	// changes to the same object.
+	if oldObj.GetUID() != newObj.GetUID() {
+		return nil
+	}
+
+	before := flattenFields(oldObj.UnstructuredContent(), ownershipIgnoredFields)
+	after := flattenFields(newObj.UnstructuredContent(), ownershipIgnoredFields)
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if o.objects == nil {
+		o.objects = map[string]map[string]*FieldHistory{}
+	}
+
+	fields, ok := o.objects[key]
+	if !ok {
+		fields = map[string]*FieldHistory{}
+		o.objects[key] = fields
+	}
+
+	changed := false
+
+	for _, name := range unionKeys(before, after) {
+		prev, next := before[name], after[name]
+		if prev == next {
+			continue
+		}
+
+		h, ok := fields[name]
+		if !ok {
+			h = &FieldHistory{seen: map[string]struct{}{prev: {}}}
+			fields[name] = h
+		}
+
+		h.Changes++
+		if _, ok := h.seen[next]; ok {
+			h.Reverts++
+		}
+
+		h.seen[next] = struct{}{}
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	histories := make(map[string]FieldHistory, len(fields))
+	for name, h := range fields {
+		histories[name] = FieldHistory{Changes: h.Changes, Reverts: h.Reverts}
+	}
+
+	return histories
+}
+
+// flattenFields returns the JSON encoding of each leaf field of the
+// object, indexed by its dotted field path. Lists are treated as
+// leaf values. The ignored fields, and any fields they contain, are
+// omitted.
+func flattenFields(obj map[string]interface{}, ignoredFields []string) map[string]string {
+	fields := map[string]string{}
+
+	var walk func(prefix string, val interface{})
+	walk = func(prefix string, val interface{}) {
+		for _, ignored := range ignoredFields {
+			if prefix == ignored {
+				return
+			}
+		}
+
+		if m, ok := val.(map[string]interface{}); ok && len(m) > 0 {
+			for k, v := range m {
+				if prefix == "" {
+					walk(k, v)
+				} else {
+					walk(prefix+"."+k, v)
+				}
+			}
+
+			return
+		}
+
+		data, err := json.Marshal(val)
+		if err != nil {
+			data = []byte(err.Error())
+		}
+
+		fields[prefix] = string(data)
+	}
+
+	walk("", obj)
+	return fields
+}
+
+// unionKeys returns the sorted union of the keys of two maps.
+func unionKeys(a map[string]string, b map[string]string) []string {
+	keys := make([]string, 0, len(a))
+
+	for k := range a {
+		keys = append(keys, k)
+	}
+
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// storeOwnership stores the field change histories of an object at
+// the path '/test/ownership/$RESOURCE/$NAMESPACE/$NAME'.
+func storeOwnership(k *driver.KubeClient, r driver.RegoDriver, u *unstructured.Unstructured, fields map[string]FieldHistory) error {
+	gvr, err := k.ResourceForKind(u.GetObjectKind().GroupVersionKind())
+	if err != nil {
+		return err
+	}
+
+	histories := map[string]interface{}{}
+	for name, h := range fields {
+		histories[name] = map[string]interface{}{
+			"changes": h.Changes,
+			"reverts": h.Reverts,
+		}
+	}
+
+	return storeItem(r,
+		ownershipPath(gvr.Resource, u),
+		map[string]interface{}{
+			"kind":   u.GetKind(),
+			"fields": histories,
+		})
+}
+
+// ownershipPath returns the store path of the ownership data for
+// the given object.
+func ownershipPath(resource string, u *unstructured.Unstructured) string {
+	return path.Join("/test/ownership",
+		resource, utils.NamespaceOrDefault(u), u.GetName())
+}
@@ -0,0 +1,63 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestOwnershipTracker(t *testing.T) {
+	deployment := func(uid string, version string, replicas int64) *unstructured.Unstructured {
+		return &unstructured.Unstructured{
+			Object: map[string]interface{}{
+				"apiVersion": "apps/v1",
+				"kind":       "Deployment",
+				"metadata": map[string]interface{}{
+					"name":            "contour",
+					"namespace":       "projectcontour",
+					"uid":             uid,
+					"resourceVersion": version,
+				},
+				"spec": map[string]interface{}{
+					"replicas": replicas,
+				},
+				"status": map[string]interface{}{
+					"replicas": replicas,
+				},
+			},
+		}
+	}
+
+	o := &OwnershipTracker{}
+
+	// Only the resource version changed.
+	assert.Nil(t, o.Observe("uid", deployment("uid", "1", 2), deployment("uid", "2", 2)))
+
+	// The tester and the controller fight over the replicas.
+	fields := o.Observe("uid", deployment("uid", "2", 2), deployment("uid", "3", 1))
+	assert.Equal(t, map[string]FieldHistory{"spec.replicas": {Changes: 1}}, fields)
+
+	fields = o.Observe("uid", deployment("uid", "3", 1), deployment("uid", "4", 2))
+	assert.Equal(t, map[string]FieldHistory{"spec.replicas": {Changes: 2, Reverts: 1}}, fields)
+
+	fields = o.Observe("uid", deployment("uid", "4", 2), deployment("uid", "5", 1))
+	assert.Equal(t, map[string]FieldHistory{"spec.replicas": {Changes: 3, Reverts: 2}}, fields)
+
+	// A recreated object is not a field change.
+	assert.Nil(t, o.Observe("uid", deployment("uid", "5", 1), deployment("other", "6", 3)))
+}
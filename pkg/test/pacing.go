@@ -0,0 +1,187 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/projectcontour/integration-tester/pkg/result"
+
+	"github.com/open-policy-agent/opa/ast"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// StepDelayOpt inserts a delay before each fragment of a test
+// document, except the first. This paces the requests that a test
+// document makes, e.g. to stay within the rate limits of a cloud
+// API.
+func StepDelayOpt(delay time.Duration) RunOpt {
+	return RunOpt(func(tc *testContext) {
+		tc.stepDelay = delay
+	})
+}
+
+// moduleDelay returns the duration from a "$delay:" comment in the
+// module, or 0 if the module has no delay.
+func moduleDelay(m *ast.Module) (time.Duration, error) {
+	for _, c := range m.Comments {
+		text := strings.TrimSpace(string(c.Text))
+		if !strings.HasPrefix(text, "$delay:") {
+			continue
+		}
+
+		value := strings.TrimSpace(strings.TrimPrefix(text, "$delay:"))
+		delay, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse %q comment: %w", "$delay", err)
+		}
+
+		if delay < 0 {
+			return 0, fmt.Errorf("invalid %q duration %q", "$delay", value)
+		}
+
+		return delay, nil
+	}
+
+	return 0, nil
+}
+
+// pace waits for the given delay in its own test step, so that the
+// delay is recorded in the test results. It returns false if the
+// test run was interrupted during the delay.
+func pace(tc *testContext, stepID string, delay time.Duration) bool {
+	if delay <= 0 {
+		return true
+	}
+
+	interrupted := false
+
+	step(tc.recorder, stepID, fmt.Sprintf("delaying for %s", delay), func() {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+			tc.recorder.Update(result.Infof("delayed for %s", delay))
+		case <-tc.interrupt:
+			interrupted = true
+		}
+	})
+
+	return !interrupted
+}
+
+// KindPacer limits the rate at which objects of each kind are
+// operated on. A single KindPacer is shared by all the test documents
+// of a test run, so that the rate holds however many documents run
+// concurrently.
+type KindPacer struct {
+	limiters map[string]flowcontrol.RateLimiter
+}
+
+// NewKindPacer returns a KindPacer that allows the given number of
+// operations per second on objects of each kind. Kinds are matched
+// case-insensitively.
+func NewKindPacer(rates map[string]float32) *KindPacer {
+	k := &KindPacer{limiters: map[string]flowcontrol.RateLimiter{}}
+
+	for kind, qps := range rates {
+		k.limiters[strings.ToLower(kind)] = flowcontrol.NewTokenBucketRateLimiter(qps, 1)
+	}
+
+	return k
+}
+
+// Wait blocks until an object of the given kind can be operated on.
+// It returns how long it waited, and false if the interrupt channel
+// was closed while it was waiting.
+func (k *KindPacer) Wait(kind string, interrupt <-chan struct{}) (time.Duration, bool) {
+	limiter, ok := k.limiters[strings.ToLower(kind)]
+	if !ok {
+		return 0, true
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		select {
+		case <-interrupt:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	start := time.Now()
+	err := limiter.Wait(ctx)
+
+	return time.Since(start), err == nil
+}
+
+// ParseKindRates parses a list of rates in KIND=RATE format, where
+// RATE is the number of operations per second on objects of the kind.
+func ParseKindRates(rates []string) (map[string]float32, error) {
+	parsed := map[string]float32{}
+
+	for _, r := range rates {
+		parts := strings.SplitN(r, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid kind rate %q: must be in KIND=RATE format", r)
+		}
+
+		qps, err := strconv.ParseFloat(parts[1], 32)
+		if err != nil || qps <= 0 {
+			return nil, fmt.Errorf("invalid %s rate %q", parts[0], parts[1])
+		}
+
+		parsed[parts[0]] = float32(qps)
+	}
+
+	return parsed, nil
+}
+
+// KindPacerOpt paces the operations on Kubernetes objects with the
+// given KindPacer.
+func KindPacerOpt(k *KindPacer) RunOpt {
+	return RunOpt(func(tc *testContext) {
+		tc.kindPacer = k
+	})
+}
+
+// paceKind waits for the kind pacer, if there is one, before an
+// operation on the object. It returns false if the test run was
+// interrupted while waiting.
+func paceKind(tc *testContext, kind string) bool {
+	if tc.kindPacer == nil {
+		return true
+	}
+
+	waited, ok := tc.kindPacer.Wait(kind, tc.interrupt)
+	if !ok {
+		tc.recorder.Update(result.Fatalf("test run was interrupted"))
+		return false
+	}
+
+	if waited >= time.Millisecond {
+		tc.recorder.Update(result.Infof("paced %s operation for %s",
+			kind, waited.Round(time.Millisecond)))
+	}
+
+	return true
+}
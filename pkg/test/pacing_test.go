@@ -0,0 +1,101 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/projectcontour/integration-tester/pkg/result"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModuleDelay(t *testing.T) {
+	m, err := ast.ParseModule("delay.rego", `package delay
+# $delay: 250ms
+error[msg] { false; msg := "never" }
+`)
+	require.NoError(t, err)
+
+	delay, err := moduleDelay(m)
+	require.NoError(t, err)
+	assert.Equal(t, 250*time.Millisecond, delay)
+
+	m, err = ast.ParseModule("delay.rego", `package delay
+# $delay: -1s
+error[msg] { false; msg := "never" }
+`)
+	require.NoError(t, err)
+
+	_, err = moduleDelay(m)
+	assert.Error(t, err)
+}
+
+func TestPace(t *testing.T) {
+	j := &JSONWriter{}
+	tc := &testContext{recorder: j}
+
+	docCloser := j.NewDocument("one.yaml")
+
+	// A zero delay doesn't record a step.
+	assert.True(t, pace(tc, "one.yaml#0:delay", 0))
+	assert.True(t, pace(tc, "one.yaml#1:delay", time.Millisecond))
+
+	interrupt := make(chan struct{})
+	close(interrupt)
+	tc.interrupt = interrupt
+
+	assert.False(t, pace(tc, "one.yaml#2:delay", time.Hour))
+
+	docCloser.Close()
+
+	require.Len(t, j.Documents[0].Steps, 2)
+	assert.Equal(t, "one.yaml#1:delay", j.Documents[0].Steps[0].ID)
+	assert.Equal(t, result.SeverityNone, j.Documents[0].Steps[0].Results[0].Severity)
+	assert.Empty(t, j.Documents[0].Steps[1].Results)
+}
+
+func TestParseKindRates(t *testing.T) {
+	rates, err := ParseKindRates([]string{"ConfigMap=5", "secret=0.5"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float32{"ConfigMap": 5, "secret": 0.5}, rates)
+
+	for _, r := range []string{"ConfigMap", "=5", "ConfigMap=", "ConfigMap=0", "ConfigMap=-1", "ConfigMap=fast"} {
+		_, err := ParseKindRates([]string{r})
+		assert.Error(t, err, r)
+	}
+}
+
+func TestKindPacerWait(t *testing.T) {
+	k := NewKindPacer(map[string]float32{"ConfigMap": 0.001})
+
+	// Kinds without a rate are never paced.
+	waited, ok := k.Wait("Secret", nil)
+	assert.True(t, ok)
+	assert.Zero(t, waited)
+
+	// The first operation spends the bucket's single token.
+	_, ok = k.Wait("configmap", nil)
+	assert.True(t, ok)
+
+	interrupt := make(chan struct{})
+	close(interrupt)
+
+	_, ok = k.Wait("ConfigMap", interrupt)
+	assert.False(t, ok)
+}
@@ -0,0 +1,175 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/projectcontour/integration-tester/pkg/driver"
+	"github.com/projectcontour/integration-tester/pkg/utils"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// patchIgnoredFields are the object fields that change on every
+// update, so they would add noise to every patch.
+var patchIgnoredFields = []string{
+	"/metadata/managedFields",
+	"/metadata/resourceVersion",
+}
+
+// PatchHistory records the changes to Kubernetes objects that are
+// observed by the object informers as a sequence of JSON Patch (RFC
+// 6902) arrays, so that checks can tell exactly which fields changed,
+// and in which order.
+type PatchHistory struct {
+	lock    sync.Mutex
+	objects map[string]*objectPatches
+}
+
+type objectPatches struct {
+	uid     string
+	patches []interface{}
+}
+
+// Observe records the changes between two versions of the object
+// identified by key. It returns all the patches that were recorded for
+// the object, or nil if no fields changed. If the object was recreated,
+// its earlier patches are discarded.
+func (p *PatchHistory) Observe(key string, oldObj *unstructured.Unstructured, newObj *unstructured.Unstructured) []interface{} {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.objects == nil {
+		p.objects = map[string]*objectPatches{}
+	}
+
+	uid := string(newObj.GetUID())
+
+	obj, ok := p.objects[key]
+	if !ok || obj.uid != uid {
+		obj = &objectPatches{uid: uid}
+		p.objects[key] = obj
+	}
+
+	// If the object was recreated, the old version is a different
+	// object, so there is nothing to compare it with.
+	if oldObj.GetUID() != newObj.GetUID() {
+		return nil
+	}
+
+	patch := diffJSONPatch("", oldObj.UnstructuredContent(), newObj.UnstructuredContent())
+	if len(patch) == 0 {
+		return nil
+	}
+
+	obj.patches = append(obj.patches, patch)
+
+	return append([]interface{}(nil), obj.patches...)
+}
+
+// diffJSONPatch returns the JSON Patch operations that transform the
+// before value into the after value at the given JSON Pointer. Maps
+// are compared key by key, in sorted key order, but any other changed
+// value (including a list) is replaced as a whole.
+func diffJSONPatch(pointer string, before interface{}, after interface{}) []interface{} {
+	beforeMap, beforeIsMap := before.(map[string]interface{})
+	afterMap, afterIsMap := after.(map[string]interface{})
+
+	if !beforeIsMap || !afterIsMap {
+		if reflect.DeepEqual(before, after) {
+			return nil
+		}
+
+		return []interface{}{patchOp("replace", pointer, after)}
+	}
+
+	keys := make([]string, 0, len(beforeMap)+len(afterMap))
+	for k := range beforeMap {
+		keys = append(keys, k)
+	}
+
+	for k := range afterMap {
+		if _, ok := beforeMap[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+
+	var ops []interface{}
+
+	for _, k := range keys {
+		p := pointer + "/" + escapeJSONPointer(k)
+		if utils.ContainsString(patchIgnoredFields, p) {
+			continue
+		}
+
+		b, inBefore := beforeMap[k]
+		a, inAfter := afterMap[k]
+
+		switch {
+		case !inAfter:
+			ops = append(ops, map[string]interface{}{"op": "remove", "path": p})
+		case !inBefore:
+			ops = append(ops, patchOp("add", p, a))
+		default:
+			ops = append(ops, diffJSONPatch(p, b, a)...)
+		}
+	}
+
+	return ops
+}
+
+func patchOp(op string, pointer string, value interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"op":    op,
+		"path":  pointer,
+		"value": value,
+	}
+}
+
+// escapeJSONPointer escapes a JSON Pointer reference token.
+func escapeJSONPointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+// storePatches stores the patches of an object at the path
+// '/history/patches/$RESOURCE/$NAMESPACE/$NAME'.
+func storePatches(r driver.RegoDriver, gvr schema.GroupVersionResource, u *unstructured.Unstructured, patches []interface{}) error {
+	return storeItem(r, patchesPath(gvr.Resource, u), patches)
+}
+
+// containsGroupResource returns whether the wanted resource is one of
+// the given resources.
+func containsGroupResource(resources []schema.GroupResource, wanted schema.GroupResource) bool {
+	for _, r := range resources {
+		if r == wanted {
+			return true
+		}
+	}
+
+	return false
+}
+
+func patchesPath(resource string, u *unstructured.Unstructured) string {
+	return path.Join("/history/patches",
+		resource, utils.NamespaceOrDefault(u), u.GetName())
+}
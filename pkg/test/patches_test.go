@@ -0,0 +1,95 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestDiffJSONPatch(t *testing.T) {
+	before := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name":            "echo",
+			"resourceVersion": "1",
+			"annotations": map[string]interface{}{
+				"a/b":  "1",
+				"gone": "x",
+			},
+		},
+		"spec": map[string]interface{}{
+			"ports": []interface{}{int64(80)},
+		},
+	}
+
+	after := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name":            "echo",
+			"resourceVersion": "2",
+			"annotations": map[string]interface{}{
+				"a/b": "2",
+			},
+		},
+		"spec": map[string]interface{}{
+			"ports": []interface{}{int64(80), int64(443)},
+		},
+		"status": map[string]interface{}{
+			"ready": false,
+		},
+	}
+
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"op": "replace", "path": "/metadata/annotations/a~1b", "value": "2"},
+		map[string]interface{}{"op": "remove", "path": "/metadata/annotations/gone"},
+		map[string]interface{}{"op": "replace", "path": "/spec/ports", "value": []interface{}{int64(80), int64(443)}},
+		map[string]interface{}{"op": "add", "path": "/status", "value": map[string]interface{}{"ready": false}},
+	}, diffJSONPatch("", before, after))
+
+	assert.Empty(t, diffJSONPatch("", before, before))
+}
+
+func TestPatchHistory(t *testing.T) {
+	obj := func(uid string, replicas int64) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"metadata": map[string]interface{}{"uid": uid},
+			"spec":     map[string]interface{}{"replicas": replicas},
+		}}
+	}
+
+	h := &PatchHistory{}
+
+	assert.Nil(t, h.Observe("echo", obj("1", 1), obj("1", 1)))
+	assert.Len(t, h.Observe("echo", obj("1", 1), obj("1", 2)), 1)
+
+	patches := h.Observe("echo", obj("1", 2), obj("1", 3))
+	assert.Equal(t, []interface{}{
+		[]interface{}{map[string]interface{}{"op": "replace", "path": "/spec/replicas", "value": int64(2)}},
+		[]interface{}{map[string]interface{}{"op": "replace", "path": "/spec/replicas", "value": int64(3)}},
+	}, patches)
+
+	// A recreated object starts a new history.
+	assert.Nil(t, h.Observe("echo", obj("1", 3), obj("2", 1)))
+	assert.Len(t, h.Observe("echo", obj("2", 1), obj("2", 2)), 1)
+}
+
+func TestPatchesPath(t *testing.T) {
+	u := &unstructured.Unstructured{}
+	u.SetName("echo")
+	u.SetNamespace("projectcontour")
+
+	assert.Equal(t, "/history/patches/services/projectcontour/echo", patchesPath("services", u))
+}
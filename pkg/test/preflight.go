@@ -0,0 +1,149 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/projectcontour/integration-tester/pkg/doc"
+	"github.com/projectcontour/integration-tester/pkg/driver"
+	"github.com/projectcontour/integration-tester/pkg/result"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/rego"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// PreflightResult reports whether a test document can run against
+// the target cluster.
+type PreflightResult struct {
+	Document  string   `json:"document"`
+	Supported bool     `json:"supported"`
+	Reasons   []string `json:"reasons,omitempty"`
+}
+
+// Preflight checks whether the given test document can run against
+// the target cluster, without applying any objects. A document is not
+// supported if the cluster doesn't serve the kind of any of its objects,
+// or if any of its skip rules trigger. Since no objects are created,
+// only skip rules that depend on the cluster capabilities (e.g. on
+// `data.resources[".versions"]`) or on parameters are meaningful.
+//
+// Unlike Run, Preflight expects that the document fragments have
+// already been decoded.
+func Preflight(testDoc *doc.Document, opts ...RunOpt) (*PreflightResult, error) {
+	tc := testContext{
+		regoDriver: driver.NewRegoDriver(),
+	}
+
+	for _, o := range opts {
+		o(&tc)
+	}
+
+	if tc.kubeDriver == nil {
+		return nil, fmt.Errorf("missing Kubernetes client")
+	}
+
+	if tc.objectDriver != nil {
+		defer tc.objectDriver.Done()
+	}
+
+	res := &PreflightResult{
+		Document:  testDoc.Name,
+		Supported: true,
+	}
+
+	unsupported := func(format string, args ...interface{}) {
+		res.Supported = false
+		res.Reasons = append(res.Reasons, fmt.Sprintf(format, args...))
+	}
+
+	for _, p := range testDoc.Parts {
+		objects := []*unstructured.Unstructured{p.Object()}
+		for i := range p.Items() {
+			objects = append(objects, p.Items()[i].Object())
+		}
+
+		for _, obj := range objects {
+			// A List is not a resource, only its items are.
+			if obj == nil || obj.IsList() {
+				continue
+			}
+
+			gvk := obj.GroupVersionKind()
+			if _, err := tc.kubeDriver.ResourceForKind(gvk); err != nil {
+				unsupported("%s %s is not served (lines %s)",
+					gvk.GroupVersion(), gvk.Kind, p.Location)
+			}
+		}
+	}
+
+	if _, err := storeResourceVersions(tc.kubeDriver, tc.regoDriver); err != nil {
+		return nil, err
+	}
+
+	compiler, err := compileDocument(testDoc, tc.policyModules, tc.capabilities)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range testDoc.Parts {
+		m := p.Rego()
+		if m == nil {
+			continue
+		}
+
+		results, err := tc.regoDriver.Eval(skipRules(m), rego.Compiler(compiler))
+		if err != nil {
+			return nil, err
+		}
+
+		scope, err := moduleSkipScope(m)
+		if err != nil {
+			return nil, err
+		}
+
+		// Skips that are scoped to a step or group don't stop
+		// the document from running.
+		for _, r := range scopeSkips(results, scope) {
+			if r.Severity == result.SeveritySkip && r.IsTerminal() {
+				unsupported("%s (lines %s)", r.Text(), p.Location)
+			}
+		}
+	}
+
+	return res, nil
+}
+
+// skipRules returns a copy of the module that contains only the
+// rules named by the "skip" result token. The copy is only used
+// to find the names of the rules to query, since the compiler
+// already has the full module.
+func skipRules(m *ast.Module) *ast.Module {
+	skips := &ast.Module{
+		Package: m.Package,
+	}
+
+	for _, r := range m.Rules {
+		name := r.Head.Name.String()
+		if name == "skip" || strings.HasPrefix(name, "skip_") {
+			skips.Rules = append(skips.Rules, r)
+		}
+	}
+
+	return skips
+}
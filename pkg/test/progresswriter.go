@@ -0,0 +1,203 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/projectcontour/integration-tester/pkg/result"
+)
+
+// ProgressMode selects how a ProgressWriter reports progress.
+type ProgressMode int
+
+const (
+	// ProgressLine redraws a single status line that shows the
+	// number of completed documents, the current step and the
+	// elapsed time. This is intended for interactive terminals.
+	ProgressLine ProgressMode = iota
+	// ProgressDots writes a single character for each completed
+	// step. This is intended for CI logs.
+	ProgressDots
+)
+
+type progressFailure struct {
+	stepID string
+	result result.Result
+}
+
+// ProgressWriter is a Recorder that writes a compact indication of
+// the progress of the test run, followed by a list of the failures
+// when it is closed at the end of the run.
+type ProgressWriter struct {
+	clock
+
+	// Out is where the progress is written. If Out is nil, the
+	// progress is written to standard output.
+	Out io.Writer
+
+	// Mode selects the progress display.
+	Mode ProgressMode
+
+	// Total is the number of test documents that the run will
+	// record, or 0 if it is unknown.
+	Total int
+
+	start    time.Time
+	done     int
+	doc      string
+	step     string
+	stepID   string
+	severity result.Severity
+	failures []progressFailure
+
+	// docFailures is the number of failures before the current
+	// document, so that the failures of a retried document can
+	// be dropped.
+	docFailures int
+}
+
+var _ Recorder = &ProgressWriter{}
+
+func (p *ProgressWriter) out() io.Writer {
+	return outputOrStdout(p.Out)
+}
+
+// ShouldContinue ...
+func (p *ProgressWriter) ShouldContinue() bool {
+	return true
+}
+
+// Failed ...
+func (p *ProgressWriter) Failed() bool {
+	return false
+}
+
+// NewDocument ...
+func (p *ProgressWriter) NewDocument(desc string) Closer {
+	if p.start.IsZero() {
+		p.start = p.now()
+	}
+
+	p.doc = desc
+	p.docFailures = len(p.failures)
+	p.render()
+
+	return CloserFunc(func() {
+		p.done++
+		p.doc = ""
+		p.render()
+	})
+}
+
+// RetryDocument drops the failures of the retried document, which
+// no longer counts as done. In dots mode, the retry is marked with
+// an "R".
+func (p *ProgressWriter) RetryDocument() {
+	p.done--
+	p.failures = p.failures[:p.docFailures]
+
+	if p.Mode == ProgressDots {
+		fmt.Fprint(p.out(), "R")
+	}
+
+	p.render()
+}
+
+// NewStep ...
+func (p *ProgressWriter) NewStep(id string, desc string) Closer {
+	p.step = desc
+	p.stepID = id
+	p.severity = result.SeverityNone
+	p.render()
+
+	return CloserFunc(func() {
+		if p.Mode == ProgressDots {
+			fmt.Fprint(p.out(), progressMarks[p.severity])
+		}
+
+		p.step = ""
+		p.stepID = ""
+		p.render()
+	})
+}
+
+// progressMarks are the characters written for each step in dots mode.
+var progressMarks = map[result.Severity]string{
+	result.SeverityNone:  ".",
+	result.SeverityError: "F",
+	result.SeverityFatal: "E",
+	result.SeveritySkip:  "S",
+}
+
+// Update ...
+func (p *ProgressWriter) Update(results ...result.Result) {
+	for _, r := range results {
+		if severityRank[r.Severity] > severityRank[p.severity] {
+			p.severity = r.Severity
+		}
+
+		if r.IsFailed() {
+			p.failures = append(p.failures, progressFailure{stepID: p.stepID, result: r})
+		}
+	}
+}
+
+// render redraws the progress line.
+func (p *ProgressWriter) render() {
+	if p.Mode != ProgressLine {
+		return
+	}
+
+	total := "?"
+	if p.Total > 0 {
+		total = fmt.Sprint(p.Total)
+	}
+
+	line := fmt.Sprintf("[%d/%s] %s", p.done, total, p.elapsed())
+	if p.doc != "" {
+		line += " " + p.doc
+	}
+
+	if p.step != "" {
+		line += ": " + p.step
+	}
+
+	if len(p.failures) > 0 {
+		line += fmt.Sprintf(" (%d failed)", len(p.failures))
+	}
+
+	// Return to the start of the line and clear it.
+	fmt.Fprintf(p.out(), "\r\033[K%s", line)
+}
+
+func (p *ProgressWriter) elapsed() time.Duration {
+	if p.start.IsZero() {
+		return 0
+	}
+
+	return p.now().Sub(p.start).Round(time.Second)
+}
+
+// Close ends the progress display and writes the failures.
+func (p *ProgressWriter) Close() {
+	fmt.Fprintf(p.out(), "\n")
+
+	for _, f := range p.failures {
+		indentf(p.out(), "", "%s %s: %s", progressMarks[f.result.Severity], f.stepID, f.result.Text())
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/projectcontour/integration-tester/pkg/result"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func recordProgress(p *ProgressWriter) {
+	docCloser := p.NewDocument("one.yaml")
+	stepCloser := p.NewStep("one.yaml#compile", "compiling")
+	p.Update(result.Infof("compiled"))
+	stepCloser.Close()
+	stepCloser = p.NewStep("one.yaml#0:check", "checking")
+	p.Update(result.Errorf("failed"))
+	stepCloser.Close()
+	docCloser.Close()
+
+	docCloser = p.NewDocument("two.yaml")
+	stepCloser = p.NewStep("two.yaml#0:check", "checking")
+	p.Update(result.Skipf("skipped"))
+	stepCloser.Close()
+	stepCloser = p.NewStep("two.yaml#1:check", "checking")
+	p.Update(result.Fatalf("broken"))
+	stepCloser.Close()
+	docCloser.Close()
+
+	p.Close()
+}
+
+func TestProgressDots(t *testing.T) {
+	buf := bytes.Buffer{}
+	recordProgress(&ProgressWriter{Out: &buf, Mode: ProgressDots})
+
+	assert.Equal(t, strings.Join([]string{
+		".FSE",
+		"F one.yaml#0:check: failed",
+		"E two.yaml#1:check: broken",
+		"",
+	}, "\n"), buf.String())
+}
+
+func TestProgressLine(t *testing.T) {
+	buf := bytes.Buffer{}
+	recordProgress(&ProgressWriter{Out: &buf, Total: 2})
+
+	out := buf.String()
+
+	// Each update redraws the line.
+	assert.Contains(t, out, "\r\033[K[0/2] 0s one.yaml: checking")
+	assert.Contains(t, out, "\r\033[K[1/2] 0s two.yaml: checking (1 failed)")
+	assert.Contains(t, out, "\r\033[K[2/2] 0s (2 failed)\n")
+	assert.True(t, strings.HasSuffix(out,
+		"F one.yaml#0:check: failed\nE two.yaml#1:check: broken\n"))
+}
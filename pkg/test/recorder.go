@@ -22,6 +22,36 @@ import (
 	"github.com/projectcontour/integration-tester/pkg/result"
 )
 
+// clock tells the time of the records that a recorder receives.
+// While a DocumentBuffer replays records, it sets the clock of the
+// recorders that it replays into, so that the replayed records keep
+// the time at which they were originally recorded. Otherwise, the
+// clock tells the current time.
+type clock struct {
+	replayed time.Time
+}
+
+func (c *clock) now() time.Time {
+	if !c.replayed.IsZero() {
+		return c.replayed
+	}
+
+	return time.Now()
+}
+
+func (c *clock) setClock(when time.Time) {
+	c.replayed = when
+}
+
+// setClock sets the clock of r and of the recorders that it wraps
+// to when. If when is the zero time, the clocks tell the current
+// time again.
+func setClock(r Recorder, when time.Time) {
+	if c, ok := r.(interface{ setClock(time.Time) }); ok {
+		c.setClock(when)
+	}
+}
+
 // Document records the execution of a test document.
 type Document struct {
 	Description string
@@ -29,6 +59,13 @@ type Document struct {
 	Steps       []*Step
 }
 
+// Retried returns true if the document was a failed attempt that
+// was retried.
+func (d *Document) Retried() bool {
+	retried, _ := d.Properties["retried"].(bool)
+	return retried
+}
+
 // EachResult walks the test document and applies the function to
 // each error.
 func (d *Document) EachResult(f func(*Step, *result.Result)) {
@@ -43,6 +80,7 @@ func (d *Document) EachResult(f func(*Step, *result.Result)) {
 // Step describes a stage in a test document that can generate onr
 // or more related errors.
 type Step struct {
+	ID          string
 	Description string
 	Start       time.Time
 	End         time.Time
@@ -79,14 +117,22 @@ type Recorder interface {
 	// closed by calling the returned Closer.
 	NewDocument(desc string) Closer
 
-	// NewDocument created a new test document that can be
-	// closed by calling the returned Closer.
-	NewStep(desc string) Closer
+	// NewStep creates a new test step that can be closed by
+	// calling the returned Closer. The ID is a stable identifier
+	// for the step (see StepID).
+	NewStep(id string, desc string) Closer
 
 	Update(...result.Result)
+
+	// RetryDocument marks the most recently closed test document
+	// as a failed attempt that is retried by the next document.
+	// The failures of a retried document don't fail the test run.
+	RetryDocument()
 }
 
 type defaultRecorder struct {
+	clock
+
 	docs []*Document
 
 	currentDoc  *Document
@@ -96,6 +142,13 @@ type defaultRecorder struct {
 // DefaultRecorder ...
 var DefaultRecorder Recorder = &defaultRecorder{}
 
+// NewRecorder returns a new Recorder that, like DefaultRecorder,
+// tracks whether any results were terminal or failed, but that is
+// independent of the results recorded by DefaultRecorder.
+func NewRecorder() Recorder {
+	return &defaultRecorder{}
+}
+
 // ShouldContinue returns false if any fatal errors have been recorded.
 func (r *defaultRecorder) ShouldContinue() bool {
 	terminal := false
@@ -109,6 +162,10 @@ func (r *defaultRecorder) ShouldContinue() bool {
 	}
 
 	for _, d := range which {
+		if d.Retried() {
+			continue
+		}
+
 		d.EachResult(func(s *Step, r *result.Result) {
 			if r.IsTerminal() {
 				terminal = true
@@ -124,6 +181,10 @@ func (r *defaultRecorder) Failed() bool {
 	failed := false
 
 	for _, d := range r.docs {
+		if d.Retried() {
+			continue
+		}
+
 		d.EachResult(func(s *Step, r *result.Result) {
 			if r.IsFailed() {
 				failed = true
@@ -139,7 +200,10 @@ func (r *defaultRecorder) NewDocument(desc string) Closer {
 	must.Check(r.currentStep == nil,
 		fmt.Errorf("can't create a new doc with an open step"))
 
-	doc := &Document{}
+	doc := &Document{
+		Description: desc,
+		Properties:  map[string]interface{}{},
+	}
 
 	r.currentDoc = doc
 	r.docs = append(r.docs, doc)
@@ -156,13 +220,14 @@ func (r *defaultRecorder) NewDocument(desc string) Closer {
 
 // NewStep creates a new Step within the current Document and makes
 // that the current Step.
-func (r *defaultRecorder) NewStep(desc string) Closer {
+func (r *defaultRecorder) NewStep(id string, desc string) Closer {
 	must.Check(r.currentDoc != nil,
 		fmt.Errorf("no open document"))
 
 	step := &Step{
+		ID:          id,
 		Description: desc,
-		Start:       time.Now(),
+		Start:       r.now(),
 	}
 
 	r.currentStep = step
@@ -172,7 +237,7 @@ func (r *defaultRecorder) NewStep(desc string) Closer {
 		must.Check(r.currentStep == step,
 			fmt.Errorf("overlapping steps"))
 
-		step.End = time.Now()
+		step.End = r.now()
 
 		r.currentStep = nil
 	})
@@ -182,3 +247,13 @@ func (r *defaultRecorder) Update(res ...result.Result) {
 	must.Check(r.currentStep != nil, fmt.Errorf("no open step"))
 	r.currentStep.Results = append(r.currentStep.Results, res...)
 }
+
+// RetryDocument marks the most recent Document as retried.
+func (r *defaultRecorder) RetryDocument() {
+	must.Check(r.currentDoc == nil,
+		fmt.Errorf("can't retry an open doc"))
+	must.Check(len(r.docs) > 0,
+		fmt.Errorf("no doc to retry"))
+
+	r.docs[len(r.docs)-1].Properties["retried"] = true
+}
@@ -15,15 +15,18 @@
 package test
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/projectcontour/integration-tester/pkg/builtin"
 	"github.com/projectcontour/integration-tester/pkg/doc"
 	"github.com/projectcontour/integration-tester/pkg/driver"
+	"github.com/projectcontour/integration-tester/pkg/external"
 	"github.com/projectcontour/integration-tester/pkg/filter"
 	"github.com/projectcontour/integration-tester/pkg/must"
 	"github.com/projectcontour/integration-tester/pkg/result"
@@ -41,11 +44,12 @@ import (
 // RunOpt sets options for the test run.
 type RunOpt func(*testContext)
 
-// KubeClientOpt sets the Kubernetes client.
+// KubeClientOpt sets the Kubernetes client. The client can be
+// shared by concurrent test runs, since each run uses a scoped copy.
 func KubeClientOpt(kube *driver.KubeClient) RunOpt {
 	return RunOpt(func(tc *testContext) {
-		tc.kubeDriver = kube
-		tc.objectDriver = driver.NewObjectDriver(kube)
+		tc.kubeDriver = kube.Scoped()
+		tc.objectDriver = driver.NewObjectDriver(tc.kubeDriver)
 	})
 }
 
@@ -92,6 +96,14 @@ func PreserveObjectsOpt() RunOpt {
 	})
 }
 
+// PreserveOnFailureOpt disables automatic object deletion if the
+// test document fails.
+func PreserveOnFailureOpt() RunOpt {
+	return RunOpt(func(tc *testContext) {
+		tc.preserveOnFailure = true
+	})
+}
+
 // WatchResourceOpt adds an explicit informer for the given resource.
 func WatchResourceOpt(gvr schema.GroupVersionResource) RunOpt {
 	return RunOpt(func(tc *testContext) {
@@ -99,13 +111,96 @@ func WatchResourceOpt(gvr schema.GroupVersionResource) RunOpt {
 	})
 }
 
-// DryRunOpt enables Kubernetes dry-run mode (TODO).
+// PatchHistoryOpt records the changes to objects of the given resource
+// as JSON Patch arrays. The resource is also watched.
+func PatchHistoryOpt(gvr schema.GroupVersionResource) RunOpt {
+	return RunOpt(func(tc *testContext) {
+		tc.watchedResources = append(tc.watchedResources, gvr)
+		tc.patchedResources = append(tc.patchedResources, gvr.GroupResource())
+	})
+}
+
+// WatchNamespacesOpt limits the namespaced objects that an Evaluator
+// stores to those in the given namespaces. Cluster-scoped objects are
+// always stored.
+func WatchNamespacesOpt(namespaces ...string) RunOpt {
+	return RunOpt(func(tc *testContext) {
+		tc.watchedNamespaces = append(tc.watchedNamespaces, namespaces...)
+	})
+}
+
+// ExternalSourcesOpt polls the given external data sources at the
+// given interval for the duration of each test document. The data
+// from each source is published at `data.external.$NAME`.
+func ExternalSourcesOpt(interval time.Duration, sources ...external.Source) RunOpt {
+	return RunOpt(func(tc *testContext) {
+		tc.externalSources = append(tc.externalSources, sources...)
+		tc.externalInterval = interval
+	})
+}
+
+// RegoCapabilitiesOpt restricts the Rego builtins that checks can
+// use to those in the given capabilities.
+func RegoCapabilitiesOpt(c *ast.Capabilities) RunOpt {
+	return RunOpt(func(tc *testContext) {
+		tc.capabilities = c
+	})
+}
+
+// RuleSeveritiesOpt treats the additional Rego rule names in s as
+// test results.
+func RuleSeveritiesOpt(s *driver.RuleSeverities) RunOpt {
+	return RunOpt(func(tc *testContext) {
+		tc.regoDriver.SetRuleSeverities(s)
+	})
+}
+
+// DryRunOpt enables Kubernetes dry-run mode. Objects are applied
+// with server-side dry-run requests, so the cluster is not changed,
+// but checks are evaluated against the objects that the API server
+// returns.
 func DryRunOpt() RunOpt {
 	return RunOpt(func(tc *testContext) {
 		tc.dryRun = true
 	})
 }
 
+// InterruptOpt sets a channel that is closed to interrupt the test
+// run. When the run is interrupted, the current step is stopped and
+// the test objects are cleaned up as they are when a step fails.
+func InterruptOpt(interrupt <-chan struct{}) RunOpt {
+	return RunOpt(func(tc *testContext) {
+		tc.interrupt = interrupt
+	})
+}
+
+// NamespaceOpt sets the namespace that namespaced objects are
+// created in when the test document does not specify one. Objects
+// in this namespace are stored in the Rego data document as if
+// they were in the "default" namespace.
+func NamespaceOpt(ns string) RunOpt {
+	return RunOpt(func(tc *testContext) {
+		tc.namespace = ns
+	})
+}
+
+// RunIDOpt sets the test run ID that is used to label the
+// Kubernetes objects created by the test. If this option is not
+// given, each test document is run with a random ID.
+func RunIDOpt(id string) RunOpt {
+	return RunOpt(func(tc *testContext) {
+		tc.runID = id
+	})
+}
+
+// DocumentDescOpt sets the description that the results of the
+// test document are recorded with, if it is not the document name.
+func DocumentDescOpt(desc string) RunOpt {
+	return RunOpt(func(tc *testContext) {
+		tc.docDesc = desc
+	})
+}
+
 // CheckTimeoutOpt sets the check timeout.
 func CheckTimeoutOpt(timeout time.Duration) RunOpt {
 	return RunOpt(func(tc *testContext) {
@@ -113,8 +208,16 @@ func CheckTimeoutOpt(timeout time.Duration) RunOpt {
 	})
 }
 
-func step(tc Recorder, stepDesc string, f func()) {
-	stepCloser := tc.NewStep(stepDesc)
+// EnvVarsOpt allows the objects in test documents to refer to the
+// named environment variables (see driver.EnvVarsOpt).
+func EnvVarsOpt(names []string) RunOpt {
+	return RunOpt(func(tc *testContext) {
+		tc.envVars = append(tc.envVars, names...)
+	})
+}
+
+func step(tc Recorder, stepID string, stepDesc string, f func()) {
+	stepCloser := tc.NewStep(stepID, stepDesc)
 	defer stepCloser.Close()
 
 	if !tc.ShouldContinue() {
@@ -125,6 +228,17 @@ func step(tc Recorder, stepDesc string, f func()) {
 	f()
 }
 
+// cleanupStep is like step, except that f runs even if the test
+// document was stopped by an earlier step. This is for steps that
+// need to run however the document ended, like deleting the test
+// objects.
+func cleanupStep(tc Recorder, stepID string, stepDesc string, f func()) {
+	stepCloser := tc.NewStep(stepID, stepDesc)
+	defer stepCloser.Close()
+
+	f()
+}
+
 type testContext struct {
 	kubeDriver   *driver.KubeClient
 	objectDriver driver.ObjectDriver
@@ -132,11 +246,40 @@ type testContext struct {
 	envDriver    driver.Environment
 	recorder     Recorder
 
-	dryRun           bool
-	preserve         bool
-	checkTimeout     time.Duration
-	watchedResources []schema.GroupVersionResource
-	policyModules    []*ast.Module
+	runID             string
+	docDesc           string
+	dryRun            bool
+	namespace         string
+	preserve          bool
+	preserveOnFailure bool
+	checkTimeout      time.Duration
+	checkInterval     time.Duration
+	watchedResources  []schema.GroupVersionResource
+	patchedResources  []schema.GroupResource
+	bulkApply         int
+	kindPacer         *KindPacer
+	watchedNamespaces []string
+	stepDelay         time.Duration
+	policyModules     []*ast.Module
+	capabilities      *ast.Capabilities
+	suite             *Suite
+	budget            *Budget
+	ioBudget          driver.IOBudget
+	externalSources   []external.Source
+	externalInterval  time.Duration
+	interrupt         <-chan struct{}
+	artifactsDir      string
+	envVars           []string
+}
+
+// interrupted returns whether the test run was interrupted.
+func (tc *testContext) interrupted() bool {
+	select {
+	case <-tc.interrupt:
+		return true
+	default:
+		return false
+	}
 }
 
 // Run executes a test document.
@@ -147,21 +290,57 @@ func Run(testDoc *doc.Document, opts ...RunOpt) error {
 	var err error
 
 	tc := testContext{
-		envDriver:    driver.NewEnvironment(),
-		regoDriver:   driver.NewRegoDriver(),
-		checkTimeout: time.Second * 10,
+		regoDriver:    driver.NewRegoDriver(),
+		checkTimeout:  time.Second * 10,
+		checkInterval: DefaultCheckInterval,
+		namespace:     metav1.NamespaceDefault,
 	}
 
 	for _, o := range opts {
 		o(&tc)
 	}
 
+	if !tc.ioBudget.IsZero() {
+		tc.regoDriver.SetIOBudget(tc.ioBudget)
+	}
+
+	if tc.dryRun && tc.kubeDriver != nil {
+		tc.objectDriver = driver.NewObjectDriver(tc.kubeDriver, driver.ServerDryRunOpt())
+	}
+
 	if tc.objectDriver == nil {
 		return fmt.Errorf("missing Kubernetes object driver")
 	}
 
+	// Track the failures of this document separately from the
+	// failures of any earlier documents in the test run.
+	failures := NewRecorder()
+	failuresCloser := failures.NewDocument(testDoc.Name)
+	defer failuresCloser.Close()
+
+	tc.recorder = StackRecorders(tc.recorder, failures)
+
+	var envOpts []driver.EnvironmentOpt
+	if tc.runID != "" {
+		envOpts = append(envOpts, driver.UniqueIDOpt(tc.runID))
+	}
+
+	if len(tc.envVars) > 0 {
+		envOpts = append(envOpts, driver.EnvVarsOpt(tc.envVars))
+	}
+
+	// Cluster fixtures need to fetch objects from the cluster.
+	if tc.kubeDriver != nil {
+		tc.envDriver = driver.NewClusterEnvironment(tc.kubeDriver.GetObject, envOpts...)
+	} else {
+		tc.envDriver = driver.NewEnvironment(envOpts...)
+	}
+
 	defer tc.objectDriver.Done()
 
+	ownership := &OwnershipTracker{}
+	patches := &PatchHistory{}
+
 	// Start receiving Kubernetes objects and adding them to the
 	// store. We currently don't need any locking around this since
 	// the Rego store is transactional and this path doesn't touch
@@ -169,15 +348,32 @@ func Run(testDoc *doc.Document, opts ...RunOpt) error {
 	cancelWatch := tc.objectDriver.Watch(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(o interface{}) {
 			if u, ok := o.(*unstructured.Unstructured); ok {
-				must.Must(storeResource(tc.kubeDriver, tc.regoDriver, u))
+				must.Must(storeResource(tc.kubeDriver, tc.regoDriver, tc.namespace, u))
 			}
 		}, UpdateFunc: func(oldObj interface{}, newObj interface{}) {
 			if u, ok := newObj.(*unstructured.Unstructured); ok {
-				must.Must(storeResource(tc.kubeDriver, tc.regoDriver, u))
+				must.Must(storeResource(tc.kubeDriver, tc.regoDriver, tc.namespace, u))
+
+				if prev, ok := oldObj.(*unstructured.Unstructured); ok {
+					key := string(u.GetUID())
+					if fields := ownership.Observe(key, prev, u); fields != nil {
+						must.Must(storeOwnership(tc.kubeDriver, tc.regoDriver, u, fields))
+					}
+
+					if len(tc.patchedResources) > 0 {
+						gvr, err := tc.kubeDriver.ResourceForKind(u.GetObjectKind().GroupVersionKind())
+						if err == nil && containsGroupResource(tc.patchedResources, gvr.GroupResource()) {
+							if p := patches.Observe(key, prev, u); p != nil {
+								must.Must(storePatches(tc.regoDriver, gvr, u, p))
+							}
+						}
+					}
+				}
 			}
 		}, DeleteFunc: func(o interface{}) {
 			if u, ok := o.(*unstructured.Unstructured); ok {
-				must.Must(removeResource(tc.kubeDriver, tc.regoDriver, u))
+				must.Must(removeResource(tc.kubeDriver, tc.regoDriver, tc.namespace, u))
+				must.Must(storeTombstone(tc.kubeDriver, tc.regoDriver, tc.namespace, u, time.Now()))
 			}
 		},
 	})
@@ -195,27 +391,213 @@ func Run(testDoc *doc.Document, opts ...RunOpt) error {
 		return err
 	}
 
-	if err := storeResourceVersions(tc.kubeDriver, tc.regoDriver); err != nil {
+	if err := storeInformerStatus(tc.regoDriver, tc.objectDriver.InformerStatus()); err != nil {
+		return err
+	}
+
+	discoveryFailures, err := storeResourceVersions(tc.kubeDriver, tc.regoDriver)
+	if err != nil {
 		return err
 	}
 
 	tc.regoDriver.StoreItem("/test/params/run-id", tc.envDriver.UniqueID())
+	tc.regoDriver.StoreItem("/test/params/dry-run", tc.dryRun)
+	tc.regoDriver.StoreItem("/test/params/namespace", tc.namespace)
+
+	if len(tc.externalSources) > 0 {
+		poller := &external.Poller{
+			Sources:  tc.externalSources,
+			Interval: tc.externalInterval,
+			Store: func(name string, data map[string]interface{}) error {
+				return storeItem(tc.regoDriver, path.Join("/", "external", name), data)
+			},
+		}
+
+		stopPolling := poller.Start()
+		defer stopPolling()
+	}
+
+	fragmentIDs := FragmentIDs(testDoc)
+
+	var budget *budgetTracker
+	if tc.budget != nil {
+		budget = newBudgetTracker(tc.budget)
+	}
 
-	step(tc.recorder, "compiling test document", func() {
+	step(tc.recorder, StepID(testDoc.Name, "", "compile"), "compiling test document", func() {
 		tc.recorder.Update(
 			result.Infof("test run ID is %s", tc.envDriver.UniqueID()))
 
-		compiler, err = compileDocument(testDoc, tc.policyModules)
+		var failedGroups []string
+		for gv := range discoveryFailures {
+			failedGroups = append(failedGroups, gv)
+		}
+
+		sort.Strings(failedGroups)
+
+		for _, gv := range failedGroups {
+			tc.recorder.Update(result.Infof(
+				"API discovery failed for %s: %s", gv, discoveryFailures[gv]))
+		}
+
+		compiler, err = compileDocument(testDoc, tc.policyModules, tc.capabilities)
 		if err != nil {
 			tc.recorder.Update(result.Fatalf("%s", err.Error()))
+			return
+		}
+
+		interval, err := documentCheckInterval(testDoc)
+		switch {
+		case err != nil:
+			tc.recorder.Update(result.Fatalf("%s", err.Error()))
+		case interval > 0:
+			tc.checkInterval = interval
 		}
+
+		applyDocumentMeta(&tc, testDoc.Meta)
 	})
 
-	for _, p := range testDoc.Parts {
+	// The result of the most recent object operation, which
+	// is the input to standalone Rego checks.
+	var lastOpResult *driver.OperationResult
+
+	mutations := &MutationTimeline{}
+
+	// stopInterrupted records a fatal result if the test run was
+	// interrupted, so that no further fragments run.
+	stoppedInterrupt := false
+	stopInterrupted := func(fragmentID string) bool {
+		if stoppedInterrupt || !tc.interrupted() {
+			return stoppedInterrupt
+		}
+
+		stoppedInterrupt = true
+		step(tc.recorder, StepID(testDoc.Name, fragmentID, "interrupt"), "stopping interrupted test document", func() {
+			tc.recorder.Update(result.Fatalf("test run was interrupted"))
+		})
+
+		return true
+	}
+
+	// The fragments in any of the skippedGroups are skipped.
+	var skippedGroups [][]int
+	skippedGroup := func(p *doc.Fragment) bool {
+		for _, g := range skippedGroups {
+			if p.InGroup(g) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	// The step delay is only inserted between fragments, so
+	// ranFragment is set once the first fragment runs.
+	ranFragment := false
+	fragmentDelay := func(delay time.Duration) time.Duration {
+		if ranFragment {
+			delay += tc.stepDelay
+		}
+
+		ranFragment = true
+		return delay
+	}
+
+	// Fragments up to bulkEnd were applied in a bulk run.
+	bulkEnd := 0
+
+	var bulk map[int]int
+	if tc.bulkApply > 0 {
+		bulk = bulkRuns(testDoc.Parts, tc.bulkApply)
+	}
+
+	for i, p := range testDoc.Parts {
+		fragmentID := fragmentIDs[i]
+
+		if i < bulkEnd {
+			continue
+		}
+
+		checkInput := func(op *driver.OperationResult) *CheckInput {
+			return &CheckInput{
+				OperationResult: op,
+				Step: CheckStep{
+					ID:       StepID(testDoc.Name, fragmentID, "check"),
+					Document: testDoc.Name,
+					Fragment: fragmentID,
+					RunID:    tc.envDriver.UniqueID(),
+				},
+			}
+		}
+
 		if !tc.recorder.ShouldContinue() {
 			break
 		}
 
+		if stopInterrupted(fragmentID) {
+			break
+		}
+
+		// A group-scoped skip skips the remaining fragments
+		// that were read from the same file, and from the
+		// files that it includes.
+		if skippedGroup(&p) {
+			step(tc.recorder, StepID(testDoc.Name, fragmentID, "skip"), fragmentStepDesc(&p, "skipping fragment"), func() {
+				tc.recorder.Update(result.Result{
+					Severity:  result.SeveritySkip,
+					Scope:     result.ScopeStep,
+					Message:   "skipped by an earlier skip in the same group",
+					Timestamp: time.Now(),
+				})
+			})
+
+			continue
+		}
+
+		if end, ok := bulk[i]; ok {
+			bulkEnd = end
+
+			if !pace(&tc, StepID(testDoc.Name, fragmentID, "delay"), fragmentDelay(0)) {
+				continue
+			}
+
+			run := bulkRun{
+				document: testDoc.Name,
+				id:       fragmentID,
+				what:     p.Object().GetKind() + " objects",
+				parts:    testDoc.Parts[i:end],
+				ids:      fragmentIDs[i:end],
+			}
+
+			if opResult := applyBulk(&tc, run, compiler, budget, mutations); opResult != nil {
+				lastOpResult = opResult
+			}
+
+			continue
+		}
+
+		// The items of a List object are applied in order, and
+		// checked once they have all been applied.
+		if items := p.Items(); items != nil {
+			if !pace(&tc, StepID(testDoc.Name, fragmentID, "delay"), fragmentDelay(0)) {
+				continue
+			}
+
+			run := bulkRun{
+				document: testDoc.Name,
+				id:       fragmentID,
+				what:     p.Object().GetKind() + " items",
+				parts:    items,
+				ids:      ItemIDs(fragmentID, items, fragmentIDs),
+			}
+
+			if opResult := applyBulk(&tc, run, compiler, budget, mutations); opResult != nil {
+				lastOpResult = opResult
+			}
+
+			continue
+		}
+
 		// TODO(jpeach): this is a step, record actions, errors, results.
 
 		// TODO(jpeach): if there are any pending fatal
@@ -229,9 +611,11 @@ func Run(testDoc *doc.Document, opts ...RunOpt) error {
 		case doc.FragmentTypeObject:
 			var obj *driver.Object
 			var opResult *driver.OperationResult
+			var skipFragment bool
 
 			step(tc.recorder,
-				fmt.Sprintf("hydrating Kubernetes object lines %s", p.Location),
+				StepID(testDoc.Name, fragmentID, "hydrate"),
+				fragmentStepDesc(&p, fmt.Sprintf("hydrating Kubernetes object lines %s", p.Location)),
 				func() {
 					obj, err = tc.envDriver.HydrateObject(p.Bytes)
 					if err != nil {
@@ -240,6 +624,12 @@ func Run(testDoc *doc.Document, opts ...RunOpt) error {
 						return
 					}
 
+					if err := defaultNamespace(tc.kubeDriver, obj.Object, tc.namespace); err != nil {
+						tc.recorder.Update(
+							result.Fatalf("failed to default object namespace: %s", err))
+						return
+					}
+
 					if obj.Object.GetName() == "" {
 						tc.recorder.Update(
 							result.Infof("hydrated anonymous %s:%s object",
@@ -253,14 +643,27 @@ func Run(testDoc *doc.Document, opts ...RunOpt) error {
 								utils.NamespaceOrDefault(obj.Object),
 								obj.Object.GetName()))
 					}
+
+					skipFragment = !testCondition(&tc, compiler, obj.When)
+					if !skipFragment {
+						skipFragment = testSkip(&tc, compiler, obj.Skip)
+					}
 				})
 
+			if skipFragment {
+				continue
+			}
+
+			if obj != nil && !pace(&tc, StepID(testDoc.Name, fragmentID, "delay"), fragmentDelay(obj.Delay)) {
+				continue
+			}
+
 			// If we don't have an object name, try to
 			// select it using the labels. Note that we
 			// may have to wait here, because the objects
 			// we want to select may not have been created
 			// yet.
-			step(tc.recorder, "matching anonymous Kubernetes object", func() {
+			step(tc.recorder, StepID(testDoc.Name, fragmentID, "match"), fragmentStepDesc(&p, "matching anonymous Kubernetes object"), func() {
 				if obj.Object.GetName() != "" {
 					return
 				}
@@ -310,7 +713,17 @@ func Run(testDoc *doc.Document, opts ...RunOpt) error {
 
 			})
 
-			step(tc.recorder, "updating Kubernetes object", func() {
+			step(tc.recorder, StepID(testDoc.Name, fragmentID, "update"), fragmentStepDesc(&p, "updating Kubernetes object"), func() {
+				if obj.Snapshot != "" {
+					if err := snapshotStore(tc.regoDriver, obj.Snapshot); err != nil {
+						tc.recorder.Update(result.Fatalf(
+							"failed to save store snapshot %q: %s", obj.Snapshot, err))
+						return
+					}
+
+					tc.recorder.Update(result.Infof("saved store snapshot %q", obj.Snapshot))
+				}
+
 				tc.recorder.Update(result.Infof(
 					"performing %s operation on %s '%s/%s'",
 					obj.Operation,
@@ -318,11 +731,54 @@ func Run(testDoc *doc.Document, opts ...RunOpt) error {
 					utils.NamespaceOrDefault(obj.Object),
 					obj.Object.GetName()))
 
+				if !paceKind(&tc, obj.Object.GetKind()) {
+					return
+				}
+
 				switch obj.Operation {
 				case driver.ObjectOperationUpdate:
-					opResult, err = applyObject(tc.kubeDriver, tc.objectDriver, obj.Object)
+					if budget != nil {
+						if err := budget.admit(obj.Object); err != nil {
+							tc.recorder.Update(result.Fatalf("%s", err))
+							return
+						}
+					}
+
+					if len(obj.Versions) > 0 {
+						opResult, err = applyVersions(tc.kubeDriver, tc.objectDriver, obj.Object, obj.Versions)
+					} else {
+						opResult, err = applyObject(tc.kubeDriver, tc.objectDriver, obj.Object)
+					}
+				case driver.ObjectOperationCreate:
+					if budget != nil {
+						if err := budget.admit(obj.Object); err != nil {
+							tc.recorder.Update(result.Fatalf("%s", err))
+							return
+						}
+					}
+
+					opResult, err = createObject(tc.kubeDriver, tc.objectDriver, obj.Object)
 				case driver.ObjectOperationDelete:
 					opResult, err = tc.objectDriver.Delete(obj.Object)
+					if budget != nil && err == nil {
+						budget.release(obj.Object)
+					}
+				case driver.ObjectOperationPatch:
+					// We can't know the effect of a patch
+					// from the patch itself, so check the
+					// budget against a dry run of the patch.
+					// If the dry run fails, the patch fails
+					// in the same way below.
+					if budget != nil {
+						if patched, err := patchedObject(tc.kubeDriver, obj.Object, obj.Patch); err == nil {
+							if err := budget.admit(patched); err != nil {
+								tc.recorder.Update(result.Fatalf("%s", err))
+								return
+							}
+						}
+					}
+
+					opResult, err = tc.objectDriver.Patch(obj.Object, obj.Patch)
 				}
 
 				if err != nil {
@@ -332,6 +788,33 @@ func Run(testDoc *doc.Document, opts ...RunOpt) error {
 					return
 				}
 
+				if opResult.Succeeded() {
+					tc.recorder.Update(appliedObjectResult(obj.Operation, obj.Object, opResult.Latest))
+				}
+
+				// Expose the view of the object at each of
+				// its versions to the check. Dry-run objects
+				// are not persisted, so they have no views.
+				if len(obj.Versions) > 0 && opResult.Succeeded() && !tc.dryRun {
+					opResult.Versions, err = tc.kubeDriver.GetObjectVersions(opResult.Latest, obj.Versions)
+					if err != nil {
+						tc.recorder.Update(result.Fatalf("%s", err))
+						return
+					}
+
+					tc.recorder.Update(result.Infof(
+						"fetched object at versions %s", strings.Join(obj.Versions, ", ")))
+				}
+
+				// The operation may have started informers for
+				// new resources, which need to sync before
+				// checks can see the objects.
+				if err := syncInformers(&tc, tc.checkTimeout); err != nil {
+					tc.recorder.Update(result.Fatalf(
+						"failed to store informer status: %s", err))
+					return
+				}
+
 				if opResult.Latest != nil {
 					// First, push the result into the store.
 					if err := storeItem(tc.regoDriver, "/resources/applied/last",
@@ -342,10 +825,64 @@ func Run(testDoc *doc.Document, opts ...RunOpt) error {
 					}
 
 					// TODO(jpeach): create an array at `/resources/applied/log` and append this.
+
+					alias := obj.Alias
+					if alias == "" {
+						alias = fragmentID
+					}
+
+					if opResult.Succeeded() && obj.Operation != driver.ObjectOperationDelete {
+						if err := storeAppliedIdentity(tc.regoDriver, alias, opResult.Latest); err != nil {
+							tc.recorder.Update(result.Fatalf(
+								"failed to store applied object identity: %s", err))
+							return
+						}
+					}
+
+					// Patches don't submit a whole object, so
+					// only updates and creates can be compared.
+					if opResult.Succeeded() &&
+						(obj.Operation == driver.ObjectOperationUpdate || obj.Operation == driver.ObjectOperationCreate) {
+						mutations.Record(StepID(testDoc.Name, fragmentID, "update"),
+							obj.Object, opResult.Latest)
+
+						if err := storeItem(tc.regoDriver, "/test/mutations", mutations.Entries); err != nil {
+							tc.recorder.Update(result.Fatalf(
+								"failed to store object mutations: %s", err))
+							return
+						}
+					}
+				}
+
+				if obj.Rotated && opResult.Succeeded() {
+					if err := storeSecretDigest(tc.regoDriver, obj.Object); err != nil {
+						tc.recorder.Update(result.Fatalf(
+							"failed to store secret digest: %s", err))
+						return
+					}
+
+					tc.recorder.Update(result.Infof(
+						"rotated Secret '%s/%s'",
+						utils.NamespaceOrDefault(obj.Object),
+						obj.Object.GetName()))
 				}
 			})
 
-			step(tc.recorder, "running object update check", func() {
+			if opResult != nil {
+				lastOpResult = opResult
+			}
+
+			// Wait for the object to reach the requested state
+			// before the check and the following fragments run.
+			// Dry-run objects are not persisted, so they never
+			// reach it.
+			if obj != nil && obj.Wait != nil && opResult != nil && opResult.Succeeded() && !tc.dryRun {
+				step(tc.recorder, StepID(testDoc.Name, fragmentID, "wait"), fragmentStepDesc(&p, "waiting for Kubernetes object"), func() {
+					waitForObject(&tc, opResult.Latest, obj.Wait)
+				})
+			}
+
+			step(tc.recorder, StepID(testDoc.Name, fragmentID, "check"), fragmentStepDesc(&p, "running object update check"), func() {
 				tc.recorder.Update(result.Infof(
 					"checking %s of %s '%s/%s'",
 					obj.Operation,
@@ -356,7 +893,7 @@ func Run(testDoc *doc.Document, opts ...RunOpt) error {
 				check := obj.Check
 				opts := []driver.RegoOpt{
 					rego.Compiler(compiler),
-					rego.Input(opResult),
+					rego.Input(checkInput(opResult)),
 				}
 
 				// If we have a check from the object,
@@ -371,25 +908,93 @@ func Run(testDoc *doc.Document, opts ...RunOpt) error {
 					check = DefaultObjectCheckForOperation(obj.Operation)
 				}
 
+				timeout := tc.checkTimeout
+				if obj.Timeout > 0 {
+					timeout = obj.Timeout
+				}
+
 				checkResults, err := runCheck(
-					tc.regoDriver, check, tc.checkTimeout, opts...)
+					tc.regoDriver, tc.objectDriver, check, timeout, tc.checkInterval, tc.interrupt, opts...)
 				if err != nil {
 					tc.recorder.Update(result.Fatalf("%s", err))
 				}
 
+				checkResults = scopeSkips(checkResults, obj.SkipScope)
+				if skipsGroup(checkResults) {
+					skippedGroups = append(skippedGroups, p.Group)
+				}
+
 				tc.recorder.Update(checkResults...)
 			})
 
 		case doc.FragmentTypeModule:
+			delay, delayErr := moduleDelay(p.Rego())
+			if delayErr != nil {
+				step(tc.recorder, StepID(testDoc.Name, fragmentID, "delay"), "delaying", func() {
+					tc.recorder.Update(result.Fatalf("%s", delayErr))
+				})
+
+				continue
+			}
+
+			if !pace(&tc, StepID(testDoc.Name, fragmentID, "delay"), fragmentDelay(delay)) {
+				continue
+			}
+
 			step(tc.recorder,
-				fmt.Sprintf("running Rego check lines %s", p.Location),
+				StepID(testDoc.Name, fragmentID, "check"),
+				fragmentStepDesc(&p, fmt.Sprintf("running Rego check lines %s", p.Location)),
 				func() {
+					when, err := moduleCondition(p.Rego())
+					if err != nil {
+						tc.recorder.Update(result.Fatalf("%s", err))
+						return
+					}
+
+					if !testCondition(&tc, compiler, when) {
+						return
+					}
+
+					scope, err := moduleSkipScope(p.Rego())
+					if err != nil {
+						tc.recorder.Update(result.Fatalf("%s", err))
+						return
+					}
+
+					snapshot, err := moduleSnapshot(p.Rego())
+					if err != nil {
+						tc.recorder.Update(result.Fatalf("%s", err))
+						return
+					}
+
+					if snapshot != "" {
+						if err := snapshotStore(tc.regoDriver, snapshot); err != nil {
+							tc.recorder.Update(result.Fatalf(
+								"failed to save store snapshot %q: %s", snapshot, err))
+							return
+						}
+
+						tc.recorder.Update(result.Infof("saved store snapshot %q", snapshot))
+					}
+
+					timeout, err := checkTimeout(p.Rego(), tc.checkTimeout)
+					if err != nil {
+						tc.recorder.Update(result.Fatalf("%s", err))
+						return
+					}
+
 					checkResults, err := runCheck(
-						tc.regoDriver, p.Rego(), tc.checkTimeout, rego.Compiler(compiler))
+						tc.regoDriver, tc.objectDriver, p.Rego(), timeout, tc.checkInterval, tc.interrupt,
+						rego.Compiler(compiler), rego.Input(checkInput(lastOpResult)))
 					if err != nil {
 						tc.recorder.Update(result.Fatalf("%s", err))
 					}
 
+					checkResults = scopeSkips(checkResults, scope)
+					if skipsGroup(checkResults) {
+						skippedGroups = append(skippedGroups, p.Group)
+					}
+
 					tc.recorder.Update(checkResults...)
 				})
 
@@ -404,12 +1009,49 @@ func Run(testDoc *doc.Document, opts ...RunOpt) error {
 		}
 	}
 
-	if tc.preserve {
-		step(tc.recorder, "preserving test objects", func() {})
-	} else {
-		step(tc.recorder, "deleting test objects", func() {
+	// Report an interrupt that stopped the last fragment.
+	stopInterrupted("")
+
+	desc := testDoc.Name
+	if testDoc.Meta.Name != "" {
+		desc = testDoc.Meta.Name
+	}
+
+	if tc.docDesc != "" {
+		desc = tc.docDesc
+	}
+
+	// Capture the final resources before we delete anything.
+	if tc.suite != nil {
+		if err := captureSuite(tc.recorder, tc.envDriver.UniqueID(), tc.regoDriver); err != nil {
+			return fmt.Errorf("failed to capture suite resources: %w", err)
+		}
+	}
+
+	if tc.artifactsDir != "" && tc.kubeDriver != nil && !tc.dryRun && failures.Failed() {
+		dir := artifactsPath(tc.artifactsDir, desc, tc.envDriver.UniqueID())
+
+		cleanupStep(tc.recorder, StepID(testDoc.Name, "", "artifacts"), "collecting failure diagnostics", func() {
+			if err := collectArtifacts(tc.kubeDriver, tc.regoDriver, tc.envDriver.UniqueID(), dir); err != nil {
+				tc.recorder.Update(result.Errorf("failed to collect diagnostics: %s", err))
+				return
+			}
+
+			tc.recorder.Update(result.Infof("wrote failure diagnostics to %s", dir))
+		})
+	}
+
+	switch {
+	case tc.dryRun:
+		cleanupStep(tc.recorder, StepID(testDoc.Name, "", "cleanup"), "skipping cleanup of dry-run objects", func() {})
+	case tc.preserve:
+		cleanupStep(tc.recorder, StepID(testDoc.Name, "", "cleanup"), "preserving test objects", func() {})
+	case tc.preserveOnFailure && failures.Failed() && !tc.interrupted():
+		cleanupStep(tc.recorder, StepID(testDoc.Name, "", "cleanup"), "preserving objects of failed test", func() {})
+	default:
+		cleanupStep(tc.recorder, StepID(testDoc.Name, "", "cleanup"), "deleting test objects", func() {
 			if err := tc.objectDriver.DeleteAll(); err != nil {
-				tc.recorder.Update(result.Fatalf("object deletion failed: %s", err))
+				tc.recorder.Update(cleanupResults(err)...)
 			}
 		})
 	}
@@ -418,7 +1060,86 @@ func Run(testDoc *doc.Document, opts ...RunOpt) error {
 	return nil
 }
 
+// cleanupResults returns a result for each object that DeleteAll
+// failed to delete. The value of each result identifies the object
+// and the reason that the deletion failed.
+func cleanupResults(err error) []result.Result {
+	var deleteErr *driver.DeleteAllError
+	if !errors.As(err, &deleteErr) {
+		return []result.Result{result.Errorf("object deletion failed: %s", err)}
+	}
+
+	results := make([]result.Result, 0, len(deleteErr.Failures))
+
+	for _, f := range deleteErr.Failures {
+		r := result.Errorf("failed to delete %s '%s/%s': %s",
+			f.Target.Meta.Kind, f.Target.Namespace, f.Target.Name, f.Err)
+		r.Value = map[string]interface{}{
+			"kind":      f.Target.Meta.Kind,
+			"namespace": f.Target.Namespace,
+			"name":      f.Target.Name,
+			"reason":    string(f.Reason),
+		}
+
+		results = append(results, r)
+	}
+
+	return results
+}
+
+// appliedObjectResult returns an informational result that records
+// the object that an operation succeeded on. The result value is a
+// JSONObject, so that structured output can list the objects of
+// each test document.
+func appliedObjectResult(op driver.ObjectOperationType, submitted *unstructured.Unstructured, latest *unstructured.Unstructured) result.Result {
+	u := latest
+	if u == nil {
+		u = submitted
+	}
+
+	o := JSONObject{
+		APIVersion: u.GetAPIVersion(),
+		Kind:       u.GetKind(),
+		Namespace:  u.GetNamespace(),
+		Name:       u.GetName(),
+		Operation:  string(op),
+	}
+
+	return result.Result{
+		Severity: result.SeverityNone,
+		Message: fmt.Sprintf("%s %s:%s '%s/%s' succeeded",
+			op, o.APIVersion, o.Kind, utils.NamespaceOrDefault(u), o.Name),
+		Value:     o,
+		Timestamp: time.Now(),
+	}
+}
+
 func applyObject(k *driver.KubeClient,
+	o driver.ObjectDriver,
+	u *unstructured.Unstructured) (*driver.OperationResult, error) {
+	if result, err := createImplicitNamespace(k, o, u); result != nil || err != nil {
+		return result, err
+	}
+
+	return o.Apply(u)
+}
+
+// createObject creates the object in the same way as applyObject,
+// except that it fails if the object already exists.
+func createObject(k *driver.KubeClient,
+	o driver.ObjectDriver,
+	u *unstructured.Unstructured) (*driver.OperationResult, error) {
+	if result, err := createImplicitNamespace(k, o, u); result != nil || err != nil {
+		return result, err
+	}
+
+	return o.Create(u)
+}
+
+// createImplicitNamespace creates the namespace of the object if it
+// doesn't exist. It returns the operation result only if creating the
+// namespace failed.
+func createImplicitNamespace(k *driver.KubeClient,
 	o driver.ObjectDriver,
 	u *unstructured.Unstructured) (*driver.OperationResult, error) {
 	// Implicitly create the object namespace to reduce test document boilerplate.
@@ -451,12 +1172,88 @@ func applyObject(k *driver.KubeClient,
 		}
 	}
 
-	return o.Apply(u)
+	return nil, nil
 }
 
-// compileDocument compiles all the Rego policies in the test document.
-func compileDocument(d *doc.Document, modules []*ast.Module) (*ast.Compiler, error) {
+// moduleCondition returns the condition query from a "$when:" comment
+// in the module, or nil if the module has no condition.
+func moduleCondition(m *ast.Module) (ast.Body, error) {
+	for _, c := range m.Comments {
+		text := strings.TrimSpace(string(c.Text))
+		if !strings.HasPrefix(text, "$when:") {
+			continue
+		}
+
+		query, err := ast.ParseBody(strings.TrimPrefix(text, "$when:"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %q comment: %w", "$when", err)
+		}
+
+		return query, nil
+	}
+
+	return nil, nil
+}
+
+// testCondition evaluates the condition for a fragment and returns
+// whether the fragment should run. A fragment with no condition
+// always runs. Evaluation errors are fatal.
+func testCondition(tc *testContext, compiler *ast.Compiler, when ast.Body) bool {
+	if when == nil {
+		return true
+	}
+
+	ok, err := tc.regoDriver.Test(when, rego.Compiler(compiler))
+	if err != nil {
+		tc.recorder.Update(result.Fatalf(
+			"failed to evaluate %q condition %q: %s", "$when", when, err))
+		return false
+	}
+
+	if !ok {
+		tc.recorder.Update(result.Infof(
+			"skipping fragment because %q condition %q is false", "$when", when))
+	}
+
+	return ok
+}
+
+// testSkip evaluates a "$skip" condition. If it is true, a Skip
+// result is recorded, which skips the rest of the test document.
+func testSkip(tc *testContext, compiler *ast.Compiler, skip ast.Body) bool {
+	if skip == nil {
+		return false
+	}
+
+	ok, err := tc.regoDriver.Test(skip, rego.Compiler(compiler))
+	if err != nil {
+		tc.recorder.Update(result.Fatalf(
+			"failed to evaluate %q condition %q: %s", doc.SkipKey, skip, err))
+		return true
+	}
+
+	if ok {
+		tc.recorder.Update(result.Skipf(
+			"skipped because %q condition %q is true", doc.SkipKey, skip))
+	}
+
+	return ok
+}
+
+// newCompiler returns a Rego compiler that restricts builtins to the
+// given capabilities. If capabilities is nil, all builtins are allowed.
+func newCompiler(capabilities *ast.Capabilities) *ast.Compiler {
 	compiler := ast.NewCompiler()
+	if capabilities != nil {
+		compiler = compiler.WithCapabilities(capabilities)
+	}
+
+	return compiler
+}
+
+// compileDocument compiles all the Rego policies in the test document.
+func compileDocument(d *doc.Document, modules []*ast.Module, capabilities *ast.Capabilities) (*ast.Compiler, error) {
+	compiler := newCompiler(capabilities)
 
 	modmap, err := builtin.CompileModules()
 	if err != nil {
@@ -495,13 +1292,20 @@ func compileDocument(d *doc.Document, modules []*ast.Module) (*ast.Compiler, err
 
 func runCheck(
 	c driver.RegoDriver,
+	o driver.ObjectDriver,
 	m *ast.Module,
 	timeout time.Duration,
+	interval time.Duration,
+	interrupt <-chan struct{},
 	opts ...driver.RegoOpt) ([]result.Result, error) {
 	var err error
 	var results []result.Result
 
 	startTime := time.Now()
+	backoff := NewCheckBackoff(interval)
+
+	// The I/O budget covers all the evaluations of the check.
+	c.ResetIOUsage()
 
 	for time.Since(startTime) < timeout {
 		results, err = c.Eval(m, opts...)
@@ -521,19 +1325,74 @@ func runCheck(
 			return nil, nil
 		}
 
-		time.Sleep(time.Millisecond * 500)
+		// If the informers are not delivering events, then the
+		// check is evaluating stale data and can't converge.
+		// Report that rather than waiting for the timeout.
+		if err := o.Health(); err != nil {
+			return append([]result.Result{
+				result.Fatalf("unhealthy Kubernetes event stream:\n%s", err),
+			}, results...), nil
+		}
+
+		select {
+		case <-interrupt:
+			// The caller will notice the interrupt
+			// and stop the test.
+			return results, nil
+		case <-time.After(backoff.Next()):
+		}
+	}
+
+	// Say where the time went, so that timeouts can be told
+	// apart from checks that fail on the first evaluation.
+	if len(results) > 0 {
+		results = append([]result.Result{
+			result.Infof("check did not succeed within %s", timeout),
+		}, results...)
 	}
 
 	return results, err
 }
 
-// Resources in the default namespace are stored as:
+// fragmentStepDesc returns the description of a step of the given
+// fragment. If the fragment has a leading comment, the comment
+// describes the step better than the generic description does, and
+// the step ID still tells the steps of the fragment apart.
+func fragmentStepDesc(p *doc.Fragment, generic string) string {
+	if desc := p.Description(); desc != "" {
+		return desc
+	}
+
+	return generic
+}
+
+// defaultNamespace sets the namespace of a namespaced object that
+// doesn't already have one.
+func defaultNamespace(k *driver.KubeClient, u *unstructured.Unstructured, ns string) error {
+	if k == nil || u.GetNamespace() != "" || ns == metav1.NamespaceDefault {
+		return nil
+	}
+
+	namespaced, err := k.KindIsNamespaced(u.GroupVersionKind())
+	if err != nil {
+		return err
+	}
+
+	if namespaced {
+		u.SetNamespace(ns)
+	}
+
+	return nil
+}
+
+// Resources in the default namespace (which may be overridden
+// by NamespaceOpt) are stored as:
 //	/resources/$resource/$name
 //
 // Namespaced resources are stored as:
 //     /resources/$namespace/$resource/$name
-func pathForResource(resource string, u *unstructured.Unstructured) string {
-	if u.GetNamespace() == metav1.NamespaceDefault {
+func pathForResource(resource string, defaultNS string, u *unstructured.Unstructured) string {
+	if u.GetNamespace() == defaultNS {
 		return path.Join("/", "resources", resource, u.GetName())
 	}
 
@@ -558,14 +1417,75 @@ func storeItem(c driver.RegoDriver, where string, what interface{}) error {
 	return err
 }
 
+// storeSecretDigest stores the digest of a rotated Secret at the
+// path '/test/secrets/$NAMESPACE/$NAME'.
+func storeSecretDigest(r driver.RegoDriver, u *unstructured.Unstructured) error {
+	digest, err := driver.SecretDigest(u)
+	if err != nil {
+		return err
+	}
+
+	digest["rotated"] = time.Now().UTC().Format(time.RFC3339)
+
+	return storeItem(r,
+		path.Join("/test/secrets", utils.NamespaceOrDefault(u), u.GetName()),
+		digest)
+}
+
+// storeAppliedIdentity stores the identity of an applied object at
+// the path '/test/applied/$ALIAS', so that later checks can verify
+// that the object was not recreated. If the alias was previously
+// applied with a different UID, the "recreated" field is set.
+func storeAppliedIdentity(r driver.RegoDriver, alias string, u *unstructured.Unstructured) error {
+	where := path.Join("/test/applied", alias)
+
+	identity := map[string]interface{}{
+		"apiVersion":      u.GetAPIVersion(),
+		"kind":            u.GetKind(),
+		"namespace":       u.GetNamespace(),
+		"name":            u.GetName(),
+		"uid":             string(u.GetUID()),
+		"resourceVersion": u.GetResourceVersion(),
+		"generation":      u.GetGeneration(),
+		"recreated":       false,
+	}
+
+	previous, err := r.ReadPath(where)
+	if err := ignoreStorageNotFoundErr(err); err != nil {
+		return err
+	}
+
+	if prev, ok := previous.(map[string]interface{}); ok {
+		if uid, ok := prev["uid"].(string); ok && uid != string(u.GetUID()) {
+			identity["recreated"] = true
+		}
+	}
+
+	return storeItem(r, where, identity)
+}
+
 // storeResourceVersions queries the API server for all resource
 // versions, and stores a list of GroupVersionKind objects at the
 // path '/resources/$RESOURCE/.versions'. This lets test documents
 // probe whether the facilities they need are available in the cluster.
-func storeResourceVersions(k *driver.KubeClient, r driver.RegoDriver) error {
+//
+// If discovery fails for some API groups, the versions of the other
+// resources are still stored. The failed group versions and their
+// errors are stored at '/cluster/discovery_errors' and returned.
+func storeResourceVersions(k *driver.KubeClient, r driver.RegoDriver) (map[string]string, error) {
 	resources, err := k.ServerResources()
-	if err != nil {
-		return fmt.Errorf("failed to query API server resources: %w", err)
+	failures := driver.DiscoveryFailures(err)
+	if err != nil && failures == nil {
+		return nil, fmt.Errorf("failed to query API server resources: %w", err)
+	}
+
+	discoveryErrors := map[string]interface{}{}
+	for gv, msg := range failures {
+		discoveryErrors[gv] = msg
+	}
+
+	if err := storeItem(r, "/cluster/discovery_errors", discoveryErrors); err != nil {
+		return nil, fmt.Errorf("failed to store discovery errors: %w", err)
 	}
 
 	resourceVersions := map[string][]schema.GroupVersionKind{}
@@ -587,16 +1507,16 @@ func storeResourceVersions(k *driver.KubeClient, r driver.RegoDriver) error {
 	for k, v := range resourceVersions {
 		versPath := path.Join("/", "resources", k, ".versions")
 		if err := storeItem(r, versPath, v); err != nil {
-			return fmt.Errorf("failed to store %q: %w", versPath, err)
+			return nil, fmt.Errorf("failed to store %q: %w", versPath, err)
 		}
 	}
 
-	return nil
+	return failures, nil
 }
 
 // storeResource stores a Kubernetes object in the resources hierarchy
 // of the Rego data document.
-func storeResource(k *driver.KubeClient, c driver.RegoDriver, u *unstructured.Unstructured) error {
+func storeResource(k *driver.KubeClient, c driver.RegoDriver, ns string, u *unstructured.Unstructured) error {
 	gvr, err := k.ResourceForKind(u.GetObjectKind().GroupVersionKind())
 	if err != nil {
 		return err
@@ -605,12 +1525,12 @@ func storeResource(k *driver.KubeClient, c driver.RegoDriver, u *unstructured.Un
 	// NOTE(jpeach): we have to marshall the inner object into
 	// the store because we don't want the resource enclosed in
 	// a dictionary with the key "Object".
-	return storeItem(c, pathForResource(gvr.Resource, u), u.UnstructuredContent())
+	return storeItem(c, pathForResource(gvr.Resource, ns, u), u.UnstructuredContent())
 }
 
 // removeResource removes a Kubernetes object from the resources hierarchy
 // of the Rego data document.
-func removeResource(k *driver.KubeClient, c driver.RegoDriver, u *unstructured.Unstructured) error {
+func removeResource(k *driver.KubeClient, c driver.RegoDriver, ns string, u *unstructured.Unstructured) error {
 	gvr, err := k.ResourceForKind(u.GetObjectKind().GroupVersionKind())
 	if err != nil {
 		return err
@@ -620,7 +1540,30 @@ func removeResource(k *driver.KubeClient, c driver.RegoDriver, u *unstructured.U
 	// as long as it's not there when we are done. We can end up
 	// receiving multiple delete events for the same object, which
 	// can attempt to remove the same path again.
-	return ignoreStorageNotFoundErr(c.RemovePath(pathForResource(gvr.Resource, u)))
+	return ignoreStorageNotFoundErr(c.RemovePath(pathForResource(gvr.Resource, ns, u)))
+}
+
+// storeTombstone records the deletion of a Kubernetes object. The
+// tombstone is stored at the path of the object in the resources
+// hierarchy, relative to '/test/tombstones' instead of '/resources'.
+func storeTombstone(k *driver.KubeClient, c driver.RegoDriver, ns string, u *unstructured.Unstructured, when time.Time) error {
+	gvr, err := k.ResourceForKind(u.GetObjectKind().GroupVersionKind())
+	if err != nil {
+		return err
+	}
+
+	return storeItem(c,
+		tombstonePath(pathForResource(gvr.Resource, ns, u)),
+		map[string]interface{}{
+			"kind":    u.GetKind(),
+			"uid":     string(u.GetUID()),
+			"deleted": when.UTC().Format(time.RFC3339Nano),
+		})
+}
+
+// tombstonePath returns the tombstone path for the given resource path.
+func tombstonePath(resourcePath string) string {
+	return path.Join("/", "test", "tombstones", strings.TrimPrefix(resourcePath, "/resources"))
 }
 
 func ignoreStorageNotFoundErr(err error) error {
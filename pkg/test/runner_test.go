@@ -15,15 +15,22 @@
 package test
 
 import (
+	"errors"
 	"testing"
 
+	"github.com/projectcontour/integration-tester/pkg/driver"
+	"github.com/projectcontour/integration-tester/pkg/result"
+	"github.com/projectcontour/integration-tester/pkg/utils"
+
 	"github.com/magiconair/properties/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 func TestPathforResource(t *testing.T) {
 	assert.Equal(t,
-		pathForResource("pods",
+		pathForResource("pods", "default",
 			&unstructured.Unstructured{
 				Object: map[string]interface{}{
 					"metadata": map[string]interface{}{
@@ -36,7 +43,7 @@ func TestPathforResource(t *testing.T) {
 	)
 
 	assert.Equal(t,
-		pathForResource("services",
+		pathForResource("services", "default",
 			&unstructured.Unstructured{
 				Object: map[string]interface{}{
 					"metadata": map[string]interface{}{
@@ -47,4 +54,130 @@ func TestPathforResource(t *testing.T) {
 			}),
 		"/resources/services/two",
 	)
+
+	assert.Equal(t,
+		pathForResource("services", "testing",
+			&unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"metadata": map[string]interface{}{
+						"name":      "three",
+						"namespace": "testing",
+					},
+				},
+			}),
+		"/resources/services/three",
+	)
+
+	assert.Equal(t,
+		pathForResource("services", "testing",
+			&unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"metadata": map[string]interface{}{
+						"name":      "four",
+						"namespace": "default",
+					},
+				},
+			}),
+		"/resources/default/services/four",
+	)
+}
+
+func TestModuleCondition(t *testing.T) {
+	m, err := utils.ParseCheckFragment("", `
+# $when: data.test.params.ingress == "contour"
+error[msg] {
+  msg := "failed"
+}
+`)
+	assert.Equal(t, err, nil)
+
+	when, err := moduleCondition(m)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(when), 1)
+	assert.Matches(t, when.String(), `data\.test\.params\.ingress`)
+
+	m, err = utils.ParseCheckFragment("", `
+error[msg] {
+  msg := "failed"
+}
+`)
+	assert.Equal(t, err, nil)
+
+	when, err = moduleCondition(m)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, when == nil, true)
+}
+
+func TestStoreAppliedIdentity(t *testing.T) {
+	r := driver.NewRegoDriver()
+
+	newObject := func(uid string, version string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{
+			Object: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "Service",
+				"metadata": map[string]interface{}{
+					"name":            "echo",
+					"namespace":       "default",
+					"uid":             uid,
+					"resourceVersion": version,
+				},
+			},
+		}
+	}
+
+	read := func() map[string]interface{} {
+		val, err := r.ReadPath("/test/applied/service/echo")
+		assert.Equal(t, err, nil)
+		return val.(map[string]interface{})
+	}
+
+	assert.Equal(t, storeAppliedIdentity(r, "service/echo", newObject("one", "1")), nil)
+	assert.Equal(t, read()["uid"], "one")
+	assert.Equal(t, read()["resourceVersion"], "1")
+	assert.Equal(t, read()["recreated"], false)
+
+	assert.Equal(t, storeAppliedIdentity(r, "service/echo", newObject("one", "2")), nil)
+	assert.Equal(t, read()["resourceVersion"], "2")
+	assert.Equal(t, read()["recreated"], false)
+
+	assert.Equal(t, storeAppliedIdentity(r, "service/echo", newObject("two", "3")), nil)
+	assert.Equal(t, read()["uid"], "two")
+	assert.Equal(t, read()["recreated"], true)
+}
+
+func TestTombstonePath(t *testing.T) {
+	assert.Equal(t, tombstonePath("/resources/pods/one"), "/test/tombstones/pods/one")
+	assert.Equal(t, tombstonePath("/resources/system/pods/one"), "/test/tombstones/system/pods/one")
+}
+
+func TestInformerKey(t *testing.T) {
+	assert.Equal(t, informerKey(schema.GroupVersionResource{Version: "v1", Resource: "pods"}), "pods.v1")
+	assert.Equal(t, informerKey(schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}),
+		"deployments.v1.apps")
+}
+
+func TestCleanupResults(t *testing.T) {
+	target := driver.ObjectReference{Name: "echo", Namespace: "default"}
+	target.Meta.Kind = "Service"
+
+	results := cleanupResults(&driver.DeleteAllError{
+		Failures: []driver.DeleteFailure{
+			{Target: target, Reason: metav1.StatusReasonForbidden, Err: errors.New("forbidden")},
+		},
+	})
+
+	assert.Equal(t, len(results), 1)
+	assert.Equal(t, results[0].Severity, result.SeverityError)
+	assert.Equal(t, results[0].Message, "failed to delete Service 'default/echo': forbidden")
+	assert.Equal(t, results[0].Value, map[string]interface{}{
+		"kind":      "Service",
+		"namespace": "default",
+		"name":      "echo",
+		"reason":    "Forbidden",
+	})
+
+	results = cleanupResults(errors.New("broken"))
+	assert.Equal(t, len(results), 1)
+	assert.Equal(t, results[0].Message, "object deletion failed: broken")
 }
@@ -0,0 +1,253 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+
+	"github.com/projectcontour/integration-tester/pkg/doc"
+	"github.com/projectcontour/integration-tester/pkg/driver"
+	"github.com/projectcontour/integration-tester/pkg/fixture"
+	"github.com/projectcontour/integration-tester/pkg/result"
+
+	"github.com/open-policy-agent/opa/rego"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// SelfCheck is a check of the integration-tester installation on
+// the current host. Self checks don't use a Kubernetes cluster, so
+// that installation problems can be told apart from cluster and test
+// problems.
+type SelfCheck struct {
+	Name string
+
+	// Run performs the check and returns a short description of
+	// what it found.
+	Run func() (string, error)
+}
+
+// SelfChecks returns the checks that the selftest command runs.
+func SelfChecks() []SelfCheck {
+	return []SelfCheck{
+		{Name: "builtin modules", Run: checkBuiltinModules},
+		{Name: "builtin fixtures", Run: checkBuiltinFixtures},
+		{Name: "self-test document", Run: checkSelfTestDocument},
+		{Name: "dns", Run: checkDNS},
+		{Name: "tls", Run: checkTLS},
+		{Name: "kubeconfig", Run: checkKubeConfig},
+	}
+}
+
+// selfTestDocument is the embedded test document. Its objects are
+// stored directly in the Rego data document instead of being applied
+// to a cluster, and its checks exercise the builtin modules and the
+// TLS probe.
+const selfTestDocument = `# Self-test document.
+
+import data.builtin.duration
+
+error[msg] {
+  not data.resources.deployments["integration-tester-echo"]
+  msg := "missing echo Deployment"
+}
+
+error[msg] {
+  svc := data.resources.services["integration-tester-echo"]
+  svc.spec.ports[0].port != 80
+  msg := sprintf("unexpected echo Service port %v", [svc.spec.ports[0].port])
+}
+
+error[msg] {
+  duration.ns("1m30s") != 90000000000
+  msg := "duration.ns returned the wrong value"
+}
+
+---
+
+error[msg] {
+  cert := tls.probe(data.selftest.tls.address, "example.com")
+  names := {n | n := cert.dns_names[_]}
+  not names["example.com"]
+  msg := sprintf("unexpected TLS certificate names %v", [cert.dns_names])
+}
+`
+
+func checkBuiltinModules() (string, error) {
+	compiler, err := compileDocument(&doc.Document{}, nil, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%d modules", len(compiler.Modules)), nil
+}
+
+func checkBuiltinFixtures() (string, error) {
+	if err := fixture.AddEcho(fixture.DefaultEchoImage); err != nil {
+		return "", err
+	}
+
+	for _, kind := range []string{"Deployment", "Service"} {
+		if _, err := echoFixture(kind); err != nil {
+			return "", err
+		}
+	}
+
+	return fixture.DefaultEchoImage, nil
+}
+
+// echoFixture returns the builtin echo fixture of the given kind.
+func echoFixture(kind string) (*unstructured.Unstructured, error) {
+	u := &unstructured.Unstructured{}
+	u.SetKind(kind)
+	u.SetName(fixture.EchoName)
+
+	switch kind {
+	case "Deployment":
+		u.SetAPIVersion("apps/v1")
+	default:
+		u.SetAPIVersion("v1")
+	}
+
+	f := fixture.Set.Match(u)
+	if f == nil {
+		return nil, fmt.Errorf("missing builtin %s fixture %q", kind, fixture.EchoName)
+	}
+
+	return f.AsUnstructured(), nil
+}
+
+// checkSelfTestDocument runs the embedded test document, using the
+// Rego data document as a fake cluster.
+func checkSelfTestDocument() (string, error) {
+	if err := fixture.AddEcho(fixture.DefaultEchoImage); err != nil {
+		return "", err
+	}
+
+	// The probe closes the connection after the handshake, so
+	// discard the server's handshake errors.
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	d, err := doc.ReadDocument(strings.NewReader(selfTestDocument))
+	if err != nil {
+		return "", err
+	}
+
+	for i := range d.Parts {
+		if _, err := d.Parts[i].Decode(); err != nil {
+			return "", fmt.Errorf("failed to decode fragment %d: %w", i, err)
+		}
+	}
+
+	compiler, err := compileDocument(d, nil, nil)
+	if err != nil {
+		return "", err
+	}
+
+	c := driver.NewRegoDriver()
+
+	for kind, resource := range map[string]string{"Deployment": "deployments", "Service": "services"} {
+		u, err := echoFixture(kind)
+		if err != nil {
+			return "", err
+		}
+
+		if err := storeItem(c, pathForResource(resource, u.GetNamespace(), u), u.UnstructuredContent()); err != nil {
+			return "", err
+		}
+	}
+
+	if err := storeItem(c, "/selftest/tls", map[string]interface{}{
+		"address": srv.Listener.Addr().String(),
+	}); err != nil {
+		return "", err
+	}
+
+	checks := 0
+
+	for _, p := range d.Parts {
+		if p.Type != doc.FragmentTypeModule {
+			continue
+		}
+
+		results, err := c.Eval(p.Rego(), rego.Compiler(compiler))
+		if err != nil {
+			return "", fmt.Errorf("fragment %s: %w", p.Location, err)
+		}
+
+		for _, r := range results {
+			if r.Severity == result.SeverityError || r.Severity == result.SeverityFatal {
+				return "", fmt.Errorf("fragment %s: %s", p.Location, r.Message)
+			}
+		}
+
+		checks++
+	}
+
+	return fmt.Sprintf("%d checks passed", checks), nil
+}
+
+func checkDNS() (string, error) {
+	addrs, err := net.LookupHost("localhost")
+	if err != nil {
+		return "", err
+	}
+
+	if len(addrs) == 0 {
+		return "", fmt.Errorf("no addresses for %q", "localhost")
+	}
+
+	return fmt.Sprintf("localhost is %s", strings.Join(addrs, ", ")), nil
+}
+
+func checkTLS() (string, error) {
+	// The system roots aren't available on Windows until Go 1.18.
+	if runtime.GOOS == "windows" {
+		return "system roots not checked on windows", nil
+	}
+
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		return "", fmt.Errorf("failed to load system root certificates: %w", err)
+	}
+
+	n := len(roots.Subjects())
+	if n == 0 {
+		return "", fmt.Errorf("no system root certificates")
+	}
+
+	return fmt.Sprintf("%d system root certificates", n), nil
+}
+
+func checkKubeConfig() (string, error) {
+	kube, err := driver.NewKubeClient()
+	if err != nil {
+		return "", err
+	}
+
+	// The cluster is not contacted, so this only checks that
+	// the configuration can be loaded.
+	return strings.Join(kube.Identifiers, ", "), nil
+}
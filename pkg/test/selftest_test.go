@@ -0,0 +1,39 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelfChecks(t *testing.T) {
+	for _, c := range SelfChecks() {
+		// The kubeconfig depends on the test environment.
+		if c.Name == "kubeconfig" {
+			continue
+		}
+
+		_, err := c.Run()
+		assert.NoError(t, err, c.Name)
+	}
+}
+
+func TestSelfTestDocument(t *testing.T) {
+	what, err := checkSelfTestDocument()
+	assert.NoError(t, err)
+	assert.Equal(t, "2 checks passed", what)
+}
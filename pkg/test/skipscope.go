@@ -0,0 +1,73 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/projectcontour/integration-tester/pkg/result"
+
+	"github.com/open-policy-agent/opa/ast"
+)
+
+// moduleSkipScope returns the scope from a "$skip-scope:" comment in
+// the module, or "" if the module has no scope.
+func moduleSkipScope(m *ast.Module) (result.Scope, error) {
+	for _, c := range m.Comments {
+		text := strings.TrimSpace(string(c.Text))
+		if !strings.HasPrefix(text, "$skip-scope:") {
+			continue
+		}
+
+		scope, err := result.ParseScope(strings.TrimSpace(strings.TrimPrefix(text, "$skip-scope:")))
+		if err != nil {
+			return "", fmt.Errorf("failed to parse %q comment: %w", "$skip-scope", err)
+		}
+
+		return scope, nil
+	}
+
+	return "", nil
+}
+
+// scopeSkips sets the scope of the Skip results that don't have one
+// to the given scope. Rules can still override the scope with a
+// "scope" field in their result.
+func scopeSkips(results []result.Result, scope result.Scope) []result.Result {
+	if scope == "" {
+		return results
+	}
+
+	for i := range results {
+		if results[i].Severity == result.SeveritySkip && results[i].Scope == "" {
+			results[i].Scope = scope
+		}
+	}
+
+	return results
+}
+
+// skipsGroup returns whether any of the results skip the rest of
+// the current fragment group.
+func skipsGroup(results []result.Result) bool {
+	for _, r := range results {
+		if r.Severity == result.SeveritySkip && r.Scope == result.ScopeGroup {
+			return true
+		}
+	}
+
+	return false
+}
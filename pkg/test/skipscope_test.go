@@ -0,0 +1,57 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"testing"
+
+	"github.com/projectcontour/integration-tester/pkg/result"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModuleSkipScope(t *testing.T) {
+	m, err := ast.ParseModule("skip.rego", `package skip
+# $skip-scope: group
+skip[msg] { msg := "skip" }
+`)
+	require.NoError(t, err)
+
+	scope, err := moduleSkipScope(m)
+	require.NoError(t, err)
+	assert.Equal(t, result.ScopeGroup, scope)
+}
+
+func TestScopeSkips(t *testing.T) {
+	ruleScoped := result.Skipf("rule")
+	ruleScoped.Scope = result.ScopeStep
+
+	results := scopeSkips([]result.Result{
+		result.Skipf("default"),
+		ruleScoped,
+		result.Errorf("error"),
+	}, result.ScopeGroup)
+
+	assert.Equal(t, result.ScopeGroup, results[0].Scope)
+	assert.Equal(t, result.ScopeStep, results[1].Scope)
+	assert.Equal(t, result.Scope(""), results[2].Scope)
+
+	assert.True(t, skipsGroup(results))
+	assert.False(t, results[0].IsTerminal())
+	assert.False(t, results[1].IsTerminal())
+	assert.True(t, result.Skipf("document").IsTerminal())
+}
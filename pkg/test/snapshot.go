@@ -0,0 +1,65 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/projectcontour/integration-tester/pkg/driver"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/storage"
+)
+
+// snapshotNameRegex matches valid snapshot names, which must be
+// usable as Rego references.
+var snapshotNameRegex = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]*$`)
+
+// snapshotStore copies the current Kubernetes resources in the Rego
+// store to the path '/test/snapshots/$NAME', so that later checks can
+// compare them with the 'store.diff' builtin.
+func snapshotStore(c driver.RegoDriver, name string) error {
+	resources, err := c.ReadPath("/resources")
+	switch {
+	case storage.IsNotFound(err):
+		resources = map[string]interface{}{}
+	case err != nil:
+		return err
+	}
+
+	return storeItem(c, "/test/snapshots/"+name, resources)
+}
+
+// moduleSnapshot returns the snapshot name from a "$store-snapshot:"
+// comment in the module, or "" if the module has no snapshot.
+func moduleSnapshot(m *ast.Module) (string, error) {
+	for _, c := range m.Comments {
+		text := strings.TrimSpace(string(c.Text))
+		if !strings.HasPrefix(text, "$store-snapshot:") {
+			continue
+		}
+
+		name := strings.TrimSpace(strings.TrimPrefix(text, "$store-snapshot:"))
+		if !snapshotNameRegex.MatchString(name) {
+			return "", fmt.Errorf("invalid %q comment %q", "$store-snapshot", name)
+		}
+
+		return name, nil
+	}
+
+	return "", nil
+}
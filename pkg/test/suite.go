@@ -0,0 +1,268 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/projectcontour/integration-tester/pkg/builtin"
+	"github.com/projectcontour/integration-tester/pkg/driver"
+	"github.com/projectcontour/integration-tester/pkg/result"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/rego"
+)
+
+// SuiteResult is a result that was recorded by a test document.
+type SuiteResult struct {
+	ID       string          `json:"id"`
+	Step     string          `json:"step"`
+	Severity result.Severity `json:"severity"`
+	Rule     string          `json:"rule,omitempty"`
+	Message  string          `json:"message"`
+}
+
+// SuiteDocument captures the final state of a test document.
+type SuiteDocument struct {
+	Name      string        `json:"name"`
+	RunID     string        `json:"runID"`
+	Resources interface{}   `json:"resources"`
+	Results   []SuiteResult `json:"results"`
+	Timings   Timings       `json:"timings"`
+}
+
+// Suite is a Recorder that aggregates the final resources and the
+// results of each test document, so that suite checks can make
+// assertions across all the documents in a test run.
+type Suite struct {
+	clock
+
+	Documents []*SuiteDocument
+
+	currentDoc    *SuiteDocument
+	currentStep   string
+	currentStepID string
+}
+
+var _ Recorder = &Suite{}
+
+// ShouldContinue ...
+func (s *Suite) ShouldContinue() bool {
+	return true
+}
+
+// Failed ...
+func (s *Suite) Failed() bool {
+	return false
+}
+
+// NewDocument ...
+func (s *Suite) NewDocument(desc string) Closer {
+	s.currentDoc = &SuiteDocument{
+		Name:    desc,
+		Results: []SuiteResult{},
+		Timings: Timings{},
+	}
+
+	return CloserFunc(func() {
+		s.Documents = append(s.Documents, s.currentDoc)
+		s.currentDoc = nil
+	})
+}
+
+// RetryDocument drops the retried document, so that suite checks
+// only see the final attempt.
+func (s *Suite) RetryDocument() {
+	if n := len(s.Documents); n > 0 {
+		s.Documents = s.Documents[:n-1]
+	}
+}
+
+// NewStep ...
+func (s *Suite) NewStep(id string, desc string) Closer {
+	s.currentStep = desc
+	s.currentStepID = id
+	timer := startStep(s.now(), id, desc)
+
+	return CloserFunc(func() {
+		s.currentDoc.Timings = append(s.currentDoc.Timings, timer.stop(s.now()))
+		s.currentStep = ""
+		s.currentStepID = ""
+	})
+}
+
+// Update ...
+func (s *Suite) Update(results ...result.Result) {
+	for _, r := range results {
+		s.currentDoc.Results = append(s.currentDoc.Results, SuiteResult{
+			ID:       s.currentStepID,
+			Step:     s.currentStep,
+			Severity: r.Severity,
+			Rule:     r.Rule,
+			Message:  r.Message,
+		})
+	}
+}
+
+func (s *Suite) captureDocument(runID string, resources interface{}) {
+	if s.currentDoc != nil {
+		s.currentDoc.RunID = runID
+		s.currentDoc.Resources = resources
+	}
+}
+
+// captureSuite records the final resources of the current test
+// document in the recorder stream, so that any Suite in r captures
+// them into the document that is being recorded. Since the capture
+// is part of the stream, a buffered document is captured when it is
+// replayed, and each retried attempt keeps its own resources.
+func captureSuite(r Recorder, runID string, rego driver.RegoDriver) error {
+	resources, err := rego.ReadPath("/resources")
+	if err != nil {
+		return ignoreStorageNotFoundErr(err)
+	}
+
+	captureDocument(r, runID, resources)
+	return nil
+}
+
+// captureDocument passes a captured test document to r, if r captures
+// documents, or to the recorders that it wraps.
+func captureDocument(r Recorder, runID string, resources interface{}) {
+	if c, ok := r.(interface {
+		captureDocument(string, interface{})
+	}); ok {
+		c.captureDocument(runID, resources)
+	}
+}
+
+// generic returns the suite documents as generic JSON data.
+func (s *Suite) generic() (interface{}, error) {
+	// Round trip through JSON so that we end up with the same
+	// generic types that the Rego store would read itself.
+	data, err := json.Marshal(s.Documents)
+	if err != nil {
+		return nil, err
+	}
+
+	var documents interface{}
+	if err := json.Unmarshal(data, &documents); err != nil {
+		return nil, err
+	}
+
+	return documents, nil
+}
+
+// WriteSnapshot writes the suite documents to w as a JSON snapshot
+// of the test run. If scrub is not nil, it is applied to the
+// documents before they are written.
+func (s *Suite) WriteSnapshot(w io.Writer, scrub func(interface{}) interface{}) error {
+	documents, err := s.generic()
+	if err != nil {
+		return err
+	}
+
+	if scrub != nil {
+		documents = scrub(documents)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(map[string]interface{}{
+		"documents": documents,
+	})
+}
+
+// SuiteOpt captures the final state of the test document into the
+// given Suite. The capture is recorded like a result, so the Suite
+// must also be one of the recorders that the document records into.
+func SuiteOpt(s *Suite) RunOpt {
+	return RunOpt(func(tc *testContext) {
+		tc.suite = s
+	})
+}
+
+// RunSuiteChecks evaluates each of the checks once, against the
+// aggregated documents in the suite. The suite documents are
+// published in the Rego data document at `data.suite.documents`.
+// Any additional policy modules are made available to the checks. If
+// capabilities is not nil, the checks can only use the builtins that
+// it allows. The rules named in severities are treated as test
+// results, like they are in test documents.
+func RunSuiteChecks(s *Suite, r Recorder, checks []*ast.Module, policies []*ast.Module, capabilities *ast.Capabilities, severities *driver.RuleSeverities) error {
+	regoDriver := driver.NewRegoDriver()
+	regoDriver.SetRuleSeverities(severities)
+
+	documents, err := s.generic()
+	if err != nil {
+		return err
+	}
+
+	if err := storeItem(regoDriver, "/suite/documents", documents); err != nil {
+		return fmt.Errorf("failed to store suite documents: %w", err)
+	}
+
+	modmap, err := builtin.CompileModules()
+	if err != nil {
+		return fmt.Errorf("failed to compile builtin modules: %w", err)
+	}
+
+	for _, modules := range [][]*ast.Module{policies, checks} {
+		for _, m := range modules {
+			name := m.Package.Loc().File
+			if _, ok := modmap[name]; ok {
+				return fmt.Errorf("duplicate Rego module file %q", name)
+			}
+
+			modmap[name] = m
+		}
+	}
+
+	docCloser := r.NewDocument("suite checks")
+	defer docCloser.Close()
+
+	compiler := newCompiler(capabilities)
+
+	step(r, StepID("suite", "", "compile"), "compiling suite checks", func() {
+		if compiler.Compile(modmap); compiler.Failed() {
+			r.Update(result.Fatalf("%s", compiler.Errors))
+		}
+	})
+
+	// Run the checks in a stable order.
+	sorted := make([]*ast.Module, len(checks))
+	copy(sorted, checks)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Package.Loc().File < sorted[j].Package.Loc().File
+	})
+
+	for _, m := range sorted {
+		file := m.Package.Loc().File
+		step(r, StepID("suite", file, "check"), fmt.Sprintf("running suite check %s", file), func() {
+			checkResults, err := regoDriver.Eval(m, rego.Compiler(compiler))
+			if err != nil {
+				r.Update(result.Fatalf("%s", err))
+			}
+
+			r.Update(checkResults...)
+		})
+	}
+
+	return nil
+}
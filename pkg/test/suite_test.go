@@ -0,0 +1,136 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"testing"
+
+	"github.com/projectcontour/integration-tester/pkg/result"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuiteRecordsResults(t *testing.T) {
+	s := &Suite{}
+
+	docCloser := s.NewDocument("one.yaml")
+	stepCloser := s.NewStep("one.yaml#compile", "first step")
+	s.Update(result.Infof("info"), result.Errorf("error"))
+	stepCloser.Close()
+	docCloser.Close()
+
+	require.Len(t, s.Documents, 1)
+	assert.Equal(t, "one.yaml", s.Documents[0].Name)
+	assert.Equal(t, []SuiteResult{
+		{ID: "one.yaml#compile", Step: "first step", Severity: result.SeverityNone, Message: "info"},
+		{ID: "one.yaml#compile", Step: "first step", Severity: result.SeverityError, Message: "error"},
+	}, s.Documents[0].Results)
+}
+
+func TestSuiteCapturesBufferedRetries(t *testing.T) {
+	s := &Suite{}
+	buf := &DocumentBuffer{}
+
+	// Both attempts complete before the buffer is replayed, like
+	// a retried document that runs in parallel.
+	for attempt, runID := range []string{"run-1", "run-1-retry-1"} {
+		if attempt > 0 {
+			buf.RetryDocument()
+		}
+
+		docCloser := buf.NewDocument("echo.yaml")
+		captureDocument(buf, runID, map[string]interface{}{"attempt": attempt})
+		docCloser.Close()
+	}
+
+	buf.Flush(LimitRecorders(StackRecorders(s, NewRecorder()), ResultLimits{}))
+
+	require.Len(t, s.Documents, 1)
+	assert.Equal(t, "run-1-retry-1", s.Documents[0].RunID)
+	assert.Equal(t, map[string]interface{}{"attempt": 1}, s.Documents[0].Resources)
+}
+
+func TestRunSuiteChecks(t *testing.T) {
+	s := &Suite{
+		Documents: []*SuiteDocument{{
+			Name:    "one.yaml",
+			Results: []SuiteResult{{Severity: result.SeverityError, Message: "bad"}},
+		}, {
+			Name:    "two.yaml",
+			Results: []SuiteResult{},
+		}},
+	}
+
+	check, err := ast.ParseModule("suite.rego", `
+package suite
+
+error[msg] {
+  doc := data.suite.documents[_]
+  doc.results[_].severity == "Error"
+  msg := sprintf("%s failed", [doc.name])
+}
+`)
+	require.NoError(t, err)
+
+	r := &defaultRecorder{}
+	require.NoError(t, RunSuiteChecks(s, r, []*ast.Module{check}, nil, nil, nil))
+
+	require.Len(t, r.docs, 1)
+	assert.True(t, r.Failed())
+
+	var messages []string
+	r.docs[0].EachResult(func(_ *Step, res *result.Result) {
+		messages = append(messages, res.Text())
+	})
+
+	assert.Equal(t, []string{"raised predicate \"error\"\none.yaml failed"}, messages)
+}
+
+func TestRunSuiteChecksCapabilities(t *testing.T) {
+	check, err := ast.ParseModule("suite.rego", `
+package suite
+
+error[msg] {
+  resp := http.send({"method": "GET", "url": "http://example.com"})
+  msg := sprintf("status %d", [resp.status_code])
+}
+`)
+	require.NoError(t, err)
+
+	capabilities := ast.CapabilitiesForThisVersion()
+	allowed := capabilities.Builtins[:0]
+	for _, b := range capabilities.Builtins {
+		if b.Name != "http.send" {
+			allowed = append(allowed, b)
+		}
+	}
+	capabilities.Builtins = allowed
+
+	r := &defaultRecorder{}
+	require.NoError(t, RunSuiteChecks(&Suite{}, r, []*ast.Module{check}, nil, capabilities, nil))
+
+	require.Len(t, r.docs, 1)
+	assert.False(t, r.ShouldContinue())
+
+	var messages []string
+	r.docs[0].EachResult(func(_ *Step, res *result.Result) {
+		messages = append(messages, res.Text())
+	})
+
+	require.NotEmpty(t, messages)
+	assert.Contains(t, messages[0], "undefined function http.send")
+}
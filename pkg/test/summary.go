@@ -17,6 +17,7 @@ package test
 import (
 	"fmt"
 	"io"
+	"strings"
 	"text/tabwriter"
 
 	"github.com/projectcontour/integration-tester/pkg/must"
@@ -24,14 +25,30 @@ import (
 )
 
 type docSummary struct {
-	doc    string
-	status result.Severity
+	doc     string
+	status  result.Severity
+	retries int
+
+	// cleanupFailed is set if the test objects could not be
+	// deleted. This doesn't change the status of the document.
+	cleanupFailed bool
 }
 
 // SummaryWriter collects a summary of the final test results.
 type SummaryWriter struct {
+	clock
+
 	currentDoc *docSummary
 	docResults []docSummary
+	timings    Timings
+
+	// retries is the number of failed attempts of the next
+	// document.
+	retries int
+
+	// inCleanup is set while the cleanup step of a document is
+	// open.
+	inCleanup bool
 }
 
 var _ Recorder = &SummaryWriter{}
@@ -48,7 +65,9 @@ func (s *SummaryWriter) Failed() bool {
 
 // NewDocument ...
 func (s *SummaryWriter) NewDocument(desc string) Closer {
-	s.currentDoc = &docSummary{doc: desc, status: result.SeverityNone}
+	s.currentDoc = &docSummary{doc: desc, status: result.SeverityNone, retries: s.retries}
+	s.retries = 0
+
 	return CloserFunc(func() {
 		s.docResults = append(s.docResults, *s.currentDoc)
 		s.currentDoc = nil
@@ -56,22 +75,47 @@ func (s *SummaryWriter) NewDocument(desc string) Closer {
 }
 
 // NewStep ...
-func (s *SummaryWriter) NewStep(desc string) Closer {
-	return CloserFunc(nil)
+func (s *SummaryWriter) NewStep(id string, desc string) Closer {
+	timer := startStep(s.now(), id, desc)
+	s.inCleanup = strings.HasSuffix(id, "#cleanup")
+
+	return CloserFunc(func() {
+		s.timings = append(s.timings, timer.stop(s.now()))
+		s.inCleanup = false
+	})
 }
 
 // Update ...
 func (s *SummaryWriter) Update(results ...result.Result) {
 	for _, r := range results {
+		if s.inCleanup && r.IsFailed() {
+			s.currentDoc.cleanupFailed = true
+			continue
+		}
+
 		switch r.Severity {
 		case result.SeverityFatal,
-			result.SeverityError,
-			result.SeveritySkip:
+			result.SeverityError:
 			s.currentDoc.status = r.Severity
+		case result.SeveritySkip:
+			// A skip that is scoped to a step or group
+			// doesn't skip the document.
+			if r.IsTerminal() {
+				s.currentDoc.status = r.Severity
+			}
 		}
 	}
 }
 
+// RetryDocument replaces the summary of the retried document with
+// the summary of the next attempt.
+func (s *SummaryWriter) RetryDocument() {
+	if n := len(s.docResults); n > 0 {
+		s.retries = s.docResults[n-1].retries + 1
+		s.docResults = s.docResults[:n-1]
+	}
+}
+
 // Summarize write a summary of the test results to out.
 func (s *SummaryWriter) Summarize(out io.Writer) {
 	summaryNames := map[result.Severity]string{
@@ -86,8 +130,90 @@ func (s *SummaryWriter) Summarize(out io.Writer) {
 	fmt.Fprintf(tab, "\n")
 
 	for _, r := range s.docResults {
-		fmt.Fprintf(tab, "%s\t%s\n", r.doc, summaryNames[r.status])
+		status := summaryNames[r.status]
+
+		// A document that passes after being retried is flaky.
+		if r.status == result.SeverityNone && r.retries > 0 {
+			status = fmt.Sprintf("FLAKY PASS (%d retries)", r.retries)
+		}
+
+		if r.cleanupFailed {
+			status += " (CLEANUP FAILED)"
+		}
+
+		fmt.Fprintf(tab, "%s\t%s\n", r.doc, status)
 	}
 
 	must.Must(tab.Flush())
 }
+
+// OnlyCleanupFailed returns true if the objects of some test
+// documents could not be deleted, but every test document otherwise
+// passed or was skipped.
+func (s *SummaryWriter) OnlyCleanupFailed() bool {
+	cleanupFailed := false
+
+	for _, r := range s.docResults {
+		switch r.status {
+		case result.SeverityError, result.SeverityFatal:
+			return false
+		}
+
+		if r.cleanupFailed {
+			cleanupFailed = true
+		}
+	}
+
+	return cleanupFailed
+}
+
+// IterationDesc returns the description of the given iteration of a
+// test document that is run count times.
+func IterationDesc(desc string, iteration int, count int) string {
+	return fmt.Sprintf("%s [%d/%d]", desc, iteration, count)
+}
+
+// SummarizeIterations writes the number of iterations of each of the
+// given test documents that passed, failed or were skipped to out.
+// The iterations are matched by the description from IterationDesc.
+func (s *SummaryWriter) SummarizeIterations(out io.Writer, docs []string, count int) {
+	results := map[string]result.Severity{}
+	for _, r := range s.docResults {
+		results[r.doc] = r.status
+	}
+
+	tab := tabwriter.NewWriter(out, 0, 4, 4, ' ', 0)
+
+	fmt.Fprintf(tab, "\n")
+
+	for _, d := range docs {
+		var passed, failed, skipped int
+
+		for i := 1; i <= count; i++ {
+			status, ok := results[IterationDesc(d, i, count)]
+			if !ok {
+				continue
+			}
+
+			switch status {
+			case result.SeverityError, result.SeverityFatal:
+				failed++
+			case result.SeveritySkip:
+				skipped++
+			default:
+				passed++
+			}
+		}
+
+		fmt.Fprintf(tab, "%s\t%d/%d PASSED\t%d FAILED\t%d SKIPPED\n",
+			d, passed, count, failed, skipped)
+	}
+
+	must.Must(tab.Flush())
+}
+
+// SummarizeTimings writes a breakdown of where the test run spent
+// its time, including the n slowest steps, to out.
+func (s *SummaryWriter) SummarizeTimings(out io.Writer, n int) {
+	s.timings.WriteReport(out, n)
+}
@@ -0,0 +1,132 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/projectcontour/integration-tester/pkg/result"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummarizeIterations(t *testing.T) {
+	s := &SummaryWriter{}
+
+	run := func(desc string, results ...result.Result) {
+		closer := s.NewDocument(desc)
+		s.NewStep("id", "step").Close()
+		s.Update(results...)
+		closer.Close()
+	}
+
+	run(IterationDesc("one.yaml", 1, 3))
+	run(IterationDesc("two.yaml", 1, 3), result.Errorf("broken"))
+	run(IterationDesc("one.yaml", 2, 3), result.Infof("ok"))
+	run(IterationDesc("two.yaml", 2, 3))
+	run(IterationDesc("one.yaml", 3, 3), result.Skipf("skipped"))
+	run(IterationDesc("two.yaml", 3, 3), result.Fatalf("broken"))
+
+	var out bytes.Buffer
+	s.SummarizeIterations(&out, []string{"one.yaml", "two.yaml"}, 3)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.Equal(t, []string{"one.yaml", "2/3", "PASSED", "0", "FAILED", "1", "SKIPPED"}, strings.Fields(lines[0]))
+	assert.Equal(t, []string{"two.yaml", "1/3", "PASSED", "2", "FAILED", "0", "SKIPPED"}, strings.Fields(lines[1]))
+}
+
+func TestSummarizeRetries(t *testing.T) {
+	s := &SummaryWriter{}
+
+	run := func(desc string, results ...result.Result) {
+		closer := s.NewDocument(desc)
+		s.NewStep("id", "step").Close()
+		s.Update(results...)
+		closer.Close()
+	}
+
+	run("one.yaml", result.Errorf("broken"))
+	s.RetryDocument()
+	run("one.yaml", result.Errorf("broken"))
+	s.RetryDocument()
+	run("one.yaml")
+
+	run("two.yaml", result.Errorf("broken"))
+	s.RetryDocument()
+	run("two.yaml", result.Errorf("broken"))
+
+	var out bytes.Buffer
+	s.Summarize(&out)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.Equal(t, []string{"one.yaml", "FLAKY", "PASS", "(2", "retries)"}, strings.Fields(lines[0]))
+	assert.Equal(t, []string{"two.yaml", "FAILED"}, strings.Fields(lines[1]))
+}
+
+func TestSummarizeCleanupFailure(t *testing.T) {
+	s := &SummaryWriter{}
+
+	closer := s.NewDocument("one.yaml")
+	step := s.NewStep("one.yaml#service/echo:check", "step")
+	s.Update(result.Infof("ok"))
+	step.Close()
+	step = s.NewStep("one.yaml#cleanup", "deleting test objects")
+	s.Update(result.Errorf("failed to delete Service 'default/echo': forbidden"))
+	step.Close()
+	closer.Close()
+
+	var out bytes.Buffer
+	s.Summarize(&out)
+
+	assert.Equal(t, "one.yaml    PASSED (CLEANUP FAILED)", strings.TrimSpace(out.String()))
+	assert.True(t, s.OnlyCleanupFailed())
+
+	closer = s.NewDocument("two.yaml")
+	step = s.NewStep("two.yaml#0:check", "step")
+	s.Update(result.Errorf("broken"))
+	step.Close()
+	closer.Close()
+
+	assert.False(t, s.OnlyCleanupFailed())
+}
+
+func TestSummarizeScopedSkips(t *testing.T) {
+	s := &SummaryWriter{}
+
+	run := func(desc string, results ...result.Result) {
+		closer := s.NewDocument(desc)
+		s.NewStep("id", "step").Close()
+		s.Update(results...)
+		closer.Close()
+	}
+
+	stepSkip := result.Skipf("skipped")
+	stepSkip.Scope = result.ScopeStep
+
+	run("one.yaml", stepSkip)
+	run("two.yaml", result.Skipf("skipped"))
+
+	var out bytes.Buffer
+	s.Summarize(&out)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.Equal(t, []string{"one.yaml", "PASSED"}, strings.Fields(lines[0]))
+	assert.Equal(t, []string{"two.yaml", "SKIPPED"}, strings.Fields(lines[1]))
+}
@@ -16,6 +16,7 @@ package test
 
 import (
 	"fmt"
+	"io"
 	"strings"
 
 	"github.com/projectcontour/integration-tester/pkg/must"
@@ -24,12 +25,30 @@ import (
 	"sigs.k8s.io/yaml"
 )
 
-// TapWriter writes test records in TAP format.
+// TapWriter writes test records in TAP format. All the test
+// documents in a run are reported as a single TAP stream, so the
+// plan is written when the TapWriter is closed at the end of the run.
 // See https://testanything.org/tap-version-13-specification.html
 type TapWriter struct {
-	docCount  int
+	// Out is where the results are written. If Out is nil, the
+	// results are written to standard output.
+	Out io.Writer
+
+	// BailOut specifies that the TAP stream is stopped with a
+	// "Bail out!" line when a step records a fatal result. No
+	// further tests are run after bailing out.
+	BailOut bool
+
+	started   bool
+	failed    bool
+	bailed    bool
 	stepCount int
 
+	// failedBefore is whether any step failed before the
+	// current document, so that a retried document can be
+	// discounted.
+	failedBefore bool
+
 	stepErrors []result.Result
 	stepSkips  []result.Result
 }
@@ -38,83 +57,150 @@ var _ Recorder = &TapWriter{}
 
 // indentf prints a (possibly multi-line) message, prefixed by the indent.
 // nolint(unparam)
-func indentf(indent string, format string, args ...interface{}) {
+func indentf(w io.Writer, indent string, format string, args ...interface{}) {
 	msg := fmt.Sprintf(format, args...)
 	for _, line := range strings.Split(msg, "\n") {
-		fmt.Printf("%s%s\n", indent, line)
+		fmt.Fprintf(w, "%s%s\n", indent, line)
 	}
 }
 
-// ShouldContinue ...
+func (t *TapWriter) out() io.Writer {
+	return outputOrStdout(t.Out)
+}
+
+// ShouldContinue returns false if the TAP stream bailed out.
 func (t *TapWriter) ShouldContinue() bool {
-	return true
+	return !t.bailed
 }
 
-// Failed ...
+// Failed returns true if any step recorded an error.
 func (t *TapWriter) Failed() bool {
-	return false
+	return t.failed
 }
 
 // NewDocument ...
 func (t *TapWriter) NewDocument(desc string) Closer {
-	// It's not obvious how TAP separates test runs into suites
-	// (maybe it doesn't?). Let's stuff a newline in there so at
-	// least it's visually distinguished.
-	if t.docCount == 0 {
-		fmt.Printf("TAP version 13\n")
-	} else {
-		fmt.Printf("\nTAP version 13\n")
+	if t.bailed {
+		return CloserFunc(nil)
 	}
 
-	t.docCount++
-	t.stepCount = 0
-	t.stepErrors = nil
-	t.stepSkips = nil
+	t.start()
+	t.failedBefore = t.failed
 
-	return CloserFunc(func() {
-		// NOTE, it's a closed interval.
-		fmt.Printf("1..%d\n", t.stepCount)
-	})
+	// TAP has no notion of test suites, so we separate test
+	// documents with a comment.
+	indentf(t.out(), "# ", "Running: %s", desc)
+
+	return CloserFunc(nil)
+}
+
+// RetryDocument discounts the failures of the retried document. The
+// TAP stream has already reported its steps, so the retry is noted
+// in a comment.
+func (t *TapWriter) RetryDocument() {
+	if t.bailed {
+		return
+	}
+
+	t.failed = t.failedBefore
+	indentf(t.out(), "# ", "Retrying the failed document")
 }
 
 // NewStep ...
-func (t *TapWriter) NewStep(desc string) Closer {
-	stepNum := t.stepCount + 1
+func (t *TapWriter) NewStep(id string, desc string) Closer {
+	if t.bailed {
+		return CloserFunc(nil)
+	}
+
 	t.stepCount++
+	stepNum := t.stepCount
+
+	// Use the stable step ID as the TAP test description so
+	// that results can be tracked across runs. The (human
+	// readable) step description becomes a comment.
+	indentf(t.out(), "# ", desc)
 
 	return CloserFunc(func() {
 		switch {
 		case len(t.stepErrors) > 0:
-			fmt.Printf("not ok %d - %s\n", stepNum, desc)
+			fmt.Fprintf(t.out(), "not ok %d - %s\n", stepNum, id)
 		case len(t.stepSkips) > 0:
-			fmt.Printf("ok %d - %s # skip\n", stepNum, desc)
+			fmt.Fprintf(t.out(), "ok %d - %s # skip\n", stepNum, id)
 		default:
-			fmt.Printf("ok %d - %s\n", stepNum, desc)
+			fmt.Fprintf(t.out(), "ok %d - %s\n", stepNum, id)
 		}
 
 		if len(t.stepErrors) > 0 {
 			indent := "  "
-			indentf(indent, "---")
-			indentf(indent, string(must.Bytes(yaml.Marshal(t.stepErrors))))
-			indentf(indent, "...")
+			indentf(t.out(), indent, "---")
+			indentf(t.out(), indent, string(must.Bytes(yaml.Marshal(t.stepErrors))))
+			indentf(t.out(), indent, "...")
+		}
+
+		if t.BailOut {
+			for _, r := range t.stepErrors {
+				if r.IsTerminal() {
+					fmt.Fprintf(t.out(), "Bail out! %s\n", firstLine(r.Text()))
+					t.bailed = true
+					break
+				}
+			}
 		}
 
 		t.stepErrors = nil
+		t.stepSkips = nil
 	})
 }
 
 // Update ...
 func (t *TapWriter) Update(results ...result.Result) {
+	if t.bailed {
+		return
+	}
+
 	for _, r := range results {
 		switch r.Severity {
 		case result.SeverityNone:
-			indentf("# ", r.Message)
+			indentf(t.out(), "# ", r.Text())
 		case result.SeveritySkip:
-			indentf(fmt.Sprintf("# %s - ", string(r.Severity)), r.Message)
+			indentf(t.out(), fmt.Sprintf("# %s - ", string(r.Severity)), r.Text())
 			t.stepSkips = append(t.stepSkips, r)
 		default:
-			indentf(fmt.Sprintf("# %s - ", string(r.Severity)), r.Message)
+			indentf(t.out(), fmt.Sprintf("# %s - ", string(r.Severity)), r.Text())
 			t.stepErrors = append(t.stepErrors, r)
+
+			if r.IsFailed() {
+				t.failed = true
+			}
 		}
 	}
 }
+
+// Close ends the TAP stream by writing the plan for all the steps
+// in the run. The plan is omitted if the stream bailed out.
+func (t *TapWriter) Close() {
+	if t.bailed {
+		return
+	}
+
+	t.start()
+
+	// NOTE, it's a closed interval.
+	fmt.Fprintf(t.out(), "1..%d\n", t.stepCount)
+}
+
+func (t *TapWriter) start() {
+	if !t.started {
+		fmt.Fprintf(t.out(), "TAP version 13\n")
+		t.started = true
+	}
+}
+
+// firstLine returns the first line of a (possibly multi-line) message.
+func firstLine(msg string) string {
+	if i := strings.IndexByte(msg, '\n'); i >= 0 {
+		return msg[:i]
+	}
+
+	return msg
+}
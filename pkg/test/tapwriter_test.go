@@ -0,0 +1,91 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/projectcontour/integration-tester/pkg/result"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTapWriter(t *testing.T) {
+	buf := bytes.Buffer{}
+	tap := &TapWriter{Out: &buf}
+
+	docCloser := tap.NewDocument("one.yaml")
+	stepCloser := tap.NewStep("one.yaml#compile", "compiling")
+	tap.Update(result.Infof("compiled"))
+	stepCloser.Close()
+	docCloser.Close()
+
+	assert.False(t, tap.Failed())
+
+	docCloser = tap.NewDocument("two.yaml")
+	stepCloser = tap.NewStep("two.yaml#0:check", "checking")
+	tap.Update(result.Errorf("failed"))
+	stepCloser.Close()
+	stepCloser = tap.NewStep("two.yaml#cleanup", "cleaning up")
+	tap.Update(result.Skipf("skipped"))
+	stepCloser.Close()
+	docCloser.Close()
+
+	tap.Close()
+
+	assert.True(t, tap.Failed())
+	assert.True(t, tap.ShouldContinue())
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.NotEmpty(t, lines)
+
+	// A single TAP stream with the plan at the end.
+	assert.Equal(t, "TAP version 13", lines[0])
+	assert.Equal(t, 1, strings.Count(buf.String(), "TAP version 13"))
+	assert.Equal(t, "1..3", lines[len(lines)-1])
+
+	assert.Contains(t, lines, "# Running: two.yaml")
+	assert.Contains(t, lines, "ok 1 - one.yaml#compile")
+	assert.Contains(t, lines, "not ok 2 - two.yaml#0:check")
+	assert.Contains(t, lines, "ok 3 - two.yaml#cleanup # skip")
+}
+
+func TestTapWriterBailOut(t *testing.T) {
+	buf := bytes.Buffer{}
+	tap := &TapWriter{Out: &buf, BailOut: true}
+
+	docCloser := tap.NewDocument("one.yaml")
+	stepCloser := tap.NewStep("one.yaml#compile", "compiling")
+	tap.Update(result.Fatalf("compilation failed\nat line 2"))
+	stepCloser.Close()
+
+	assert.False(t, tap.ShouldContinue())
+
+	// Nothing is written after bailing out.
+	stepCloser = tap.NewStep("one.yaml#cleanup", "cleaning up")
+	tap.Update(result.Infof("cleaning"))
+	stepCloser.Close()
+	docCloser.Close()
+
+	tap.Close()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Equal(t, "Bail out! compilation failed", lines[len(lines)-1])
+	assert.NotContains(t, buf.String(), "cleaning")
+	assert.NotContains(t, buf.String(), "1..")
+}
@@ -0,0 +1,140 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/projectcontour/integration-tester/pkg/must"
+)
+
+// Phase is a category of test execution time.
+type Phase string
+
+const (
+	// PhaseEvaluating is time spent compiling and evaluating
+	// the test document.
+	PhaseEvaluating Phase = "evaluating"
+
+	// PhaseApplying is time spent applying and deleting
+	// Kubernetes objects.
+	PhaseApplying Phase = "applying"
+
+	// PhaseWaiting is time spent in checks, which are retried
+	// until they succeed or time out.
+	PhaseWaiting Phase = "waiting"
+)
+
+// PhaseForStep returns the phase that the time spent in the step
+// with the given ID (see StepID) is attributed to.
+func PhaseForStep(id string) Phase {
+	action := id[strings.LastIndexAny(id, "#:")+1:]
+
+	switch action {
+	case "update", "cleanup":
+		return PhaseApplying
+	case "check":
+		return PhaseWaiting
+	default:
+		return PhaseEvaluating
+	}
+}
+
+// StepTiming records how long a test step took. The duration is
+// in nanoseconds when serialized, for consistency with the
+// builtin.duration Rego helpers.
+type StepTiming struct {
+	ID       string        `json:"id"`
+	Step     string        `json:"step"`
+	Phase    Phase         `json:"phase"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Timings is a list of step timings.
+type Timings []StepTiming
+
+// Slowest returns the n slowest steps, slowest first.
+func (t Timings) Slowest(n int) Timings {
+	sorted := make(Timings, len(t))
+	copy(sorted, t)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Duration > sorted[j].Duration
+	})
+
+	if n < len(sorted) {
+		sorted = sorted[:n]
+	}
+
+	return sorted
+}
+
+// Phases returns the total time spent in each phase.
+func (t Timings) Phases() map[Phase]time.Duration {
+	phases := map[Phase]time.Duration{}
+
+	for _, s := range t {
+		phases[s.Phase] += s.Duration
+	}
+
+	return phases
+}
+
+// WriteReport writes a report of the time spent in each phase and
+// the n slowest steps to out.
+func (t Timings) WriteReport(out io.Writer, n int) {
+	tab := tabwriter.NewWriter(out, 0, 4, 4, ' ', 0)
+	phases := t.Phases()
+
+	fmt.Fprintf(tab, "\nTime by phase:\n")
+
+	for _, p := range []Phase{PhaseEvaluating, PhaseApplying, PhaseWaiting} {
+		fmt.Fprintf(tab, "    %s\t%s\n", p, phases[p].Round(time.Millisecond))
+	}
+
+	fmt.Fprintf(tab, "\nSlowest steps:\n")
+
+	for _, s := range t.Slowest(n) {
+		fmt.Fprintf(tab, "    %s\t%s\t%s\t%s\n",
+			s.Duration.Round(time.Millisecond), s.Phase, s.ID, s.Step)
+	}
+
+	must.Must(tab.Flush())
+}
+
+// stepTimer records the timing of a step in progress.
+type stepTimer struct {
+	id    string
+	desc  string
+	start time.Time
+}
+
+func startStep(start time.Time, id string, desc string) stepTimer {
+	return stepTimer{id: id, desc: desc, start: start}
+}
+
+func (s stepTimer) stop(end time.Time) StepTiming {
+	return StepTiming{
+		ID:       s.id,
+		Step:     s.desc,
+		Phase:    PhaseForStep(s.id),
+		Duration: end.Sub(s.start),
+	}
+}
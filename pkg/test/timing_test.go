@@ -0,0 +1,59 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPhaseForStep(t *testing.T) {
+	assert.Equal(t, PhaseEvaluating, PhaseForStep(StepID("one.yaml", "", "compile")))
+	assert.Equal(t, PhaseEvaluating, PhaseForStep(StepID("one.yaml", "0", "hydrate")))
+	assert.Equal(t, PhaseApplying, PhaseForStep(StepID("one.yaml", "pod/echo", "update")))
+	assert.Equal(t, PhaseApplying, PhaseForStep(StepID("one.yaml", "", "cleanup")))
+	assert.Equal(t, PhaseWaiting, PhaseForStep(StepID("one.yaml", "pod/echo", "check")))
+	assert.Equal(t, PhaseWaiting, PhaseForStep(StepID("suite", "checks/all.rego", "check")))
+}
+
+func TestTimingsReport(t *testing.T) {
+	timings := Timings{
+		{ID: "one.yaml#compile", Phase: PhaseEvaluating, Duration: time.Millisecond},
+		{ID: "one.yaml#0:check", Phase: PhaseWaiting, Duration: 3 * time.Second},
+		{ID: "one.yaml#pod/echo:update", Phase: PhaseApplying, Duration: 2 * time.Second},
+		{ID: "one.yaml#1:check", Phase: PhaseWaiting, Duration: time.Second},
+	}
+
+	slowest := timings.Slowest(2)
+	assert.Equal(t, "one.yaml#0:check", slowest[0].ID)
+	assert.Equal(t, "one.yaml#pod/echo:update", slowest[1].ID)
+	assert.Len(t, timings.Slowest(10), 4)
+
+	assert.Equal(t, map[Phase]time.Duration{
+		PhaseEvaluating: time.Millisecond,
+		PhaseApplying:   2 * time.Second,
+		PhaseWaiting:    4 * time.Second,
+	}, timings.Phases())
+
+	buf := bytes.Buffer{}
+	timings.WriteReport(&buf, 1)
+
+	assert.Contains(t, buf.String(), "waiting       4s")
+	assert.Contains(t, buf.String(), "3s    waiting    one.yaml#0:check")
+	assert.NotContains(t, buf.String(), "one.yaml#pod/echo:update")
+}
@@ -16,8 +16,9 @@ package test
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"strings"
-	"time"
 
 	"github.com/projectcontour/integration-tester/pkg/must"
 	"github.com/projectcontour/integration-tester/pkg/result"
@@ -38,6 +39,31 @@ const (
 	emptyLeader  leader = ""
 )
 
+type color string
+
+const (
+	// ANSI terminal color escape sequences.
+	colorNone   color = ""
+	colorRed    color = "\x1b[31m"
+	colorGreen  color = "\x1b[32m"
+	colorYellow color = "\x1b[33m"
+	colorDim    color = "\x1b[2m"
+	colorReset  color = "\x1b[0m"
+)
+
+// colorForSeverity returns the color to display results of the
+// given severity.
+func colorForSeverity(s result.Severity) color {
+	switch s {
+	case result.SeverityError, result.SeverityFatal:
+		return colorRed
+	case result.SeveritySkip:
+		return colorYellow
+	default:
+		return colorNone
+	}
+}
+
 func formatIndent(n int) string {
 	b := strings.Builder{}
 	b.Grow(n * len(boxVertical))
@@ -78,18 +104,63 @@ func formatFailCounters(fails map[result.Severity]int) string {
 // TreeWriter is a Recorder that write test results to a standard
 // output in a tree notation.
 type TreeWriter struct {
+	clock
+
+	// Out is where the results are written. If Out is nil, the
+	// results are written to standard output.
+	Out io.Writer
+
+	// Color enables ANSI terminal colors. Errors are shown in
+	// red, skips in yellow and passes in green. Timestamps are
+	// dimmed.
+	Color bool
+
+	// Verbosity controls the amount of detail in the output.
+	// At the default verbosity of 0, informational results are
+	// suppressed, and only steps and their failures are shown.
+	// A positive verbosity shows all results, and a negative
+	// verbosity only shows the steps that did not pass.
+	Verbosity int
+
 	indent    int
 	docCount  int
 	stepCount int
 
+	// retries is the number of failed attempts of the current
+	// document, and nextRetries of the next document.
+	retries     int
+	nextRetries int
+
+	// pendingStep prints the header for the current step. In
+	// quiet mode, it is deferred until the step shows a result.
+	pendingStep func()
+
 	stepErrors map[result.Severity]int
 	allErrors  map[result.Severity]int
+
+	// docSkipped is set when a skip result skips the rest of
+	// the current document.
+	docSkipped bool
 }
 
 var _ Recorder = &TreeWriter{}
 
-func tabPrintf(indent int, leader leader, format string, args ...interface{}) {
-	timestamp := time.Now().Format("15:04:05.0000")
+func (t *TreeWriter) out() io.Writer {
+	return outputOrStdout(t.Out)
+}
+
+// paint wraps the string in the given color, if colors are enabled.
+func (t *TreeWriter) paint(c color, s string) string {
+	if !t.Color || c == colorNone {
+		return s
+	}
+
+	return string(c) + s + string(colorReset)
+}
+
+func (t *TreeWriter) tabPrintf(c color, leader leader, format string, args ...interface{}) {
+	indent := t.indent
+	timestamp := t.paint(colorDim, t.now().Format("15:04:05.0000"))
 	msg := fmt.Sprintf(format, args...)
 	lines := strings.Split(msg, "\n")
 
@@ -100,11 +171,11 @@ func tabPrintf(indent int, leader leader, format string, args ...interface{}) {
 		// but will horrendously munge elbowLeader ones (the
 		// logic needs to be reversed).
 		if n == 0 {
-			fmt.Printf("%s\t%s%s%s\n",
-				timestamp, formatIndent(indent), leader, line)
+			fmt.Fprintf(t.out(), "%s\t%s%s%s\n",
+				timestamp, formatIndent(indent), leader, t.paint(c, line))
 		} else {
-			fmt.Printf("%s\t%s %s\n",
-				timestamp, formatIndent(indent+1), line)
+			fmt.Fprintf(t.out(), "%s\t%s %s\n",
+				timestamp, formatIndent(indent+1), t.paint(c, line))
 		}
 	}
 }
@@ -122,47 +193,76 @@ func (t *TreeWriter) Failed() bool {
 // NewDocument ...
 func (t *TreeWriter) NewDocument(desc string) Closer {
 	if t.docCount > 0 {
-		fmt.Printf("\n")
+		fmt.Fprintf(t.out(), "\n")
 	}
 
-	tabPrintf(t.indent, emptyLeader, "Running: %s", desc)
+	t.retries = t.nextRetries
+	t.nextRetries = 0
+
+	if t.retries > 0 {
+		t.tabPrintf(colorNone, emptyLeader, "Retrying: %s (attempt %d)", desc, t.retries+1)
+	} else {
+		t.tabPrintf(colorNone, emptyLeader, "Running: %s", desc)
+	}
 
 	t.docCount++
 	t.stepCount = 0
 	t.allErrors = map[result.Severity]int{}
+	t.docSkipped = false
 
 	return CloserFunc(func() {
 		switch {
-		case t.allErrors[result.SeveritySkip] > 0:
-			tabPrintf(t.indent, elbowLeader, "Skipped after %d steps", t.stepCount)
+		case t.docSkipped:
+			t.tabPrintf(colorYellow, elbowLeader, "Skipped after %d steps", t.stepCount)
 		case (t.allErrors[result.SeverityFatal] + t.allErrors[result.SeverityError]) > 0:
-			tabPrintf(t.indent, elbowLeader,
+			t.tabPrintf(colorRed, elbowLeader,
 				"Failed with %s ", formatFailCounters(t.allErrors))
+		case t.retries > 0:
+			t.tabPrintf(colorYellow, elbowLeader,
+				"Flaky pass with %d steps OK after %d retries", t.stepCount, t.retries)
 		default:
-			tabPrintf(t.indent, elbowLeader, "Pass with %d steps OK", t.stepCount)
+			t.tabPrintf(colorGreen, elbowLeader, "Pass with %d steps OK", t.stepCount)
 		}
 	})
 }
 
+// RetryDocument ...
+func (t *TreeWriter) RetryDocument() {
+	t.nextRetries = t.retries + 1
+}
+
 // NewStep ...
-func (t *TreeWriter) NewStep(desc string) Closer {
-	tabPrintf(t.indent, branchLeader, "Step %d: %s", t.stepCount, desc)
+func (t *TreeWriter) NewStep(id string, desc string) Closer {
+	stepNum := t.stepCount
+	t.pendingStep = func() {
+		t.indent--
+		t.tabPrintf(colorNone, branchLeader, "Step %d [%s]: %s", stepNum, id, desc)
+		t.indent++
+	}
 
 	t.indent++
 	t.stepCount++
 	t.stepErrors = map[result.Severity]int{}
 
+	if t.Verbosity >= 0 {
+		t.flushStep()
+	}
+
 	return CloserFunc(func() {
 		switch {
 		case t.stepErrors[result.SeveritySkip] > 0:
-			tabPrintf(t.indent, elbowLeader, "Skipped")
+			t.flushStep()
+			t.tabPrintf(colorYellow, elbowLeader, "Skipped")
 		case (t.stepErrors[result.SeverityFatal] + t.stepErrors[result.SeverityError]) > 0:
-			tabPrintf(t.indent, elbowLeader,
+			t.flushStep()
+			t.tabPrintf(colorRed, elbowLeader,
 				"Failed with %s ", formatFailCounters(t.stepErrors))
-		default:
-			tabPrintf(t.indent, elbowLeader, "Pass")
+		case t.pendingStep == nil:
+			t.tabPrintf(colorGreen, elbowLeader, "Pass")
 		}
 
+		t.pendingStep = nil
+
 		t.indent--
 		for k, v := range t.stepErrors {
 			t.allErrors[k] = t.allErrors[k] + v
@@ -175,10 +275,34 @@ func (t *TreeWriter) Update(results ...result.Result) {
 	for _, r := range results {
 		switch r.Severity {
 		case result.SeverityNone:
-			tabPrintf(t.indent, branchLeader, "%s", r.Message)
+			if t.Verbosity > 0 {
+				t.tabPrintf(colorNone, branchLeader, "%s", r.Text())
+			}
 		default:
+			t.flushStep()
 			t.stepErrors[r.Severity]++
-			tabPrintf(t.indent, branchLeader, "%s: %s", strings.ToUpper(string(r.Severity)), r.Message)
+			if r.Severity == result.SeveritySkip && r.IsTerminal() {
+				t.docSkipped = true
+			}
+			t.tabPrintf(colorForSeverity(r.Severity), branchLeader,
+				"%s: %s", strings.ToUpper(string(r.Severity)), r.Text())
 		}
 	}
 }
+
+// flushStep prints the pending step header, if there is one.
+func (t *TreeWriter) flushStep() {
+	if t.pendingStep != nil {
+		t.pendingStep()
+		t.pendingStep = nil
+	}
+}
+
+// outputOrStdout returns w, or standard output if w is nil.
+func outputOrStdout(w io.Writer) io.Writer {
+	if w == nil {
+		return os.Stdout
+	}
+
+	return w
+}
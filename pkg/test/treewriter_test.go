@@ -0,0 +1,108 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/projectcontour/integration-tester/pkg/result"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTree(tree *TreeWriter) {
+	docCloser := tree.NewDocument("one.yaml")
+
+	stepCloser := tree.NewStep("one.yaml#compile", "compiling")
+	tree.Update(result.Infof("compiled"))
+	stepCloser.Close()
+
+	stepCloser = tree.NewStep("one.yaml#0:check", "checking")
+	tree.Update(result.Errorf("failed"))
+	stepCloser.Close()
+
+	docCloser.Close()
+}
+
+func TestTreeWriterVerbosity(t *testing.T) {
+	buf := bytes.Buffer{}
+	writeTree(&TreeWriter{Out: &buf})
+
+	assert.Contains(t, buf.String(), "Running: one.yaml")
+	assert.Contains(t, buf.String(), "Step 0 [one.yaml#compile]: compiling")
+	assert.NotContains(t, buf.String(), "compiled")
+	assert.Contains(t, buf.String(), "ERROR: failed")
+	assert.Contains(t, buf.String(), "Failed with 1 error")
+
+	buf.Reset()
+	writeTree(&TreeWriter{Out: &buf, Verbosity: 1})
+
+	assert.Contains(t, buf.String(), "compiled")
+
+	buf.Reset()
+	writeTree(&TreeWriter{Out: &buf, Verbosity: -1})
+
+	assert.NotContains(t, buf.String(), "one.yaml#compile")
+	assert.Contains(t, buf.String(), "Step 1 [one.yaml#0:check]: checking")
+	assert.Contains(t, buf.String(), "ERROR: failed")
+}
+
+func TestTreeWriterColor(t *testing.T) {
+	buf := bytes.Buffer{}
+	writeTree(&TreeWriter{Out: &buf})
+
+	assert.NotContains(t, buf.String(), "\x1b[")
+
+	buf.Reset()
+	writeTree(&TreeWriter{Out: &buf, Color: true})
+
+	assert.Contains(t, buf.String(), string(colorRed)+"ERROR: failed"+string(colorReset))
+	assert.Contains(t, buf.String(), string(colorGreen)+"Pass"+string(colorReset))
+	assert.Contains(t, buf.String(), string(colorDim))
+}
+
+func TestTreeWriterRetry(t *testing.T) {
+	buf := bytes.Buffer{}
+	tree := &TreeWriter{Out: &buf}
+
+	writeTree(tree)
+	tree.RetryDocument()
+
+	docCloser := tree.NewDocument("one.yaml")
+	tree.NewStep("one.yaml#compile", "compiling").Close()
+	docCloser.Close()
+
+	assert.Contains(t, buf.String(), "Retrying: one.yaml (attempt 2)")
+	assert.Contains(t, buf.String(), "Flaky pass with 1 steps OK after 1 retries")
+}
+
+func TestDefaultRecorderRetry(t *testing.T) {
+	r := NewRecorder()
+
+	docCloser := r.NewDocument("one.yaml")
+	stepCloser := r.NewStep("one.yaml#0:check", "checking")
+	r.Update(result.Fatalf("failed"))
+	stepCloser.Close()
+	docCloser.Close()
+
+	assert.True(t, r.Failed())
+	assert.False(t, r.ShouldContinue())
+
+	r.RetryDocument()
+
+	assert.False(t, r.Failed())
+	assert.True(t, r.ShouldContinue())
+}
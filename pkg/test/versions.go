@@ -0,0 +1,49 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"github.com/projectcontour/integration-tester/pkg/driver"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// applyVersions applies the object at each of the given API versions
+// in turn, stopping at the first operation that fails. This writes
+// the same logical object through each version of a custom resource,
+// so that checks can assert that conversion round-trips.
+func applyVersions(k *driver.KubeClient,
+	o driver.ObjectDriver,
+	u *unstructured.Unstructured,
+	versions []string) (*driver.OperationResult, error) {
+	var opResult *driver.OperationResult
+
+	for _, v := range versions {
+		kind := u.GroupVersionKind()
+		kind.Version = v
+
+		versioned := u.DeepCopy()
+		versioned.SetGroupVersionKind(kind)
+
+		var err error
+
+		opResult, err = applyObject(k, o, versioned)
+		if err != nil || !opResult.Succeeded() {
+			return opResult, err
+		}
+	}
+
+	return opResult, nil
+}
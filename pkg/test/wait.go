@@ -0,0 +1,78 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/projectcontour/integration-tester/pkg/driver"
+	"github.com/projectcontour/integration-tester/pkg/result"
+	"github.com/projectcontour/integration-tester/pkg/utils"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// waitForObject polls the API server until the object reaches the
+// state that w is waiting for, the wait times out, or the test run
+// is interrupted. A timeout is fatal, since the rest of the test
+// document depends on the state being reached.
+func waitForObject(tc *testContext, u *unstructured.Unstructured, w *driver.Wait) {
+	timeout := w.Timeout
+	if timeout == 0 {
+		timeout = tc.checkTimeout
+	}
+
+	desc := fmt.Sprintf("%s '%s/%s'", u.GetKind(), utils.NamespaceOrDefault(u), u.GetName())
+	tc.recorder.Update(result.Infof("waiting up to %s for %s of %s", timeout, w, desc))
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	reason := ""
+
+	for {
+		current, err := tc.kubeDriver.GetObject(u.GroupVersionKind(), u.GetNamespace(), u.GetName())
+		switch {
+		case apierrors.IsNotFound(err):
+			current = nil
+		case err != nil:
+			tc.recorder.Update(result.Fatalf("failed to get %s: %s", desc, err))
+			return
+		}
+
+		var ok bool
+		if ok, reason = w.Satisfied(current); ok {
+			tc.recorder.Update(result.Infof("%s reached %s", desc, w))
+			return
+		}
+
+		poll := time.NewTimer(tc.checkInterval)
+
+		select {
+		case <-poll.C:
+		case <-deadline.C:
+			poll.Stop()
+			tc.recorder.Update(result.Fatalf(
+				"timed out after %s waiting for %s of %s: %s", timeout, w, desc, reason))
+			return
+		case <-tc.interrupt:
+			poll.Stop()
+			tc.recorder.Update(result.Fatalf("interrupted waiting for %s of %s", w, desc))
+			return
+		}
+	}
+}
@@ -14,7 +14,11 @@
 
 package test
 
-import "github.com/projectcontour/integration-tester/pkg/result"
+import (
+	"time"
+
+	"github.com/projectcontour/integration-tester/pkg/result"
+)
 
 // StackRecorders returns a new Recorder that stacks top and next.
 // For each method in the Recorder interface, methods from top will
@@ -57,10 +61,10 @@ func (w wrapRecorder) NewDocument(desc string) Closer {
 	return wrappedCloser(closers)
 }
 
-func (w wrapRecorder) NewStep(desc string) Closer {
+func (w wrapRecorder) NewStep(id string, desc string) Closer {
 	closers := []Closer{
-		w.top.NewStep(desc),
-		w.next.NewStep(desc),
+		w.top.NewStep(id, desc),
+		w.next.NewStep(id, desc),
 	}
 
 	return wrappedCloser(closers)
@@ -70,3 +74,18 @@ func (w wrapRecorder) Update(results ...result.Result) {
 	w.top.Update(results...)
 	w.next.Update(results...)
 }
+
+func (w wrapRecorder) RetryDocument() {
+	w.top.RetryDocument()
+	w.next.RetryDocument()
+}
+
+func (w wrapRecorder) setClock(when time.Time) {
+	setClock(w.top, when)
+	setClock(w.next, when)
+}
+
+func (w wrapRecorder) captureDocument(runID string, resources interface{}) {
+	captureDocument(w.top, runID, resources)
+	captureDocument(w.next, runID, resources)
+}
@@ -0,0 +1,75 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package upload
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// azureStorageVersion is the Azure Storage REST API version that
+// requests use. Since 2019-12-12, a single Put Blob request can
+// upload up to 5000 MiB.
+const azureStorageVersion = "2019-12-12"
+
+// AzureStore is a Store that uploads objects as block blobs to an
+// Azure Storage container.
+type AzureStore struct {
+	Account   string
+	Container string
+	Prefix    string
+
+	// SASToken is a shared access signature that grants write
+	// access to the container.
+	SASToken string
+
+	// Endpoint is the URL of the Blob service. If it is empty,
+	// it is derived from the account name.
+	Endpoint string
+
+	Client *http.Client
+}
+
+var _ Store = &AzureStore{}
+
+// Put ...
+func (a *AzureStore) Put(ctx context.Context, key string, data []byte, contentType string) (string, error) {
+	endpoint := fmt.Sprintf("https://%s.blob.core.windows.net", a.Account)
+	if a.Endpoint != "" {
+		endpoint = strings.TrimSuffix(a.Endpoint, "/")
+	}
+
+	blobURL := fmt.Sprintf("%s/%s/%s", endpoint, a.Container, escapePath(objectKey(a.Prefix, key)))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut,
+		blobURL+"?"+strings.TrimPrefix(a.SASToken, "?"), bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-Ms-Blob-Type", "BlockBlob")
+	req.Header.Set("X-Ms-Version", azureStorageVersion)
+
+	if err := do(a.Client, req); err != nil {
+		return "", err
+	}
+
+	// The URL doesn't include the SAS token, so it's safe to print.
+	return blobURL, nil
+}
@@ -0,0 +1,74 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package upload
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// gcsEndpoint is the Google Cloud Storage API server.
+const gcsEndpoint = "https://storage.googleapis.com"
+
+// GCSStore is a Store that uploads objects to a Google Cloud
+// Storage bucket.
+type GCSStore struct {
+	Bucket string
+	Prefix string
+
+	// Endpoint is the URL of a GCS-compatible server (e.g. an
+	// emulator). If it is empty, objects are uploaded to GCS.
+	Endpoint string
+
+	// AccessToken is an OAuth2 access token (e.g. from
+	// "gcloud auth print-access-token").
+	AccessToken string
+
+	Client *http.Client
+}
+
+var _ Store = &GCSStore{}
+
+// Put ...
+func (g *GCSStore) Put(ctx context.Context, key string, data []byte, contentType string) (string, error) {
+	endpoint := gcsEndpoint
+	if g.Endpoint != "" {
+		endpoint = strings.TrimSuffix(g.Endpoint, "/")
+	}
+
+	name := objectKey(g.Prefix, key)
+
+	uploadURL := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?%s",
+		endpoint, url.PathEscape(g.Bucket),
+		url.Values{"uploadType": {"media"}, "name": {name}}.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, uploadURL, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+g.AccessToken)
+
+	if err := do(g.Client, req); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s/%s/%s", endpoint, g.Bucket, escapePath(name)), nil
+}
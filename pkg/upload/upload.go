@@ -0,0 +1,272 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package upload copies test artifacts to cloud object stores.
+package upload
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Store is an object store that artifacts can be uploaded to.
+type Store interface {
+	// Put stores data as the object with the given key, and
+	// returns the URL of the stored object.
+	Put(ctx context.Context, key string, data []byte, contentType string) (string, error)
+}
+
+// NewStore returns the Store for the destination URL dest, which
+// is one of "s3://bucket/prefix", "gs://bucket/prefix" or
+// "azblob://container/prefix". The prefix is optional, and is
+// prepended to the key of every object that is stored. The store
+// credentials are taken from the environment variables that
+// getenv returns (usually os.Getenv). S3 uploads use
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and the optional
+// AWS_SESSION_TOKEN, in the region given by AWS_REGION or
+// AWS_DEFAULT_REGION. AWS_ENDPOINT_URL selects an S3-compatible
+// server. GCS uploads use the GOOGLE_OAUTH_ACCESS_TOKEN access token,
+// and STORAGE_EMULATOR_HOST selects a GCS-compatible server. Azure
+// uploads use the AZURE_STORAGE_ACCOUNT storage account and the
+// AZURE_STORAGE_SAS_TOKEN shared access signature.
+func NewStore(dest string, getenv func(string) string) (Store, error) {
+	u, err := url.Parse(dest)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upload destination %q: %w", dest, err)
+	}
+
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid upload destination %q: missing bucket name", dest)
+	}
+
+	prefix := strings.Trim(u.Path, "/")
+
+	// require returns the values of the given environment
+	// variables, or an error naming the first one that is unset.
+	require := func(names ...string) ([]string, error) {
+		values := make([]string, 0, len(names))
+		for _, n := range names {
+			v := getenv(n)
+			if v == "" {
+				return nil, fmt.Errorf("uploading to %s requires the %s environment variable", u.Scheme, n)
+			}
+
+			values = append(values, v)
+		}
+
+		return values, nil
+	}
+
+	switch u.Scheme {
+	case "s3":
+		creds, err := require("AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY")
+		if err != nil {
+			return nil, err
+		}
+
+		region := getenv("AWS_REGION")
+		if region == "" {
+			region = getenv("AWS_DEFAULT_REGION")
+		}
+
+		if region == "" {
+			region = "us-east-1"
+		}
+
+		return &S3Store{
+			Bucket:          u.Host,
+			Prefix:          prefix,
+			Region:          region,
+			Endpoint:        getenv("AWS_ENDPOINT_URL"),
+			AccessKeyID:     creds[0],
+			SecretAccessKey: creds[1],
+			SessionToken:    getenv("AWS_SESSION_TOKEN"),
+		}, nil
+
+	case "gs":
+		creds, err := require("GOOGLE_OAUTH_ACCESS_TOKEN")
+		if err != nil {
+			return nil, err
+		}
+
+		endpoint := getenv("STORAGE_EMULATOR_HOST")
+		if endpoint != "" && !strings.Contains(endpoint, "://") {
+			endpoint = "http://" + endpoint
+		}
+
+		return &GCSStore{
+			Bucket:      u.Host,
+			Prefix:      prefix,
+			Endpoint:    endpoint,
+			AccessToken: creds[0],
+		}, nil
+
+	case "azblob":
+		creds, err := require("AZURE_STORAGE_ACCOUNT", "AZURE_STORAGE_SAS_TOKEN")
+		if err != nil {
+			return nil, err
+		}
+
+		return &AzureStore{
+			Account:   creds[0],
+			Container: u.Host,
+			Prefix:    prefix,
+			SASToken:  creds[1],
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported upload destination %q (must be s3://, gs:// or azblob://)", dest)
+	}
+}
+
+// Directory archives the files below dir into a gzipped tar file,
+// and stores it in s as the object "name.tar.gz". It returns the URL
+// of the stored archive.
+func Directory(ctx context.Context, s Store, dir string, name string) (string, error) {
+	data, err := Archive(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to archive %s: %w", dir, err)
+	}
+
+	return s.Put(ctx, name+".tar.gz", data, "application/gzip")
+}
+
+// Archive returns a gzipped tar archive of the regular files below
+// dir. The archive paths are relative to dir, and always use '/' as
+// the separator.
+func Archive(dir string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	zw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(zw)
+
+	err := filepath.Walk(dir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, filePath)
+		if err != nil {
+			return err
+		}
+
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+
+		hdr.Name = filepath.ToSlash(rel)
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		f, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+
+		defer f.Close()
+
+		_, err = io.Copy(tw, f)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// objectKey joins the store prefix to key.
+func objectKey(prefix string, key string) string {
+	if prefix == "" {
+		return key
+	}
+
+	return path.Join(prefix, key)
+}
+
+// escapePath escapes each element of an object key for use in a
+// URL path.
+func escapePath(key string) string {
+	parts := strings.Split(key, "/")
+	for i, p := range parts {
+		parts[i] = url.PathEscape(p)
+	}
+
+	return strings.Join(parts, "/")
+}
+
+// do sends req, and returns an error if the response doesn't have
+// a 2xx status.
+func do(client *http.Client, req *http.Request) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	// Don't leak any credentials in the query string.
+	u := *req.URL
+	u.RawQuery = ""
+
+	resp, err := client.Do(req)
+	if err != nil {
+		if uerr, ok := err.(*url.Error); ok {
+			uerr.URL = u.String()
+		}
+
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg := strings.TrimSpace(string(body))
+		if msg == "" {
+			return fmt.Errorf("%s %s: %s", req.Method, u.String(), resp.Status)
+		}
+
+		return fmt.Errorf("%s %s: %s: %s", req.Method, u.String(), resp.Status, msg)
+	}
+
+	return nil
+}
@@ -0,0 +1,213 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package upload
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewStore(t *testing.T) {
+	env := map[string]string{
+		"AWS_ACCESS_KEY_ID":         "AKID",
+		"AWS_SECRET_ACCESS_KEY":     "secret",
+		"AWS_DEFAULT_REGION":        "eu-west-1",
+		"GOOGLE_OAUTH_ACCESS_TOKEN": "token",
+		"STORAGE_EMULATOR_HOST":     "localhost:9023",
+		"AZURE_STORAGE_ACCOUNT":     "ci",
+		"AZURE_STORAGE_SAS_TOKEN":   "sv=2019-12-12&sig=abc",
+	}
+
+	getenv := func(name string) string { return env[name] }
+
+	s, err := NewStore("s3://artifacts/ci/builds/", getenv)
+	require.NoError(t, err)
+	assert.Equal(t, &S3Store{
+		Bucket:          "artifacts",
+		Prefix:          "ci/builds",
+		Region:          "eu-west-1",
+		AccessKeyID:     "AKID",
+		SecretAccessKey: "secret",
+	}, s)
+
+	s, err = NewStore("gs://artifacts", getenv)
+	require.NoError(t, err)
+	assert.Equal(t, &GCSStore{
+		Bucket:      "artifacts",
+		Endpoint:    "http://localhost:9023",
+		AccessToken: "token",
+	}, s)
+
+	s, err = NewStore("azblob://artifacts/ci", getenv)
+	require.NoError(t, err)
+	assert.Equal(t, &AzureStore{
+		Account:   "ci",
+		Container: "artifacts",
+		Prefix:    "ci",
+		SASToken:  "sv=2019-12-12&sig=abc",
+	}, s)
+
+	_, err = NewStore("s3://artifacts", func(string) string { return "" })
+	assert.EqualError(t, err, "uploading to s3 requires the AWS_ACCESS_KEY_ID environment variable")
+
+	_, err = NewStore("ftp://artifacts", getenv)
+	assert.Error(t, err)
+
+	_, err = NewStore("artifacts", getenv)
+	assert.Error(t, err)
+}
+
+func TestArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "upload")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "echo.yaml", "logs"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "echo.yaml", "objects.yaml"), []byte("kind: Pod\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "echo.yaml", "logs", "echo.log"), []byte("started\n"), 0644))
+
+	data, err := Archive(dir)
+	require.NoError(t, err)
+
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+
+	files := map[string]string{}
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+
+		require.NoError(t, err)
+
+		content, err := ioutil.ReadAll(tr)
+		require.NoError(t, err)
+
+		files[hdr.Name] = string(content)
+	}
+
+	assert.Equal(t, map[string]string{
+		"echo.yaml/objects.yaml":  "kind: Pod\n",
+		"echo.yaml/logs/echo.log": "started\n",
+	}, files)
+}
+
+// TestSignV4 checks the signature of the "get-vanilla" request from
+// the AWS Signature Version 4 test suite.
+func TestSignV4(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	require.NoError(t, err)
+
+	emptyHash := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	signV4(req, emptyHash, "us-east-1", "service",
+		"AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	assert.Equal(t, "20150830T123600Z", req.Header.Get("X-Amz-Date"))
+	assert.Equal(t,
+		"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+			"SignedHeaders=host;x-amz-date, "+
+			"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		req.Header.Get("Authorization"))
+}
+
+func TestPut(t *testing.T) {
+	var got *http.Request
+	var body []byte
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = ioutil.ReadAll(r.Body)
+
+		if r.URL.Query().Get("sig") == "bad" {
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, "AuthenticationFailed") //nolint(errcheck)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	data := []byte("artifacts")
+
+	s3 := &S3Store{
+		Bucket:          "artifacts",
+		Prefix:          "ci",
+		Region:          "us-west-2",
+		Endpoint:        srv.URL,
+		AccessKeyID:     "AKID",
+		SecretAccessKey: "secret",
+		SessionToken:    "session",
+	}
+
+	u, err := s3.Put(ctx, "run 1.tar.gz", data, "application/gzip")
+	require.NoError(t, err)
+	assert.Equal(t, srv.URL+"/artifacts/ci/run%201.tar.gz", u)
+	assert.Equal(t, http.MethodPut, got.Method)
+	assert.Equal(t, "/artifacts/ci/run 1.tar.gz", got.URL.Path)
+	assert.Equal(t, "session", got.Header.Get("X-Amz-Security-Token"))
+	assert.True(t, strings.HasPrefix(got.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/"))
+	assert.Contains(t, got.Header.Get("Authorization"), "/us-west-2/s3/aws4_request")
+	assert.Equal(t, data, body)
+
+	gcs := &GCSStore{Bucket: "artifacts", Endpoint: srv.URL, AccessToken: "token"}
+
+	u, err = gcs.Put(ctx, "run-1.tar.gz", data, "application/gzip")
+	require.NoError(t, err)
+	assert.Equal(t, srv.URL+"/artifacts/run-1.tar.gz", u)
+	assert.Equal(t, http.MethodPost, got.Method)
+	assert.Equal(t, "/upload/storage/v1/b/artifacts/o", got.URL.Path)
+	assert.Equal(t, "run-1.tar.gz", got.URL.Query().Get("name"))
+	assert.Equal(t, "Bearer token", got.Header.Get("Authorization"))
+	assert.Equal(t, data, body)
+
+	azure := &AzureStore{
+		Account:   "ci",
+		Container: "artifacts",
+		Prefix:    "builds",
+		SASToken:  "?sv=2019-12-12&sig=abc",
+		Endpoint:  srv.URL,
+	}
+
+	u, err = azure.Put(ctx, "run-1.tar.gz", data, "application/gzip")
+	require.NoError(t, err)
+	assert.Equal(t, srv.URL+"/artifacts/builds/run-1.tar.gz", u)
+	assert.Equal(t, http.MethodPut, got.Method)
+	assert.Equal(t, "abc", got.URL.Query().Get("sig"))
+	assert.Equal(t, "BlockBlob", got.Header.Get("X-Ms-Blob-Type"))
+	assert.Equal(t, data, body)
+
+	// Errors include the response, but not the SAS token.
+	azure.SASToken = "sig=bad"
+	_, err = azure.Put(ctx, "run-1.tar.gz", data, "application/gzip")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "403 Forbidden: AuthenticationFailed")
+	assert.NotContains(t, err.Error(), "sig=bad")
+}
@@ -16,7 +16,6 @@ package utils
 
 import (
 	"os"
-	"path"
 	"path/filepath"
 	"strings"
 )
@@ -43,7 +42,7 @@ func WalkFiles(walkPath string, walkFn func(string) error) error {
 			}
 
 			// Skip (hidden) dotfiles.
-			if strings.HasPrefix(path.Base(filePath), ".") {
+			if strings.HasPrefix(filepath.Base(filePath), ".") {
 				return nil
 			}
 
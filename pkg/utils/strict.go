@@ -0,0 +1,178 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package utils
+
+import (
+	"sort"
+
+	"github.com/open-policy-agent/opa/ast"
+)
+
+// deprecatedBuiltins lists the Rego builtins that are deprecated in
+// favor of newer equivalents.
+var deprecatedBuiltins = map[string]string{
+	"all":              "use a comprehension and negation",
+	"any":              "use a comprehension or 'some'",
+	"cast_array":       "use the type checking builtins",
+	"cast_boolean":     "use the type checking builtins",
+	"cast_null":        "use the type checking builtins",
+	"cast_object":      "use the type checking builtins",
+	"cast_set":         "use the type checking builtins",
+	"cast_string":      "use the type checking builtins",
+	"net.cidr_overlap": "use 'net.cidr_contains'",
+	"re_match":         "use 'regex.match'",
+	"set_diff":         "use the '-' operator",
+}
+
+// StrictCheckModule applies additional checks to a Rego module
+// that the OPA compiler does not enforce. These are similar in
+// spirit to OPA strict mode. It reports:
+//
+// - local variables that are declared with ':=' or 'some' but never used,
+// - calls to deprecated builtins,
+// - local variables that shadow 'input', 'data', imports or rules.
+//
+// StrictCheckModule returns nil if there are no violations.
+func StrictCheckModule(m *ast.Module) ast.Errors {
+	var errs ast.Errors
+
+	// Names that a local variable must not shadow.
+	reserved := map[ast.Var]string{
+		ast.InputRootDocument.Value.(ast.Var):   "the input document",
+		ast.DefaultRootDocument.Value.(ast.Var): "the data document",
+	}
+
+	for _, i := range m.Imports {
+		if name := i.Name(); name != "" {
+			reserved[name] = "an import"
+		}
+	}
+
+	for _, r := range m.Rules {
+		reserved[r.Head.Name] = "a rule"
+	}
+
+	ast.WalkExprs(m, func(e *ast.Expr) bool {
+		if e.IsCall() {
+			errs = append(errs, checkDeprecatedCall(e.Operator(), e.Location)...)
+		}
+
+		return false
+	})
+
+	ast.WalkTerms(m, func(t *ast.Term) bool {
+		if call, ok := t.Value.(ast.Call); ok && len(call) > 0 {
+			errs = append(errs, checkDeprecatedCall(call[0].Value.(ast.Ref), t.Location)...)
+		}
+
+		return false
+	})
+
+	for _, r := range m.Rules {
+		for ; r != nil; r = r.Else {
+			errs = append(errs, checkRuleVars(r, reserved)...)
+		}
+	}
+
+	sort.SliceStable(errs, func(i, j int) bool {
+		a, b := errs[i].Location, errs[j].Location
+		if a == nil || b == nil {
+			return b != nil
+		}
+
+		if a.Row != b.Row {
+			return a.Row < b.Row
+		}
+
+		return a.Col < b.Col
+	})
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errs
+}
+
+func checkDeprecatedCall(op ast.Ref, loc *ast.Location) ast.Errors {
+	name := op.String()
+
+	if hint, ok := deprecatedBuiltins[name]; ok {
+		return ast.Errors{
+			ast.NewError(ast.CompileErr, loc, "deprecated builtin %q: %s", name, hint),
+		}
+	}
+
+	return nil
+}
+
+// checkRuleVars reports the local variables in the rule that are
+// declared but not used, or that shadow a reserved name.
+func checkRuleVars(r *ast.Rule, reserved map[ast.Var]string) ast.Errors {
+	var errs ast.Errors
+
+	declared := map[ast.Var]*ast.Location{}
+	used := map[ast.Var]int{}
+
+	declare := func(t *ast.Term) {
+		ast.WalkVars(t, func(v ast.Var) bool {
+			if v.IsWildcard() || v.IsGenerated() {
+				return false
+			}
+
+			if what, ok := reserved[v]; ok {
+				errs = append(errs, ast.NewError(ast.CompileErr, t.Location,
+					"variable %q shadows %s", v, what))
+			}
+
+			declared[v] = t.Location
+			return false
+		})
+	}
+
+	use := func(x interface{}) {
+		ast.WalkVars(x, func(v ast.Var) bool {
+			used[v]++
+			return false
+		})
+	}
+
+	use(r.Head)
+
+	for _, e := range r.Body {
+		switch terms := e.Terms.(type) {
+		case *ast.SomeDecl:
+			for _, t := range terms.Symbols {
+				declare(t)
+			}
+		default:
+			if e.IsAssignment() {
+				declare(e.Operand(0))
+				use(e.Operand(1))
+			} else {
+				use(e)
+			}
+		}
+	}
+
+	for v, loc := range declared {
+		if used[v] == 0 {
+			errs = append(errs, ast.NewError(ast.CompileErr, loc,
+				"variable %q is declared but not used", v))
+		}
+	}
+
+	return errs
+}
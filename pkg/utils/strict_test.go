@@ -0,0 +1,110 @@
+// Copyright  Project Contour Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.  You may obtain
+// a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func strictMessages(t *testing.T, input string) []string {
+	t.Helper()
+
+	m, err := ParseCheckFragment("strict.rego", input)
+	require.NoError(t, err)
+
+	var messages []string
+	for _, e := range StrictCheckModule(m) {
+		messages = append(messages, e.Message)
+	}
+
+	return messages
+}
+
+func TestStrictCheckClean(t *testing.T) {
+	assert.Nil(t, strictMessages(t, `
+import data.builtin.pod
+
+error[msg] {
+  some name
+  p := input.pods[name]
+  not pod.is_ready(p)
+  msg := sprintf("pod %s is not ready", [name])
+}
+`))
+}
+
+func TestStrictCheckUnused(t *testing.T) {
+	assert.Equal(t, []string{
+		`variable "unused" is declared but not used`,
+		`variable "idx" is declared but not used`,
+	}, strictMessages(t, `
+error[msg] {
+  unused := input.count
+  some idx
+  msg := "failed"
+}
+`))
+}
+
+func TestStrictCheckDeprecated(t *testing.T) {
+	assert.Equal(t, []string{
+		`deprecated builtin "re_match": use 'regex.match'`,
+		`deprecated builtin "any": use a comprehension or 'some'`,
+	}, strictMessages(t, `
+error[msg] {
+  re_match("^a", input.name)
+  x := any([true, false])
+  x
+  msg := "failed"
+}
+`))
+}
+
+func TestStrictCheckShadowing(t *testing.T) {
+	assert.Equal(t, []string{
+		`variable "pod" shadows an import`,
+		`variable "error" shadows a rule`,
+	}, strictMessages(t, `
+import data.builtin.pod
+
+error[msg] {
+  pod := input.pod
+  pod.ready
+  error := "failed"
+  msg := error
+}
+`))
+}
+
+func TestStrictCheckElse(t *testing.T) {
+	m := ast.MustParseModule(`
+package test
+
+p = x {
+  input.a
+  x := 1
+} else = 2 {
+  y := 3
+}
+`)
+
+	errs := StrictCheckModule(m)
+	require.Len(t, errs, 1)
+	assert.Equal(t, `variable "y" is declared but not used`, errs[0].Message)
+}